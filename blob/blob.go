@@ -0,0 +1,106 @@
+// Package blob stores file contents under the SHA-256 digest of their
+// bytes, so content uploaded any number of times is stored once and can be
+// checked against its key whenever it is read.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned for a key with no stored content.
+var ErrNotFound = errors.New("blob not found")
+
+// Info describes stored content. Key is the lowercase hex SHA-256 digest
+// of the content.
+type Info struct {
+	Key  string
+	Size int64
+}
+
+// Store keeps content by key. Put stores what it reads and returns its
+// key, storing nothing new when the content is already there. Open returns
+// the content for reading and seeking, so it can be served in ranges.
+// Deleting content still in use elsewhere is up to the caller to avoid.
+type Store interface {
+	Put(ctx context.Context, r io.Reader) (Info, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Lister is a Store that can list the keys it holds.
+type Lister interface {
+	List(ctx context.Context, fn func(key string) error) error
+}
+
+// Presigner is a Store that can hand out URLs that download content
+// directly from it, without credentials, until they expire.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, opts PresignOptions) (string, error)
+}
+
+// PresignOptions set how long a presigned URL works and the type and file
+// name its download is answered with.
+type PresignOptions struct {
+	Expires     time.Duration
+	ContentType string
+	Filename    string
+}
+
+// ValidKey reports whether key is a lowercase hex SHA-256 digest.
+func ValidKey(key string) bool {
+	if len(key) != 2*sha256.Size {
+		return false
+	}
+	for _, r := range key {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Key returns the key of the content of r.
+func Key(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Verify reads the content stored under key and checks that it still
+// hashes to key.
+func Verify(ctx context.Context, s Store, key string) error {
+	rc, err := s.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	sum, _, err := Key(rc)
+	if err != nil {
+		return err
+	}
+	if sum != key {
+		return &ChecksumError{Key: key, Actual: sum}
+	}
+	return nil
+}
+
+// ChecksumError reports content that does not hash to the key it was
+// expected to have.
+type ChecksumError struct {
+	Key    string
+	Actual string
+}
+
+func (e *ChecksumError) Error() string {
+	return "content of " + e.Key + " has SHA-256 " + e.Actual
+}
@@ -0,0 +1,129 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps content in files under Root, at <Root>/ab/cd/abcd... for the
+// key abcd.... Uploads are written to <Root>/tmp first and moved into
+// place once their key is known, so a partly written file never has a key.
+type Local struct {
+	Root string
+}
+
+// NewLocal returns a store under root, creating the directory if needed.
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{Root: root}, nil
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.Root, key[:2], key[2:4], key)
+}
+
+func (l *Local) Put(ctx context.Context, r io.Reader) (Info, error) {
+	tmp, err := os.CreateTemp(filepath.Join(l.Root, "tmp"), "upload-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Info{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return Info{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Key: hex.EncodeToString(h.Sum(nil)), Size: size}
+	dst := l.path(info.Key)
+	if _, err := os.Stat(dst); err == nil {
+		return info, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return Info{}, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return Info{}, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (Info, error) {
+	if !ValidKey(key) {
+		return Info{}, ErrNotFound
+	}
+	fi, err := os.Stat(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Key: key, Size: fi.Size()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrNotFound
+	}
+	err := os.Remove(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// List calls fn with every key in the store, in no particular order.
+func (l *Local) List(ctx context.Context, fn func(key string) error) error {
+	return filepath.WalkDir(l.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == filepath.Join(l.Root, "tmp") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !ValidKey(d.Name()) {
+			return nil
+		}
+		return fn(d.Name())
+	})
+}
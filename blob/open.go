@@ -0,0 +1,74 @@
+package blob
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromURL opens the store a URL names:
+//
+//	/var/lib/library/blobs or file:///var/lib/library/blobs
+//	s3://bucket/prefix/?endpoint=http://localhost:9000&region=us-east-1&path_style=true
+//
+// An s3 URL takes its credentials from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, and its region from
+// AWS_REGION when the URL has none. The endpoint defaults to AWS S3 in the
+// region; part_size sets the multipart part size in bytes.
+func FromURL(raw string) (Store, error) {
+	if !strings.Contains(raw, "://") {
+		return NewLocal(raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		return NewLocal(u.Path)
+	case "s3":
+		query := u.Query()
+		region := query.Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := query.Get("endpoint")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("%s: no bucket", raw)
+		}
+
+		s := NewS3(endpoint, region, u.Host, Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+		s.Prefix = strings.TrimPrefix(u.Path, "/")
+		if v := query.Get("path_style"); v != "" {
+			if s.PathStyle, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("path_style: %w", err)
+			}
+		}
+		if v := query.Get("part_size"); v != "" {
+			if s.PartSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return nil, fmt.Errorf("part_size: %w", err)
+			}
+			if s.PartSize < minPartSize {
+				return nil, fmt.Errorf("part_size: must be at least %d bytes", minPartSize)
+			}
+		}
+		if s.Credentials.AccessKeyID == "" || s.Credentials.SecretAccessKey == "" {
+			return nil, fmt.Errorf("%s: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set", raw)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("%s: unknown storage scheme %q", raw, u.Scheme)
+}
@@ -0,0 +1,455 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPartSize is the size of the parts content larger than it is
+	// uploaded in. S3 takes parts of 5 MiB to 5 GiB, and at most 10,000.
+	DefaultPartSize = 16 << 20
+	minPartSize     = 5 << 20
+	maxParts        = 10000
+)
+
+// S3 keeps content as objects <Prefix><key> in a bucket of an S3-compatible
+// server, such as AWS S3 or MinIO. Requests are signed with Signature
+// Version 4. Content is spooled to a temporary file while its key is
+// worked out, then uploaded in one request or, past PartSize, as a
+// multipart upload. Every request carries the SHA-256 of its payload, so
+// the server rejects anything corrupted on the way.
+//
+// With PathStyle, objects are addressed as <Endpoint>/<Bucket>/<object>,
+// which MinIO and most local stand-ins need; otherwise as
+// <Bucket>.<Endpoint host>/<object>.
+type S3 struct {
+	Endpoint    string
+	Region      string
+	Bucket      string
+	Prefix      string
+	PathStyle   bool
+	Credentials Credentials
+	PartSize    int64
+	TempDir     string
+	Client      *http.Client
+}
+
+// S3Error is an error response from the server.
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// NewS3 returns a store for the bucket at endpoint, like
+// https://s3.eu-west-1.amazonaws.com or http://localhost:9000.
+func NewS3(endpoint, region, bucket string, credentials Credentials) *S3 {
+	return &S3{
+		Endpoint:    strings.TrimRight(endpoint, "/"),
+		Region:      region,
+		Bucket:      bucket,
+		Credentials: credentials,
+		PartSize:    DefaultPartSize,
+		Client:      &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+func (s *S3) signer() signer {
+	return signer{credentials: s.Credentials, region: s.Region}
+}
+
+// objectURL is the URL of the object for key, or of the bucket if key is
+// empty.
+func (s *S3) objectURL(key string, query url.Values) (*url.URL, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	object := ""
+	if key != "" {
+		object = s.Prefix + key
+	}
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/" + object
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + object
+	}
+	u.RawQuery = canonicalQuery(query)
+	return u, nil
+}
+
+// do sends a signed request and returns the response if its status is
+// 2xx, or the server's error.
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, payloadHash string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.signer().sign(req, payloadHash, time.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	s3Err := &S3Error{StatusCode: resp.StatusCode}
+	if method != http.MethodHead {
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(s3Err)
+	}
+	if resp.StatusCode == http.StatusNotFound && s3Err.Code != "NoSuchBucket" && s3Err.Code != "NoSuchUpload" {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, s3Err)
+	}
+	return nil, s3Err
+}
+
+func (s *S3) Put(ctx context.Context, r io.Reader) (Info, error) {
+	tmp, err := os.CreateTemp(s.TempDir, "s3-upload-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Key: hex.EncodeToString(h.Sum(nil)), Size: size}
+
+	if _, err := s.Stat(ctx, info.Key); err == nil {
+		return info, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return Info{}, err
+	}
+
+	partSize := s.PartSize
+	if partSize < minPartSize {
+		partSize = minPartSize
+	}
+	for size > partSize*maxParts {
+		partSize *= 2
+	}
+	if size <= partSize {
+		err = s.putObject(ctx, info, tmp)
+	} else {
+		err = s.putMultipart(ctx, info, tmp, partSize)
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+func (s *S3) putObject(ctx context.Context, info Info, content io.ReaderAt) error {
+	u, err := s.objectURL(info.Key, nil)
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err := s.do(ctx, http.MethodPut, u, io.NewSectionReader(content, 0, info.Size), info.Size, info.Key, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// putMultipart uploads the content in parts, aborting the upload if any
+// part fails so the server does not keep the parts already sent.
+func (s *S3) putMultipart(ctx context.Context, info Info, content io.ReaderAt, partSize int64) (err error) {
+	u, err := s.objectURL(info.Key, url.Values{"uploads": {""}})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, u, nil, 0, emptyPayloadHash, http.Header{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("s3: decoding multipart upload: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			s.abortMultipart(info.Key, initiated.UploadID)
+		}
+	}()
+
+	var complete completeMultipartUpload
+	for offset, number := int64(0), 1; offset < info.Size; offset, number = offset+partSize, number+1 {
+		part := io.NewSectionReader(content, offset, min(partSize, info.Size-offset))
+		partHash, _, err := Key(part)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		u, err := s.objectURL(info.Key, url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {initiated.UploadID}})
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(ctx, http.MethodPut, u, part, part.Size(), partHash, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	u, err = s.objectURL(info.Key, url.Values{"uploadId": {initiated.UploadID}})
+	if err != nil {
+		return err
+	}
+	resp, err = s.do(ctx, http.MethodPost, u, bytes.NewReader(body), int64(len(body)), hashHex(body), http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The server can still fail the upload after answering 200.
+	var result S3Error
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if xml.Unmarshal(data, &result) == nil && result.Code != "" {
+		result.StatusCode = resp.StatusCode
+		return &result
+	}
+	return nil
+}
+
+func (s *S3) abortMultipart(key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	u, err := s.objectURL(key, url.Values{"uploadId": {uploadID}})
+	if err != nil {
+		return
+	}
+	if resp, err := s.do(ctx, http.MethodDelete, u, nil, 0, emptyPayloadHash, nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Open returns a reader that fetches the object from where it has been
+// seeked to, so serving a range only transfers that range.
+func (s *S3) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Reader{ctx: ctx, s: s, key: key, size: info.Size}, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	if !ValidKey(key) {
+		return Info{}, ErrNotFound
+	}
+	u, err := s.objectURL(key, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, u, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+	return Info{Key: key, Size: resp.ContentLength}, nil
+}
+
+// Delete removes the object. S3 does not tell whether there was one, so
+// Delete never returns ErrNotFound.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrNotFound
+	}
+	u, err := s.objectURL(key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, u, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List calls fn with the key of every object under Prefix, in key order.
+func (s *S3) List(ctx context.Context, fn func(key string) error) error {
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+	for {
+		u, err := s.objectURL("", query)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(ctx, http.MethodGet, u, nil, 0, emptyPayloadHash, nil)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3: decoding object list: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			key := strings.TrimPrefix(object.Key, s.Prefix)
+			if !ValidKey(key) {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// PresignGet returns a URL that downloads the object without credentials
+// until it expires, answered with the given type and as an attachment
+// with the given file name.
+func (s *S3) PresignGet(ctx context.Context, key string, opts PresignOptions) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrNotFound
+	}
+	query := url.Values{}
+	if opts.ContentType != "" {
+		query.Set("response-content-type", opts.ContentType)
+	}
+	if opts.Filename != "" {
+		if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": opts.Filename}); disposition != "" {
+			query.Set("response-content-disposition", disposition)
+		}
+	}
+	u, err := s.objectURL(key, query)
+	if err != nil {
+		return "", err
+	}
+	return s.signer().presign(u, opts.Expires, time.Now()).String(), nil
+}
+
+// s3Reader reads an object from offset on, opening a ranged GET on the
+// first read after each seek.
+type s3Reader struct {
+	ctx    context.Context
+	s      *S3
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		u, err := r.s.objectURL(r.key, nil)
+		if err != nil {
+			return 0, err
+		}
+		header := http.Header{"Range": {"bytes=" + strconv.FormatInt(r.offset, 10) + "-"}}
+		resp, err := r.s.do(r.ctx, http.MethodGet, u, nil, 0, emptyPayloadHash, header)
+		if err != nil {
+			return 0, err
+		}
+		r.body = resp.Body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("s3: seek before start of object")
+	}
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
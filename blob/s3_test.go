@@ -0,0 +1,361 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/blob/s3test"
+)
+
+// newTestS3 returns a store for a stand-in bucket that checks every
+// request's signature.
+func newTestS3(t *testing.T) (*S3, *s3test.Server) {
+	t.Helper()
+	srv := s3test.NewServer("library-files")
+	t.Cleanup(srv.Close)
+
+	s := NewS3(srv.URL, "eu-west-1", "library-files", Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"})
+	s.PathStyle = true
+	s.Prefix = "files/"
+	s.TempDir = t.TempDir()
+	s.Client = srv.Client()
+	srv.Verify = verifySignature(s.signer())
+	return s, srv
+}
+
+// verifySignature checks requests the way S3 does, by signing what
+// arrived again, so anything the client changes after signing, such as
+// the path's encoding or the query's order, fails.
+func verifySignature(s signer) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		query := r.URL.Query()
+		if signature := query.Get("X-Amz-Signature"); signature != "" {
+			t, err := time.Parse(sigV4TimeFormat, query.Get("X-Amz-Date"))
+			if err != nil {
+				return err
+			}
+			query.Del("X-Amz-Signature")
+			unsigned := *r.URL
+			unsigned.RawQuery = query.Encode()
+			if want := s.signature(r.Method, &unsigned, "host:"+r.Host+"\n", "host", unsignedPayload, t); signature != want {
+				return errors.New("presigned URL signature does not match")
+			}
+			return nil
+		}
+
+		t, err := time.Parse(sigV4TimeFormat, r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			return err
+		}
+		_, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		var signedHeaders, signature string
+		for _, param := range strings.Split(params, ", ") {
+			name, value, _ := strings.Cut(param, "=")
+			switch name {
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				signature = value
+			}
+		}
+		var headers strings.Builder
+		for _, name := range strings.Split(signedHeaders, ";") {
+			value := strings.Join(r.Header.Values(name), ",")
+			if name == "host" {
+				value = r.Host
+			}
+			headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+		}
+		if want := s.signature(r.Method, r.URL, headers.String(), signedHeaders, r.Header.Get("X-Amz-Content-Sha256"), t); signature != want {
+			return errors.New("signature does not match")
+		}
+		return nil
+	}
+}
+
+func TestS3Put(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), (11<<20)/16)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		stored    bool
+		puts      int
+		parts     int
+		completes int
+	}{
+		{name: "one request", content: []byte("hello"), puts: 1},
+		{name: "empty", content: []byte{}, puts: 1},
+		{name: "already stored", content: []byte("hello"), stored: true},
+		{name: "in parts", content: large, parts: 3, completes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, srv := newTestS3(t)
+			s.PartSize = minPartSize
+			key, _, _ := Key(bytes.NewReader(tt.content))
+			if tt.stored {
+				srv.SetObject("files/"+key, tt.content)
+			}
+
+			info, err := s.Put(context.Background(), bytes.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Key != key || info.Size != int64(len(tt.content)) {
+				t.Errorf("Put = %+v, want key %s and size %d", info, key, len(tt.content))
+			}
+			if got, ok := srv.Object("files/" + key); !ok || !bytes.Equal(got, tt.content) {
+				t.Errorf("stored %d bytes, want %d", len(got), len(tt.content))
+			}
+			if got := srv.Requests("PutObject"); got != tt.puts {
+				t.Errorf("%d PutObject requests, want %d", got, tt.puts)
+			}
+			if got := srv.Requests("UploadPart"); got != tt.parts {
+				t.Errorf("%d UploadPart requests, want %d", got, tt.parts)
+			}
+			if got := srv.Requests("CompleteMultipartUpload"); got != tt.completes {
+				t.Errorf("%d CompleteMultipartUpload requests, want %d", got, tt.completes)
+			}
+			if srv.Uploads() != 0 {
+				t.Errorf("%d uploads left open", srv.Uploads())
+			}
+		})
+	}
+}
+
+func TestS3PutMultipartFailure(t *testing.T) {
+	tests := []struct {
+		fail   string
+		aborts int
+	}{
+		{fail: "CreateMultipartUpload"},
+		{fail: "UploadPart", aborts: 1},
+		{fail: "CompleteMultipartUpload", aborts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fail, func(t *testing.T) {
+			s, srv := newTestS3(t)
+			s.PartSize = minPartSize
+			srv.Fail = tt.fail
+
+			_, err := s.Put(context.Background(), bytes.NewReader(make([]byte, minPartSize+1)))
+			var s3Err *S3Error
+			if !errors.As(err, &s3Err) || s3Err.Code != "InternalError" {
+				t.Fatalf("Put error = %v, want InternalError", err)
+			}
+			if got := srv.Requests("AbortMultipartUpload"); got != tt.aborts {
+				t.Errorf("%d AbortMultipartUpload requests, want %d", got, tt.aborts)
+			}
+			if srv.Uploads() != 0 {
+				t.Errorf("%d uploads left open", srv.Uploads())
+			}
+		})
+	}
+}
+
+func TestS3Open(t *testing.T) {
+	content := []byte("The quick brown fox jumps over the lazy dog")
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		want   string
+	}{
+		{name: "whole", want: string(content)},
+		{name: "from an offset", offset: 35, whence: io.SeekStart, want: "lazy dog"},
+		{name: "from the end", offset: -3, whence: io.SeekEnd, want: "dog"},
+		{name: "at the end", offset: 0, whence: io.SeekEnd, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestS3(t)
+			ctx := context.Background()
+			info, err := s.Put(ctx, bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := s.Open(ctx, info.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if _, err := r.Seek(tt.offset, tt.whence); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3NotFound(t *testing.T) {
+	s, _ := newTestS3(t)
+	ctx := context.Background()
+	missing, _, _ := Key(strings.NewReader("missing"))
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"missing", missing},
+		{"not a key", "../secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Stat(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Stat error = %v, want ErrNotFound", err)
+			}
+			if _, err := s.Open(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Open error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3Delete(t *testing.T) {
+	s, srv := newTestS3(t)
+	ctx := context.Background()
+	info, err := s.Put(ctx, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete(ctx, info.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.Object("files/" + info.Key); ok {
+		t.Error("object still stored")
+	}
+	if _, err := s.Stat(ctx, info.Key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, info.Key); err != nil {
+		t.Errorf("deleting again: %v", err)
+	}
+	if err := s.Delete(ctx, "../secret"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3List(t *testing.T) {
+	s, srv := newTestS3(t)
+	srv.PageSize = 2
+	ctx := context.Background()
+
+	var want []string
+	for _, content := range []string{"a", "b", "c", "d", "e"} {
+		info, err := s.Put(ctx, strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, info.Key)
+	}
+	srv.SetObject("files/notes.txt", []byte("not content"))
+	srv.SetObject("other/"+want[0], []byte("a"))
+
+	var got []string
+	if err := s.List(ctx, func(key string) error {
+		got = append(got, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	// Six objects under the prefix, two to a page.
+	if n := srv.Requests("ListObjectsV2"); n != 3 {
+		t.Errorf("%d list requests, want 3", n)
+	}
+}
+
+func TestS3PresignGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        PresignOptions
+		tamper      func(string) string
+		status      int
+		contentType string
+		disposition string
+	}{
+		{
+			name:        "with type and file name",
+			opts:        PresignOptions{Expires: time.Minute, ContentType: "application/pdf", Filename: "Algebra notes.pdf"},
+			status:      http.StatusOK,
+			contentType: "application/pdf",
+			disposition: `attachment; filename="Algebra notes.pdf"`,
+		},
+		{
+			name:        "bare",
+			opts:        PresignOptions{Expires: time.Minute},
+			status:      http.StatusOK,
+			contentType: "application/octet-stream",
+		},
+		{
+			name:   "file name changed",
+			opts:   PresignOptions{Expires: time.Minute, Filename: "notes.pdf"},
+			tamper: func(u string) string { return strings.Replace(u, "notes.pdf", "other.pdf", 1) },
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "expiry extended",
+			opts:   PresignOptions{Expires: time.Minute},
+			tamper: func(u string) string { return strings.Replace(u, "X-Amz-Expires=60", "X-Amz-Expires=600", 1) },
+			status: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, srv := newTestS3(t)
+			ctx := context.Background()
+			info, err := s.Put(ctx, strings.NewReader("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := s.PresignGet(ctx, info.Key, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				u = tt.tamper(u)
+			}
+			resp, err := srv.Client().Get(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if string(body) != "hello" {
+				t.Errorf("body = %q", body)
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if got := resp.Header.Get("Content-Disposition"); got != tt.disposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.disposition)
+			}
+		})
+	}
+}
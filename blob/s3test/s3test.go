@@ -0,0 +1,368 @@
+// Package s3test runs an in-memory stand-in for one S3 bucket, addressed
+// path-style, for testing what talks to S3 without a server. It answers
+// the requests blob.S3 makes: objects are put, read in ranges, deleted and
+// listed, uploaded in parts, and fetched through presigned URLs.
+//
+// Like S3, it rejects unsigned and expired requests, payloads that do not
+// match their X-Amz-Content-Sha256, and multipart uploads whose parts,
+// all but the last, are under 5 MiB. Signatures themselves are only
+// checked by Verify, if set.
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minPartSize     = 5 << 20
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	timeFormat      = "20060102T150405Z"
+)
+
+// Server is a bucket served over HTTP at URL.
+type Server struct {
+	*httptest.Server
+	Bucket string
+
+	// Verify, if set, checks each request's signature, answering 403 if
+	// it returns an error.
+	Verify func(r *http.Request) error
+
+	// Fail names an operation, like "UploadPart", that is answered with
+	// 500 InternalError.
+	Fail string
+
+	// PageSize is the most keys one list request returns.
+	PageSize int
+
+	mu         sync.Mutex
+	objects    map[string][]byte
+	uploads    map[string]map[int][]byte
+	nextUpload int
+	requests   map[string]int
+}
+
+// NewServer starts a server for bucket. Close it when done.
+func NewServer(bucket string) *Server {
+	s := &Server{
+		Bucket:   bucket,
+		PageSize: 1000,
+		objects:  map[string][]byte{},
+		uploads:  map[string]map[int][]byte{},
+		requests: map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Object returns the content of the named object.
+func (s *Server) Object(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.objects[name]
+	return content, ok
+}
+
+// SetObject stores content as the named object.
+func (s *Server) SetObject(name string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[name] = content
+}
+
+// Requests returns how many requests for the operation, like "PutObject",
+// were answered, successfully or not.
+func (s *Server) Requests(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[operation]
+}
+
+// Uploads returns how many multipart uploads are neither completed nor
+// aborted.
+func (s *Server) Uploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: message})
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+// operation names the S3 operation a request for the bucket makes.
+func operation(r *http.Request, object string) string {
+	query := r.URL.Query()
+	switch {
+	case object == "" && r.Method == http.MethodGet:
+		return "ListObjectsV2"
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		return "CreateMultipartUpload"
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		return "UploadPart"
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		return "CompleteMultipartUpload"
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		return "AbortMultipartUpload"
+	case r.Method == http.MethodPut:
+		return "PutObject"
+	case r.Method == http.MethodGet:
+		return "GetObject"
+	case r.Method == http.MethodHead:
+		return "HeadObject"
+	case r.Method == http.MethodDelete:
+		return "DeleteObject"
+	}
+	return ""
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, object, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	op := operation(r, object)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[op]++
+
+	switch {
+	case bucket != s.Bucket:
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	case op == "":
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" is not allowed here")
+		return
+	}
+	if code, err := s.authorize(r); err != nil {
+		writeError(w, http.StatusForbidden, code, err.Error())
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != "" && hash != unsignedPayload {
+		if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != hash {
+			writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed")
+			return
+		}
+	}
+	if op == s.Fail {
+		writeError(w, http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again.")
+		return
+	}
+
+	query := r.URL.Query()
+	switch op {
+	case "ListObjectsV2":
+		s.list(w, query)
+	case "CreateMultipartUpload":
+		s.nextUpload++
+		id := "upload-" + strconv.Itoa(s.nextUpload)
+		s.uploads[id] = map[int][]byte{}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string   `xml:"Bucket"`
+			Key      string   `xml:"Key"`
+			UploadID string   `xml:"UploadId"`
+		}{Bucket: bucket, Key: object, UploadID: id})
+	case "UploadPart":
+		parts, ok := s.uploads[query.Get("uploadId")]
+		number, err := strconv.Atoi(query.Get("partNumber"))
+		if !ok || err != nil || number < 1 || number > 10000 {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist")
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", etag(body))
+	case "CompleteMultipartUpload":
+		s.complete(w, object, query.Get("uploadId"), body)
+	case "AbortMultipartUpload":
+		if _, ok := s.uploads[query.Get("uploadId")]; !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist")
+			return
+		}
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case "PutObject":
+		s.objects[object] = body
+		w.Header().Set("ETag", etag(body))
+	case "GetObject", "HeadObject":
+		content, ok := s.objects[object]
+		if !ok {
+			if op == "HeadObject" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		contentType := query.Get("response-content-type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		if disposition := query.Get("response-content-disposition"); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		w.Header().Set("ETag", etag(content))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	case "DeleteObject":
+		delete(s.objects, object)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// authorize checks that a request is signed, in its headers or its query,
+// and that a presigned URL has not expired. It returns the error code to
+// answer with.
+func (s *Server) authorize(r *http.Request) (string, error) {
+	query := r.URL.Query()
+	switch {
+	case query.Has("X-Amz-Signature"):
+		if r.Method != http.MethodGet {
+			return "AccessDenied", errors.New("presigned URLs are only signed for GET")
+		}
+		signed, err := time.Parse(timeFormat, query.Get("X-Amz-Date"))
+		if err != nil {
+			return "AuthorizationQueryParametersError", fmt.Errorf("X-Amz-Date: %w", err)
+		}
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires < 1 || expires > 7*24*3600 {
+			return "AuthorizationQueryParametersError", errors.New("X-Amz-Expires must be between 1 and 604800 seconds")
+		}
+		if time.Now().After(signed.Add(time.Duration(expires) * time.Second)) {
+			return "AccessDenied", errors.New("Request has expired")
+		}
+	case strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="):
+		if r.Header.Get("X-Amz-Content-Sha256") == "" {
+			return "InvalidRequest", errors.New("Missing required header for this request: x-amz-content-sha256")
+		}
+	default:
+		return "AccessDenied", errors.New("Access Denied")
+	}
+	if s.Verify != nil {
+		if err := s.Verify(r); err != nil {
+			return "SignatureDoesNotMatch", err
+		}
+	}
+	return "", nil
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string   `xml:"Name"`
+	Prefix                string   `xml:"Prefix"`
+	KeyCount              int      `xml:"KeyCount"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
+	Contents              []struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// list answers a page of the objects under the prefix in key order. The
+// continuation token is the last key of the page before.
+func (s *Server) list(w http.ResponseWriter, query url.Values) {
+	prefix, after := query.Get("prefix"), query.Get("continuation-token")
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) && name > after {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	result := listResult{Name: s.Bucket, Prefix: prefix}
+	if len(names) > s.PageSize {
+		names = names[:s.PageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = names[len(names)-1]
+	}
+	for _, name := range names {
+		result.Contents = append(result.Contents, struct {
+			Key  string `xml:"Key"`
+			Size int    `xml:"Size"`
+		}{name, len(s.objects[name])})
+	}
+	result.KeyCount = len(names)
+	writeXML(w, result)
+}
+
+// complete joins the parts of an upload into the object, checking the
+// list of parts the way S3 does.
+func (s *Server) complete(w http.ResponseWriter, object, uploadID string, body []byte) {
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist")
+		return
+	}
+	var request struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &request); err != nil || len(request.Parts) == 0 {
+		writeError(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+		return
+	}
+
+	var content []byte
+	for i, part := range request.Parts {
+		data, ok := parts[part.PartNumber]
+		switch {
+		case i > 0 && part.PartNumber <= request.Parts[i-1].PartNumber:
+			writeError(w, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order")
+			return
+		case !ok || part.ETag != etag(data):
+			writeError(w, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found")
+			return
+		case i < len(request.Parts)-1 && len(data) < minPartSize:
+			writeError(w, http.StatusBadRequest, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size")
+			return
+		}
+		content = append(content, data...)
+	}
+	s.objects[object] = content
+	delete(s.uploads, uploadID)
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{Key: object, ETag: etag(content)})
+}
+
+func etag(content []byte) string {
+	sum := md5.Sum(content)
+	return strconv.Quote(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,205 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4, as S3 and S3-compatible servers take it
+// (https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html).
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4Service    = "s3"
+
+	// unsignedPayload stands for the payload hash of presigned URLs, whose
+	// payload is not known when they are signed.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// emptyPayloadHash is the SHA-256 of no bytes.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Credentials sign requests. SessionToken is only set for temporary
+// credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signer signs requests for one region with one set of credentials.
+type signer struct {
+	credentials Credentials
+	region      string
+}
+
+func (s signer) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/" + sigV4Service + "/aws4_request"
+}
+
+func (s signer) key(t time.Time) []byte {
+	k := hmacSHA256([]byte("AWS4"+s.credentials.SecretAccessKey), t.Format("20060102"))
+	k = hmacSHA256(k, s.region)
+	k = hmacSHA256(k, sigV4Service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+// sign adds the X-Amz-Date, X-Amz-Content-Sha256 and Authorization headers
+// to req, whose body hashes to payloadHash. The host, Range, Content-Type,
+// Content-MD5 and every X-Amz- header are signed.
+func (s signer) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.credentials.SessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	signature := s.signature(req.Method, req.URL, headers, signedHeaders, payloadHash, t)
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+s.credentials.AccessKeyID+"/"+s.scope(t)+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// presign returns u with the query parameters that let anyone holding the
+// URL make a GET of it until it expires.
+func (s signer) presign(u *url.URL, expires time.Duration, t time.Time) *url.URL {
+	t = t.UTC()
+	signed := *u
+	query := signed.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.credentials.AccessKeyID+"/"+s.scope(t))
+	query.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if s.credentials.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.credentials.SessionToken)
+	}
+	signed.RawQuery = canonicalQuery(query)
+
+	signature := s.signature(http.MethodGet, &signed, "host:"+signed.Host+"\n", "host", unsignedPayload, t)
+	signed.RawQuery += "&X-Amz-Signature=" + signature
+	return &signed
+}
+
+func (s signer) signature(method string, u *url.URL, canonicalHeaders, signedHeaders, payloadHash string, t time.Time) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		t.Format(sigV4TimeFormat),
+		s.scope(t),
+		hashHex([]byte(canonicalRequest(method, u, canonicalHeaders, signedHeaders, payloadHash))),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(s.key(t), stringToSign))
+}
+
+func canonicalRequest(method string, u *url.URL, canonicalHeaders, signedHeaders, payloadHash string) string {
+	return strings.Join([]string{
+		method,
+		canonicalPath(u),
+		canonicalQuery(u.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalHeaders returns the headers of req that are signed, one
+// "name:value" line each, and their names joined by semicolons.
+func canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": requestHost(req)}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || name == "content-type" || name == "content-md5" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// canonicalPath encodes each segment of the path once, as S3 expects.
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		unescaped = u.Path
+	}
+	segments := strings.Split(unescaped, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the parameters and encodes them the way SigV4 does,
+// which differs from url.Values.Encode for spaces and a few symbols.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte but the unreserved characters.
+func uriEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
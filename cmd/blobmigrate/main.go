@@ -0,0 +1,145 @@
+// Command blobmigrate copies material file content from one store to
+// another, e.g. from local disk to S3:
+//
+//	go run ./cmd/blobmigrate -from data/blobs -to 's3://library-files/?endpoint=http://localhost:9000&path_style=true'
+//
+// Stores are named as BLOB_STORE names them. Content the destination
+// already holds is skipped, and every copy is read back and checked against
+// its SHA-256 key unless -verify=false. With -delete, which needs
+// verifying, the source copy is removed once the destination's is
+// verified. Running it again resumes an interrupted migration.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/arjunsaxaena/Library-Management/blob"
+)
+
+func main() {
+	from := flag.String("from", "", "store to copy content from")
+	to := flag.String("to", "", "store to copy content to")
+	verify := flag.Bool("verify", true, "read every copy back and check its checksum")
+	remove := flag.Bool("delete", false, "delete content from the source once it is copied and verified")
+	dryRun := flag.Bool("dry-run", false, "list what would be copied without copying it")
+	flag.Parse()
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *remove && !*verify {
+		fmt.Fprintln(flag.CommandLine.Output(), "-delete cannot be used with -verify=false: source content is only deleted once its copy is verified")
+		os.Exit(2)
+	}
+
+	src, err := blob.FromURL(*from)
+	if err != nil {
+		log.Fatalf("Failed to open source store: %v", err)
+	}
+	dst, err := blob.FromURL(*to)
+	if err != nil {
+		log.Fatalf("Failed to open destination store: %v", err)
+	}
+	lister, ok := src.(blob.Lister)
+	if !ok {
+		log.Fatalf("Source store %s cannot list its content", *from)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var copied, skipped, failed int
+	err = lister.List(ctx, func(key string) error {
+		done, err := migrate(ctx, src, dst, key, *verify, *dryRun)
+		switch {
+		case err != nil:
+			log.Printf("%s: %v", key, err)
+			failed++
+			return ctx.Err()
+		case done:
+			copied++
+		default:
+			skipped++
+		}
+		if *remove && !*dryRun {
+			if err := src.Delete(ctx, key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+				log.Printf("%s: delete from source: %v", key, err)
+				failed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list source store: %v", err)
+		failed++
+	}
+
+	if *dryRun {
+		log.Printf("%d to copy, %d already copied", copied, skipped)
+	} else {
+		log.Printf("%d copied, %d already copied, %d failed", copied, skipped, failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// migrate copies the content under key unless dst already has it, and
+// reports whether it copied. Content already at the destination is
+// verified too, so -delete never removes the only good copy.
+func migrate(ctx context.Context, src, dst blob.Store, key string, verify, dryRun bool) (bool, error) {
+	srcInfo, err := src.Stat(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	dstInfo, err := dst.Stat(ctx, key)
+	switch {
+	case err == nil && dstInfo.Size == srcInfo.Size:
+		if verify && !dryRun {
+			return false, blob.Verify(ctx, dst, key)
+		}
+		return false, nil
+	case err != nil && !errors.Is(err, blob.ErrNotFound):
+		return false, err
+	}
+	if dryRun {
+		return true, nil
+	}
+	if err == nil {
+		// Put keeps content already stored under its key, so a copy of the
+		// wrong size has to go first.
+		if err := dst.Delete(ctx, key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return false, err
+		}
+	}
+
+	content, err := src.Open(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer content.Close()
+
+	info, err := dst.Put(ctx, content)
+	if err != nil {
+		return false, err
+	}
+	if info.Key != key {
+		// The source copy is corrupt; keep the destination free of it.
+		if err := dst.Delete(ctx, info.Key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+			log.Printf("%s: delete corrupt copy %s: %v", key, info.Key, err)
+		}
+		return false, &blob.ChecksumError{Key: key, Actual: info.Key}
+	}
+	if verify {
+		if err := blob.Verify(ctx, dst, key); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
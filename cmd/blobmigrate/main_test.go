@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/blob"
+	"github.com/arjunsaxaena/Library-Management/blob/s3test"
+)
+
+func TestMigrate(t *testing.T) {
+	const content = "Algebra notes"
+	key, _, _ := blob.Key(strings.NewReader(content))
+
+	tests := []struct {
+		name     string
+		source   string // stored under key on local disk
+		dest     string // already in the bucket under key, if not empty
+		verify   bool
+		dryRun   bool
+		copied   bool
+		checksum bool
+		want     string // in the bucket afterwards
+	}{
+		{name: "copied", source: content, verify: true, copied: true, want: content},
+		{name: "copied unverified", source: content, copied: true, want: content},
+		{name: "already copied", source: content, dest: content, verify: true, want: content},
+		{name: "partly copied", source: content, dest: "Algebra", verify: true, copied: true, want: content},
+		{name: "corrupt copy at the destination", source: content, dest: "Algebra NOTES", verify: true, checksum: true, want: "Algebra NOTES"},
+		{name: "corrupt source", source: "Algebra n0tes", verify: true, checksum: true},
+		{name: "dry run", source: content, dryRun: true, copied: true},
+		{name: "dry run of copied content", source: content, dest: content, dryRun: true, want: content},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			src, err := blob.NewLocal(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(src.Root, key[:2], key[2:4], key)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(tt.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			srv := s3test.NewServer("library-files")
+			defer srv.Close()
+			dst := blob.NewS3(srv.URL, "us-east-1", "library-files", blob.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"})
+			dst.PathStyle, dst.TempDir, dst.Client = true, t.TempDir(), srv.Client()
+			if tt.dest != "" {
+				srv.SetObject(key, []byte(tt.dest))
+			}
+
+			copied, err := migrate(ctx, src, dst, key, tt.verify, tt.dryRun)
+			var mismatch *blob.ChecksumError
+			if got := errors.As(err, &mismatch); got != tt.checksum {
+				t.Fatalf("migrate error = %v, want a checksum error %v", err, tt.checksum)
+			}
+			if !tt.checksum && err != nil {
+				t.Fatal(err)
+			}
+			if copied != tt.copied {
+				t.Errorf("copied = %v, want %v", copied, tt.copied)
+			}
+
+			got, ok := srv.Object(key)
+			if string(got) != tt.want || ok != (tt.want != "") {
+				t.Errorf("bucket has %q, want %q", got, tt.want)
+			}
+			// A corrupt copy is stored under its own key, and must not be left
+			// behind either.
+			var keys []string
+			if err := dst.List(ctx, func(k string) error {
+				keys = append(keys, k)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) > 1 || (len(keys) == 1) != ok {
+				t.Errorf("bucket holds %v", keys)
+			}
+		})
+	}
+}
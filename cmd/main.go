@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/arjunsaxaena/Library-Management/blob"
 	"github.com/arjunsaxaena/Library-Management/controllers"
+	"github.com/arjunsaxaena/Library-Management/identifiers"
+	"github.com/arjunsaxaena/Library-Management/linkcheck"
+	"github.com/arjunsaxaena/Library-Management/metadata"
 	"github.com/arjunsaxaena/Library-Management/web"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -27,64 +35,74 @@ func main() {
 
 	handler := web.NewHandler(bookStore, authorStore, locationStore, userStore, issuedBookStore, subjectStore, materialStore)
 
+	// Card number and barcode schemes, e.g. PATRON_CARD_PREFIX=21234 PATRON_CARD_DIGITS=8
+	if handler.CardNumbers, err = identifiers.SchemeFromEnv("PATRON_CARD", identifiers.DefaultPatronScheme); err != nil {
+		log.Fatalf("Invalid patron card scheme: %v", err)
+	}
+	if handler.Barcodes, err = identifiers.SchemeFromEnv("ITEM_BARCODE", identifiers.DefaultItemScheme); err != nil {
+		log.Fatalf("Invalid item barcode scheme: %v", err)
+	}
+	handler.Metadata = metadata.NewOpenLibrary(os.Getenv("OPENLIBRARY_URL"))
+	handler.Tx = controllers.NewDBTxRunner(db)
+	handler.AuditStore = controllers.NewDBAuditStore(db)
+	handler.VocabularyStore = controllers.NewDBVocabularyStore(db)
+	handler.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	// Material file content, in a directory or an S3 bucket, e.g.
+	// BLOB_STORE=/var/lib/library/blobs or
+	// BLOB_STORE=s3://library-files/?endpoint=http://minio:9000&path_style=true
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore == "" {
+		blobStore = "data/blobs"
+	}
+	if handler.Blobs, err = blob.FromURL(blobStore); err != nil {
+		log.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	if err := handler.AssignMissingIdentifiers(); err != nil {
+		log.Fatalf("Failed to assign card numbers and barcodes: %v", err)
+	}
+
+	// Background checks of material links, e.g. LINK_CHECK_INTERVAL=30m;
+	// LINK_CHECK_INTERVAL=0 turns them off
+	linkChecks := linkcheck.NewWorker(materialStore, linkcheck.NewChecker())
+	if interval := os.Getenv("LINK_CHECK_INTERVAL"); interval != "" {
+		if linkChecks.Interval, err = time.ParseDuration(interval); err != nil {
+			log.Fatalf("Invalid link check interval: %v", err)
+		}
+	}
+	if linkChecks.Interval > 0 {
+		go linkChecks.Run(context.Background())
+	}
+
 	router := gin.Default()
 
-	// Book routes
-	router.GET("/books", handler.GetBooks)
-	router.GET("/books/:id", handler.GetBook)
-	router.POST("/books", handler.CreateBook)
-	router.PUT("/books/:id", handler.UpdateBook)
-	router.DELETE("/books/:id", handler.DeleteBook)
-
-	// User routes
-	router.GET("/users", handler.GetUsers)
-	router.GET("/users/:id", handler.GetUser)
-	router.POST("/users", handler.CreateUser)
-	router.PUT("/users/:id", handler.UpdateUser)
-	router.DELETE("/users/:id", handler.DeleteUser)
-
-	// Location routes
-	router.GET("/locations", handler.GetLocations)
-	router.GET("/locations/:id", handler.GetLocation)
-	router.POST("/locations", handler.CreateLocation)
-	router.PUT("/locations/:id", handler.UpdateLocation)
-	router.DELETE("/locations/:id", handler.DeleteLocation)
-
-	// Author routes
-	router.GET("/authors", handler.GetAuthors)
-	router.GET("/authors/:id", handler.GetAuthor)
-	router.POST("/authors", handler.CreateAuthor)
-	router.PUT("/authors/:id", handler.UpdateAuthor)
-	router.DELETE("/authors/:id", handler.DeleteAuthor)
-
-	// Issued Book routes
-	router.GET("books/issue/:id", handler.GetIssuedBook)
-	router.GET("books/issue", handler.GetIssuedBooks)
-	router.POST("books/issue", handler.IssueBook)
-	router.POST("books/return", handler.ReturnBook)
-
-	// Material routes
-	router.GET("/materials", handler.GetMaterials)
-	router.GET("/materials/:id", handler.GetMaterial)
-	router.POST("/materials", handler.CreateMaterial)
-	router.GET("/materials/subject/:subject_name", handler.GetMaterialsBySubject)
-	router.GET("/materials/language/:language", handler.GetMaterialsByLanguage)
-	router.PUT("/materials/:id", handler.UpdateMaterial)
-	router.DELETE("/materials/:id", handler.DeleteMaterial)
-
-	// Subject routes
-	router.GET("/subjects", handler.GetSubjects)
-	router.GET("/subjects/:id", handler.GetSubject)
-	router.POST("/subjects", handler.CreateSubject)
-	router.GET("/subjects/name/:name", handler.GetSubjectByName)
-	router.PUT("/subjects/:id", handler.UpdateSubject)
-	router.DELETE("/subjects/:id", handler.DeleteSubject)
+	spec := web.NewOpenAPISpec()
+	if os.Getenv("GO_ENV") == "development" {
+		router.Use(spec.ValidateResponses())
+	}
+
+	// Librarian web UI
+	if err := web.RegisterUI(router, handler); err != nil {
+		log.Fatalf("Failed to load web UI: %v", err)
+	}
+
+	// API routes under /api/v1, plus the deprecated unversioned aliases
+	web.RegisterAPI(router, handler, web.DefaultAPIConfig())
 
 	// Health check route
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
+	// API documentation
+	router.GET("/openapi.json", spec.ServeJSON)
+	router.GET("/docs", spec.ServeDocs)
+
+	if missing := spec.MissingRoutes(router.Routes()); len(missing) > 0 {
+		log.Fatalf("Routes missing from the OpenAPI spec: %s", strings.Join(missing, ", "))
+	}
+
 	// Start the server
 	if err := router.Run(":3000"); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
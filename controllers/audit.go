@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/jmoiron/sqlx"
+)
+
+// systemActor is recorded for writes no request is behind, such as the
+// identifier backfill at startup.
+const systemActor = "system"
+
+// auditTables maps audit entity types to the tables holding them.
+var auditTables = map[string]string{
+	model.EntityBook:     "books",
+	model.EntityAuthor:   "authors",
+	model.EntityLocation: "locations",
+	model.EntityUser:     "users",
+	model.EntitySubject:  "subjects",
+	model.EntityMaterial: "materials",
+	model.EntityLoan:     "issued_books",
+	model.EntityTerm:     "vocabulary_terms",
+}
+
+// snapshotQueries overrides how a record is captured for the audit log.
+// Books include their contributors, books and materials the subjects they
+// are filed under, materials their files, and vocabulary terms their
+// labels, all of which live in tables of their own.
+var snapshotQueries = map[string]string{
+	model.EntityBook: `SELECT to_jsonb(t) || jsonb_build_object('contributors', COALESCE(
+		(SELECT jsonb_agg(jsonb_build_object('author_id', bc.author_id, 'role', bc.role) ORDER BY bc.position)
+		 FROM book_contributors bc WHERE bc.book_id = t.id), '[]'::jsonb),
+		'subject_ids', COALESCE(
+		(SELECT jsonb_agg(l.subject_id ORDER BY l.subject_id) FROM book_subjects l WHERE l.book_id = t.id), '[]'::jsonb))
+		FROM books t WHERE t.id = $1`,
+	model.EntityMaterial: `SELECT to_jsonb(t) || jsonb_build_object('subject_ids', COALESCE(
+		(SELECT jsonb_agg(l.subject_id ORDER BY l.subject_id) FROM material_subjects l WHERE l.material_id = t.id), '[]'::jsonb),
+		'files', COALESCE(
+		(SELECT jsonb_agg(jsonb_build_object('id', f.id, 'filename', f.filename, 'sha256', f.sha256, 'size', f.size) ORDER BY f.id)
+		 FROM material_files f WHERE f.material_id = t.id), '[]'::jsonb))
+		FROM materials t WHERE t.id = $1`,
+	model.EntityTerm: `SELECT to_jsonb(t) || jsonb_build_object('labels', COALESCE(
+		(SELECT jsonb_object_agg(l.locale, l.label) FROM vocabulary_labels l WHERE l.term_id = t.id), '{}'::jsonb))
+		FROM vocabulary_terms t WHERE t.id = $1`,
+}
+
+// snapshot returns the record as a JSON object, or nil if it does not
+// exist.
+func snapshot(q queryer, entity string, id uuid.UUID) (json.RawMessage, error) {
+	query, ok := snapshotQueries[entity]
+	if !ok {
+		query = fmt.Sprintf("SELECT to_jsonb(t) FROM %s t WHERE t.id = $1", auditTables[entity])
+	}
+	var row []byte
+	if err := q.Get(&row, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return row, nil
+}
+
+// change is a write being audited: the record's state before it, taken
+// when the change starts, and what to record once it is done.
+type change struct {
+	action string
+	entity string
+	id     uuid.UUID
+	before json.RawMessage
+}
+
+// startChange captures the record before a write. Call record on the
+// result after the write, with the same queryer.
+func startChange(q queryer, action, entity string, id uuid.UUID) (*change, error) {
+	before, err := snapshot(q, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	return &change{action: action, entity: entity, id: id, before: before}, nil
+}
+
+// record captures the record after the write and adds the change to the
+// audit log, keeping only the fields that differ.
+func (c *change) record(q queryer, audit model.Audit) error {
+	after, err := snapshot(q, c.entity, c.id)
+	if err != nil {
+		return err
+	}
+	before, after, err := diff(c.before, after)
+	if err != nil {
+		return err
+	}
+
+	actor := audit.Actor
+	if actor == "" {
+		actor = systemActor
+	}
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("audit_log").
+		Cols("id", "actor", "action", "entity_type", "entity_id", "old_values", "new_values", "request_id", "reason", "created_at").
+		Values(uuid.New(), actor, c.action, c.entity, c.id, string(before), string(after), audit.RequestID, audit.Reason, time.Now())
+
+	query, args := ib.Build()
+	_, err = q.Exec(query, args...)
+	return err
+}
+
+// audited runs write in a transaction and records it as one change to the
+// entity.
+func audited(q queryer, audit model.Audit, action, entity string, id uuid.UUID, write func(q queryer) error) error {
+	return withTx(q, func(q queryer) error {
+		c, err := startChange(q, action, entity, id)
+		if err != nil {
+			return err
+		}
+		if err := write(q); err != nil {
+			return err
+		}
+		return c.record(q, audit)
+	})
+}
+
+// diff reduces two snapshots to the fields that differ between them, a
+// field only one of them has counting as null in the other. A missing
+// snapshot stays null and leaves the other one whole.
+func diff(before, after json.RawMessage) (json.RawMessage, json.RawMessage, error) {
+	if before == nil || after == nil {
+		return orNull(before), orNull(after), nil
+	}
+
+	var b, a map[string]json.RawMessage
+	if err := json.Unmarshal(before, &b); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(after, &a); err != nil {
+		return nil, nil, err
+	}
+	changedBefore := map[string]json.RawMessage{}
+	changedAfter := map[string]json.RawMessage{}
+	for key, value := range a {
+		if !bytes.Equal(b[key], value) {
+			changedBefore[key] = orNull(b[key])
+			changedAfter[key] = value
+		}
+	}
+	for key, value := range b {
+		if _, ok := a[key]; !ok {
+			changedBefore[key] = value
+			changedAfter[key] = orNull(nil)
+		}
+	}
+
+	oldValues, err := json.Marshal(changedBefore)
+	if err != nil {
+		return nil, nil, err
+	}
+	newValues, err := json.Marshal(changedAfter)
+	return oldValues, newValues, err
+}
+
+func orNull(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+type DBAuditStore struct {
+	db queryer
+}
+
+func NewDBAuditStore(db *sqlx.DB) *DBAuditStore {
+	return &DBAuditStore{db: db}
+}
+
+func (s *DBAuditStore) AuditEntries(f model.AuditFilter) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("audit_log")
+
+	if f.Actor != "" {
+		sb.Where(sb.Equal("actor", f.Actor))
+	}
+	if f.Action != "" {
+		sb.Where(sb.Equal("action", f.Action))
+	}
+	if f.EntityType != "" {
+		sb.Where(sb.Equal("entity_type", f.EntityType))
+	}
+	if f.EntityID != uuid.Nil {
+		sb.Where(sb.Equal("entity_id", f.EntityID))
+	}
+	if f.RequestID != "" {
+		sb.Where(sb.Equal("request_id", f.RequestID))
+	}
+	if !f.Since.IsZero() {
+		sb.Where(sb.GreaterEqualThan("created_at", f.Since))
+	}
+	if !f.Until.IsZero() {
+		sb.Where(sb.LessThan("created_at", f.Until))
+	}
+
+	if f.Oldest {
+		sb.OrderBy("created_at ASC", "id ASC")
+	} else {
+		sb.OrderBy("created_at DESC", "id DESC")
+	}
+	if f.Limit > 0 {
+		sb.Limit(f.Limit)
+	}
+	if f.Offset > 0 {
+		sb.Offset(f.Offset)
+	}
+
+	query, args := sb.Build()
+	err := s.db.Select(&entries, query, args...)
+	return entries, err
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     string
+		after      string
+		wantBefore string
+		wantAfter  string
+		wantErr    bool
+	}{
+		{
+			name:       "create",
+			after:      `{"id": 1, "name": "Ann"}`,
+			wantBefore: `null`,
+			wantAfter:  `{"id": 1, "name": "Ann"}`,
+		},
+		{
+			name:       "delete",
+			before:     `{"id": 1, "name": "Ann"}`,
+			wantBefore: `{"id": 1, "name": "Ann"}`,
+			wantAfter:  `null`,
+		},
+		{
+			name:       "changed fields only",
+			before:     `{"id": 1, "name": "Ann", "class": "9", "version": 1}`,
+			after:      `{"id": 1, "name": "Ann", "class": "10", "version": 2}`,
+			wantBefore: `{"class": "9", "version": 1}`,
+			wantAfter:  `{"class": "10", "version": 2}`,
+		},
+		{
+			name:       "nested values compare whole",
+			before:     `{"contributors": [{"author_id": "a", "role": "author"}], "subject_ids": []}`,
+			after:      `{"contributors": [{"author_id": "a", "role": "editor"}], "subject_ids": []}`,
+			wantBefore: `{"contributors": [{"author_id": "a", "role": "author"}]}`,
+			wantAfter:  `{"contributors": [{"author_id": "a", "role": "editor"}]}`,
+		},
+		{
+			name:       "field set and cleared",
+			before:     `{"deleted_at": null, "isbn13": "9780306406157"}`,
+			after:      `{"deleted_at": "2024-01-02T03:04:05Z", "isbn13": null}`,
+			wantBefore: `{"deleted_at": null, "isbn13": "9780306406157"}`,
+			wantAfter:  `{"deleted_at": "2024-01-02T03:04:05Z", "isbn13": null}`,
+		},
+		{
+			name:       "field added and removed",
+			before:     `{"id": 1, "old": "x"}`,
+			after:      `{"id": 1, "new": "y"}`,
+			wantBefore: `{"old": "x", "new": null}`,
+			wantAfter:  `{"old": null, "new": "y"}`,
+		},
+		{
+			name:       "no change",
+			before:     `{"id": 1}`,
+			after:      `{"id": 1}`,
+			wantBefore: `{}`,
+			wantAfter:  `{}`,
+		},
+		{name: "not an object", before: `[1]`, after: `{"id": 1}`, wantErr: true},
+	}
+	raw := func(s string) json.RawMessage {
+		if s == "" {
+			return nil
+		}
+		return json.RawMessage(s)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, err := diff(raw(tt.before), raw(tt.after))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diff error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assertJSON(t, "before", before, tt.wantBefore)
+			assertJSON(t, "after", after, tt.wantAfter)
+		})
+	}
+}
+
+func assertJSON(t *testing.T, name string, got json.RawMessage, want string) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("%s is not JSON: %s", name, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("%s = %s, want %s", name, got, want)
+	}
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/arjunsaxaena/Library-Management/model"
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
@@ -8,7 +10,8 @@ import (
 )
 
 type DBAuthorStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBAuthorStore(db *sqlx.DB) *DBAuthorStore {
@@ -19,7 +22,7 @@ func (s *DBAuthorStore) Author(id uuid.UUID) (model.Author, error) {
 	var author model.Author
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("authors").Where(sb.Equal("id", id))
+	sb.Select("*").From("authors").Where(sb.Equal("id", id), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&author, query, args...)
@@ -30,7 +33,7 @@ func (s *DBAuthorStore) Authors() ([]model.Author, error) {
 	var authors []model.Author
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("authors")
+	sb.Select("*").From("authors").Where(sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&authors, query, args...)
@@ -40,31 +43,196 @@ func (s *DBAuthorStore) Authors() ([]model.Author, error) {
 func (s *DBAuthorStore) CreateAuthor(a *model.Author) error {
 	sb := sqlbuilder.NewInsertBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.InsertInto("authors").Cols("id", "name").Values(a.ID, a.Name)
+	a.Version = 1
+	sb.InsertInto("authors").Cols("id", "name", "version").Values(a.ID, a.Name, a.Version)
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "create", model.EntityAuthor, a.ID, func(q queryer) error {
+		_, err := q.Exec(query, args...)
+		return err
+	})
 }
 
-func (s *DBAuthorStore) UpdateAuthor(a *model.Author) error {
+func (s *DBAuthorStore) UpdateAuthor(a *model.Author, columns ...string) error {
 	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Update("authors").Set(
-		sb.Assign("name", a.Name),
-	).Where(sb.Equal("id", a.ID))
+	sb.Update("authors")
+	if err := assignColumns(sb, []column{
+		{"name", a.Name},
+	}, columns); err != nil {
+		return err
+	}
+
+	return audited(s.db, s.audit, "update", model.EntityAuthor, a.ID, func(q queryer) error {
+		version, err := updateVersioned(q, model.EntityAuthor, sb, a.ID, a.Version)
+		if err != nil {
+			return err
+		}
+		a.Version = version
+		return nil
+	})
+}
+
+func (s *DBAuthorStore) DeleteAuthor(id uuid.UUID, version int) error {
+	return softDelete(s.db, s.audit, model.EntityAuthor, id, version)
+}
+
+func (s *DBAuthorStore) RestoreAuthor(id uuid.UUID) error {
+	return restore(s.db, s.audit, model.EntityAuthor, id)
+}
+
+func (s *DBAuthorStore) PurgeAuthor(id uuid.UUID) error {
+	return purge(s.db, s.audit, model.EntityAuthor, id,
+		"SELECT 1 FROM books WHERE author_id = $1",
+		"SELECT 1 FROM book_contributors WHERE author_id = $1")
+}
+
+func (s *DBAuthorStore) DeletedAuthors() ([]model.Author, error) {
+	return selectDeleted[model.Author](s.db, "authors")
+}
+
+// Contributions returns every book the author contributes to, one entry
+// per role. Deleted books are left out.
+func (s *DBAuthorStore) Contributions(authorID uuid.UUID) ([]model.Contributor, error) {
+	return selectContributors(s.db, "bc.author_id", authorID, true)
+}
+
+// BookCounts returns how many books each author contributes to. Authors
+// without books are left out.
+func (s *DBAuthorStore) BookCounts() (map[uuid.UUID]int, error) {
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("bc.author_id AS id", "COUNT(DISTINCT bc.book_id) AS books").
+		From("book_contributors bc").
+		Join("books b", "b.id = bc.book_id").
+		Where(sb.IsNull("b.deleted_at")).
+		GroupBy("bc.author_id")
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return selectCounts(s.db, query, args)
+}
+
+// MergeAuthors folds each merged author into the survivor: their books,
+// contributions and earlier merges move over, the merge is recorded and
+// the merged author is deleted. All merges happen in one transaction.
+func (s *DBAuthorStore) MergeAuthors(survivorID uuid.UUID, mergedIDs []uuid.UUID) ([]model.AuthorMerge, error) {
+	var merges []model.AuthorMerge
+	err := withTx(s.db, func(q queryer) error {
+		for _, mergedID := range mergedIDs {
+			merge, err := mergeAuthor(q, s.audit, survivorID, mergedID)
+			if err != nil {
+				return err
+			}
+			merges = append(merges, merge)
+		}
+		return nil
+	})
+	return merges, err
+}
+
+// mergeAuthor records the merged author's removal and every book it moves
+// as merge changes in the audit log.
+func mergeAuthor(q queryer, audit model.Audit, survivorID, mergedID uuid.UUID) (model.AuthorMerge, error) {
+	merge := model.AuthorMerge{
+		ID:         uuid.New(),
+		SurvivorID: survivorID,
+		MergedID:   mergedID,
+		MergedAt:   time.Now(),
+	}
+	if err := q.Get(&merge.MergedName, "SELECT name FROM authors WHERE id = $1", mergedID); err != nil {
+		return model.AuthorMerge{}, err
+	}
+	var bookIDs []uuid.UUID
+	if err := q.Select(&bookIDs, "SELECT DISTINCT book_id FROM book_contributors WHERE author_id = $1", mergedID); err != nil {
+		return model.AuthorMerge{}, err
+	}
+	merge.BooksMoved = len(bookIDs)
+
+	changes := make([]*change, 0, len(bookIDs)+1)
+	authorChange, err := startChange(q, "merge", model.EntityAuthor, mergedID)
+	if err != nil {
+		return model.AuthorMerge{}, err
+	}
+	changes = append(changes, authorChange)
+	for _, bookID := range bookIDs {
+		c, err := startChange(q, "merge", model.EntityBook, bookID)
+		if err != nil {
+			return model.AuthorMerge{}, err
+		}
+		changes = append(changes, c)
+	}
+
+	if _, err := q.Exec("UPDATE books SET version = version + 1 WHERE id IN (SELECT book_id FROM book_contributors WHERE author_id = $1)", mergedID); err != nil {
+		return model.AuthorMerge{}, err
+	}
+
+	statements := []string{
+		// Where both contribute to a book in the same role, the survivor's
+		// entry already covers it.
+		`DELETE FROM book_contributors m USING book_contributors s
+		 WHERE m.author_id = $2 AND s.author_id = $1 AND s.book_id = m.book_id AND s.role = m.role`,
+		"UPDATE book_contributors SET author_id = $1 WHERE author_id = $2",
+		"UPDATE books SET author_id = $1 WHERE author_id = $2",
+		// Merges into the merged author would go with it, by cascade.
+		"UPDATE author_merges SET survivor_id = $1 WHERE survivor_id = $2",
+	}
+	for _, statement := range statements {
+		if _, err := q.Exec(statement, survivorID, mergedID); err != nil {
+			return model.AuthorMerge{}, err
+		}
+	}
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("author_merges").
+		Cols("id", "survivor_id", "merged_id", "merged_name", "books_moved", "merged_at").
+		Values(merge.ID, merge.SurvivorID, merge.MergedID, merge.MergedName, merge.BooksMoved, merge.MergedAt)
+	query, args := ib.Build()
+	if _, err := q.Exec(query, args...); err != nil {
+		return model.AuthorMerge{}, err
+	}
+
+	db := sqlbuilder.NewDeleteBuilder()
+	db.SetFlavor(sqlbuilder.PostgreSQL)
+	db.DeleteFrom("authors").Where(db.Equal("id", mergedID))
+	query, args = db.Build()
+	if _, err := q.Exec(query, args...); err != nil {
+		return model.AuthorMerge{}, err
+	}
+
+	for _, c := range changes {
+		if err := c.record(q, audit); err != nil {
+			return model.AuthorMerge{}, err
+		}
+	}
+	return merge, nil
 }
 
-func (s *DBAuthorStore) DeleteAuthor(id uuid.UUID) error {
-	sb := sqlbuilder.NewDeleteBuilder()
+func (s *DBAuthorStore) AuthorMerges(survivorID uuid.UUID) ([]model.AuthorMerge, error) {
+	var merges []model.AuthorMerge
+	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.DeleteFrom("authors").Where(sb.Equal("id", id))
+	sb.Select("*").
+		From("author_merges").
+		Where(sb.Equal("survivor_id", survivorID)).
+		OrderBy("merged_at")
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	err := s.db.Select(&merges, query, args...)
+	return merges, err
+}
+
+func selectCounts(q queryer, query string, args []interface{}) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		ID    uuid.UUID `db:"id"`
+		Books int       `db:"books"`
+	}
+	if err := q.Select(&rows, query, args...); err != nil {
+		return nil, err
+	}
+	counts := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		counts[row.ID] = row.Books
+	}
+	return counts, nil
 }
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"errors"
+
 	"github.com/arjunsaxaena/Library-Management/model"
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
@@ -8,7 +10,8 @@ import (
 )
 
 type DBBookStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBBookStore(db *sqlx.DB) *DBBookStore {
@@ -19,7 +22,7 @@ func (s *DBBookStore) Book(id uuid.UUID) (model.Book, error) {
 	var book model.Book
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("books").Where(sb.Equal("id", id))
+	sb.Select("*").From("books").Where(sb.Equal("id", id), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&book, query, args...)
@@ -32,49 +35,345 @@ func (s *DBBookStore) Books() ([]model.Book, error) {
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
 	sb.Select("*").
 		From("books").
-		Where(sb.Equal("is_checked_out", false))
+		Where(sb.Equal("is_checked_out", false), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&books, query, args...)
 	return books, err
 }
 
+// CreateBook inserts the book with its contributors: b.Contributors if
+// given, otherwise b.AuthorID as the only author.
 func (s *DBBookStore) CreateBook(b *model.Book) error {
+	if err := checkTerms(s.db, "", uuid.Nil, nil, termField{"book_type", model.VocabularyBookType, &b.BookType}); err != nil {
+		return err
+	}
+
+	contributors := b.Contributors
+	if len(contributors) == 0 {
+		contributors = []model.Contributor{{AuthorID: b.AuthorID, Role: model.RoleAuthor}}
+	}
+	b.AuthorID = primaryAuthor(contributors)
+	b.Version = 1
+
 	sb := sqlbuilder.NewInsertBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
 	sb.InsertInto("books").
-		Cols("id", "title", "author_id", "location_id", "is_checked_out", "book_type", "created_at").
-		Values(b.ID, b.Title, b.AuthorID, b.LocationID, b.IsCheckedOut, b.BookType, b.CreatedAt)
+		Cols("id", "title", "author_id", "location_id", "is_checked_out", "book_type", "created_at", "barcode", "isbn10", "isbn13",
+			"publisher", "publication_year", "page_count", "cover_url", "version").
+		Values(b.ID, b.Title, b.AuthorID, b.LocationID, b.IsCheckedOut, b.BookType, b.CreatedAt, b.Barcode, b.ISBN10, b.ISBN13,
+			b.Publisher, b.PublicationYear, b.PageCount, b.CoverURL, b.Version)
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "create", model.EntityBook, b.ID, func(q queryer) error {
+		if _, err := q.Exec(query, args...); err != nil {
+			return err
+		}
+		return writeContributors(q, b.ID, contributors)
+	})
+}
+
+// UpdateBook writes the book's fields; with columns, only those. Its
+// contributors are replaced by b.Contributors if given; otherwise a changed
+// AuthorID takes the place of the previous primary author. IsCheckedOut is
+// left alone: only issuing and returning the book change it.
+func (s *DBBookStore) UpdateBook(b *model.Book, columns ...string) error {
+	if err := checkTerms(s.db, model.EntityBook, b.ID, columns, termField{"book_type", model.VocabularyBookType, &b.BookType}); err != nil {
+		return err
+	}
+
+	if len(b.Contributors) > 0 && !writesColumn(columns, "author_id") {
+		columns = append(columns, "author_id")
+	}
+
+	return audited(s.db, s.audit, "update", model.EntityBook, b.ID, func(q queryer) error {
+		contributors := b.Contributors
+		if len(contributors) == 0 && writesColumn(columns, "author_id") {
+			current, err := selectContributors(q, "bc.book_id", b.ID, false)
+			if err != nil {
+				return err
+			}
+			contributors = replacePrimaryAuthor(current, b.AuthorID)
+		}
+		if len(contributors) > 0 {
+			b.AuthorID = primaryAuthor(contributors)
+		}
+
+		sb := sqlbuilder.NewUpdateBuilder()
+		sb.SetFlavor(sqlbuilder.PostgreSQL)
+		sb.Update("books")
+		if err := assignColumns(sb, []column{
+			{"title", b.Title},
+			{"author_id", b.AuthorID},
+			{"location_id", b.LocationID},
+			{"book_type", b.BookType},
+			{"isbn10", b.ISBN10},
+			{"isbn13", b.ISBN13},
+			{"publisher", b.Publisher},
+			{"publication_year", b.PublicationYear},
+			{"page_count", b.PageCount},
+			{"cover_url", b.CoverURL},
+		}, columns); err != nil {
+			return err
+		}
+
+		version, err := updateVersioned(q, model.EntityBook, sb, b.ID, b.Version)
+		if err != nil {
+			return err
+		}
+		b.Version = version
+		if len(contributors) == 0 {
+			return nil
+		}
+		return writeContributors(q, b.ID, contributors)
+	})
+}
+
+func (s *DBBookStore) DeleteBook(id uuid.UUID, version int) error {
+	return softDelete(s.db, s.audit, model.EntityBook, id, version)
+}
+
+func (s *DBBookStore) RestoreBook(id uuid.UUID) error {
+	return restore(s.db, s.audit, model.EntityBook, id)
+}
+
+func (s *DBBookStore) PurgeBook(id uuid.UUID) error {
+	return purge(s.db, s.audit, model.EntityBook, id,
+		"SELECT 1 FROM issued_books WHERE book_id = $1 AND return_date IS NULL")
+}
+
+func (s *DBBookStore) DeletedBooks() ([]model.Book, error) {
+	return selectDeleted[model.Book](s.db, "books")
+}
+
+func (s *DBBookStore) BookByBarcode(barcode string) (model.Book, error) {
+	var book model.Book
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("books").Where(sb.Equal("barcode", barcode), sb.IsNull("deleted_at"))
+
+	query, args := sb.Build()
+	err := s.db.Get(&book, query, args...)
+	return book, err
 }
 
-func (s *DBBookStore) UpdateBook(b *model.Book) error {
+func (s *DBBookStore) BooksWithoutBarcode() ([]model.Book, error) {
+	var books []model.Book
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").
+		From("books").
+		Where(sb.IsNull("barcode")).
+		OrderBy("created_at")
+
+	query, args := sb.Build()
+	err := s.db.Select(&books, query, args...)
+	return books, err
+}
+
+func (s *DBBookStore) NextBarcodeSequence() (int64, error) {
+	var seq int64
+	err := s.db.Get(&seq, "SELECT nextval('item_barcode_seq')")
+	return seq, err
+}
+
+func (s *DBBookStore) SetBarcode(id uuid.UUID, barcode string) error {
 	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
 	sb.Update("books").
-		Set(
-			sb.Assign("title", b.Title),
-			sb.Assign("author_id", b.AuthorID),
-			sb.Assign("location_id", b.LocationID),
-			sb.Assign("is_checked_out", b.IsCheckedOut),
-			sb.Assign("book_type", b.BookType),
-		).
-		Where(sb.Equal("id", b.ID))
+		Set(sb.Assign("barcode", barcode), sb.Incr("version")).
+		Where(sb.Equal("id", id))
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "update", model.EntityBook, id, func(q queryer) error {
+		_, err := q.Exec(query, args...)
+		return err
+	})
 }
 
-func (s *DBBookStore) DeleteBook(id uuid.UUID) error {
-	sb := sqlbuilder.NewDeleteBuilder()
+func (s *DBBookStore) BookByISBN(isbn13 string) (model.Book, error) {
+	var book model.Book
+	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.DeleteFrom("books").Where(sb.Equal("id", id))
+	sb.Select("*").From("books").Where(sb.Equal("isbn13", isbn13), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
+	err := s.db.Get(&book, query, args...)
+	return book, err
+}
+
+// EachBook calls fn for every book in creation order, reading rows as they
+// arrive so the whole catalogue is never held in memory.
+func (s *DBBookStore) EachBook(fn func(model.Book) error) error {
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("books").Where(sb.IsNull("deleted_at")).OrderBy("created_at", "id")
+
+	query, args := sb.Build()
+	rows, err := s.db.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book model.Book
+		if err := rows.StructScan(&book); err != nil {
+			return err
+		}
+		if err := fn(book); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Contributors returns the book's contributors in order, with their names.
+func (s *DBBookStore) Contributors(bookID uuid.UUID) ([]model.Contributor, error) {
+	return selectContributors(s.db, "bc.book_id", bookID, false)
+}
+
+// BooksAtLocation returns the books shelved at the location.
+func (s *DBBookStore) BooksAtLocation(locationID uuid.UUID) ([]model.Book, error) {
+	var books []model.Book
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").
+		From("books").
+		Where(sb.Equal("location_id", locationID), sb.IsNull("deleted_at")).
+		OrderBy("title")
+
+	query, args := sb.Build()
+	err := s.db.Select(&books, query, args...)
+	return books, err
+}
+
+// SetContributors replaces the book's contributors and moves its AuthorID
+// to the new primary author. The contributors are part of the book, so
+// this is versioned like any other update to it.
+func (s *DBBookStore) SetContributors(bookID uuid.UUID, version int, contributors []model.Contributor) error {
+	return audited(s.db, s.audit, "update", model.EntityBook, bookID, func(q queryer) error {
+		ub := sqlbuilder.NewUpdateBuilder()
+		ub.SetFlavor(sqlbuilder.PostgreSQL)
+		ub.Update("books")
+		if _, err := updateVersioned(q, model.EntityBook, ub, bookID, version); err != nil {
+			return err
+		}
+		return writeContributors(q, bookID, contributors)
+	})
+}
+
+func (s *DBBookStore) Subjects(bookID uuid.UUID) ([]model.Subject, error) {
+	return selectFiledSubjects(s.db, "book_subjects", "book_id", bookID)
+}
+
+// SetSubjects replaces the subjects the book is filed under. Like its
+// contributors, they are part of the book and versioned with it.
+func (s *DBBookStore) SetSubjects(bookID uuid.UUID, version int, subjectIDs []uuid.UUID) error {
+	return audited(s.db, s.audit, "update", model.EntityBook, bookID, func(q queryer) error {
+		ub := sqlbuilder.NewUpdateBuilder()
+		ub.SetFlavor(sqlbuilder.PostgreSQL)
+		ub.Update("books")
+		if _, err := updateVersioned(q, model.EntityBook, ub, bookID, version); err != nil {
+			return err
+		}
+		return writeFiledSubjects(q, "book_subjects", "book_id", bookID, subjectIDs)
+	})
+}
+
+func (s *DBBookStore) BooksInSubject(subjectID uuid.UUID, subtree bool) ([]model.Book, error) {
+	return selectFiledUnder[model.Book](s.db, "r.*", "books r", "book_subjects", "book_id", subjectID, subtree)
+}
+
+// selectContributors lists contributor rows matching id. With liveBooks,
+// rows of deleted books are left out.
+func selectContributors(q queryer, column string, id uuid.UUID, liveBooks bool) ([]model.Contributor, error) {
+	var contributors []model.Contributor
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("bc.book_id", "bc.author_id", "a.name", "bc.role", "bc.position").
+		From("book_contributors bc").
+		Join("authors a", "a.id = bc.author_id").
+		Where(sb.Equal(column, id)).
+		OrderBy("bc.book_id", "bc.position")
+	if liveBooks {
+		sb.Join("books b", "b.id = bc.book_id").Where(sb.IsNull("b.deleted_at"))
+	}
+
+	query, args := sb.Build()
+	err := q.Select(&contributors, query, args...)
+	return contributors, err
+}
+
+// writeContributors stores contributors as the book's complete list,
+// numbering positions in slice order.
+func writeContributors(q queryer, bookID uuid.UUID, contributors []model.Contributor) error {
+	if len(contributors) == 0 {
+		return errors.New("a book needs at least one contributor")
+	}
+
+	db := sqlbuilder.NewDeleteBuilder()
+	db.SetFlavor(sqlbuilder.PostgreSQL)
+	db.DeleteFrom("book_contributors").Where(db.Equal("book_id", bookID))
+	query, args := db.Build()
+	if _, err := q.Exec(query, args...); err != nil {
+		return err
+	}
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("book_contributors").Cols("book_id", "author_id", "role", "position")
+	for i, c := range contributors {
+		ib.Values(bookID, c.AuthorID, c.Role, i)
+	}
+	query, args = ib.Build()
+	if _, err := q.Exec(query, args...); err != nil {
+		return err
+	}
+
+	ub := sqlbuilder.NewUpdateBuilder()
+	ub.SetFlavor(sqlbuilder.PostgreSQL)
+	ub.Update("books").Set(ub.Assign("author_id", primaryAuthor(contributors))).Where(ub.Equal("id", bookID))
+	query, args = ub.Build()
+	_, err := q.Exec(query, args...)
 	return err
 }
+
+// primaryIndex finds the first contributor in the author role, or the
+// first contributor if none is an author.
+func primaryIndex(contributors []model.Contributor) int {
+	for i, c := range contributors {
+		if c.Role == model.RoleAuthor {
+			return i
+		}
+	}
+	return 0
+}
+
+func primaryAuthor(contributors []model.Contributor) uuid.UUID {
+	return contributors[primaryIndex(contributors)].AuthorID
+}
+
+// replacePrimaryAuthor puts authorID in the primary author's place,
+// dropping any other entry of it in the same role.
+func replacePrimaryAuthor(contributors []model.Contributor, authorID uuid.UUID) []model.Contributor {
+	if len(contributors) == 0 {
+		return []model.Contributor{{AuthorID: authorID, Role: model.RoleAuthor}}
+	}
+	i := primaryIndex(contributors)
+	primary := contributors[i]
+	if primary.AuthorID == authorID {
+		return contributors
+	}
+
+	replaced := make([]model.Contributor, 0, len(contributors))
+	for j, c := range contributors {
+		if j == i {
+			c.AuthorID = authorID
+		} else if c.AuthorID == authorID && c.Role == primary.Role {
+			continue
+		}
+		replaced = append(replaced, c)
+	}
+	return replaced
+}
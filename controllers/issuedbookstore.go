@@ -9,106 +9,129 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// accruedFees is the fee of a loan: accruing while it is open, as charged
+// once it is returned.
+const accruedFees = "CASE " +
+	"WHEN return_date IS NULL THEN GREATEST(0, (EXTRACT(DAY FROM (CURRENT_DATE - issue_date)) - 15) * 2) " +
+	"ELSE COALESCE(late_fees, 0) " +
+	"END"
+
 type DBIssuedBookStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBIssuedBookStore(db *sqlx.DB) *DBIssuedBookStore {
 	return &DBIssuedBookStore{db: db}
 }
 
+// CreateIssuedBook records the loan and checks the book out, auditing
+// both as an issue.
 func (s *DBIssuedBookStore) CreateIssuedBook(issuedBook *model.IssuedBook) error {
-	tx := s.db.MustBegin()
-	defer tx.Rollback()
+	issuedBook.Version = 1
+	return withTx(s.db, func(q queryer) error {
+		loanChange, err := startChange(q, "issue", model.EntityLoan, issuedBook.ID)
+		if err != nil {
+			return err
+		}
+		bookChange, err := startChange(q, "issue", model.EntityBook, issuedBook.BookID)
+		if err != nil {
+			return err
+		}
 
-	sbInsert := sqlbuilder.NewInsertBuilder()
-	sbInsert.SetFlavor(sqlbuilder.PostgreSQL)
-	sbInsert.InsertInto("issued_books").
-		Cols("id", "book_id", "user_id", "issue_date").
-		Values(issuedBook.ID, issuedBook.BookID, issuedBook.UserID, issuedBook.IssueDate)
+		sbInsert := sqlbuilder.NewInsertBuilder()
+		sbInsert.SetFlavor(sqlbuilder.PostgreSQL)
+		sbInsert.InsertInto("issued_books").
+			Cols("id", "book_id", "user_id", "issue_date", "version").
+			Values(issuedBook.ID, issuedBook.BookID, issuedBook.UserID, issuedBook.IssueDate, issuedBook.Version)
 
-	queryInsert, argsInsert := sbInsert.Build()
-	_, err := tx.Exec(queryInsert, argsInsert...)
-	if err != nil {
-		return err
-	}
+		queryInsert, argsInsert := sbInsert.Build()
+		if _, err := q.Exec(queryInsert, argsInsert...); err != nil {
+			return err
+		}
 
-	sbUpdate := sqlbuilder.NewUpdateBuilder()
-	sbUpdate.SetFlavor(sqlbuilder.PostgreSQL)
-	sbUpdate.Update("books").
-		Set(sbUpdate.Assign("is_checked_out", true)).
-		Where(sbUpdate.Equal("id", issuedBook.BookID))
+		sbUpdate := sqlbuilder.NewUpdateBuilder()
+		sbUpdate.SetFlavor(sqlbuilder.PostgreSQL)
+		sbUpdate.Update("books").
+			Set(sbUpdate.Assign("is_checked_out", true), sbUpdate.Incr("version")).
+			Where(sbUpdate.Equal("id", issuedBook.BookID))
 
-	queryUpdate, argsUpdate := sbUpdate.Build()
-	_, err = tx.Exec(queryUpdate, argsUpdate...)
-	if err != nil {
-		return err
-	}
+		queryUpdate, argsUpdate := sbUpdate.Build()
+		if _, err := q.Exec(queryUpdate, argsUpdate...); err != nil {
+			return err
+		}
 
-	return tx.Commit()
+		if err := loanChange.record(q, s.audit); err != nil {
+			return err
+		}
+		return bookChange.record(q, s.audit)
+	})
 }
 
+// ReturnBook closes the book's open loan with its late fees and checks the
+// book back in, auditing both as a return.
 func (s *DBIssuedBookStore) ReturnBook(bookID uuid.UUID) (float64, error) {
-	tx := s.db.MustBegin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	var lateFees float64
+	err := withTx(s.db, func(q queryer) error {
+		var issuedBook model.IssuedBook
+		sbSelect := sqlbuilder.NewSelectBuilder()
+		sbSelect.SetFlavor(sqlbuilder.PostgreSQL)
+		sbSelect.Select("*").
+			From("issued_books").
+			Where(sbSelect.Equal("book_id", bookID)).
+			Where(sbSelect.IsNull("return_date"))
 
-	var issuedBook model.IssuedBook
-	sbSelect := sqlbuilder.NewSelectBuilder()
-	sbSelect.SetFlavor(sqlbuilder.PostgreSQL)
-	sbSelect.Select("*").
-		From("issued_books").
-		Where(sbSelect.Equal("book_id", bookID)).
-		Where(sbSelect.IsNull("return_date"))
+		querySelect, argsSelect := sbSelect.Build()
+		if err := q.Get(&issuedBook, querySelect, argsSelect...); err != nil {
+			return err
+		}
 
-	querySelect, argsSelect := sbSelect.Build()
-	err := s.db.Get(&issuedBook, querySelect, argsSelect...)
-	if err != nil {
-		return 0, err
-	}
+		loanChange, err := startChange(q, "return", model.EntityLoan, issuedBook.ID)
+		if err != nil {
+			return err
+		}
+		bookChange, err := startChange(q, "return", model.EntityBook, bookID)
+		if err != nil {
+			return err
+		}
 
-	var lateFees float64
-	daysLate := time.Since(issuedBook.IssueDate).Hours() / 24
-	if daysLate > 15 {
-		lateFees = (daysLate - 15) * 2
-	}
+		daysLate := time.Since(issuedBook.IssueDate).Hours() / 24
+		if daysLate > 15 {
+			lateFees = (daysLate - 15) * 2
+		}
 
-	currentTime := time.Now()
+		sbUpdateReturn := sqlbuilder.NewUpdateBuilder()
+		sbUpdateReturn.SetFlavor(sqlbuilder.PostgreSQL)
+		sbUpdateReturn.Update("issued_books").Set(
+			sbUpdateReturn.Assign("return_date", time.Now()),
+			sbUpdateReturn.Assign("late_fees", lateFees),
+			sbUpdateReturn.Incr("version"),
+		).Where(sbUpdateReturn.Equal("id", issuedBook.ID))
 
-	sbUpdateReturn := sqlbuilder.NewUpdateBuilder()
-	sbUpdateReturn.SetFlavor(sqlbuilder.PostgreSQL)
-	sbUpdateReturn.Update("issued_books").Set(
-		sbUpdateReturn.Assign("return_date", currentTime),
-		sbUpdateReturn.Assign("late_fees", lateFees),
-	).Where(sbUpdateReturn.Equal("book_id", bookID))
+		queryUpdateReturn, argsUpdateReturn := sbUpdateReturn.Build()
+		if _, err := q.Exec(queryUpdateReturn, argsUpdateReturn...); err != nil {
+			return err
+		}
 
-	queryUpdateReturn, argsUpdateReturn := sbUpdateReturn.Build()
-	_, err = tx.Exec(queryUpdateReturn, argsUpdateReturn...)
-	if err != nil {
-		tx.Rollback()
-		return 0, err
-	}
+		sbUpdateBook := sqlbuilder.NewUpdateBuilder()
+		sbUpdateBook.SetFlavor(sqlbuilder.PostgreSQL)
+		sbUpdateBook.Update("books").
+			Set(sbUpdateBook.Assign("is_checked_out", false), sbUpdateBook.Incr("version")).
+			Where(sbUpdateBook.Equal("id", bookID))
 
-	sbUpdateBook := sqlbuilder.NewUpdateBuilder()
-	sbUpdateBook.SetFlavor(sqlbuilder.PostgreSQL)
-	sbUpdateBook.Update("books").
-		Set(sbUpdateBook.Assign("is_checked_out", false)).
-		Where(sbUpdateBook.Equal("id", bookID))
+		queryUpdateBook, argsUpdateBook := sbUpdateBook.Build()
+		if _, err := q.Exec(queryUpdateBook, argsUpdateBook...); err != nil {
+			return err
+		}
 
-	queryUpdateBook, argsUpdateBook := sbUpdateBook.Build()
-	_, err = tx.Exec(queryUpdateBook, argsUpdateBook...)
+		if err := loanChange.record(q, s.audit); err != nil {
+			return err
+		}
+		return bookChange.record(q, s.audit)
+	})
 	if err != nil {
-		tx.Rollback()
 		return 0, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return 0, err
-	}
-
 	return lateFees, nil
 }
 
@@ -124,13 +147,14 @@ func (s *DBIssuedBookStore) GetIssuedBookByBookID(bookID uuid.UUID) (model.Issue
 		"user_id",
 		"issue_date",
 		"return_date",
-		"CASE "+
-			"WHEN return_date IS NULL THEN GREATEST(0, (EXTRACT(DAY FROM (CURRENT_DATE - issue_date)) - 15) * 2) "+
-			"ELSE late_fees "+
-			"END AS late_fees",
+		accruedFees+" AS late_fees",
+		"fees_paid_at",
+		"version",
 	).
 		From("issued_books").
-		Where(sb.Equal("book_id", bookID))
+		Where(sb.Equal("book_id", bookID)).
+		OrderBy("issue_date").Desc().
+		Limit(1)
 
 	query, args := sb.Build()
 	err := s.db.Get(&issuedBook, query, args...)
@@ -149,10 +173,9 @@ func (s *DBIssuedBookStore) IssuedBooks() ([]model.IssuedBook, error) {
 		"user_id",
 		"issue_date",
 		"return_date",
-		"CASE "+
-			"WHEN return_date IS NULL THEN GREATEST(0, (EXTRACT(DAY FROM (CURRENT_DATE - issue_date)) - 15) * 2) "+
-			"ELSE late_fees "+
-			"END AS late_fees",
+		accruedFees+" AS late_fees",
+		"fees_paid_at",
+		"version",
 	).
 		From("issued_books").
 		Where(sb.IsNull("return_date"))
@@ -162,6 +185,101 @@ func (s *DBIssuedBookStore) IssuedBooks() ([]model.IssuedBook, error) {
 	return issuedBooks, err
 }
 
+// Books currently issued to one user
+
+func (s *DBIssuedBookStore) IssuedBooksByUser(userID uuid.UUID) ([]model.IssuedBook, error) {
+	var issuedBooks []model.IssuedBook
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select(
+		"id",
+		"book_id",
+		"user_id",
+		"issue_date",
+		"return_date",
+		accruedFees+" AS late_fees",
+		"fees_paid_at",
+		"version",
+	).
+		From("issued_books").
+		Where(
+			sb.Equal("user_id", userID),
+			sb.IsNull("return_date"),
+		).
+		OrderBy("issue_date")
+
+	query, args := sb.Build()
+	err := s.db.Select(&issuedBooks, query, args...)
+	return issuedBooks, err
+}
+
+func (s *DBIssuedBookStore) UnpaidFees(userID uuid.UUID) (float64, error) {
+	var fees float64
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("COALESCE(SUM("+accruedFees+"), 0)").
+		From("issued_books").
+		Where(
+			sb.Equal("user_id", userID),
+			sb.IsNull("fees_paid_at"),
+		)
+
+	query, args := sb.Build()
+	err := s.db.Get(&fees, query, args...)
+	return fees, err
+}
+
+// PayFees audits each loan it settles as a payment.
+func (s *DBIssuedBookStore) PayFees(userID uuid.UUID) (float64, error) {
+	var paid float64
+	err := withTx(s.db, func(q queryer) error {
+		var loans []model.IssuedBook
+		sbSelect := sqlbuilder.NewSelectBuilder()
+		sbSelect.SetFlavor(sqlbuilder.PostgreSQL)
+		sbSelect.Select("*").
+			From("issued_books").
+			Where(
+				sbSelect.Equal("user_id", userID),
+				sbSelect.IsNotNull("return_date"),
+				sbSelect.IsNull("fees_paid_at"),
+				sbSelect.GreaterThan("late_fees", 0),
+			).
+			ForUpdate()
+
+		querySelect, argsSelect := sbSelect.Build()
+		if err := q.Select(&loans, querySelect, argsSelect...); err != nil {
+			return err
+		}
+
+		for _, loan := range loans {
+			loanChange, err := startChange(q, "pay", model.EntityLoan, loan.ID)
+			if err != nil {
+				return err
+			}
+
+			sbUpdate := sqlbuilder.NewUpdateBuilder()
+			sbUpdate.SetFlavor(sqlbuilder.PostgreSQL)
+			sbUpdate.Update("issued_books").
+				Set(sbUpdate.Assign("fees_paid_at", time.Now()), sbUpdate.Incr("version")).
+				Where(sbUpdate.Equal("id", loan.ID))
+
+			queryUpdate, argsUpdate := sbUpdate.Build()
+			if _, err := q.Exec(queryUpdate, argsUpdate...); err != nil {
+				return err
+			}
+			if err := loanChange.record(q, s.audit); err != nil {
+				return err
+			}
+			paid += loan.LateFees
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return paid, nil
+}
+
 func (s *DBIssuedBookStore) DeleteIssuedBook(id uuid.UUID) error {
 	sb := sqlbuilder.NewDeleteBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
@@ -169,6 +287,8 @@ func (s *DBIssuedBookStore) DeleteIssuedBook(id uuid.UUID) error {
 		Where(sb.Equal("id", id))
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "purge", model.EntityLoan, id, func(q queryer) error {
+		_, err := q.Exec(query, args...)
+		return err
+	})
 }
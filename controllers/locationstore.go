@@ -8,7 +8,8 @@ import (
 )
 
 type DBLocationStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBLocationStore(db *sqlx.DB) *DBLocationStore {
@@ -19,7 +20,7 @@ func (s *DBLocationStore) Location(id uuid.UUID) (model.Location, error) {
 	var location model.Location
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("locations").Where(sb.Equal("id", id))
+	sb.Select("*").From("locations").Where(sb.Equal("id", id), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&location, query, args...)
@@ -30,7 +31,7 @@ func (s *DBLocationStore) Locations() ([]model.Location, error) {
 	var locations []model.Location
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("locations")
+	sb.Select("*").From("locations").Where(sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&locations, query, args...)
@@ -40,33 +41,65 @@ func (s *DBLocationStore) Locations() ([]model.Location, error) {
 func (s *DBLocationStore) CreateLocation(l *model.Location) error {
 	sb := sqlbuilder.NewInsertBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	l.Version = 1
 	sb.InsertInto("locations").
-		Cols("id", "name").
-		Values(l.ID, l.Name)
+		Cols("id", "name", "version").
+		Values(l.ID, l.Name, l.Version)
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "create", model.EntityLocation, l.ID, func(q queryer) error {
+		_, err := q.Exec(query, args...)
+		return err
+	})
 }
 
-func (s *DBLocationStore) UpdateLocation(l *model.Location) error {
+func (s *DBLocationStore) UpdateLocation(l *model.Location, columns ...string) error {
 	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Update("locations").
-		Set(sb.Assign("name", l.Name)).
-		Where(sb.Equal("id", l.ID))
+	sb.Update("locations")
+	if err := assignColumns(sb, []column{
+		{"name", l.Name},
+	}, columns); err != nil {
+		return err
+	}
 
-	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "update", model.EntityLocation, l.ID, func(q queryer) error {
+		version, err := updateVersioned(q, model.EntityLocation, sb, l.ID, l.Version)
+		if err != nil {
+			return err
+		}
+		l.Version = version
+		return nil
+	})
+}
+
+func (s *DBLocationStore) DeleteLocation(id uuid.UUID, version int) error {
+	return softDelete(s.db, s.audit, model.EntityLocation, id, version)
+}
+
+func (s *DBLocationStore) RestoreLocation(id uuid.UUID) error {
+	return restore(s.db, s.audit, model.EntityLocation, id)
 }
 
-func (s *DBLocationStore) DeleteLocation(id uuid.UUID) error {
-	sb := sqlbuilder.NewDeleteBuilder()
+func (s *DBLocationStore) PurgeLocation(id uuid.UUID) error {
+	return purge(s.db, s.audit, model.EntityLocation, id,
+		"SELECT 1 FROM books WHERE location_id = $1")
+}
+
+func (s *DBLocationStore) DeletedLocations() ([]model.Location, error) {
+	return selectDeleted[model.Location](s.db, "locations")
+}
+
+// BookCounts returns how many books are shelved at each location.
+// Locations without books are left out.
+func (s *DBLocationStore) BookCounts() (map[uuid.UUID]int, error) {
+	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.DeleteFrom("locations").Where(sb.Equal("id", id))
+	sb.Select("location_id AS id", "COUNT(*) AS books").
+		From("books").
+		Where(sb.IsNotNull("location_id"), sb.IsNull("deleted_at")).
+		GroupBy("location_id")
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return selectCounts(s.db, query, args)
 }
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/arjunsaxaena/Library-Management/model"
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
@@ -8,18 +10,27 @@ import (
 )
 
 type DBMaterialStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBMaterialStore(db *sqlx.DB) *DBMaterialStore {
 	return &DBMaterialStore{db: db}
 }
 
-func (s *DBMaterialStore) Material(id uuid.UUID) (model.Material, error) {
-	var material model.Material
+// selectMaterials starts a query for materials, m, with the name of their
+// subject, s.
+func selectMaterials() *sqlbuilder.SelectBuilder {
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("materials").Where(sb.Equal("id", id))
+	sb.Select("m.*", "s.name AS subject_name").From("materials m").Join("subjects s", "s.id = m.subject_id")
+	return sb
+}
+
+func (s *DBMaterialStore) Material(id uuid.UUID) (model.Material, error) {
+	var material model.Material
+	sb := selectMaterials()
+	sb.Where(sb.Equal("m.id", id), sb.IsNull("m.deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&material, query, args...)
@@ -28,9 +39,8 @@ func (s *DBMaterialStore) Material(id uuid.UUID) (model.Material, error) {
 
 func (s *DBMaterialStore) Materials() ([]model.Material, error) {
 	var materials []model.Material
-	sb := sqlbuilder.NewSelectBuilder()
-	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("materials")
+	sb := selectMaterials()
+	sb.Where(sb.IsNull("m.deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&materials, query, args...)
@@ -38,52 +48,102 @@ func (s *DBMaterialStore) Materials() ([]model.Material, error) {
 }
 
 func (s *DBMaterialStore) CreateMaterial(material *model.Material) error {
+	if err := checkTerms(s.db, "", uuid.Nil, nil,
+		termField{"type", model.VocabularyMaterialType, &material.Type},
+		termField{"language", model.VocabularyLanguage, &material.Language}); err != nil {
+		return err
+	}
+
 	sb := sqlbuilder.NewInsertBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	material.Version = 1
 	sb.InsertInto("materials").Cols(
-		"id", "title", "description", "notes", "type", "link", "language", "subject_name", "created_at",
+		"id", "title", "description", "notes", "type", "link", "language", "subject_id", "created_at", "version",
 	).Values(
-		material.ID, material.Title, material.Description, material.Notes, material.Type, material.Link, material.Language, material.SubjectName, material.CreatedAt,
+		material.ID, material.Title, material.Description, material.Notes, material.Type, material.Link, material.Language, material.SubjectID, material.CreatedAt, material.Version,
 	)
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "create", model.EntityMaterial, material.ID, func(q queryer) error {
+		if _, err := q.Exec(query, args...); err != nil {
+			return err
+		}
+		if err := fileUnderOwnSubject(q, material.ID); err != nil {
+			return err
+		}
+		return recordRevision(q, s.audit, material.ID, "")
+	})
 }
 
-func (s *DBMaterialStore) UpdateMaterial(material *model.Material) error {
+func (s *DBMaterialStore) UpdateMaterial(material *model.Material, columns ...string) error {
+	return s.updateMaterial(material, "", columns...)
+}
+
+// updateMaterial updates the material and records the revision it makes
+// with the given summary, or one naming the fields it changed.
+func (s *DBMaterialStore) updateMaterial(material *model.Material, summary string, columns ...string) error {
+	if err := checkTerms(s.db, model.EntityMaterial, material.ID, columns,
+		termField{"type", model.VocabularyMaterialType, &material.Type},
+		termField{"language", model.VocabularyLanguage, &material.Language}); err != nil {
+		return err
+	}
+
 	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Update("materials").Set(
-		sb.Assign("title", material.Title),
-		sb.Assign("description", material.Description),
-		sb.Assign("notes", material.Notes),
-		sb.Assign("type", material.Type),
-		sb.Assign("link", material.Link),
-		sb.Assign("language", material.Language),
-		sb.Assign("subject_name", material.SubjectName),
-	).Where(sb.Equal("id", material.ID))
+	sb.Update("materials")
+	if err := assignColumns(sb, []column{
+		{"title", material.Title},
+		{"description", material.Description},
+		{"notes", material.Notes},
+		{"type", material.Type},
+		{"link", material.Link},
+		{"language", material.Language},
+		{"subject_id", material.SubjectID},
+	}, columns); err != nil {
+		return err
+	}
 
-	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "update", model.EntityMaterial, material.ID, func(q queryer) error {
+		version, err := updateVersioned(q, model.EntityMaterial, sb, material.ID, material.Version)
+		if err != nil {
+			return err
+		}
+		material.Version = version
+		if writesColumn(columns, "subject_id") {
+			if err := fileUnderOwnSubject(q, material.ID); err != nil {
+				return err
+			}
+		}
+		return recordRevision(q, s.audit, material.ID, summary)
+	})
 }
 
-func (s *DBMaterialStore) DeleteMaterial(id uuid.UUID) error {
-	sb := sqlbuilder.NewDeleteBuilder()
-	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.DeleteFrom("materials").Where(sb.Equal("id", id))
+func (s *DBMaterialStore) DeleteMaterial(id uuid.UUID, version int) error {
+	return softDelete(s.db, s.audit, model.EntityMaterial, id, version)
+}
+
+func (s *DBMaterialStore) RestoreMaterial(id uuid.UUID) error {
+	return restore(s.db, s.audit, model.EntityMaterial, id)
+}
+
+func (s *DBMaterialStore) PurgeMaterial(id uuid.UUID) error {
+	return purge(s.db, s.audit, model.EntityMaterial, id)
+}
+
+func (s *DBMaterialStore) DeletedMaterials() ([]model.Material, error) {
+	var materials []model.Material
+	sb := selectMaterials()
+	sb.Where(sb.IsNotNull("m.deleted_at")).OrderBy("m.deleted_at").Desc()
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	err := s.db.Select(&materials, query, args...)
+	return materials, err
 }
 
 func (s *DBMaterialStore) GetMaterialsBySubject(subjectName string) ([]model.Material, error) {
 	var materials []model.Material
-	sb := sqlbuilder.NewSelectBuilder()
-	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("materials").Where(sb.Equal("subject_name", subjectName))
+	sb := selectMaterials()
+	sb.Where(sb.Equal("s.name", subjectName), sb.IsNull("m.deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&materials, query, args...)
@@ -92,11 +152,220 @@ func (s *DBMaterialStore) GetMaterialsBySubject(subjectName string) ([]model.Mat
 
 func (s *DBMaterialStore) GetMaterialsByLanguage(language string) ([]model.Material, error) {
 	var materials []model.Material
+	sb := selectMaterials()
+	sb.Where(sb.Equal("m.language", language), sb.IsNull("m.deleted_at"))
+
+	query, args := sb.Build()
+	err := s.db.Select(&materials, query, args...)
+	return materials, err
+}
+
+func (s *DBMaterialStore) Subjects(materialID uuid.UUID) ([]model.Subject, error) {
+	return selectFiledSubjects(s.db, "material_subjects", "material_id", materialID)
+}
+
+// SetSubjects replaces the subjects the material is filed under, keeping
+// its own.
+func (s *DBMaterialStore) SetSubjects(materialID uuid.UUID, version int, subjectIDs []uuid.UUID) error {
+	return audited(s.db, s.audit, "update", model.EntityMaterial, materialID, func(q queryer) error {
+		ub := sqlbuilder.NewUpdateBuilder()
+		ub.SetFlavor(sqlbuilder.PostgreSQL)
+		ub.Update("materials")
+		if _, err := updateVersioned(q, model.EntityMaterial, ub, materialID, version); err != nil {
+			return err
+		}
+		if err := writeFiledSubjects(q, "material_subjects", "material_id", materialID, subjectIDs); err != nil {
+			return err
+		}
+		return fileUnderOwnSubject(q, materialID)
+	})
+}
+
+func (s *DBMaterialStore) MaterialsInSubject(subjectID uuid.UUID, subtree bool) ([]model.Material, error) {
+	return selectFiledUnder[model.Material](s.db, "r.*, s.name AS subject_name",
+		"materials r JOIN subjects s ON s.id = r.subject_id", "material_subjects", "material_id", subjectID, subtree)
+}
+
+// ReassignMaterials moves the live materials of one subject to another. A
+// moved material is filed under its new subject in place of the old one,
+// and each move is recorded in the audit log and as a revision.
+func (s *DBMaterialStore) ReassignMaterials(fromSubjectID, toSubjectID uuid.UUID) (int, error) {
+	var materialIDs []uuid.UUID
+	err := withTx(s.db, func(q queryer) error {
+		if err := q.Select(&materialIDs, "SELECT id FROM materials WHERE subject_id = $1 AND deleted_at IS NULL", fromSubjectID); err != nil {
+			return err
+		}
+
+		changes := make([]*change, 0, len(materialIDs))
+		for _, materialID := range materialIDs {
+			c, err := startChange(q, "update", model.EntityMaterial, materialID)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, c)
+		}
+
+		if _, err := q.Exec("UPDATE materials SET subject_id = $2, version = version + 1 WHERE subject_id = $1 AND deleted_at IS NULL",
+			fromSubjectID, toSubjectID); err != nil {
+			return err
+		}
+		for _, materialID := range materialIDs {
+			if _, err := q.Exec("DELETE FROM material_subjects WHERE material_id = $1 AND subject_id = $2", materialID, fromSubjectID); err != nil {
+				return err
+			}
+			if err := fileUnderOwnSubject(q, materialID); err != nil {
+				return err
+			}
+			if err := recordRevision(q, s.audit, materialID, ""); err != nil {
+				return err
+			}
+		}
+
+		for _, c := range changes {
+			if err := c.record(q, s.audit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(materialIDs), nil
+}
+
+func (s *DBMaterialStore) Files(materialID uuid.UUID) ([]model.MaterialFile, error) {
+	files := []model.MaterialFile{}
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("materials").Where(sb.Equal("language", language))
+	sb.Select("*").From("material_files").Where(sb.Equal("material_id", materialID)).OrderBy("created_at", "filename")
+
+	query, args := sb.Build()
+	err := s.db.Select(&files, query, args...)
+	return files, err
+}
+
+func (s *DBMaterialStore) File(materialID, fileID uuid.UUID) (model.MaterialFile, error) {
+	var file model.MaterialFile
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("material_files").Where(sb.Equal("id", fileID), sb.Equal("material_id", materialID))
+
+	query, args := sb.Build()
+	err := s.db.Get(&file, query, args...)
+	return file, err
+}
+
+func (s *DBMaterialStore) AddFile(f *model.MaterialFile, version int) error {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("material_files").
+		Cols("id", "material_id", "sha256", "filename", "content_type", "size", "created_at").
+		Values(f.ID, f.MaterialID, f.SHA256, f.Filename, f.ContentType, f.Size, f.CreatedAt)
+	query, args := ib.Build()
+
+	return audited(s.db, s.audit, "update", model.EntityMaterial, f.MaterialID, func(q queryer) error {
+		ub := sqlbuilder.NewUpdateBuilder()
+		ub.SetFlavor(sqlbuilder.PostgreSQL)
+		ub.Update("materials")
+		if _, err := updateVersioned(q, model.EntityMaterial, ub, f.MaterialID, version); err != nil {
+			return err
+		}
+		_, err := q.Exec(query, args...)
+		return err
+	})
+}
+
+func (s *DBMaterialStore) RemoveFile(materialID, fileID uuid.UUID, version int) error {
+	db := sqlbuilder.NewDeleteBuilder()
+	db.SetFlavor(sqlbuilder.PostgreSQL)
+	db.DeleteFrom("material_files").Where(db.Equal("id", fileID), db.Equal("material_id", materialID))
+	query, args := db.Build()
+
+	return audited(s.db, s.audit, "update", model.EntityMaterial, materialID, func(q queryer) error {
+		ub := sqlbuilder.NewUpdateBuilder()
+		ub.SetFlavor(sqlbuilder.PostgreSQL)
+		ub.Update("materials")
+		if _, err := updateVersioned(q, model.EntityMaterial, ub, materialID, version); err != nil {
+			return err
+		}
+		res, err := q.Exec(query, args...)
+		return affectedOne(res, err)
+	})
+}
+
+// LockContent takes the digest's row lock, adding the row if there is
+// none; the no-op update locks an existing row just as the insert locks a
+// new one.
+func (s *DBMaterialStore) LockContent(sha256 string) error {
+	_, err := s.db.Exec("INSERT INTO material_contents (sha256) VALUES ($1) ON CONFLICT (sha256) DO UPDATE SET sha256 = EXCLUDED.sha256", sha256)
+	return err
+}
+
+func (s *DBMaterialStore) ReleaseContent(sha256 string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM material_contents WHERE sha256 = $1 AND NOT EXISTS (SELECT 1 FROM material_files WHERE sha256 = $1)", sha256)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// joinLinkChecks adds the check of each material's current link, lc, to
+// a query for materials, if there is one.
+func joinLinkChecks(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
+	return sb.JoinWithOption(sqlbuilder.LeftJoin, "material_link_checks lc", "lc.material_id = m.id", "lc.url = m.link")
+}
+
+func (s *DBMaterialStore) MaterialsByLinkStatus(status string) ([]model.Material, error) {
+	var materials []model.Material
+	sb := joinLinkChecks(selectMaterials())
+	sb.Where(sb.IsNull("m.deleted_at"), sb.NotEqual("m.link", ""))
+	if status == model.LinkStatusUnchecked {
+		sb.Where(sb.IsNull("lc.material_id"))
+	} else {
+		sb.Where(sb.Equal("lc.status", status))
+	}
+	sb.OrderBy("m.title")
 
 	query, args := sb.Build()
 	err := s.db.Select(&materials, query, args...)
 	return materials, err
 }
+
+func (s *DBMaterialStore) LinkCheck(materialID uuid.UUID) (model.LinkCheck, error) {
+	var check model.LinkCheck
+	err := s.db.Get(&check, `SELECT lc.* FROM material_link_checks lc
+JOIN materials m ON m.id = lc.material_id AND m.link = lc.url
+WHERE lc.material_id = $1`, materialID)
+	return check, err
+}
+
+func (s *DBMaterialStore) LinksDue(checkedBefore time.Time, limit int) ([]model.Material, error) {
+	var materials []model.Material
+	sb := joinLinkChecks(selectMaterials())
+	sb.Where(sb.IsNull("m.deleted_at"), sb.NotEqual("m.link", ""),
+		sb.Or(sb.IsNull("lc.checked_at"), sb.LessThan("lc.checked_at", checkedBefore)))
+	sb.OrderBy("lc.checked_at NULLS FIRST", "m.id").Limit(limit)
+
+	query, args := sb.Build()
+	err := s.db.Select(&materials, query, args...)
+	return materials, err
+}
+
+func (s *DBMaterialStore) RecordLinkCheck(c *model.LinkCheck) error {
+	_, err := s.db.Exec(`INSERT INTO material_link_checks (material_id, url, status, status_code, final_url, error, checked_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (material_id) DO UPDATE SET url = EXCLUDED.url, status = EXCLUDED.status, status_code = EXCLUDED.status_code,
+	final_url = EXCLUDED.final_url, error = EXCLUDED.error, checked_at = EXCLUDED.checked_at`,
+		c.MaterialID, c.URL, c.Status, c.StatusCode, c.FinalURL, c.Error, c.CheckedAt)
+	return err
+}
+
+// fileUnderOwnSubject files the material under the subject it belongs to.
+func fileUnderOwnSubject(q queryer, materialID uuid.UUID) error {
+	_, err := q.Exec(`INSERT INTO material_subjects (material_id, subject_id)
+SELECT id, subject_id FROM materials WHERE id = $1
+ON CONFLICT DO NOTHING`, materialID)
+	return err
+}
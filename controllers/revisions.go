@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// revisionChanges lists the content fields that differ from one revision
+// to another.
+func revisionChanges(from, to model.MaterialRevision) []model.FieldChange {
+	changes := []model.FieldChange{}
+	for _, f := range []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"description", from.Description, to.Description},
+		{"notes", from.Notes, to.Notes},
+		{"type", from.Type, to.Type},
+		{"link", from.Link, to.Link},
+		{"language", from.Language, to.Language},
+		{"subject_id", from.SubjectID.String(), to.SubjectID.String()},
+	} {
+		if f.from != f.to {
+			changes = append(changes, model.FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+// recordRevision adds a revision of the material's content as it now is,
+// unless that is the content of its latest revision. summary says what the
+// write did, or when empty is worked out from the fields it changed; the
+// audit reason is added to it.
+func recordRevision(q queryer, audit model.Audit, materialID uuid.UUID, summary string) error {
+	var current model.MaterialRevision
+	err := q.Get(&current, `SELECT id AS material_id, version, title, COALESCE(description, '') AS description,
+COALESCE(notes, '') AS notes, type, link, language, subject_id FROM materials WHERE id = $1`, materialID)
+	if err != nil {
+		return err
+	}
+
+	var latest model.MaterialRevision
+	err = q.Get(&latest, "SELECT * FROM material_revisions WHERE material_id = $1 ORDER BY revision DESC LIMIT 1", materialID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if summary == "" {
+			summary = "Created"
+		}
+	case err != nil:
+		return err
+	default:
+		changes := revisionChanges(latest, current)
+		if len(changes) == 0 {
+			return nil
+		}
+		if summary == "" {
+			fields := make([]string, len(changes))
+			for i, c := range changes {
+				fields[i] = c.Field
+			}
+			summary = "Changed " + strings.Join(fields, ", ")
+		}
+	}
+	if audit.Reason != "" {
+		summary += ": " + audit.Reason
+	}
+	author := audit.Actor
+	if author == "" {
+		author = systemActor
+	}
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("material_revisions").
+		Cols("material_id", "revision", "version", "title", "description", "notes", "type", "link", "language", "subject_id", "author", "summary").
+		Values(materialID, latest.Revision+1, current.Version, current.Title, current.Description, current.Notes,
+			current.Type, current.Link, current.Language, current.SubjectID, author, summary)
+
+	query, args := ib.Build()
+	_, err = q.Exec(query, args...)
+	return err
+}
+
+func (s *DBMaterialStore) Revisions(materialID uuid.UUID) ([]model.MaterialRevision, error) {
+	revisions := []model.MaterialRevision{}
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("material_revisions").Where(sb.Equal("material_id", materialID)).OrderBy("revision")
+
+	query, args := sb.Build()
+	err := s.db.Select(&revisions, query, args...)
+	return revisions, err
+}
+
+func (s *DBMaterialStore) Revision(materialID uuid.UUID, revision int) (model.MaterialRevision, error) {
+	var r model.MaterialRevision
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("material_revisions").Where(sb.Equal("material_id", materialID), sb.Equal("revision", revision))
+
+	query, args := sb.Build()
+	err := s.db.Get(&r, query, args...)
+	return r, err
+}
+
+func (s *DBMaterialStore) DiffRevisions(materialID uuid.UUID, from, to int) ([]model.FieldChange, error) {
+	fromRevision, err := s.Revision(materialID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := s.Revision(materialID, to)
+	if err != nil {
+		return nil, err
+	}
+	return revisionChanges(fromRevision, toRevision), nil
+}
+
+func (s *DBMaterialStore) RevertMaterial(materialID uuid.UUID, revision, version int) error {
+	r, err := s.Revision(materialID, revision)
+	if err != nil {
+		return err
+	}
+	var subjectLive bool
+	if err := s.db.Get(&subjectLive, "SELECT EXISTS (SELECT 1 FROM subjects WHERE id = $1 AND deleted_at IS NULL)", r.SubjectID); err != nil {
+		return err
+	}
+	if !subjectLive {
+		return model.ErrSubjectGone
+	}
+
+	material := model.Material{
+		ID:          materialID,
+		Title:       r.Title,
+		Description: r.Description,
+		Notes:       r.Notes,
+		Type:        r.Type,
+		Link:        r.Link,
+		Language:    r.Language,
+		SubjectID:   r.SubjectID,
+		Version:     version,
+	}
+	return s.updateMaterial(&material, fmt.Sprintf("Reverted to revision %d", revision))
+}
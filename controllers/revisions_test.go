@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestRevisionChanges(t *testing.T) {
+	subjectID, otherSubjectID := uuid.New(), uuid.New()
+	base := model.MaterialRevision{
+		Revision: 1, Version: 1, Title: "Algebra", Type: "pdf", Link: "https://example.com/algebra.pdf",
+		Language: "en", SubjectID: subjectID, Author: "ann", Summary: "Created",
+	}
+
+	tests := []struct {
+		name   string
+		change func(r *model.MaterialRevision)
+		want   []model.FieldChange
+	}{
+		{name: "no change", change: func(r *model.MaterialRevision) {}, want: []model.FieldChange{}},
+		{
+			name: "bookkeeping only",
+			change: func(r *model.MaterialRevision) {
+				r.Revision, r.Version, r.Author, r.Summary = 2, 5, "bob", "Changed nothing"
+			},
+			want: []model.FieldChange{},
+		},
+		{
+			name:   "one field",
+			change: func(r *model.MaterialRevision) { r.Title = "Algebra notes" },
+			want:   []model.FieldChange{{Field: "title", From: "Algebra", To: "Algebra notes"}},
+		},
+		{
+			name: "fields in column order",
+			change: func(r *model.MaterialRevision) {
+				r.SubjectID, r.Notes, r.Link = otherSubjectID, "Chapter 1", ""
+			},
+			want: []model.FieldChange{
+				{Field: "notes", From: "", To: "Chapter 1"},
+				{Field: "link", From: "https://example.com/algebra.pdf", To: ""},
+				{Field: "subject_id", From: subjectID.String(), To: otherSubjectID.String()},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := base
+			tt.change(&to)
+			if got := revisionChanges(base, to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("revisionChanges = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// revisionQueryer answers the queries recordRevision makes with the
+// material's current content and its latest revision, if any, and keeps
+// the arguments of the revision it inserts.
+type revisionQueryer struct {
+	queryer
+	current  model.MaterialRevision
+	latest   *model.MaterialRevision
+	inserted []interface{}
+}
+
+func (q *revisionQueryer) Get(dest interface{}, query string, args ...interface{}) error {
+	r := dest.(*model.MaterialRevision)
+	switch {
+	case strings.Contains(query, "FROM materials"):
+		*r = q.current
+	case q.latest == nil:
+		return sql.ErrNoRows
+	default:
+		*r = *q.latest
+	}
+	return nil
+}
+
+func (q *revisionQueryer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	q.inserted = args
+	return nil, nil
+}
+
+func TestRecordRevision(t *testing.T) {
+	materialID, subjectID := uuid.New(), uuid.New()
+	current := model.MaterialRevision{
+		MaterialID: materialID, Version: 4, Title: "Algebra notes", Type: "pdf",
+		Link: "https://example.com/algebra.pdf", Language: "en", SubjectID: subjectID,
+	}
+	unchanged := current
+	unchanged.Revision = 3
+	renamed := unchanged
+	renamed.Title, renamed.Link = "Algebra", "https://example.com/old.pdf"
+
+	tests := []struct {
+		name     string
+		latest   *model.MaterialRevision
+		summary  string
+		audit    model.Audit
+		revision int
+		author   string
+		want     string
+	}{
+		{name: "first revision", audit: model.Audit{Actor: "ann"}, revision: 1, author: "ann", want: "Created"},
+		{name: "no change", latest: &unchanged, audit: model.Audit{Actor: "ann"}},
+		{name: "no change with a summary", latest: &unchanged, summary: "Reverted to revision 1"},
+		{name: "changed fields", latest: &renamed, audit: model.Audit{Actor: "ann"}, revision: 4, author: "ann", want: "Changed title, link"},
+		{name: "given summary", latest: &renamed, summary: "Reverted to revision 1", audit: model.Audit{Actor: "ann"}, revision: 4, author: "ann", want: "Reverted to revision 1"},
+		{name: "with a reason", latest: &renamed, audit: model.Audit{Actor: "ann", Reason: "typo"}, revision: 4, author: "ann", want: "Changed title, link: typo"},
+		{name: "no actor", audit: model.Audit{}, revision: 1, author: systemActor, want: "Created"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &revisionQueryer{current: current, latest: tt.latest}
+			if err := recordRevision(q, tt.audit, materialID, tt.summary); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if q.inserted != nil {
+					t.Errorf("inserted a revision %v of unchanged content", q.inserted)
+				}
+				return
+			}
+			if len(q.inserted) != 12 {
+				t.Fatalf("inserted %v", q.inserted)
+			}
+			if got := q.inserted[1]; got != tt.revision {
+				t.Errorf("revision = %v, want %d", got, tt.revision)
+			}
+			if got := q.inserted[2]; got != current.Version {
+				t.Errorf("version = %v, want %d", got, current.Version)
+			}
+			if got := q.inserted[3]; got != current.Title {
+				t.Errorf("title = %v, want the current %q", got, current.Title)
+			}
+			if got := q.inserted[10]; got != tt.author {
+				t.Errorf("author = %v, want %s", got, tt.author)
+			}
+			if got := q.inserted[11]; got != tt.want {
+				t.Errorf("summary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
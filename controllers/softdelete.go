@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/lib/pq"
+)
+
+// Records are deleted by stamping deleted_at; every read leaves stamped
+// rows out. Purging removes a stamped row for good.
+
+// softDelete marks the row deleted. It returns sql.ErrNoRows if there is no
+// such row or it is already deleted, and model.ErrConflict if version is
+// not zero and the row is at another one.
+func softDelete(q queryer, audit model.Audit, entity string, id uuid.UUID, version int) error {
+	return audited(q, audit, "delete", entity, id, func(q queryer) error {
+		ub := sqlbuilder.NewUpdateBuilder()
+		ub.SetFlavor(sqlbuilder.PostgreSQL)
+		ub.Update(auditTables[entity]).Set("deleted_at = NOW()")
+		_, err := updateVersioned(q, entity, ub, id, version)
+		return err
+	})
+}
+
+// restore clears the deleted mark. It returns sql.ErrNoRows if there is no
+// such deleted row, and model.ErrDuplicate if a live record has since taken
+// one of its unique identifiers.
+func restore(q queryer, audit model.Audit, entity string, id uuid.UUID) error {
+	return audited(q, audit, "restore", entity, id, func(q queryer) error {
+		res, err := q.Exec(fmt.Sprintf("UPDATE %s SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL", auditTables[entity]), id)
+		return affectedOne(res, uniqueViolation(err))
+	})
+}
+
+// purge removes a deleted row. Each of refs is a query on $1 that finds the
+// rows, deleted or not, which the database would otherwise cascade into;
+// while any of them returns a row the purge is refused with model.ErrInUse.
+func purge(q queryer, audit model.Audit, entity string, id uuid.UUID, refs ...string) error {
+	table := auditTables[entity]
+	return audited(q, audit, "purge", entity, id, func(q queryer) error {
+		var deleted bool
+		if err := q.Get(&deleted, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND deleted_at IS NOT NULL)", table), id); err != nil {
+			return err
+		}
+		if !deleted {
+			return sql.ErrNoRows
+		}
+
+		for _, ref := range refs {
+			var used bool
+			if err := q.Get(&used, "SELECT EXISTS ("+ref+")", id); err != nil {
+				return err
+			}
+			if used {
+				return model.ErrInUse
+			}
+		}
+
+		_, err := q.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", table), id)
+		return err
+	})
+}
+
+// selectDeleted lists the table's deleted rows, most recently deleted
+// first.
+func selectDeleted[T any](q queryer, table string) ([]T, error) {
+	var rows []T
+	err := q.Select(&rows, fmt.Sprintf("SELECT * FROM %s WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC", table))
+	return rows, err
+}
+
+func affectedOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// uniqueViolation turns a unique constraint error into model.ErrDuplicate.
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return model.ErrDuplicate
+	}
+	return err
+}
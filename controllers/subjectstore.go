@@ -3,6 +3,8 @@ package controllers
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/arjunsaxaena/Library-Management/model"
 	"github.com/google/uuid"
@@ -11,7 +13,8 @@ import (
 )
 
 type DBSubjectStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBSubjectStore(db *sqlx.DB) *DBSubjectStore {
@@ -22,7 +25,7 @@ func (s *DBSubjectStore) Subject(id uuid.UUID) (model.Subject, error) {
 	var subject model.Subject
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("subjects").Where(sb.Equal("id", id))
+	sb.Select("*").From("subjects").Where(sb.Equal("id", id), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&subject, query, args...)
@@ -33,7 +36,7 @@ func (s *DBSubjectStore) Subjects() ([]model.Subject, error) {
 	var subjects []model.Subject
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("subjects")
+	sb.Select("*").From("subjects").Where(sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&subjects, query, args...)
@@ -41,44 +44,80 @@ func (s *DBSubjectStore) Subjects() ([]model.Subject, error) {
 }
 
 func (s *DBSubjectStore) CreateSubject(subject *model.Subject) error {
+	if err := checkTerms(s.db, "", uuid.Nil, nil, termField{"language", model.VocabularyLanguage, &subject.Language}); err != nil {
+		return err
+	}
+
 	sb := sqlbuilder.NewInsertBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.InsertInto("subjects").Cols("id", "name", "language", "created_at").
-		Values(subject.ID, subject.Name, subject.Language, subject.CreatedAt)
+	subject.Version = 1
+	sb.InsertInto("subjects").Cols("id", "parent_id", "name", "language", "created_at", "version").
+		Values(subject.ID, subject.ParentID, subject.Name, subject.Language, subject.CreatedAt, subject.Version)
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "create", model.EntitySubject, subject.ID, func(q queryer) error {
+		if err := checkParent(q, subject.ID, subject.ParentID); err != nil {
+			return err
+		}
+		_, err := q.Exec(query, args...)
+		return uniqueViolation(err)
+	})
 }
 
-func (s *DBSubjectStore) UpdateSubject(subject *model.Subject) error {
+func (s *DBSubjectStore) UpdateSubject(subject *model.Subject, columns ...string) error {
+	if err := checkTerms(s.db, model.EntitySubject, subject.ID, columns, termField{"language", model.VocabularyLanguage, &subject.Language}); err != nil {
+		return err
+	}
+
 	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Update("subjects").Set(
-		sb.Assign("name", subject.Name),
-		sb.Assign("language", subject.Language),
-	).Where(sb.Equal("id", subject.ID))
+	sb.Update("subjects")
+	if err := assignColumns(sb, []column{
+		{"parent_id", subject.ParentID},
+		{"name", subject.Name},
+		{"language", subject.Language},
+	}, columns); err != nil {
+		return err
+	}
 
-	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "update", model.EntitySubject, subject.ID, func(q queryer) error {
+		if writesColumn(columns, "parent_id") {
+			if err := checkParent(q, subject.ID, subject.ParentID); err != nil {
+				return err
+			}
+		}
+		version, err := updateVersioned(q, model.EntitySubject, sb, subject.ID, subject.Version)
+		if err != nil {
+			return uniqueViolation(err)
+		}
+		subject.Version = version
+		return nil
+	})
 }
 
-func (s *DBSubjectStore) DeleteSubject(id uuid.UUID) error {
-	sb := sqlbuilder.NewDeleteBuilder()
-	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.DeleteFrom("subjects").Where(sb.Equal("id", id))
+func (s *DBSubjectStore) DeleteSubject(id uuid.UUID, version int) error {
+	return softDelete(s.db, s.audit, model.EntitySubject, id, version)
+}
 
-	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+func (s *DBSubjectStore) RestoreSubject(id uuid.UUID) error {
+	return restore(s.db, s.audit, model.EntitySubject, id)
+}
+
+func (s *DBSubjectStore) PurgeSubject(id uuid.UUID) error {
+	return purge(s.db, s.audit, model.EntitySubject, id,
+		"SELECT 1 FROM materials WHERE subject_id = $1",
+		"SELECT 1 FROM subjects WHERE parent_id = $1")
+}
+
+func (s *DBSubjectStore) DeletedSubjects() ([]model.Subject, error) {
+	return selectDeleted[model.Subject](s.db, "subjects")
 }
 
 func (s *DBSubjectStore) SubjectByName(name string) (model.Subject, error) {
 	var subject model.Subject
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("subjects").Where(sb.Equal("name", name))
+	sb.Select("*").From("subjects").Where(sb.Equal("name", name), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&subject, query, args...)
@@ -90,3 +129,154 @@ func (s *DBSubjectStore) SubjectByName(name string) (model.Subject, error) {
 	}
 	return subject, nil
 }
+
+func (s *DBSubjectStore) SubjectNode(id uuid.UUID) (model.SubjectNode, error) {
+	var node model.SubjectNode
+	err := s.db.Get(&node, fmt.Sprintf(subjectNodeQuery, "s.id = $1"), id)
+	return node, err
+}
+
+func (s *DBSubjectStore) SubjectNodes(parentID uuid.UUID) ([]model.SubjectNode, error) {
+	nodes := []model.SubjectNode{}
+	if parentID == uuid.Nil {
+		err := s.db.Select(&nodes, fmt.Sprintf(subjectNodeQuery, "s.parent_id IS NULL"))
+		return nodes, err
+	}
+	err := s.db.Select(&nodes, fmt.Sprintf(subjectNodeQuery, "s.parent_id = $1"), parentID)
+	return nodes, err
+}
+
+// Ancestors lists the subjects above the subject, top-level first.
+func (s *DBSubjectStore) Ancestors(id uuid.UUID) ([]model.Subject, error) {
+	ancestors := []model.Subject{}
+	err := s.db.Select(&ancestors, ancestorsQuery+
+		"SELECT s.* FROM up JOIN subjects s ON s.id = up.id WHERE up.depth > 0 ORDER BY up.depth DESC", id)
+	return ancestors, err
+}
+
+// Children lists the live subjects directly below the subject.
+func (s *DBSubjectStore) Children(id uuid.UUID) ([]model.Subject, error) {
+	var children []model.Subject
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("subjects").Where(sb.Equal("parent_id", id), sb.IsNull("deleted_at")).OrderBy("name")
+
+	query, args := sb.Build()
+	err := s.db.Select(&children, query, args...)
+	return children, err
+}
+
+// maxSubjectDepth bounds walks up the hierarchy, which checkParent keeps
+// free of cycles.
+const maxSubjectDepth = 100
+
+// ancestorsQuery walks up from the live subject $1, naming as up(id,
+// depth) the subject itself at depth 0, its parent at depth 1 and so on.
+var ancestorsQuery = fmt.Sprintf(`WITH RECURSIVE up (id, depth) AS (
+	SELECT id, 0 FROM subjects WHERE id = $1 AND deleted_at IS NULL
+	UNION ALL
+	SELECT s.parent_id, up.depth + 1 FROM up JOIN subjects s ON s.id = up.id
+	WHERE s.parent_id IS NOT NULL AND up.depth < %d
+) `, maxSubjectDepth)
+
+// subjectNodeQuery selects the live subjects matching a condition on s,
+// with counts over their subtrees. tree pairs each of them, as root, with
+// every live subject in its subtree.
+const subjectNodeQuery = `WITH RECURSIVE tree (root, id) AS (
+	SELECT s.id, s.id FROM subjects s WHERE s.deleted_at IS NULL AND %[1]s
+	UNION
+	SELECT tree.root, c.id FROM tree JOIN subjects c ON c.parent_id = tree.id AND c.deleted_at IS NULL
+)
+SELECT s.*,
+	(SELECT COUNT(*) FROM subjects c WHERE c.parent_id = s.id AND c.deleted_at IS NULL) AS children,
+	(SELECT COUNT(*) - 1 FROM tree WHERE tree.root = s.id) AS descendants,
+	(SELECT COUNT(DISTINCT l.book_id) FROM tree
+		JOIN book_subjects l ON l.subject_id = tree.id
+		JOIN books b ON b.id = l.book_id AND b.deleted_at IS NULL
+		WHERE tree.root = s.id) AS books,
+	(SELECT COUNT(DISTINCT l.material_id) FROM tree
+		JOIN material_subjects l ON l.subject_id = tree.id
+		JOIN materials m ON m.id = l.material_id AND m.deleted_at IS NULL
+		WHERE tree.root = s.id) AS materials
+FROM subjects s
+WHERE s.deleted_at IS NULL AND %[1]s
+ORDER BY s.name`
+
+// checkParent returns model.ErrInvalidParent unless parentID, if set, is a
+// live subject outside the subtree of the subject with id.
+func checkParent(q queryer, id uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+	var path []uuid.UUID
+	if err := q.Select(&path, ancestorsQuery+"SELECT id FROM up", *parentID); err != nil {
+		return err
+	}
+	if len(path) == 0 || slices.Contains(path, id) {
+		return model.ErrInvalidParent
+	}
+	return nil
+}
+
+// subtreeQuery names, as tree(id), the subject $1 and every live subject
+// below it, or with subtree false the subject alone.
+func subtreeQuery(subtree bool) string {
+	if !subtree {
+		return "WITH tree (id) AS (SELECT $1::uuid) "
+	}
+	return `WITH RECURSIVE tree (id) AS (
+	SELECT $1::uuid
+	UNION
+	SELECT s.id FROM tree JOIN subjects s ON s.parent_id = tree.id AND s.deleted_at IS NULL
+) `
+}
+
+// selectFiledUnder lists the live rows filed, through the links table,
+// under the subject or its subtree. from is the FROM clause, calling the
+// rows r, and columns the select list. column is the links column holding
+// the row's ID.
+func selectFiledUnder[T any](q queryer, columns, from, links, column string, subjectID uuid.UUID, subtree bool) ([]T, error) {
+	rows := []T{}
+	err := q.Select(&rows, subtreeQuery(subtree)+fmt.Sprintf(`SELECT %s FROM %s
+WHERE r.deleted_at IS NULL AND r.id IN (SELECT l.%s FROM %s l JOIN tree ON tree.id = l.subject_id)
+ORDER BY r.title`, columns, from, column, links), subjectID)
+	return rows, err
+}
+
+// selectFiledSubjects lists the live subjects the record with id is filed
+// under in the links table.
+func selectFiledSubjects(q queryer, links, column string, id uuid.UUID) ([]model.Subject, error) {
+	subjects := []model.Subject{}
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("s.*").
+		From("subjects s").
+		Join(links+" l", "l.subject_id = s.id").
+		Where(sb.Equal("l."+column, id), sb.IsNull("s.deleted_at")).
+		OrderBy("s.name")
+
+	query, args := sb.Build()
+	err := q.Select(&subjects, query, args...)
+	return subjects, err
+}
+
+// writeFiledSubjects replaces the subjects the record with id is filed
+// under in the links table. Links to deleted subjects are kept, so they
+// come back when the subject is restored.
+func writeFiledSubjects(q queryer, links, column string, id uuid.UUID, subjectIDs []uuid.UUID) error {
+	_, err := q.Exec(fmt.Sprintf(`DELETE FROM %s l USING subjects s
+WHERE l.subject_id = s.id AND l.%s = $1 AND s.deleted_at IS NULL`, links, column), id)
+	if err != nil || len(subjectIDs) == 0 {
+		return err
+	}
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto(links).Cols(column, "subject_id")
+	for _, subjectID := range subjectIDs {
+		ib.Values(id, subjectID)
+	}
+	query, args := ib.Build()
+	_, err = q.Exec(query+" ON CONFLICT DO NOTHING", args...)
+	return err
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// hierarchyQueryer answers ancestorsQuery from parents, walking up from $1
+// the way the recursive CTE does, and records the queries it is sent.
+type hierarchyQueryer struct {
+	queryer
+	parents map[uuid.UUID]*uuid.UUID
+	queries []string
+	args    [][]interface{}
+}
+
+func (q *hierarchyQueryer) Select(dest interface{}, query string, args ...interface{}) error {
+	q.queries = append(q.queries, query)
+	q.args = append(q.args, args)
+	path, ok := dest.(*[]uuid.UUID)
+	if !ok || !strings.HasPrefix(query, ancestorsQuery) {
+		return nil
+	}
+	id := args[0].(uuid.UUID)
+	if _, ok := q.parents[id]; !ok {
+		return nil
+	}
+	for depth := 0; depth <= maxSubjectDepth; depth++ {
+		*path = append(*path, id)
+		parent := q.parents[id]
+		if parent == nil {
+			break
+		}
+		id = *parent
+	}
+	return nil
+}
+
+func TestCheckParent(t *testing.T) {
+	science, physics, optics, art := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	parents := map[uuid.UUID]*uuid.UUID{science: nil, physics: &science, optics: &physics, art: nil}
+	missing := uuid.New()
+
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		parent  *uuid.UUID
+		want    error
+		queries int
+	}{
+		{name: "top level", id: physics},
+		{name: "new subject", id: uuid.New(), parent: &optics, queries: 1},
+		{name: "move to another branch", id: physics, parent: &art, queries: 1},
+		{name: "move up", id: optics, parent: &science, queries: 1},
+		{name: "below itself", id: physics, parent: &physics, want: model.ErrInvalidParent, queries: 1},
+		{name: "below its child", id: physics, parent: &optics, want: model.ErrInvalidParent, queries: 1},
+		{name: "below its grandchild", id: science, parent: &optics, want: model.ErrInvalidParent, queries: 1},
+		{name: "missing or deleted parent", id: physics, parent: &missing, want: model.ErrInvalidParent, queries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &hierarchyQueryer{parents: parents}
+			if err := checkParent(q, tt.id, tt.parent); !errors.Is(err, tt.want) {
+				t.Errorf("checkParent = %v, want %v", err, tt.want)
+			}
+			if len(q.queries) != tt.queries {
+				t.Errorf("%d queries, want %d", len(q.queries), tt.queries)
+			}
+		})
+	}
+}
+
+func TestSubjectQueries(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name     string
+		run      func(*DBSubjectStore) error
+		contains []string
+		excludes []string
+		args     int
+	}{
+		{
+			name:     "top-level nodes",
+			run:      func(s *DBSubjectStore) error { _, err := s.SubjectNodes(uuid.Nil); return err },
+			contains: []string{"WITH RECURSIVE tree", "s.deleted_at IS NULL AND s.parent_id IS NULL\n\tUNION"},
+			excludes: []string{"$1"},
+		},
+		{
+			name:     "children's nodes",
+			run:      func(s *DBSubjectStore) error { _, err := s.SubjectNodes(id); return err },
+			contains: []string{"s.deleted_at IS NULL AND s.parent_id = $1\n\tUNION", "WHERE s.deleted_at IS NULL AND s.parent_id = $1\nORDER BY s.name"},
+			args:     1,
+		},
+		{
+			name:     "ancestors",
+			run:      func(s *DBSubjectStore) error { _, err := s.Ancestors(id); return err },
+			contains: []string{"WITH RECURSIVE up", "WHERE up.depth > 0 ORDER BY up.depth DESC"},
+			args:     1,
+		},
+		{
+			name: "books in a subtree",
+			run: func(s *DBSubjectStore) error {
+				_, err := selectFiledUnder[model.Book](s.db, "r.*", "books r", "book_subjects", "book_id", id, true)
+				return err
+			},
+			contains: []string{"WITH RECURSIVE tree", "SELECT l.book_id FROM book_subjects l JOIN tree"},
+			args:     1,
+		},
+		{
+			name: "books filed directly",
+			run: func(s *DBSubjectStore) error {
+				_, err := selectFiledUnder[model.Book](s.db, "r.*", "books r", "book_subjects", "book_id", id, false)
+				return err
+			},
+			contains: []string{"WITH tree (id) AS (SELECT $1::uuid)", "SELECT l.book_id FROM book_subjects l JOIN tree"},
+			excludes: []string{"RECURSIVE"},
+			args:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &hierarchyQueryer{}
+			if err := tt.run(&DBSubjectStore{db: q}); err != nil {
+				t.Fatal(err)
+			}
+			if len(q.queries) != 1 {
+				t.Fatalf("%d queries, want 1", len(q.queries))
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(q.queries[0], s) {
+					t.Errorf("query does not contain %q:\n%s", s, q.queries[0])
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(q.queries[0], s) {
+					t.Errorf("query contains %q:\n%s", s, q.queries[0])
+				}
+			}
+			if len(q.args[0]) != tt.args {
+				t.Errorf("%d arguments, want %d", len(q.args[0]), tt.args)
+			}
+		})
+	}
+}
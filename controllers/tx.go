@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"database/sql"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx, so the same store
+// code runs inside or outside a transaction.
+type queryer interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Queryx(query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+// withTx runs fn in a transaction: the one q already is, or a new one if
+// q is the database itself.
+func withTx(q queryer, fn func(queryer) error) error {
+	db, ok := q.(*sqlx.DB)
+	if !ok {
+		return fn(q)
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type DBTxRunner struct {
+	db *sqlx.DB
+}
+
+func NewDBTxRunner(db *sqlx.DB) *DBTxRunner {
+	return &DBTxRunner{db: db}
+}
+
+// InTx runs fn with stores bound to a new transaction, which is committed
+// if fn returns nil and rolled back otherwise.
+func (r *DBTxRunner) InTx(audit model.Audit, fn func(model.Stores) error) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(bindStores(tx, audit)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Stores returns stores outside any transaction whose writes are
+// attributed to audit.
+func (r *DBTxRunner) Stores(audit model.Audit) model.Stores {
+	return bindStores(r.db, audit)
+}
+
+func bindStores(q queryer, audit model.Audit) model.Stores {
+	return model.Stores{
+		Books:       &DBBookStore{db: q, audit: audit},
+		Authors:     &DBAuthorStore{db: q, audit: audit},
+		Locations:   &DBLocationStore{db: q, audit: audit},
+		Users:       &DBUserStore{db: q, audit: audit},
+		Subjects:    &DBSubjectStore{db: q, audit: audit},
+		Materials:   &DBMaterialStore{db: q, audit: audit},
+		IssuedBooks: &DBIssuedBookStore{db: q, audit: audit},
+
+		Vocabularies: &DBVocabularyStore{db: q, audit: audit},
+	}
+}
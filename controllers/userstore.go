@@ -8,7 +8,8 @@ import (
 )
 
 type DBUserStore struct {
-	db *sqlx.DB
+	db    queryer
+	audit model.Audit
 }
 
 func NewDBUserStore(db *sqlx.DB) *DBUserStore {
@@ -19,7 +20,7 @@ func (s *DBUserStore) User(id uuid.UUID) (model.User, error) {
 	var user model.User
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("users").Where(sb.Equal("id", id))
+	sb.Select("*").From("users").Where(sb.Equal("id", id), sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Get(&user, query, args...)
@@ -30,7 +31,7 @@ func (s *DBUserStore) Users() ([]model.User, error) {
 	var users []model.User
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Select("*").From("users")
+	sb.Select("*").From("users").Where(sb.IsNull("deleted_at"))
 
 	query, args := sb.Build()
 	err := s.db.Select(&users, query, args...)
@@ -38,38 +39,107 @@ func (s *DBUserStore) Users() ([]model.User, error) {
 }
 
 func (s *DBUserStore) CreateUser(u *model.User) error {
+	if err := checkTerms(s.db, "", uuid.Nil, nil, termField{"class", model.VocabularyUserClass, &u.Class}); err != nil {
+		return err
+	}
+
 	sb := sqlbuilder.NewInsertBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	u.Version = 1
 	sb.InsertInto("users").
-		Cols("id", "name", "class").
-		Values(u.ID, u.Name, u.Class)
+		Cols("id", "name", "class", "card_number", "version").
+		Values(u.ID, u.Name, u.Class, u.CardNumber, u.Version)
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "create", model.EntityUser, u.ID, func(q queryer) error {
+		_, err := q.Exec(query, args...)
+		return err
+	})
 }
 
-func (s *DBUserStore) UpdateUser(u *model.User) error {
+func (s *DBUserStore) UpdateUser(u *model.User, columns ...string) error {
+	if err := checkTerms(s.db, model.EntityUser, u.ID, columns, termField{"class", model.VocabularyUserClass, &u.Class}); err != nil {
+		return err
+	}
+
 	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.Update("users").
-		Set(
-			sb.Assign("name", u.Name),
-			sb.Assign("class", u.Class),
-		).
-		Where(sb.Equal("id", u.ID))
+	sb.Update("users")
+	if err := assignColumns(sb, []column{
+		{"name", u.Name},
+		{"class", u.Class},
+	}, columns); err != nil {
+		return err
+	}
+
+	return audited(s.db, s.audit, "update", model.EntityUser, u.ID, func(q queryer) error {
+		version, err := updateVersioned(q, model.EntityUser, sb, u.ID, u.Version)
+		if err != nil {
+			return err
+		}
+		u.Version = version
+		return nil
+	})
+}
+
+func (s *DBUserStore) DeleteUser(id uuid.UUID, version int) error {
+	return softDelete(s.db, s.audit, model.EntityUser, id, version)
+}
+
+func (s *DBUserStore) RestoreUser(id uuid.UUID) error {
+	return restore(s.db, s.audit, model.EntityUser, id)
+}
+
+// PurgeUser keeps patrons with books on loan or unpaid fees on any loan,
+// returned or not, the same fees UnpaidFees adds up.
+func (s *DBUserStore) PurgeUser(id uuid.UUID) error {
+	return purge(s.db, s.audit, model.EntityUser, id,
+		"SELECT 1 FROM issued_books WHERE user_id = $1 AND return_date IS NULL",
+		"SELECT 1 FROM issued_books WHERE user_id = $1 AND fees_paid_at IS NULL AND "+accruedFees+" > 0")
+}
+
+func (s *DBUserStore) DeletedUsers() ([]model.User, error) {
+	return selectDeleted[model.User](s.db, "users")
+}
+
+func (s *DBUserStore) UserByCardNumber(cardNumber string) (model.User, error) {
+	var user model.User
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("users").Where(sb.Equal("card_number", cardNumber), sb.IsNull("deleted_at"))
+
+	query, args := sb.Build()
+	err := s.db.Get(&user, query, args...)
+	return user, err
+}
+
+func (s *DBUserStore) UsersWithoutCardNumber() ([]model.User, error) {
+	var users []model.User
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("users").Where(sb.IsNull("card_number"))
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	err := s.db.Select(&users, query, args...)
+	return users, err
 }
 
-func (s *DBUserStore) DeleteUser(id uuid.UUID) error {
-	sb := sqlbuilder.NewDeleteBuilder()
+func (s *DBUserStore) NextCardSequence() (int64, error) {
+	var seq int64
+	err := s.db.Get(&seq, "SELECT nextval('patron_card_seq')")
+	return seq, err
+}
+
+func (s *DBUserStore) SetCardNumber(id uuid.UUID, cardNumber string) error {
+	sb := sqlbuilder.NewUpdateBuilder()
 	sb.SetFlavor(sqlbuilder.PostgreSQL)
-	sb.DeleteFrom("users").Where(sb.Equal("id", id))
+	sb.Update("users").
+		Set(sb.Assign("card_number", cardNumber), sb.Incr("version")).
+		Where(sb.Equal("id", id))
 
 	query, args := sb.Build()
-	_, err := s.db.Exec(query, args...)
-	return err
+	return audited(s.db, s.audit, "update", model.EntityUser, id, func(q queryer) error {
+		_, err := q.Exec(query, args...)
+		return err
+	})
 }
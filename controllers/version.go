@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// Every write to a record bumps its version. A write given a non-zero
+// version only applies while the record is still at that version, so a
+// client cannot overwrite changes it has not seen.
+
+// updateVersioned runs ub, an update of the entity's table, on the live
+// row id and returns the row's new version. With a non-zero version the
+// row must be at that version, or model.ErrConflict is returned.
+func updateVersioned(q queryer, entity string, ub *sqlbuilder.UpdateBuilder, id uuid.UUID, version int) (int, error) {
+	ub.SetMore(ub.Incr("version")).Where(ub.Equal("id", id), ub.IsNull("deleted_at"))
+	if version != 0 {
+		ub.Where(ub.Equal("version", version))
+	}
+
+	query, args := ub.Build()
+	res, err := q.Exec(query, args...)
+	if err := affectedOne(res, err); err != nil {
+		if errors.Is(err, sql.ErrNoRows) && version != 0 {
+			return 0, missingOrConflict(q, entity, id)
+		}
+		return 0, err
+	}
+
+	var current int
+	err = q.Get(&current, fmt.Sprintf("SELECT version FROM %s WHERE id = $1", auditTables[entity]), id)
+	return current, err
+}
+
+// missingOrConflict tells why a versioned write matched no row: the live
+// row is at another version, or there is none.
+func missingOrConflict(q queryer, entity string, id uuid.UUID) error {
+	var live bool
+	if err := q.Get(&live, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND deleted_at IS NULL)", auditTables[entity]), id); err != nil {
+		return err
+	}
+	if live {
+		return model.ErrConflict
+	}
+	return sql.ErrNoRows
+}
+
+// column is a column an update can write, with its new value.
+type column struct {
+	name  string
+	value interface{}
+}
+
+// assignColumns sets the named columns on ub, or all of them when no names
+// are given. Naming a column that is not among them is an error.
+func assignColumns(ub *sqlbuilder.UpdateBuilder, columns []column, names []string) error {
+	if len(names) == 0 {
+		for _, c := range columns {
+			ub.SetMore(ub.Assign(c.name, c.value))
+		}
+		return nil
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(columns, func(c column) bool { return c.name == name })
+		if i < 0 {
+			return fmt.Errorf("column %s cannot be updated", name)
+		}
+		ub.SetMore(ub.Assign(name, columns[i].value))
+	}
+	return nil
+}
+
+// writesColumn reports whether an update of the given columns, where none
+// means all, writes name.
+func writesColumn(columns []string, name string) bool {
+	return len(columns) == 0 || slices.Contains(columns, name)
+}
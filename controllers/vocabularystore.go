@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/jmoiron/sqlx"
+)
+
+type DBVocabularyStore struct {
+	db    queryer
+	audit model.Audit
+}
+
+func NewDBVocabularyStore(db *sqlx.DB) *DBVocabularyStore {
+	return &DBVocabularyStore{db: db}
+}
+
+// termUses finds the records, deleted or not, that use a code of each
+// vocabulary.
+var termUses = map[string][]string{
+	model.VocabularyBookType:     {"SELECT 1 FROM books WHERE book_type = $1"},
+	model.VocabularyMaterialType: {"SELECT 1 FROM materials WHERE type = $1"},
+	model.VocabularyUserClass:    {"SELECT 1 FROM users WHERE class = $1"},
+	model.VocabularyLanguage: {
+		"SELECT 1 FROM subjects WHERE language = $1",
+		"SELECT 1 FROM materials WHERE language = $1",
+	},
+}
+
+func (s *DBVocabularyStore) Terms(vocabulary string) ([]model.Term, error) {
+	terms := []model.Term{}
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("vocabulary_terms").Where(sb.Equal("vocabulary", vocabulary)).OrderBy("code")
+
+	query, args := sb.Build()
+	if err := s.db.Select(&terms, query, args...); err != nil {
+		return nil, err
+	}
+	if err := selectLabels(s.db, terms); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+func (s *DBVocabularyStore) Term(vocabulary, code string) (model.Term, error) {
+	var term model.Term
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("*").From("vocabulary_terms").Where(sb.Equal("vocabulary", vocabulary), sb.Equal("code", termCode(code)))
+
+	query, args := sb.Build()
+	if err := s.db.Get(&term, query, args...); err != nil {
+		return model.Term{}, err
+	}
+	terms := []model.Term{term}
+	err := selectLabels(s.db, terms)
+	return terms[0], err
+}
+
+func (s *DBVocabularyStore) CreateTerm(t *model.Term) error {
+	t.Code = termCode(t.Code)
+	t.CreatedAt = time.Now()
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("vocabulary_terms").
+		Cols("id", "vocabulary", "code", "active", "created_at").
+		Values(t.ID, t.Vocabulary, t.Code, t.Active, t.CreatedAt)
+
+	query, args := ib.Build()
+	return audited(s.db, s.audit, "create", model.EntityTerm, t.ID, func(q queryer) error {
+		if _, err := q.Exec(query, args...); err != nil {
+			return uniqueViolation(err)
+		}
+		return writeLabels(q, t.ID, t.Labels)
+	})
+}
+
+func (s *DBVocabularyStore) UpdateTerm(t *model.Term) error {
+	current, err := s.Term(t.Vocabulary, t.Code)
+	if err != nil {
+		return err
+	}
+	t.ID, t.Code, t.CreatedAt = current.ID, current.Code, current.CreatedAt
+
+	ub := sqlbuilder.NewUpdateBuilder()
+	ub.SetFlavor(sqlbuilder.PostgreSQL)
+	ub.Update("vocabulary_terms").Set(ub.Assign("active", t.Active)).Where(ub.Equal("id", t.ID))
+
+	query, args := ub.Build()
+	return audited(s.db, s.audit, "update", model.EntityTerm, t.ID, func(q queryer) error {
+		if _, err := q.Exec(query, args...); err != nil {
+			return err
+		}
+		return writeLabels(q, t.ID, t.Labels)
+	})
+}
+
+func (s *DBVocabularyStore) DeleteTerm(vocabulary, code string) error {
+	term, err := s.Term(vocabulary, code)
+	if err != nil {
+		return err
+	}
+
+	return audited(s.db, s.audit, "delete", model.EntityTerm, term.ID, func(q queryer) error {
+		for _, use := range termUses[term.Vocabulary] {
+			var used bool
+			if err := q.Get(&used, "SELECT EXISTS ("+use+")", term.Code); err != nil {
+				return err
+			}
+			if used {
+				return model.ErrInUse
+			}
+		}
+		_, err := q.Exec("DELETE FROM vocabulary_terms WHERE id = $1", term.ID)
+		return err
+	})
+}
+
+// selectLabels fills in the labels of terms.
+func selectLabels(q queryer, terms []model.Term) error {
+	if len(terms) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*model.Term, len(terms))
+	ids := make([]interface{}, len(terms))
+	for i := range terms {
+		terms[i].Labels = map[string]string{}
+		byID[terms[i].ID] = &terms[i]
+		ids[i] = terms[i].ID
+	}
+
+	var labels []struct {
+		TermID uuid.UUID `db:"term_id"`
+		Locale string    `db:"locale"`
+		Label  string    `db:"label"`
+	}
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.SetFlavor(sqlbuilder.PostgreSQL)
+	sb.Select("term_id", "locale", "label").From("vocabulary_labels").Where(sb.In("term_id", ids...))
+
+	query, args := sb.Build()
+	if err := q.Select(&labels, query, args...); err != nil {
+		return err
+	}
+	for _, l := range labels {
+		byID[l.TermID].Labels[l.Locale] = l.Label
+	}
+	return nil
+}
+
+// writeLabels replaces the term's labels. Locales are stored in lower
+// case, like en-gb.
+func writeLabels(q queryer, termID uuid.UUID, labels map[string]string) error {
+	if _, err := q.Exec("DELETE FROM vocabulary_labels WHERE term_id = $1", termID); err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.SetFlavor(sqlbuilder.PostgreSQL)
+	ib.InsertInto("vocabulary_labels").Cols("term_id", "locale", "label")
+	for locale, label := range labels {
+		ib.Values(termID, strings.ToLower(locale), label)
+	}
+	query, args := ib.Build()
+	_, err := q.Exec(query, args...)
+	return err
+}
+
+// termCode normalizes a value to the form codes take: lower case, with
+// words joined by hyphens. The vocabularies migration normalizes existing
+// values the same way.
+func termCode(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_'
+	})
+	return strings.Join(words, "-")
+}
+
+// termField is a field of a record that holds a code of a vocabulary.
+type termField struct {
+	column     string
+	vocabulary string
+	code       *string
+}
+
+// checkTerms normalizes the codes of the fields a write of columns sets,
+// where none means all, and returns model.TermErrors for every one that is
+// not an active term of its vocabulary. An update passes the entity and ID
+// of the record it writes, and codes that match the stored ones are not
+// checked, so a record can keep a term that has since been deactivated; a
+// create passes no entity.
+func checkTerms(q queryer, entity string, id uuid.UUID, columns []string, fields ...termField) error {
+	var errs model.TermErrors
+	for _, f := range fields {
+		if !writesColumn(columns, f.column) {
+			continue
+		}
+		*f.code = termCode(*f.code)
+
+		if entity != "" {
+			var stored string
+			if err := q.Get(&stored, fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", f.column, auditTables[entity]), id); err != nil {
+				return err
+			}
+			if stored == *f.code {
+				continue
+			}
+		}
+
+		var active bool
+		err := q.Get(&active, "SELECT active FROM vocabulary_terms WHERE vocabulary = $1 AND code = $2", f.vocabulary, *f.code)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			errs = append(errs, &model.TermError{Field: f.column, Vocabulary: f.vocabulary, Code: *f.code})
+		case err != nil:
+			return err
+		case !active:
+			errs = append(errs, &model.TermError{Field: f.column, Vocabulary: f.vocabulary, Code: *f.code, Inactive: true})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// termQueryer answers the queries checkTerms makes: the stored codes of a
+// record, by column, and whether terms are active, by vocabulary/code.
+type termQueryer struct {
+	queryer
+	stored  map[string]string
+	terms   map[string]bool
+	queries int
+}
+
+func (q *termQueryer) Get(dest interface{}, query string, args ...interface{}) error {
+	q.queries++
+	switch dest := dest.(type) {
+	case *string:
+		if q.stored == nil {
+			return sql.ErrNoRows
+		}
+		*dest = q.stored[strings.Fields(query)[1]]
+	case *bool:
+		active, ok := q.terms[args[0].(string)+"/"+args[1].(string)]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		*dest = active
+	}
+	return nil
+}
+
+func TestCheckTerms(t *testing.T) {
+	terms := map[string]bool{
+		model.VocabularyMaterialType + "/lecture-notes": true,
+		model.VocabularyMaterialType + "/slides":        false,
+		model.VocabularyLanguage + "/en":                true,
+		model.VocabularyLanguage + "/la":                false,
+	}
+	stored := map[string]string{"type": "slides", "language": "la"}
+
+	tests := []struct {
+		name     string
+		entity   string
+		stored   map[string]string
+		columns  []string
+		typ      string
+		language string
+		wantType string
+		want     model.TermErrors
+		wantErr  error
+		queries  int
+	}{
+		{
+			name:     "create normalizes codes",
+			typ:      "Lecture Notes",
+			language: "EN",
+			wantType: "lecture-notes",
+			queries:  2,
+		},
+		{
+			name:     "create reports every field",
+			typ:      "podcast",
+			language: "la",
+			wantType: "podcast",
+			want: model.TermErrors{
+				{Field: "type", Vocabulary: model.VocabularyMaterialType, Code: "podcast"},
+				{Field: "language", Vocabulary: model.VocabularyLanguage, Code: "la", Inactive: true},
+			},
+			queries: 2,
+		},
+		{
+			name:     "full update keeps deactivated codes",
+			entity:   model.EntityMaterial,
+			stored:   stored,
+			typ:      "Slides",
+			language: "la",
+			wantType: "slides",
+			queries:  2,
+		},
+		{
+			name:     "update to a deactivated code",
+			entity:   model.EntityMaterial,
+			stored:   map[string]string{"type": "lecture-notes", "language": "en"},
+			typ:      "slides",
+			language: "en",
+			wantType: "slides",
+			want:     model.TermErrors{{Field: "type", Vocabulary: model.VocabularyMaterialType, Code: "slides", Inactive: true}},
+			queries:  3,
+		},
+		{
+			name:     "update of other columns",
+			entity:   model.EntityMaterial,
+			stored:   stored,
+			columns:  []string{"title"},
+			typ:      "podcast",
+			language: "xx",
+			wantType: "podcast",
+		},
+		{
+			name:     "update of one column",
+			entity:   model.EntityMaterial,
+			stored:   stored,
+			columns:  []string{"language"},
+			typ:      "podcast",
+			language: "en",
+			wantType: "podcast",
+			queries:  2,
+		},
+		{
+			name:     "update of a missing record",
+			entity:   model.EntityMaterial,
+			typ:      "slides",
+			language: "la",
+			wantType: "slides",
+			wantErr:  sql.ErrNoRows,
+			queries:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &termQueryer{stored: tt.stored, terms: terms}
+			typ, language := tt.typ, tt.language
+			err := checkTerms(q, tt.entity, uuid.New(), tt.columns,
+				termField{"type", model.VocabularyMaterialType, &typ},
+				termField{"language", model.VocabularyLanguage, &language})
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("checkTerms = %v, want %v", err, tt.wantErr)
+				}
+			case tt.want != nil:
+				var termErrs model.TermErrors
+				if !errors.As(err, &termErrs) || !reflect.DeepEqual(termErrs, tt.want) {
+					t.Errorf("checkTerms = %v, want %v", err, tt.want)
+				}
+			case err != nil:
+				t.Errorf("checkTerms = %v", err)
+			}
+			if typ != tt.wantType {
+				t.Errorf("type = %q, want %q", typ, tt.wantType)
+			}
+			if q.queries != tt.queries {
+				t.Errorf("%d queries, want %d", q.queries, tt.queries)
+			}
+		})
+	}
+}
+
+func TestTermCode(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"fiction", "fiction"},
+		{" Science  Fiction ", "science-fiction"},
+		{"lecture_notes", "lecture-notes"},
+		{"EN", "en"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := termCode(tt.in); got != tt.want {
+			t.Errorf("termCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package identifiers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    byte
+	}{
+		{"7992739871", '3'},
+		{"0", '0'},
+		{"1", '8'},
+		{"59", '6'},
+		{"3000100000001", '0'},
+		{"2000100000001", '2'},
+		{"411111111111111", '1'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			if got := CheckDigit(tt.payload); got != tt.want {
+				t.Errorf("CheckDigit(%q) = %c, want %c", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"79927398713", nil},
+		{"30001000000010", nil},
+		{"79927398710", ErrCheckDigit},
+		{"7992739871a", ErrInvalidCharacter},
+		{"7", ErrLength},
+		{"", ErrLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := Validate(tt.id); !errors.Is(err, tt.want) {
+				t.Errorf("Validate(%q) = %v, want %v", tt.id, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateCatchesTypos checks the guarantees in the package comment:
+// every single-digit substitution, and every adjacent transposition other
+// than 09/90, is rejected.
+func TestValidateCatchesTypos(t *testing.T) {
+	id, err := DefaultItemScheme.Format(4711)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range id {
+		for d := byte('0'); d <= '9'; d++ {
+			if d == id[i] {
+				continue
+			}
+			typo := id[:i] + string(d) + id[i+1:]
+			if Validate(typo) == nil {
+				t.Errorf("substitution %s passes", typo)
+			}
+		}
+	}
+	for i := 0; i+1 < len(id); i++ {
+		a, b := id[i], id[i+1]
+		if a == b || (a == '0' && b == '9') || (a == '9' && b == '0') {
+			continue
+		}
+		swapped := id[:i] + string(b) + string(a) + id[i+2:]
+		if Validate(swapped) == nil {
+			t.Errorf("transposition %s passes", swapped)
+		}
+	}
+}
+
+func TestSchemeFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		scheme  Scheme
+		seq     int64
+		want    string
+		wantErr bool
+	}{
+		{"first item", DefaultItemScheme, 1, "30001000000010", false},
+		{"first patron", DefaultPatronScheme, 1, "20001000000012", false},
+		{"largest sequence", Scheme{Prefix: "9", Digits: 2}, 99, "999" + string(CheckDigit("999")), false},
+		{"sequence too long", Scheme{Prefix: "9", Digits: 2}, 100, "", true},
+		{"negative sequence", DefaultItemScheme, -1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.scheme.Format(tt.seq)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Format(%d) error = %v, wantErr %v", tt.seq, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%d) = %q, want %q", tt.seq, got, tt.want)
+			}
+			if err == nil {
+				if err := tt.scheme.Check(got); err != nil {
+					t.Errorf("Check(%q) = %v", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemeCheck(t *testing.T) {
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"30001000000010", nil},
+		{"20001000000012", ErrPrefix},
+		{"3000100000001", ErrLength},
+		{"30001000000011", ErrCheckDigit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := DefaultItemScheme.Check(tt.id); !errors.Is(err, tt.want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.id, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		scheme  Scheme
+		wantErr bool
+	}{
+		{"default", DefaultPatronScheme, false},
+		{"no prefix", Scheme{Digits: 6}, false},
+		{"no digits", Scheme{Prefix: "2"}, true},
+		{"too many digits", Scheme{Prefix: "2", Digits: 19}, true},
+		{"letters in prefix", Scheme{Prefix: "2A", Digits: 6}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.scheme.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{" 3000-1000 0000 10 ", "30001000000010"},
+		{"A30001000000010B", "30001000000010"},
+		{"d30001000000010a", "30001000000010"},
+		{"A30001000000010", "A30001000000010"},
+		{"A", "A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Scheme
+		wantErr bool
+	}{
+		{"defaults", nil, DefaultItemScheme, false},
+		{"override", map[string]string{"TEST_ITEM_PREFIX": "39", "TEST_ITEM_DIGITS": "6"}, Scheme{Prefix: "39", Digits: 6}, false},
+		{"bad digits", map[string]string{"TEST_ITEM_DIGITS": "six"}, Scheme{}, true},
+		{"bad prefix", map[string]string{"TEST_ITEM_PREFIX": "X"}, Scheme{Prefix: "X", Digits: 8}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := SchemeFromEnv("TEST_ITEM", DefaultItemScheme)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SchemeFromEnv error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SchemeFromEnv = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package isbn validates International Standard Book Numbers and converts
+// between the 10- and 13-digit forms.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrLength    = errors.New("ISBN must have 10 or 13 digits")
+	ErrCharacter = errors.New("ISBN contains an invalid character")
+	ErrChecksum  = errors.New("ISBN check digit does not match")
+	ErrPrefix    = errors.New("ISBN-13 must start with 978 or 979")
+)
+
+// ISBN holds both forms of one edition's number, without hyphens. ISBN10 is
+// empty for 979-prefixed numbers, which have no 10-digit form.
+type ISBN struct {
+	ISBN13 string
+	ISBN10 string
+}
+
+// Parse accepts an ISBN-10 or ISBN-13, with or without hyphens or spaces
+// and an optional "ISBN" label, and returns both forms.
+func Parse(s string) (ISBN, error) {
+	n := Normalize(s)
+	switch len(n) {
+	case 10:
+		if err := validate10(n); err != nil {
+			return ISBN{}, err
+		}
+		return ISBN{ISBN13: To13(n), ISBN10: n}, nil
+	case 13:
+		if err := validate13(n); err != nil {
+			return ISBN{}, err
+		}
+		return ISBN{ISBN13: n, ISBN10: To10(n)}, nil
+	}
+	return ISBN{}, ErrLength
+}
+
+// Normalize removes the "ISBN" label, hyphens and spaces and upper-cases a
+// trailing x. It does not validate.
+func Normalize(s string) string {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	for _, label := range []string{"ISBN-13:", "ISBN-10:", "ISBN13:", "ISBN10:", "ISBN:", "ISBN"} {
+		if strings.HasPrefix(upper, label) {
+			s = s[len(label):]
+			break
+		}
+	}
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+}
+
+func validate10(n string) error {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var d int
+		switch c := n[i]; {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return ErrCharacter
+		}
+		sum += (10 - i) * d
+	}
+	if sum%11 != 0 {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func validate13(n string) error {
+	for i := 0; i < 13; i++ {
+		if n[i] < '0' || n[i] > '9' {
+			return ErrCharacter
+		}
+	}
+	if !strings.HasPrefix(n, "978") && !strings.HasPrefix(n, "979") {
+		return ErrPrefix
+	}
+	if check13(n[:12]) != n[12] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func check13(payload string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(payload[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func check10(payload string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(payload[i]-'0')
+	}
+	d := (11 - sum%11) % 11
+	if d == 10 {
+		return 'X'
+	}
+	return byte('0' + d)
+}
+
+// To13 converts a valid ISBN-10 to its ISBN-13.
+func To13(isbn10 string) string {
+	payload := "978" + isbn10[:9]
+	return payload + string(check13(payload))
+}
+
+// To10 converts a valid 978-prefixed ISBN-13 to its ISBN-10, and returns ""
+// for 979-prefixed numbers.
+func To10(isbn13 string) string {
+	if !strings.HasPrefix(isbn13, "978") {
+		return ""
+	}
+	payload := isbn13[3:12]
+	return payload + string(check10(payload))
+}
@@ -0,0 +1,72 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want ISBN
+		err  error
+	}{
+		{"ISBN-10", "0306406152", ISBN{ISBN13: "9780306406157", ISBN10: "0306406152"}, nil},
+		{"ISBN-13", "9780306406157", ISBN{ISBN13: "9780306406157", ISBN10: "0306406152"}, nil},
+		{"hyphens and label", "ISBN 978-0-306-40615-7", ISBN{ISBN13: "9780306406157", ISBN10: "0306406152"}, nil},
+		{"ISBN-10 label", "isbn-10: 0-306-40615-2", ISBN{ISBN13: "9780306406157", ISBN10: "0306406152"}, nil},
+		{"check digit X", "080442957x", ISBN{ISBN13: "9780804429573", ISBN10: "080442957X"}, nil},
+		{"979 has no ISBN-10", "979-10-90636-07-1", ISBN{ISBN13: "9791090636071"}, nil},
+		{"ISBN-10 checksum", "0306406153", ISBN{}, ErrChecksum},
+		{"ISBN-13 checksum", "9780306406158", ISBN{}, ErrChecksum},
+		{"X inside ISBN-10", "03064X6152", ISBN{}, ErrCharacter},
+		{"letter in ISBN-13", "978030640615A", ISBN{}, ErrCharacter},
+		{"wrong prefix", "9770306406155", ISBN{}, ErrPrefix},
+		{"too short", "030640615", ISBN{}, ErrLength},
+		{"empty", "", ISBN{}, ErrLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	for _, isbn10 := range []string{"0306406152", "080442957X", "0000000000", "1861972717", "0131103628"} {
+		t.Run(isbn10, func(t *testing.T) {
+			isbn13 := To13(isbn10)
+			if err := validate13(isbn13); err != nil {
+				t.Fatalf("To13(%q) = %q: %v", isbn10, isbn13, err)
+			}
+			if back := To10(isbn13); back != isbn10 {
+				t.Errorf("To10(To13(%q)) = %q", isbn10, back)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{" ISBN-13: 978-0-306-40615-7 ", "9780306406157"},
+		{"ISBN:0 8044 2957 x", "080442957X"},
+		{"isbn0306406152", "0306406152"},
+		{"978 0 306", "9780306"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
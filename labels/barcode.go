@@ -0,0 +1,153 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Symbology is a linear barcode encoding.
+type Symbology string
+
+const (
+	Code128 Symbology = "code128"
+	Codabar Symbology = "codabar"
+)
+
+var ErrUnsupportedSymbology = errors.New("unsupported barcode symbology")
+
+// Encode returns the widths of the bars and spaces of data in units of the
+// narrowest module, starting with a bar. Quiet zones are not included.
+func Encode(sym Symbology, data string) ([]int, error) {
+	switch sym {
+	case Code128:
+		return encodeCode128(data)
+	case Codabar:
+		return encodeCodabar(data)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedSymbology, sym)
+}
+
+// CODE 128
+
+// code128Patterns holds the bar/space widths of symbol values 0-105, followed
+// by the stop pattern.
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// encodeCode128 uses code set C for even-length digit strings, which is what
+// card numbers and item barcodes are, and code set B for anything else.
+func encodeCode128(data string) ([]int, error) {
+	if data == "" {
+		return nil, errors.New("code 128: empty data")
+	}
+
+	var values []int
+	if len(data)%2 == 0 && isDigits(data) {
+		values = append(values, code128StartC)
+		for i := 0; i < len(data); i += 2 {
+			values = append(values, int(data[i]-'0')*10+int(data[i+1]-'0'))
+		}
+	} else {
+		values = append(values, code128StartB)
+		for i := 0; i < len(data); i++ {
+			c := data[i]
+			if c < 32 || c > 126 {
+				return nil, fmt.Errorf("code 128: character %q cannot be encoded", c)
+			}
+			values = append(values, int(c)-32)
+		}
+	}
+
+	check := values[0]
+	for i, v := range values[1:] {
+		check += (i + 1) * v
+	}
+	values = append(values, check%103, code128Stop)
+
+	var widths []int
+	for _, v := range values {
+		for _, w := range code128Patterns[v] {
+			widths = append(widths, int(w-'0'))
+		}
+	}
+	return widths, nil
+}
+
+// CODABAR
+
+// codabarPatterns marks the wide elements of each character's seven bars and
+// spaces.
+var codabarPatterns = map[byte]string{
+	'0': "0000011", '1': "0000110", '2': "0001001", '3': "1100000",
+	'4': "0010010", '5': "1000010", '6': "0100001", '7': "0100100",
+	'8': "0110000", '9': "1001000", '-': "0001100", '$': "0011000",
+	':': "1000101", '/': "1010001", '.': "1010100", '+': "0010101",
+	'A': "0011010", 'B': "0101001", 'C': "0001011", 'D': "0001110",
+}
+
+// codabarWide is the width of a wide element; Codabar allows 2.25 to 3 times
+// the narrow width.
+const codabarWide = 3
+
+// encodeCodabar wraps data in A/B start and stop characters unless it
+// already carries its own.
+func encodeCodabar(data string) ([]int, error) {
+	if data == "" {
+		return nil, errors.New("codabar: empty data")
+	}
+	data = strings.ToUpper(data)
+	if !isCodabarGuard(data[0]) || !isCodabarGuard(data[len(data)-1]) || len(data) < 2 {
+		data = "A" + data + "B"
+	}
+
+	var widths []int
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		pattern, ok := codabarPatterns[c]
+		if !ok || (isCodabarGuard(c) && i != 0 && i != len(data)-1) {
+			return nil, fmt.Errorf("codabar: character %q cannot be encoded", c)
+		}
+		if i > 0 {
+			widths = append(widths, 1)
+		}
+		for _, e := range pattern {
+			if e == '1' {
+				widths = append(widths, codabarWide)
+			} else {
+				widths = append(widths, 1)
+			}
+		}
+	}
+	return widths, nil
+}
+
+func isCodabarGuard(c byte) bool {
+	return c >= 'A' && c <= 'D'
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package labels
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// decodeCode128 maps widths back to symbol values.
+func decodeCode128(t *testing.T, widths []int) []int {
+	t.Helper()
+	var values []int
+	for len(widths) > 0 {
+		n := min(6, len(widths))
+		if len(widths) == 7 {
+			n = 7
+		}
+		var pattern strings.Builder
+		for _, w := range widths[:n] {
+			pattern.WriteByte(byte('0' + w))
+		}
+		v := slices.Index(code128Patterns[:], pattern.String())
+		if v < 0 {
+			t.Fatalf("pattern %s is not a code 128 symbol", pattern.String())
+		}
+		values = append(values, v)
+		widths = widths[n:]
+	}
+	return values
+}
+
+func TestEncodeCode128(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		values []int
+	}{
+		// 105 + 1*30 + 3*10 + 7*10 = 235, and 235 mod 103 = 29.
+		{"code set C for even digits", "30001000000010", []int{code128StartC, 30, 0, 10, 0, 0, 0, 10, 29, code128Stop}},
+		// 104 + 1*33 + 2*34 + 3*13 + 4*17 = 312, and 312 mod 103 = 3.
+		{"code set B for text", "AB-1", []int{code128StartB, 33, 34, 13, 17, 3, code128Stop}},
+		// 104 + 1*17 + 2*18 + 3*19 = 214, and 214 mod 103 = 8.
+		{"code set B for odd digits", "123", []int{code128StartB, 17, 18, 19, 8, code128Stop}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			widths, err := Encode(Code128, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := decodeCode128(t, widths); !slices.Equal(got, tt.values) {
+				t.Errorf("values = %v, want %v", got, tt.values)
+			}
+			modules := 0
+			for _, w := range widths {
+				modules += w
+			}
+			if want := 11*(len(tt.values)-1) + 13; modules != want {
+				t.Errorf("%d modules, want %d", modules, want)
+			}
+		})
+	}
+}
+
+func TestEncodeCodabar(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		chars string
+	}{
+		{"adds start and stop", "123", "A123B"},
+		{"keeps its own guards", "c20001000000012d", "C20001000000012D"},
+		{"punctuation", "1-2$3", "A1-2$3B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			widths, err := Encode(Codabar, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 8*len(tt.chars) - 1; len(widths) != want {
+				t.Fatalf("%d elements, want %d", len(widths), want)
+			}
+			for i := 0; i < len(tt.chars); i++ {
+				var pattern strings.Builder
+				for _, w := range widths[i*8 : i*8+7] {
+					if w == codabarWide {
+						pattern.WriteByte('1')
+					} else {
+						pattern.WriteByte('0')
+					}
+				}
+				if want := codabarPatterns[tt.chars[i]]; pattern.String() != want {
+					t.Errorf("character %d = %s, want %c (%s)", i, pattern.String(), tt.chars[i], want)
+				}
+				if i > 0 && widths[i*8-1] != 1 {
+					t.Errorf("gap before character %d is %d wide", i, widths[i*8-1])
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sym  Symbology
+		data string
+		want error
+	}{
+		{"code 128 empty", Code128, "", nil},
+		{"code 128 outside ASCII", Code128, "café", nil},
+		{"codabar empty", Codabar, "", nil},
+		{"codabar letter", Codabar, "12X3", nil},
+		{"codabar guard inside", Codabar, "A12B34B", nil},
+		{"unknown symbology", Symbology("qr"), "123", ErrUnsupportedSymbology},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Encode(tt.sym, tt.data)
+			if err == nil {
+				t.Fatal("Encode succeeded")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Encode = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package labels
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Layout is a sheet of equally sized labels. All lengths are in millimetres.
+type Layout struct {
+	Name        string
+	Description string
+
+	PageWidth, PageHeight   float64
+	Columns, Rows           int
+	LabelWidth, LabelHeight float64
+
+	// MarginLeft and MarginTop locate the first label; the pitches are the
+	// distances between the corners of neighbouring labels.
+	MarginLeft, MarginTop          float64
+	PitchHorizontal, PitchVertical float64
+}
+
+// PerPage is the number of labels on one sheet.
+func (l Layout) PerPage() int {
+	return l.Columns * l.Rows
+}
+
+// origin returns the top-left corner of the i-th label on a sheet.
+func (l Layout) origin(i int) (x, y float64) {
+	col, row := i%l.Columns, i/l.Columns
+	return l.MarginLeft + float64(col)*l.PitchHorizontal, l.MarginTop + float64(row)*l.PitchVertical
+}
+
+const inch = 25.4
+
+// Layouts are the supported label and card stocks, by name.
+var Layouts = map[string]Layout{
+	"avery-5160": {
+		Name: "avery-5160", Description: "US Letter, 30 address labels (1\" x 2 5/8\")",
+		PageWidth: 8.5 * inch, PageHeight: 11 * inch, Columns: 3, Rows: 10,
+		LabelWidth: 2.625 * inch, LabelHeight: 1 * inch,
+		MarginLeft: 0.1875 * inch, MarginTop: 0.5 * inch,
+		PitchHorizontal: 2.75 * inch, PitchVertical: 1 * inch,
+	},
+	"avery-l7160": {
+		Name: "avery-l7160", Description: "A4, 21 labels (63.5 x 38.1 mm)",
+		PageWidth: 210, PageHeight: 297, Columns: 3, Rows: 7,
+		LabelWidth: 63.5, LabelHeight: 38.1,
+		MarginLeft: 7.25, MarginTop: 15.15,
+		PitchHorizontal: 66.04, PitchVertical: 38.1,
+	},
+	"avery-l7651": {
+		Name: "avery-l7651", Description: "A4, 65 mini labels (38.1 x 21.2 mm)",
+		PageWidth: 210, PageHeight: 297, Columns: 5, Rows: 13,
+		LabelWidth: 38.1, LabelHeight: 21.2,
+		MarginLeft: 4.75, MarginTop: 10.7,
+		PitchHorizontal: 40.64, PitchVertical: 21.2,
+	},
+	"avery-5371": {
+		Name: "avery-5371", Description: "US Letter, 10 business cards (2\" x 3 1/2\")",
+		PageWidth: 8.5 * inch, PageHeight: 11 * inch, Columns: 2, Rows: 5,
+		LabelWidth: 3.5 * inch, LabelHeight: 2 * inch,
+		MarginLeft: 0.75 * inch, MarginTop: 0.5 * inch,
+		PitchHorizontal: 3.5 * inch, PitchVertical: 2 * inch,
+	},
+	"avery-c32011": {
+		Name: "avery-c32011", Description: "A4, 10 business cards (85 x 54 mm)",
+		PageWidth: 210, PageHeight: 297, Columns: 2, Rows: 5,
+		LabelWidth: 85, LabelHeight: 54,
+		MarginLeft: 15, MarginTop: 13.5,
+		PitchHorizontal: 95, PitchVertical: 54,
+	},
+}
+
+// Default layouts for book labels and patron cards.
+const (
+	DefaultBookLayout   = "avery-l7160"
+	DefaultPatronLayout = "avery-c32011"
+)
+
+// LayoutByName looks up one of Layouts.
+func LayoutByName(name string) (Layout, error) {
+	l, ok := Layouts[name]
+	if !ok {
+		return Layout{}, fmt.Errorf("unknown label layout %q, expected one of %v", name, LayoutNames())
+	}
+	return l, nil
+}
+
+// LayoutNames lists the names of Layouts in sorted order.
+func LayoutNames() []string {
+	names := make([]string, 0, len(Layouts))
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
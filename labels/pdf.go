@@ -0,0 +1,116 @@
+package labels
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ptPerMM converts millimetres to PDF points.
+const ptPerMM = 72 / 25.4
+
+// pdfCanvas writes a minimal PDF 1.4 document. Each page's content stream
+// starts with a transform to millimetres with the origin at the top left,
+// so the drawing code is shared with SVG.
+type pdfCanvas struct {
+	pageWidth, pageHeight float64
+	pages                 []*bytes.Buffer
+}
+
+func newPDFCanvas(pageWidth, pageHeight float64) *pdfCanvas {
+	return &pdfCanvas{pageWidth: pageWidth, pageHeight: pageHeight}
+}
+
+func (c *pdfCanvas) current() *bytes.Buffer {
+	return c.pages[len(c.pages)-1]
+}
+
+func (c *pdfCanvas) newPage() {
+	page := &bytes.Buffer{}
+	fmt.Fprintf(page, "%.5f 0 0 %.5f 0 %.3f cm\n", ptPerMM, -ptPerMM, c.pageHeight*ptPerMM)
+	c.pages = append(c.pages, page)
+}
+
+func (c *pdfCanvas) rect(x, y, w, h float64) {
+	fmt.Fprintf(c.current(), "%.3f %.3f %.3f %.3f re f\n", x, y, w, h)
+}
+
+func (c *pdfCanvas) strokeRect(x, y, w, h float64) {
+	fmt.Fprintf(c.current(), "q 0.6 G 0.2 w %.3f %.3f %.3f %.3f re S Q\n", x, y, w, h)
+}
+
+func (c *pdfCanvas) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	// The text matrix flips y back so glyphs are upright.
+	fmt.Fprintf(c.current(), "BT /%s %.3f Tf 1 0 0 -1 %.3f %.3f Tm (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// pdfString encodes s for a literal string in WinAnsiEncoding. Characters
+// outside Latin-1 are replaced.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func (c *pdfCanvas) WriteTo(w io.Writer) (int64, error) {
+	var doc bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, doc.Len())
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are fixed; each page then takes two objects, the page
+	// and its content stream.
+	kids := make([]string, len(c.pages))
+	for i := range c.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(c.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range c.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.3f %.3f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			c.pageWidth*ptPerMM, c.pageHeight*ptPerMM, 6+2*i))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return doc.WriteTo(w)
+}
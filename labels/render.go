@@ -0,0 +1,221 @@
+// Package labels renders sheets of barcode labels for books and library
+// cards for patrons. Barcodes and documents are generated in pure Go; PDF
+// output uses the standard Helvetica fonts so nothing has to be embedded.
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Format is an output document format.
+type Format string
+
+const (
+	PDF Format = "pdf"
+	SVG Format = "svg"
+)
+
+// ContentType returns the MIME type of documents in format f.
+func (f Format) ContentType() string {
+	if f == SVG {
+		return "image/svg+xml"
+	}
+	return "application/pdf"
+}
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	ErrLabelTooSmall     = errors.New("label is too small for the barcode")
+)
+
+// Label is the content of one label: a bold heading, further lines of text
+// and the barcode, printed with its human-readable digits.
+type Label struct {
+	Heading string
+	Lines   []string
+	Code    string
+}
+
+// Options control how labels are laid out on sheets.
+type Options struct {
+	Layout    Layout
+	Symbology Symbology
+
+	// Skip leaves the first labels of the first sheet blank, so a partly
+	// used sheet can be fed through the printer again.
+	Skip int
+
+	// Outline draws the label borders, for test prints on plain paper.
+	Outline bool
+}
+
+// Narrowest printable bar, and the quiet zone on either side of a barcode
+// in modules.
+const (
+	minModule  = 0.15
+	maxModule  = 0.5
+	quietZone  = 10
+	lineFactor = 1.2
+)
+
+// Render writes labels as a document in format f.
+func Render(w io.Writer, f Format, opts Options, labels []Label) error {
+	var c canvas
+	switch f {
+	case PDF:
+		c = newPDFCanvas(opts.Layout.PageWidth, opts.Layout.PageHeight)
+	case SVG:
+		c = newSVGCanvas(opts.Layout.PageWidth, opts.Layout.PageHeight)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, f)
+	}
+	if opts.Layout.PerPage() == 0 {
+		return errors.New("label layout has no labels")
+	}
+
+	bars := make([][]int, len(labels))
+	for i, l := range labels {
+		widths, err := Encode(opts.Symbology, l.Code)
+		if err != nil {
+			return fmt.Errorf("label %d: %w", i+1, err)
+		}
+		bars[i] = widths
+	}
+
+	perPage := opts.Layout.PerPage()
+	skip := max(opts.Skip, 0) % perPage
+	for i, l := range labels {
+		slot := skip + i
+		if slot%perPage == 0 || i == 0 {
+			c.newPage()
+		}
+		x, y := opts.Layout.origin(slot % perPage)
+		if opts.Outline {
+			c.strokeRect(x, y, opts.Layout.LabelWidth, opts.Layout.LabelHeight)
+		}
+		if err := drawLabel(c, x, y, opts.Layout.LabelWidth, opts.Layout.LabelHeight, l, bars[i]); err != nil {
+			return fmt.Errorf("label %d: %w", i+1, err)
+		}
+	}
+	if len(labels) == 0 {
+		c.newPage()
+	}
+
+	_, err := c.WriteTo(w)
+	return err
+}
+
+// drawLabel lays out one label inside the box at x, y: text from the top,
+// the barcode and its digits at the bottom. Text lines that would squeeze
+// the barcode below a scannable height are dropped.
+func drawLabel(c canvas, x, y, w, h float64, l Label, bars []int) error {
+	pad := math.Min(2.5, h*0.07)
+	heading := clamp(h*0.1, 2.2, 4.5)
+	body := heading * 0.8
+	digits := body * 0.9
+	inner := w - 2*pad
+
+	modules := quietZone * 2
+	for _, b := range bars {
+		modules += b
+	}
+	module := math.Min(inner/float64(modules), maxModule)
+	if module < minModule {
+		return ErrLabelTooSmall
+	}
+
+	digitsBaseline := y + h - pad
+	barsBottom := digitsBaseline - digits - 0.3
+	minBars := math.Max(h*0.25, 4)
+
+	lines := append([]string{}, l.Lines...)
+	textBottom := func() float64 {
+		bottom := y + pad
+		if l.Heading != "" {
+			bottom += heading * lineFactor
+		}
+		return bottom + float64(len(lines))*body*lineFactor
+	}
+	for len(lines) > 0 && barsBottom-textBottom()-0.8 < minBars {
+		lines = lines[:len(lines)-1]
+	}
+	barsTop := math.Max(textBottom()+0.8, barsBottom-h*0.45)
+
+	cursor := y + pad
+	if l.Heading != "" {
+		cursor += heading
+		c.text(x+pad, cursor, heading, true, fit(l.Heading, heading, inner))
+		cursor += heading * (lineFactor - 1)
+	}
+	for _, line := range lines {
+		cursor += body
+		c.text(x+pad, cursor, body, false, fit(line, body, inner))
+		cursor += body * (lineFactor - 1)
+	}
+
+	barsWidth := float64(modules-2*quietZone) * module
+	bx := x + (w-barsWidth)/2
+	for i, b := range bars {
+		if i%2 == 0 {
+			c.rect(bx, barsTop, float64(b)*module, barsBottom-barsTop)
+		}
+		bx += float64(b) * module
+	}
+
+	c.text(x+(w-textWidth(l.Code, digits))/2, digitsBaseline, digits, false, l.Code)
+	return nil
+}
+
+// fit shortens s with an ellipsis until it fits in width at size.
+func fit(s string, size, width float64) string {
+	if textWidth(s, size) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && textWidth(string(r)+"...", size) > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// canvas draws in millimetres from the top-left corner of the page.
+type canvas interface {
+	io.WriterTo
+	newPage()
+	rect(x, y, w, h float64)
+	strokeRect(x, y, w, h float64)
+	// text draws s with its baseline at y.
+	text(x, y, size float64, bold bool, s string)
+}
+
+// helveticaWidths are the advance widths of ASCII 32-126 in Helvetica, in
+// thousandths of the font size.
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// textWidth estimates the width of s at size. Bold text runs a little wider
+// than this; the margins absorb the difference.
+func textWidth(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += helveticaWidths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
@@ -0,0 +1,183 @@
+package labels
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLayoutsFitTheirPages(t *testing.T) {
+	for _, name := range LayoutNames() {
+		t.Run(name, func(t *testing.T) {
+			l, err := LayoutByName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.Name != name {
+				t.Errorf("Name = %q", l.Name)
+			}
+			right := l.MarginLeft + float64(l.Columns-1)*l.PitchHorizontal + l.LabelWidth
+			bottom := l.MarginTop + float64(l.Rows-1)*l.PitchVertical + l.LabelHeight
+			if right > l.PageWidth+0.01 || bottom > l.PageHeight+0.01 {
+				t.Errorf("labels reach %.2f x %.2f mm on a %.2f x %.2f mm page", right, bottom, l.PageWidth, l.PageHeight)
+			}
+			if l.PitchHorizontal < l.LabelWidth || l.PitchVertical < l.LabelHeight {
+				t.Errorf("labels overlap")
+			}
+		})
+	}
+}
+
+func TestLayoutByName(t *testing.T) {
+	if _, err := LayoutByName("avery-9999"); err == nil {
+		t.Error("unknown layout was found")
+	}
+	names := LayoutNames()
+	if len(names) != len(Layouts) || !slices.IsSorted(names) {
+		t.Errorf("LayoutNames = %v", names)
+	}
+	for _, name := range []string{DefaultBookLayout, DefaultPatronLayout} {
+		if _, err := LayoutByName(name); err != nil {
+			t.Errorf("default layout %q: %v", name, err)
+		}
+	}
+}
+
+func testLabels(n int) []Label {
+	labels := make([]Label, n)
+	for i := range labels {
+		labels[i] = Label{Heading: "Dune", Lines: []string{"Frank Herbert"}, Code: fmt.Sprintf("300010000%05d", i)}
+	}
+	return labels
+}
+
+func TestRenderPages(t *testing.T) {
+	cards := Layouts[DefaultPatronLayout] // 10 per sheet
+	tests := []struct {
+		name   string
+		format Format
+		labels int
+		skip   int
+		pages  int
+	}{
+		{"no labels", PDF, 0, 0, 1},
+		{"one sheet", PDF, 10, 0, 1},
+		{"two sheets", PDF, 11, 0, 2},
+		{"skip onto a second sheet", PDF, 2, 9, 2},
+		{"skip wraps around", PDF, 10, 10, 1},
+		{"negative skip", PDF, 10, -3, 1},
+		{"svg", SVG, 12, 9, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := Options{Layout: cards, Symbology: Code128, Skip: tt.skip}
+			if err := Render(&buf, tt.format, opts, testLabels(tt.labels)); err != nil {
+				t.Fatal(err)
+			}
+			var pages int
+			switch tt.format {
+			case PDF:
+				if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-1.4")) || !bytes.HasSuffix(buf.Bytes(), []byte("%%EOF\n")) {
+					t.Fatal("output is not a PDF document")
+				}
+				fmt.Sscanf(buf.String()[strings.Index(buf.String(), "/Count "):], "/Count %d", &pages)
+			case SVG:
+				pages = strings.Count(buf.String(), `fill="#fff"`)
+			}
+			if pages != tt.pages {
+				t.Errorf("%d pages, want %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tiny := Layout{PageWidth: 50, PageHeight: 50, Columns: 1, Rows: 1, LabelWidth: 10, LabelHeight: 10, PitchHorizontal: 10, PitchVertical: 10}
+	tests := []struct {
+		name   string
+		format Format
+		opts   Options
+		labels []Label
+		want   error
+	}{
+		{"unknown format", Format("png"), Options{Layout: tiny, Symbology: Code128}, testLabels(1), ErrUnsupportedFormat},
+		{"label too small", PDF, Options{Layout: tiny, Symbology: Code128}, testLabels(1), ErrLabelTooSmall},
+		{"unencodable code", SVG, Options{Layout: Layouts[DefaultBookLayout], Symbology: Codabar}, []Label{{Code: "X"}}, nil},
+		{"empty layout", SVG, Options{Symbology: Code128}, testLabels(1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Render(&bytes.Buffer{}, tt.format, tt.opts, tt.labels)
+			if err == nil {
+				t.Fatal("Render succeeded")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Render = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderDropsLinesBeforeShrinkingBars checks that a crowded label keeps
+// its heading and barcode digits and loses text lines instead.
+func TestRenderDropsLinesBeforeShrinkingBars(t *testing.T) {
+	var buf bytes.Buffer
+	label := Label{Heading: "Dune", Lines: []string{"line one", "line two", "line three", "line four", "line five"}, Code: "30001000000010"}
+	if err := Render(&buf, SVG, Options{Layout: Layouts["avery-l7651"], Symbology: Code128}, []Label{label}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{">Dune<", ">30001000000010<"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %s", want)
+		}
+	}
+	if strings.Contains(out, ">line five<") {
+		t.Error("all lines were kept on a mini label")
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		s     string
+		width float64
+		want  string
+	}{
+		{"Dune", 100, "Dune"},
+		{"The Left Hand of Darkness", 20, "The Left Han..."},
+		{"WWWW", 1, "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got := fit(tt.s, 3, tt.width)
+			if got != tt.want {
+				t.Errorf("fit(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+			if got != tt.s && textWidth(got, 3) > tt.width && got != "..." {
+				t.Errorf("fit(%q) = %q is %.2f wide, more than %.2f", tt.s, got, textWidth(got, 3), tt.width)
+			}
+		})
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`a(b)c\d`, `a\(b\)c\\d`},
+		{"café", `caf\351`},
+		{"日本", "??"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := pdfString(tt.in); got != tt.want {
+				t.Errorf("pdfString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+)
+
+// svgCanvas stacks the sheets vertically in a single SVG document. It suits
+// previews and single sheets; PDF is the better choice for printing batches.
+type svgCanvas struct {
+	pageWidth, pageHeight float64
+	pages                 int
+	body                  bytes.Buffer
+}
+
+func newSVGCanvas(pageWidth, pageHeight float64) *svgCanvas {
+	return &svgCanvas{pageWidth: pageWidth, pageHeight: pageHeight}
+}
+
+func (c *svgCanvas) offset() float64 {
+	return float64(c.pages-1) * c.pageHeight
+}
+
+func (c *svgCanvas) newPage() {
+	c.pages++
+	fmt.Fprintf(&c.body, `<rect x="0" y="%.2f" width="%.2f" height="%.2f" fill="#fff" stroke="#ccc" stroke-width="0.2"/>`+"\n",
+		c.offset(), c.pageWidth, c.pageHeight)
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64) {
+	fmt.Fprintf(&c.body, `<rect x="%.3f" y="%.3f" width="%.3f" height="%.3f"/>`+"\n", x, y+c.offset(), w, h)
+}
+
+func (c *svgCanvas) strokeRect(x, y, w, h float64) {
+	fmt.Fprintf(&c.body, `<rect x="%.3f" y="%.3f" width="%.3f" height="%.3f" fill="none" stroke="#999" stroke-width="0.2"/>`+"\n",
+		x, y+c.offset(), w, h)
+}
+
+func (c *svgCanvas) text(x, y, size float64, bold bool, s string) {
+	weight := ""
+	if bold {
+		weight = ` font-weight="bold"`
+	}
+	fmt.Fprintf(&c.body, `<text x="%.3f" y="%.3f" font-size="%.3f"%s>%s</text>`+"\n", x, y+c.offset(), size, weight, html.EscapeString(s))
+}
+
+func (c *svgCanvas) WriteTo(w io.Writer) (int64, error) {
+	var doc bytes.Buffer
+	height := float64(c.pages) * c.pageHeight
+	fmt.Fprintf(&doc, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&doc, `<svg xmlns="http://www.w3.org/2000/svg" width="%.2fmm" height="%.2fmm" viewBox="0 0 %.2f %.2f" font-family="Helvetica, Arial, sans-serif">`+"\n",
+		c.pageWidth, height, c.pageWidth, height)
+	doc.Write(c.body.Bytes())
+	doc.WriteString("</svg>\n")
+	return doc.WriteTo(w)
+}
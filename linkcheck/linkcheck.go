@@ -0,0 +1,213 @@
+// Package linkcheck finds links that no longer lead anywhere. A Checker
+// requests each link, HEAD first and GET where HEAD is refused, following
+// redirects, with a bounded number of requests in flight and a minimum gap
+// between requests to the same host, so a catalogue with hundreds of links
+// to one site does not hammer it.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+)
+
+// Defaults for NewChecker.
+const (
+	DefaultTimeout      = 15 * time.Second
+	DefaultConcurrency  = 8
+	DefaultHostInterval = time.Second
+
+	maxRedirects = 10
+	userAgent    = "Library-Management link checker"
+)
+
+// Result is the outcome of checking one link. StatusCode is that of the
+// last response and zero if there was none; FinalURL is where redirects
+// ended. Error says why a broken link is broken.
+type Result struct {
+	URL        string
+	Status     string
+	StatusCode int
+	FinalURL   string
+	Error      string
+}
+
+// Checker checks links. Timeout bounds each request of a link, redirects
+// included, but not the wait for its host's turn. Concurrency bounds the
+// links checked at once, and HostInterval is the least time between two
+// requests to one host.
+type Checker struct {
+	Client       *http.Client
+	Timeout      time.Duration
+	Concurrency  int
+	HostInterval time.Duration
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+}
+
+// NewChecker returns a checker with the default limits.
+func NewChecker() *Checker {
+	return &Checker{
+		Client: &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return nil
+			},
+		},
+		Timeout:      DefaultTimeout,
+		Concurrency:  DefaultConcurrency,
+		HostInterval: DefaultHostInterval,
+	}
+}
+
+// wait blocks until a request to host may be made, and books the slot.
+func (c *Checker) wait(ctx context.Context, host string) error {
+	c.mu.Lock()
+	if c.nextSlot == nil {
+		c.nextSlot = map[string]time.Time{}
+	}
+	now := time.Now()
+	for h, slot := range c.nextSlot {
+		if slot.Before(now) {
+			delete(c.nextSlot, h)
+		}
+	}
+	slot := now
+	if next, ok := c.nextSlot[host]; ok && next.After(now) {
+		slot = next
+	}
+	c.nextSlot[host] = slot.Add(c.HostInterval)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// request makes one request of the link and discards the response body.
+// The timeout starts once the host's turn has come.
+func (c *Checker) request(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	if err := c.wait(ctx, strings.ToLower(u.Hostname())); err != nil {
+		return nil, err
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return resp, nil
+}
+
+// Check checks one link. It is ok if it answers 2xx, or 401 or 403: the
+// page is there, behind a login. Anything else, including no answer in
+// time, makes it broken.
+func (c *Checker) Check(ctx context.Context, link string) Result {
+	result := Result{URL: link, Status: model.LinkStatusBroken}
+	u, err := url.Parse(link)
+	if err != nil {
+		result.Error = "invalid URL"
+		return result
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		result.Error = "not an http or https URL"
+		return result
+	}
+
+	// Some servers refuse or mishandle HEAD; only a definite answer is
+	// taken without trying GET.
+	resp, err := c.request(ctx, http.MethodHead, u)
+	if err == nil && !definite(resp.StatusCode) {
+		resp, err = c.request(ctx, http.MethodGet, u)
+	}
+	if err != nil {
+		result.Error = requestError(err)
+		return result
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+	switch {
+	case resp.StatusCode/100 == 2, resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		result.Status = model.LinkStatusOK
+	default:
+		result.Error = resp.Status
+	}
+	return result
+}
+
+// definite reports whether a HEAD response settles a link's status.
+func definite(code int) bool {
+	return code/100 == 2 || code == http.StatusNotFound || code == http.StatusGone
+}
+
+// requestError describes a request that got no response.
+func requestError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Timeout() {
+			return "timed out"
+		}
+		err = urlErr.Err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timed out"
+	}
+	return err.Error()
+}
+
+// CheckAll checks the links, calling fn with each result as it comes in.
+// fn may be called from several goroutines at once. It returns once every
+// link is checked, or ctx is done.
+func (c *Checker) CheckAll(ctx context.Context, links []string, fn func(Result)) {
+	workers := max(c.Concurrency, 1)
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range min(workers, len(links)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				fn(c.Check(ctx, link))
+			}
+		}()
+	}
+
+	for _, link := range links {
+		select {
+		case queue <- link:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
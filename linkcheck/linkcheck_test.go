@@ -0,0 +1,248 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+)
+
+// newTestChecker returns a checker that does not space out requests.
+func newTestChecker() *Checker {
+	c := NewChecker()
+	c.Timeout = time.Second
+	c.HostInterval = 0
+	return c
+}
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("%s of a page HEAD found missing", r.Method)
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved-away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/missing", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		path       string
+		link       string
+		status     string
+		statusCode int
+		finalPath  string
+		err        string
+	}{
+		{path: "/ok", status: model.LinkStatusOK, statusCode: http.StatusOK},
+		{path: "/missing", status: model.LinkStatusBroken, statusCode: http.StatusNotFound, err: "404 Not Found"},
+		{path: "/no-head", status: model.LinkStatusOK, statusCode: http.StatusOK},
+		{path: "/error", status: model.LinkStatusBroken, statusCode: http.StatusInternalServerError, err: "500 Internal Server Error"},
+		{path: "/login", status: model.LinkStatusOK, statusCode: http.StatusUnauthorized},
+		{path: "/moved", status: model.LinkStatusOK, statusCode: http.StatusOK, finalPath: "/ok"},
+		{path: "/moved-away", status: model.LinkStatusBroken, statusCode: http.StatusNotFound, finalPath: "/missing", err: "404 Not Found"},
+		{path: "/loop", status: model.LinkStatusBroken, err: "stopped after 10 redirects"},
+		{path: "/slow", status: model.LinkStatusBroken, err: "timed out"},
+		{link: "ftp://example.com/notes.pdf", status: model.LinkStatusBroken, err: "not an http or https URL"},
+		{link: "http://%zz", status: model.LinkStatusBroken, err: "invalid URL"},
+	}
+	for _, tt := range tests {
+		name := tt.path + tt.link
+		t.Run(name, func(t *testing.T) {
+			c := newTestChecker()
+			c.Timeout = 100 * time.Millisecond
+			link := tt.link
+			if link == "" {
+				link = srv.URL + tt.path
+			}
+
+			got := c.Check(context.Background(), link)
+			if got.URL != link || got.Status != tt.status || got.StatusCode != tt.statusCode || !strings.Contains(got.Error, tt.err) || (tt.err == "") != (got.Error == "") {
+				t.Errorf("Check = %+v, want status %s, code %d and error %q", got, tt.status, tt.statusCode, tt.err)
+			}
+			finalPath := tt.finalPath
+			if finalPath == "" && tt.statusCode != 0 {
+				finalPath = tt.path
+			}
+			if finalPath != "" && got.FinalURL != srv.URL+finalPath {
+				t.Errorf("FinalURL = %s, want %s", got.FinalURL, srv.URL+finalPath)
+			}
+		})
+	}
+}
+
+// TestCheckTimeoutAfterWait checks that waiting for a host's turn does not
+// count against a link's timeout.
+func TestCheckTimeoutAfterWait(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	c := newTestChecker()
+	c.Timeout = 100 * time.Millisecond
+	c.HostInterval = 250 * time.Millisecond
+
+	ctx := context.Background()
+	if got := c.Check(ctx, srv.URL+"/first"); got.Status != model.LinkStatusOK {
+		t.Fatalf("first check = %+v", got)
+	}
+	start := time.Now()
+	if got := c.Check(ctx, srv.URL+"/second"); got.Status != model.LinkStatusOK {
+		t.Errorf("second check, after waiting for the host, = %+v", got)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("second check took %s, less than the host interval", waited)
+	}
+}
+
+// hostLog records when each request to a test server arrived and how many
+// were in flight at most.
+type hostLog struct {
+	mu       sync.Mutex
+	arrivals []time.Time
+	inFlight int
+	most     int
+}
+
+func (l *hostLog) handler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		l.arrivals = append(l.arrivals, time.Now())
+		l.inFlight++
+		l.most = max(l.most, l.inFlight)
+		l.mu.Unlock()
+
+		time.Sleep(delay)
+
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	const interval = 40 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		links        int
+		concurrency  int
+		hostInterval time.Duration
+		delay        time.Duration
+		most         int
+	}{
+		{name: "one host, spaced out", links: 4, concurrency: 4, hostInterval: interval, most: 1},
+		{name: "bounded concurrency", links: 6, concurrency: 2, delay: 50 * time.Millisecond, most: 2},
+		{name: "one worker", links: 3, concurrency: 1, delay: 10 * time.Millisecond, most: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log hostLog
+			srv := httptest.NewServer(log.handler(tt.delay))
+			defer srv.Close()
+
+			c := newTestChecker()
+			c.Concurrency = tt.concurrency
+			c.HostInterval = tt.hostInterval
+			var links []string
+			for i := range tt.links {
+				links = append(links, srv.URL+"/"+string(rune('a'+i)))
+			}
+
+			var mu sync.Mutex
+			seen := map[string]bool{}
+			c.CheckAll(context.Background(), links, func(r Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.Status != model.LinkStatusOK {
+					t.Errorf("%s = %+v", r.URL, r)
+				}
+				seen[r.URL] = true
+			})
+
+			if len(seen) != tt.links {
+				t.Errorf("%d links checked, want %d", len(seen), tt.links)
+			}
+			if log.most > tt.most {
+				t.Errorf("%d requests in flight at once, want at most %d", log.most, tt.most)
+			}
+			for i := 1; i < len(log.arrivals); i++ {
+				// Timers fire late, never early; allow for the clock's
+				// granularity only.
+				if gap := log.arrivals[i].Sub(log.arrivals[i-1]); gap < tt.hostInterval-time.Millisecond {
+					t.Errorf("requests %d and %d came %s apart, want at least %s", i-1, i, gap, tt.hostInterval)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckAllHosts checks that the gap between requests is kept per
+// host: links to two hosts are checked side by side.
+func TestCheckAllHosts(t *testing.T) {
+	const interval = 150 * time.Millisecond
+	var first, second hostLog
+	srv1 := httptest.NewServer(first.handler(0))
+	defer srv1.Close()
+	srv2 := httptest.NewServer(second.handler(0))
+	defer srv2.Close()
+
+	c := newTestChecker()
+	c.Concurrency = 4
+	c.HostInterval = interval
+	// Both servers listen on 127.0.0.1; naming the second localhost makes
+	// it another host.
+	other := strings.Replace(srv2.URL, "127.0.0.1", "localhost", 1)
+	links := []string{srv1.URL + "/a", other + "/a", srv1.URL + "/b", other + "/b"}
+
+	start := time.Now()
+	c.CheckAll(context.Background(), links, func(r Result) {
+		if r.Status != model.LinkStatusOK {
+			t.Errorf("%s = %+v", r.URL, r)
+		}
+	})
+	if took := time.Since(start); took > 2*interval {
+		t.Errorf("took %s, as if the hosts shared one interval", took)
+	}
+	for _, log := range []*hostLog{&first, &second} {
+		if len(log.arrivals) != 2 {
+			t.Fatalf("%d requests, want 2", len(log.arrivals))
+		}
+		if gap := log.arrivals[1].Sub(log.arrivals[0]); gap < interval-time.Millisecond {
+			t.Errorf("requests to one host came %s apart, want at least %s", gap, interval)
+		}
+	}
+}
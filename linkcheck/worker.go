@@ -0,0 +1,110 @@
+package linkcheck
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+)
+
+// Defaults for NewWorker.
+const (
+	DefaultInterval = time.Hour
+	DefaultRecheck  = 7 * 24 * time.Hour
+	DefaultBatch    = 500
+)
+
+// Worker checks the links of materials in the background. Every Interval
+// it checks up to Batch links that have never been checked or were last
+// checked more than Recheck ago, and records the results.
+type Worker struct {
+	Materials model.MaterialStore
+	Checker   *Checker
+	Interval  time.Duration
+	Recheck   time.Duration
+	Batch     int
+}
+
+// NewWorker returns a worker with the default schedule.
+func NewWorker(materials model.MaterialStore, checker *Checker) *Worker {
+	return &Worker{
+		Materials: materials,
+		Checker:   checker,
+		Interval:  DefaultInterval,
+		Recheck:   DefaultRecheck,
+		Batch:     DefaultBatch,
+	}
+}
+
+// Run checks links until ctx is done, starting at once.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		n, err := w.RunOnce(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			log.Printf("Link check failed: %v", err)
+		case n > 0:
+			log.Printf("Checked %d material links", n)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce checks the links that are due and returns how many it checked.
+// Results that cannot be recorded are logged and not counted.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	materials, err := w.Materials.LinksDue(time.Now().Add(-w.Recheck), w.Batch)
+	if err != nil {
+		return 0, err
+	}
+
+	// Materials sharing a link are checked once.
+	byLink := map[string][]model.Material{}
+	var links []string
+	for _, m := range materials {
+		if _, ok := byLink[m.Link]; !ok {
+			links = append(links, m.Link)
+		}
+		byLink[m.Link] = append(byLink[m.Link], m)
+	}
+
+	results := make(chan Result)
+	go func() {
+		w.Checker.CheckAll(ctx, links, func(r Result) { results <- r })
+		close(results)
+	}()
+
+	checked := 0
+	for r := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		now := time.Now()
+		for _, m := range byLink[r.URL] {
+			err := w.Materials.RecordLinkCheck(&model.LinkCheck{
+				MaterialID: m.ID,
+				URL:        r.URL,
+				Status:     r.Status,
+				StatusCode: r.StatusCode,
+				FinalURL:   r.FinalURL,
+				Error:      r.Error,
+				CheckedAt:  now,
+			})
+			if err != nil {
+				log.Printf("Failed to record link check of material %s: %v", m.ID, err)
+				continue
+			}
+			checked++
+		}
+	}
+	return checked, ctx.Err()
+}
@@ -0,0 +1,221 @@
+package marc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+)
+
+// Entry is the catalogue data carried by a bibliographic record, with
+// authors, subjects and location by name as other systems know them.
+//
+// Fields used:
+//
+//	001      control number (the book ID on export)
+//	020 $a   ISBN
+//	100 $a   main author
+//	700 $a   further contributors, $e/$4 relator for the role
+//	245 $a$b title and subtitle
+//	260/264  $b publisher, $c year
+//	300 $a   extent, for the page count
+//	650 $a   topical subjects
+//	655 $a   genre/form, for the book type
+//	852 $b   location, $p barcode
+//	856 $u   cover image
+type Entry struct {
+	ControlNumber string
+	Title         string
+	Contributors  []Contributor
+	ISBN          string
+	Publisher     string
+	Year          int
+	Pages         int
+	Subjects      []string
+	BookType      string
+	Location      string
+	Barcode       string
+	CoverURL      string
+}
+
+// Contributor is a name from 100 or 700 with its role, one of the model
+// contributor roles.
+type Contributor struct {
+	Name string
+	Role string
+}
+
+// relatorCodes maps MARC relator codes ($4) to contributor roles and back.
+var relatorCodes = map[string]string{
+	"aut": model.RoleAuthor,
+	"edt": model.RoleEditor,
+	"trl": model.RoleTranslator,
+	"ill": model.RoleIllustrator,
+}
+
+// relatorRole reads the role of a 700 field from its relator code or term.
+// Fields without one, or with a role the catalogue has no place for, count
+// as authors.
+func relatorRole(f Field) string {
+	if role, ok := relatorCodes[strings.ToLower(strings.TrimSpace(f.Subfield('4')))]; ok {
+		return role
+	}
+	term := strings.ToLower(clean(f.Subfield('e')))
+	for _, role := range relatorCodes {
+		if term != "" && (strings.HasPrefix(term, role) || strings.HasPrefix(role, term)) {
+			return role
+		}
+	}
+	return model.RoleAuthor
+}
+
+func relatorCode(role string) string {
+	for code, r := range relatorCodes {
+		if r == role {
+			return code
+		}
+	}
+	return ""
+}
+
+var (
+	yearPattern  = regexp.MustCompile(`\b(1[5-9]|20)\d\d\b`)
+	pagesPattern = regexp.MustCompile(`(\d+)\s*(p\b|p\.|pages)`)
+)
+
+// clean strips the ISBD punctuation MARC cataloguers end subfields with.
+func clean(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,.="))
+}
+
+// ToEntry extracts the catalogue data from a record.
+func ToEntry(r Record) Entry {
+	e := Entry{ControlNumber: strings.TrimSpace(r.ControlField("001"))}
+
+	for _, f := range r.DataFields("020") {
+		if a := strings.Fields(f.Subfield('a')); len(a) > 0 {
+			e.ISBN = a[0]
+			break
+		}
+	}
+	for _, tag := range []string{"100", "700"} {
+		for _, f := range r.DataFields(tag) {
+			name := clean(f.Subfield('a'))
+			if name == "" {
+				continue
+			}
+			role := model.RoleAuthor
+			if tag == "700" {
+				role = relatorRole(f)
+			}
+			e.Contributors = append(e.Contributors, Contributor{Name: name, Role: role})
+		}
+	}
+	if f := r.DataFields("245"); len(f) > 0 {
+		e.Title = clean(f[0].Subfield('a'))
+		if sub := clean(f[0].Subfield('b')); sub != "" {
+			e.Title += ": " + sub
+		}
+	}
+	for _, tag := range []string{"264", "260"} {
+		for _, f := range r.DataFields(tag) {
+			if tag == "264" && f.Ind2 != '1' {
+				continue
+			}
+			if e.Publisher == "" {
+				e.Publisher = clean(f.Subfield('b'))
+			}
+			if e.Year == 0 {
+				e.Year, _ = strconv.Atoi(yearPattern.FindString(f.Subfield('c')))
+			}
+		}
+	}
+	if f := r.DataFields("300"); len(f) > 0 {
+		if m := pagesPattern.FindStringSubmatch(f[0].Subfield('a')); m != nil {
+			e.Pages, _ = strconv.Atoi(m[1])
+		}
+	}
+	for _, f := range r.DataFields("650") {
+		if s := clean(f.Subfield('a')); s != "" {
+			e.Subjects = append(e.Subjects, s)
+		}
+	}
+	if f := r.DataFields("655"); len(f) > 0 {
+		e.BookType = clean(f[0].Subfield('a'))
+	}
+	if f := r.DataFields("852"); len(f) > 0 {
+		e.Location = clean(f[0].Subfield('b'))
+		e.Barcode = strings.TrimSpace(f[0].Subfield('p'))
+	}
+	if f := r.DataFields("856"); len(f) > 0 {
+		e.CoverURL = strings.TrimSpace(f[0].Subfield('u'))
+	}
+	return e
+}
+
+// FromBook builds the record of a book. The first author is the main entry
+// in 100; everyone else goes to 700 with their role.
+func FromBook(b model.Book, contributors []model.Contributor, location model.Location, subjects []model.Subject) Record {
+	var r Record
+	r.AddControl("001", b.ID.String())
+
+	year := "    "
+	dateType := 'n'
+	if b.PublicationYear != nil {
+		year = fmt.Sprintf("%04d", *b.PublicationYear)
+		dateType = 's'
+	}
+	r.AddControl("008", fmt.Sprintf("%s%c%s    xx %17s%s d", b.CreatedAt.UTC().Format("060102"), dateType, year, "", "und"))
+
+	if b.ISBN13 != nil {
+		r.AddData("020", ' ', ' ', "a", *b.ISBN13)
+	}
+	if b.ISBN10 != nil {
+		r.AddData("020", ' ', ' ', "a", *b.ISBN10)
+	}
+
+	titleInd := byte('0')
+	main := -1
+	for i, c := range contributors {
+		if c.Role == model.RoleAuthor {
+			main, titleInd = i, '1'
+			r.AddData("100", '1', ' ', "a", c.Name)
+			break
+		}
+	}
+
+	title, subtitle, _ := strings.Cut(b.Title, ": ")
+	r.AddData("245", titleInd, '0', "a", title, "b", subtitle)
+
+	var published string
+	if b.PublicationYear != nil {
+		published = strconv.Itoa(*b.PublicationYear)
+	}
+	r.AddData("264", ' ', '1', "b", deref(b.Publisher), "c", published)
+	if b.PageCount != nil {
+		r.AddData("300", ' ', ' ', "a", fmt.Sprintf("%d pages", *b.PageCount))
+	}
+	for _, s := range subjects {
+		r.AddData("650", ' ', '4', "a", s.Name)
+	}
+	r.AddData("655", ' ', '4', "a", b.BookType)
+	for i, c := range contributors {
+		if i != main {
+			r.AddData("700", '1', ' ', "a", c.Name, "e", c.Role, "4", relatorCode(c.Role))
+		}
+	}
+	r.AddData("852", ' ', ' ', "b", location.Name, "p", deref(b.Barcode))
+	if b.CoverURL != nil {
+		r.AddData("856", '4', '2', "3", "Cover image", "u", *b.CoverURL)
+	}
+	return r
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
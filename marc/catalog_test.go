@@ -0,0 +1,150 @@
+package marc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestRelatorRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		subfields []string
+		want      string
+	}{
+		{"no relator", []string{"a", "Ann"}, model.RoleAuthor},
+		{"code", []string{"a", "Ann", "4", "trl"}, model.RoleTranslator},
+		{"code in capitals", []string{"a", "Ann", "4", " EDT "}, model.RoleEditor},
+		{"term", []string{"a", "Ann", "e", "illustrator."}, model.RoleIllustrator},
+		{"abbreviated term", []string{"a", "Ann", "e", "ed.,"}, model.RoleEditor},
+		{"code wins over term", []string{"a", "Ann", "e", "editor", "4", "ill"}, model.RoleIllustrator},
+		{"unknown role", []string{"a", "Ann", "e", "narrator", "4", "nrt"}, model.RoleAuthor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Record
+			r.AddData("700", '1', ' ', tt.subfields...)
+			if got := relatorRole(r.Fields[0]); got != tt.want {
+				t.Errorf("relatorRole = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromBookToEntry(t *testing.T) {
+	id := uuid.New()
+	isbn13, barcode, publisher, cover := "9780441013593", "30001000000010", "Ace", "https://covers.example/dune.jpg"
+	year, pages := 2005, 528
+	book := model.Book{
+		ID: id, Title: "Dune: Deluxe Edition", BookType: "fiction", ISBN13: &isbn13, Barcode: &barcode,
+		Publisher: &publisher, PublicationYear: &year, PageCount: &pages, CoverURL: &cover,
+		CreatedAt: time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC),
+	}
+	subjects := []model.Subject{{Name: "Science fiction"}, {Name: "Deserts"}}
+	location := model.Location{Name: "Shelf 3"}
+
+	tests := []struct {
+		name         string
+		contributors []model.Contributor
+		want         []Contributor
+		mainEntry    bool
+	}{
+		{
+			name: "author first",
+			contributors: []model.Contributor{
+				{Name: "Frank Herbert", Role: model.RoleAuthor},
+				{Name: "Brian Herbert", Role: model.RoleEditor},
+				{Name: "Ann Translator", Role: model.RoleTranslator},
+			},
+			want: []Contributor{
+				{Name: "Frank Herbert", Role: model.RoleAuthor},
+				{Name: "Brian Herbert", Role: model.RoleEditor},
+				{Name: "Ann Translator", Role: model.RoleTranslator},
+			},
+			mainEntry: true,
+		},
+		{
+			name: "author after an editor",
+			contributors: []model.Contributor{
+				{Name: "Brian Herbert", Role: model.RoleEditor},
+				{Name: "Frank Herbert", Role: model.RoleAuthor},
+				{Name: "Kevin Anderson", Role: model.RoleAuthor},
+			},
+			want: []Contributor{
+				{Name: "Frank Herbert", Role: model.RoleAuthor},
+				{Name: "Brian Herbert", Role: model.RoleEditor},
+				{Name: "Kevin Anderson", Role: model.RoleAuthor},
+			},
+			mainEntry: true,
+		},
+		{
+			name:         "no author",
+			contributors: []model.Contributor{{Name: "Brian Herbert", Role: model.RoleEditor}},
+			want:         []Contributor{{Name: "Brian Herbert", Role: model.RoleEditor}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := FromBook(book, tt.contributors, location, subjects)
+			if got := len(rec.DataFields("100")) == 1; got != tt.mainEntry {
+				t.Errorf("main entry = %v, want %v", got, tt.mainEntry)
+			}
+			if ind := rec.DataFields("245")[0].Ind1; (ind == '1') != tt.mainEntry {
+				t.Errorf("245 first indicator = %c", ind)
+			}
+
+			data, err := Encode(rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Entry{
+				ControlNumber: id.String(),
+				Title:         "Dune: Deluxe Edition",
+				Contributors:  tt.want,
+				ISBN:          isbn13,
+				Publisher:     publisher,
+				Year:          year,
+				Pages:         pages,
+				Subjects:      []string{"Science fiction", "Deserts"},
+				BookType:      "fiction",
+				Location:      "Shelf 3",
+				Barcode:       barcode,
+				CoverURL:      cover,
+			}
+			if got := ToEntry(decoded); !reflect.DeepEqual(got, want) {
+				t.Errorf("ToEntry = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestToEntry(t *testing.T) {
+	var rec Record
+	rec.AddControl("001", " ocm42 ")
+	rec.AddData("020", ' ', ' ', "a", "0441013597 (pbk.)")
+	rec.AddData("100", '1', ' ', "a", "Herbert, Frank,")
+	rec.AddData("245", '1', '0', "a", "Dune /", "c", "Frank Herbert.")
+	rec.AddData("260", ' ', ' ', "b", "Chilton Books,", "c", "1965.")
+	rec.AddData("264", ' ', '4', "c", "©1964")
+	rec.AddData("300", ' ', ' ', "a", "412 p. ;")
+
+	want := Entry{
+		ControlNumber: "ocm42",
+		Title:         "Dune",
+		Contributors:  []Contributor{{Name: "Herbert, Frank", Role: model.RoleAuthor}},
+		ISBN:          "0441013597",
+		Publisher:     "Chilton Books",
+		Year:          1965,
+		Pages:         412,
+	}
+	if got := ToEntry(rec); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToEntry = %+v, want %+v", got, want)
+	}
+}
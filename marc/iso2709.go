@@ -0,0 +1,199 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+
+	leaderLength    = 24
+	directoryLength = 12
+)
+
+var ErrMalformed = errors.New("malformed MARC record")
+
+// Reader reads ISO 2709 records. Records are expected in UTF-8 (leader
+// position 09 "a"), which is what MARC 21 exports use today.
+type Reader struct {
+	r *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF after the last one.
+func (rd *Reader) Read() (Record, error) {
+	// Skip line breaks some tools put between records.
+	for {
+		b, err := rd.r.Peek(1)
+		if err != nil {
+			return Record{}, err
+		}
+		if b[0] != '\n' && b[0] != '\r' {
+			break
+		}
+		rd.r.ReadByte()
+	}
+
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(rd.r, head); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	length, ok := number(head)
+	if !ok || length < leaderLength+1 {
+		return Record{}, fmt.Errorf("%w: bad record length %q", ErrMalformed, head)
+	}
+	data := make([]byte, length)
+	copy(data, head)
+	if _, err := io.ReadFull(rd.r, data[5:]); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return Decode(data)
+}
+
+// Decode parses a single ISO 2709 record.
+func Decode(data []byte) (Record, error) {
+	if len(data) < leaderLength+1 || data[len(data)-1] != recordTerminator {
+		return Record{}, fmt.Errorf("%w: missing record terminator", ErrMalformed)
+	}
+	rec := Record{Leader: string(data[:leaderLength])}
+	base, ok := number(data[12:17])
+	if !ok || base <= leaderLength || base > len(data) {
+		return Record{}, fmt.Errorf("%w: bad base address", ErrMalformed)
+	}
+
+	directory := data[leaderLength : base-1]
+	if len(directory)%directoryLength != 0 {
+		return Record{}, fmt.Errorf("%w: bad directory length", ErrMalformed)
+	}
+	for i := 0; i < len(directory); i += directoryLength {
+		entry := directory[i : i+directoryLength]
+		tag := string(entry[:3])
+		flen, ok1 := number(entry[3:7])
+		start, ok2 := number(entry[7:12])
+		if !ok1 || !ok2 || flen < 1 || start < 0 || base+start < base || base+start+flen > len(data) {
+			return Record{}, fmt.Errorf("%w: bad directory entry for tag %s", ErrMalformed, tag)
+		}
+		body := bytes.TrimSuffix(data[base+start:base+start+flen], []byte{fieldTerminator})
+
+		if IsControl(tag) {
+			rec.Fields = append(rec.Fields, Field{Tag: tag, Value: string(body)})
+			continue
+		}
+		f := Field{Tag: tag, Ind1: ' ', Ind2: ' '}
+		if len(body) >= 2 {
+			f.Ind1, f.Ind2 = body[0], body[1]
+			body = body[2:]
+		}
+		for _, part := range bytes.Split(body, []byte{subfieldDelimiter}) {
+			if len(part) == 0 {
+				continue
+			}
+			f.Subfields = append(f.Subfields, Subfield{Code: part[0], Value: string(part[1:])})
+		}
+		rec.Fields = append(rec.Fields, f)
+	}
+	return rec, nil
+}
+
+// number parses a fixed-width numeric field of the leader or directory.
+// Unlike strconv.Atoi it accepts digits only, no sign or blanks.
+func number(b []byte) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// Encode serializes rec as ISO 2709, computing the record length, base
+// address and directory. Leader positions that encode structure are set;
+// the rest are kept from rec.Leader.
+func Encode(rec Record) ([]byte, error) {
+	var directory, fields bytes.Buffer
+	for _, f := range rec.Fields {
+		start := fields.Len()
+		if IsControl(f.Tag) {
+			fields.WriteString(f.Value)
+		} else {
+			fields.WriteByte(orBlank(f.Ind1))
+			fields.WriteByte(orBlank(f.Ind2))
+			for _, s := range f.Subfields {
+				fields.WriteByte(subfieldDelimiter)
+				fields.WriteByte(s.Code)
+				fields.WriteString(s.Value)
+			}
+		}
+		fields.WriteByte(fieldTerminator)
+
+		flen := fields.Len() - start
+		if len(f.Tag) != 3 || flen > 9999 || start > 99999 {
+			return nil, fmt.Errorf("marc: field %s cannot be encoded", f.Tag)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", f.Tag, flen, start)
+	}
+	directory.WriteByte(fieldTerminator)
+
+	base := leaderLength + directory.Len()
+	total := base + fields.Len() + 1
+	if total > 99999 {
+		return nil, fmt.Errorf("marc: record too long (%d bytes)", total)
+	}
+
+	leader := []byte(defaultLeader)
+	copy(leader, rec.Leader)
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	leader[9] = 'a'
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:24], "4500")
+
+	out := make([]byte, 0, total)
+	out = append(out, leader[:leaderLength]...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, fields.Bytes()...)
+	return append(out, recordTerminator), nil
+}
+
+// defaultLeader describes a new, complete record for language material
+// ("nam"), encoded in UTF-8.
+const defaultLeader = "00000nam a2200000 i 4500"
+
+func orBlank(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
+
+// Writer writes ISO 2709 records.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (wr *Writer) Write(rec Record) error {
+	data, err := Encode(rec)
+	if err != nil {
+		return err
+	}
+	_, err = wr.w.Write(data)
+	return err
+}
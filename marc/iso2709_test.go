@@ -0,0 +1,156 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRecord() Record {
+	var rec Record
+	rec.AddControl("001", "ocm123")
+	rec.AddData("245", '1', '0', "a", "Dune", "c", "Frank Herbert")
+	rec.AddData("650", ' ', '0', "a", "Science fiction")
+	return rec
+}
+
+// encodeTestRecord returns the ISO 2709 form of testRecord. Its leader
+// gives a base address of 61, and the directory entry for 245 starts at
+// byte 36.
+func encodeTestRecord(t *testing.T) []byte {
+	t.Helper()
+	data, err := Encode(testRecord())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	data := encodeTestRecord(t)
+	if got := string(data[:24]); got != "00113nam a2200061 i 4500" {
+		t.Errorf("leader = %q", got)
+	}
+	rec, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testRecord()
+	want.Leader = string(data[:24])
+	if !reflect.DeepEqual(rec, want) {
+		t.Errorf("Decode = %+v, want %+v", rec, want)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+	}{
+		{"truncated", func(b []byte) []byte { return b[:len(b)-10] }},
+		{"missing terminator", func(b []byte) []byte { return b[:len(b)-1] }},
+		{"leader only", func(b []byte) []byte { return append(b[:20:20], recordTerminator) }},
+		{"letters in base address", func(b []byte) []byte { copy(b[12:17], "00O61"); return b }},
+		{"signed base address", func(b []byte) []byte { copy(b[12:17], "+0061"); return b }},
+		{"base address inside leader", func(b []byte) []byte { copy(b[12:17], "00010"); return b }},
+		{"base address past the end", func(b []byte) []byte { copy(b[12:17], "99999"); return b }},
+		{"bad directory length", func(b []byte) []byte { copy(b[12:17], "00060"); return b }},
+		{"negative offset", func(b []byte) []byte { copy(b[36:48], "2450024-9999"); return b }},
+		{"signed offset", func(b []byte) []byte { copy(b[36:48], "2450024+0007"); return b }},
+		{"offset past the end", func(b []byte) []byte { copy(b[36:48], "245002499999"); return b }},
+		{"length past the end", func(b []byte) []byte { copy(b[36:48], "245999900007"); return b }},
+		{"empty field", func(b []byte) []byte { copy(b[36:48], "245000000007"); return b }},
+		{"blank length", func(b []byte) []byte { copy(b[36:48], "245  2400007"); return b }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.mutate(encodeTestRecord(t))
+			if _, err := Decode(data); !errors.Is(err, ErrMalformed) {
+				t.Errorf("Decode = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  Record
+	}{
+		{"bad tag", Record{Fields: []Field{{Tag: "24", Value: "x"}}}},
+		{"field too long", Record{Fields: []Field{{Tag: "500", Subfields: []Subfield{{Code: 'a', Value: strings.Repeat("x", 10000)}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Encode(tt.rec); err == nil {
+				t.Error("Encode succeeded")
+			}
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	record := string(encodeTestRecord(t))
+	tests := []struct {
+		name    string
+		input   string
+		records int
+		wantErr error
+	}{
+		{"empty", "", 0, io.EOF},
+		{"one record", record, 1, io.EOF},
+		{"line breaks between records", record + "\r\n" + record + "\n", 2, io.EOF},
+		{"bad record length", "abcde" + record[5:], 0, ErrMalformed},
+		{"length too short", "00010" + record[5:], 0, ErrMalformed},
+		{"truncated stream", record + record[:50], 1, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd := NewReader(strings.NewReader(tt.input))
+			var n int
+			var err error
+			for {
+				var rec Record
+				if rec, err = rd.Read(); err != nil {
+					break
+				}
+				if got := rec.DataFields("245")[0].Subfield('a'); got != "Dune" {
+					t.Errorf("record %d title = %q", n, got)
+				}
+				n++
+			}
+			if n != tt.records {
+				t.Errorf("read %d records, want %d", n, tt.records)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for range 3 {
+		if err := w.Write(testRecord()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd := NewReader(&buf)
+	for i := range 3 {
+		rec, err := rd.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(rec.Fields, testRecord().Fields) {
+			t.Errorf("record %d fields = %+v", i, rec.Fields)
+		}
+	}
+	if _, err := rd.Read(); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,151 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Namespace is the MARCXML schema namespace.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// XMLReader reads the records of a MARCXML <collection> (or a single
+// <record>) one at a time, so large files are not held in memory.
+type XMLReader struct {
+	d *xml.Decoder
+}
+
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{d: xml.NewDecoder(r)}
+}
+
+// Read returns the next record, or io.EOF after the last one.
+func (rd *XMLReader) Read() (Record, error) {
+	for {
+		tok, err := rd.d.Token()
+		if err != nil {
+			return Record{}, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var xr xmlRecord
+		if err := rd.d.DecodeElement(&xr, &start); err != nil {
+			return Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		return fromXML(xr), nil
+	}
+}
+
+// XML does not preserve the interleaving of control and data fields, but
+// MARC requires control fields first anyway.
+func fromXML(xr xmlRecord) Record {
+	rec := Record{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		rec.Fields = append(rec.Fields, Field{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range xr.DataFields {
+		f := Field{Tag: df.Tag, Ind1: indicator(df.Ind1), Ind2: indicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			if sf.Code == "" {
+				continue
+			}
+			f.Subfields = append(f.Subfields, Subfield{Code: sf.Code[0], Value: sf.Value})
+		}
+		rec.Fields = append(rec.Fields, f)
+	}
+	return rec
+}
+
+func indicator(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
+
+// XMLWriter writes a MARCXML <collection>. Close must be called to finish
+// the document.
+type XMLWriter struct {
+	w       io.Writer
+	e       *xml.Encoder
+	started bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	return &XMLWriter{w: w, e: e}
+}
+
+func (wr *XMLWriter) start() error {
+	if wr.started {
+		return nil
+	}
+	wr.started = true
+	if _, err := io.WriteString(wr.w, xml.Header); err != nil {
+		return err
+	}
+	return wr.e.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+	})
+}
+
+func (wr *XMLWriter) Write(rec Record) error {
+	if err := wr.start(); err != nil {
+		return err
+	}
+	xr := xmlRecord{Leader: rec.Leader}
+	if xr.Leader == "" {
+		xr.Leader = defaultLeader
+	}
+	for _, f := range rec.Fields {
+		if IsControl(f.Tag) {
+			xr.ControlFields = append(xr.ControlFields, xmlControlField{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+		df := xmlDataField{Tag: f.Tag, Ind1: string(orBlank(f.Ind1)), Ind2: string(orBlank(f.Ind2))}
+		for _, s := range f.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: string(s.Code), Value: s.Value})
+		}
+		xr.DataFields = append(xr.DataFields, df)
+	}
+	return wr.e.Encode(xr)
+}
+
+// Close ends the collection and flushes the encoder.
+func (wr *XMLWriter) Close() error {
+	if err := wr.start(); err != nil {
+		return err
+	}
+	if err := wr.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}}); err != nil {
+		return err
+	}
+	return wr.e.Flush()
+}
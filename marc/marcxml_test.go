@@ -0,0 +1,89 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	second := testRecord()
+	second.Leader = "00000cam a2200000 i 4500"
+	second.AddData("700", '1', ' ', "a", "Herbert, Brian", "e", "editor")
+	records := []Record{testRecord(), second}
+
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<collection xmlns="`+Namespace+`">`) {
+		t.Errorf("output lacks the MARCXML namespace:\n%s", buf.String())
+	}
+
+	records[0].Leader = defaultLeader
+	rd := NewXMLReader(&buf)
+	for i, want := range records {
+		got, err := rd.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := rd.Read(); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestXMLReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Record
+		wantErr error
+	}{
+		{
+			name: "single record without collection",
+			input: `<record xmlns="http://www.loc.gov/MARC21/slim"><leader>00000nam a2200000 i 4500</leader>
+				<datafield tag="245" ind1="0" ind2=""><subfield code="a">Dune</subfield><subfield code="">lost</subfield></datafield>
+				<controlfield tag="001">ocm1</controlfield></record>`,
+			want: []Record{{Leader: defaultLeader, Fields: []Field{
+				{Tag: "001", Value: "ocm1"},
+				{Tag: "245", Ind1: '0', Ind2: ' ', Subfields: []Subfield{{Code: 'a', Value: "Dune"}}},
+			}}},
+			wantErr: io.EOF,
+		},
+		{name: "no records", input: `<collection/>`, wantErr: io.EOF},
+		{name: "unclosed record", input: `<collection><record><leader>x</leader>`, wantErr: ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rd := NewXMLReader(strings.NewReader(tt.input))
+			var got []Record
+			var err error
+			for {
+				var rec Record
+				if rec, err = rd.Read(); err != nil {
+					break
+				}
+				got = append(got, rec)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %+v, want %+v", got, tt.want)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
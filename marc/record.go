@@ -0,0 +1,83 @@
+// Package marc reads and writes MARC 21 bibliographic records in ISO 2709
+// ("binary MARC") and MARCXML, and maps them to the library's catalogue.
+package marc
+
+import "strings"
+
+// Record is one MARC record: the 24-character leader and its fields in
+// order.
+type Record struct {
+	Leader string
+	Fields []Field
+}
+
+// Field is a control field (tags 001-009), which only has a Value, or a
+// data field with two indicators and subfields.
+type Field struct {
+	Tag       string
+	Value     string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// IsControl reports whether tag names a control field.
+func IsControl(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
+
+// ControlField returns the value of the first control field with tag.
+func (r Record) ControlField(tag string) string {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// DataFields returns every data field with tag.
+func (r Record) DataFields(tag string) []Field {
+	var out []Field
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Subfield returns the first subfield with code, or "".
+func (f Field) Subfield(code byte) string {
+	for _, s := range f.Subfields {
+		if s.Code == code {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// AddControl appends a control field.
+func (r *Record) AddControl(tag, value string) {
+	r.Fields = append(r.Fields, Field{Tag: tag, Value: value})
+}
+
+// AddData appends a data field built from alternating subfield codes and
+// values, skipping subfields with empty values. A field left without
+// subfields is not added.
+func (r *Record) AddData(tag string, ind1, ind2 byte, codesAndValues ...string) {
+	f := Field{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(codesAndValues); i += 2 {
+		if codesAndValues[i+1] != "" {
+			f.Subfields = append(f.Subfields, Subfield{Code: codesAndValues[i][0], Value: codesAndValues[i+1]})
+		}
+	}
+	if len(f.Subfields) > 0 {
+		r.Fields = append(r.Fields, f)
+	}
+}
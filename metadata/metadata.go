@@ -0,0 +1,28 @@
+// Package metadata looks up bibliographic records by ISBN so books can be
+// catalogued without retyping what publishers have already published.
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by providers that have no record for an ISBN.
+var ErrNotFound = errors.New("no bibliographic record found")
+
+// Record is the bibliographic data of one edition. Fields a provider does
+// not know are left at their zero value.
+type Record struct {
+	Title     string
+	Authors   []string
+	Publisher string
+	Year      int
+	Pages     int
+	Subjects  []string
+	CoverURL  string
+}
+
+// Provider looks up records by ISBN-13.
+type Provider interface {
+	LookupISBN(ctx context.Context, isbn13 string) (Record, error)
+}
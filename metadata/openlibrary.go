@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultOpenLibraryURL is the public Open Library instance.
+const DefaultOpenLibraryURL = "https://openlibrary.org"
+
+// OpenLibrary queries the Open Library Books API
+// (/api/books?bibkeys=ISBN:...&jscmd=data). Any server implementing that
+// endpoint works, including a local stub.
+type OpenLibrary struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewOpenLibrary returns a client for the instance at baseURL, or for
+// DefaultOpenLibraryURL when baseURL is empty.
+func NewOpenLibrary(baseURL string) *OpenLibrary {
+	if baseURL == "" {
+		baseURL = DefaultOpenLibraryURL
+	}
+	return &OpenLibrary{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type openLibraryName struct {
+	Name string `json:"name"`
+}
+
+type openLibraryBook struct {
+	Title         string            `json:"title"`
+	Subtitle      string            `json:"subtitle"`
+	Authors       []openLibraryName `json:"authors"`
+	Publishers    []openLibraryName `json:"publishers"`
+	PublishDate   string            `json:"publish_date"`
+	NumberOfPages int               `json:"number_of_pages"`
+	Subjects      []openLibraryName `json:"subjects"`
+	Cover         struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+}
+
+var yearPattern = regexp.MustCompile(`\b(1[5-9]|20)\d\d\b`)
+
+func (o *OpenLibrary) LookupISBN(ctx context.Context, isbn13 string) (Record, error) {
+	key := "ISBN:" + isbn13
+	query := url.Values{"bibkeys": {key}, "format": {"json"}, "jscmd": {"data"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.BaseURL+"/api/books?"+query.Encode(), nil)
+	if err != nil {
+		return Record{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return Record{}, fmt.Errorf("open library: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Record{}, fmt.Errorf("open library: unexpected status %s", resp.Status)
+	}
+
+	var books map[string]openLibraryBook
+	if err := json.NewDecoder(resp.Body).Decode(&books); err != nil {
+		return Record{}, fmt.Errorf("open library: decoding response: %w", err)
+	}
+	book, ok := books[key]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+
+	record := Record{
+		Title:    book.Title,
+		Pages:    book.NumberOfPages,
+		Authors:  names(book.Authors),
+		Subjects: names(book.Subjects),
+		CoverURL: firstNonEmpty(book.Cover.Large, book.Cover.Medium, book.Cover.Small),
+	}
+	if book.Subtitle != "" {
+		record.Title += ": " + book.Subtitle
+	}
+	if len(book.Publishers) > 0 {
+		record.Publisher = book.Publishers[0].Name
+	}
+	if y := yearPattern.FindString(book.PublishDate); y != "" {
+		record.Year, _ = strconv.Atoi(y)
+	}
+	return record, nil
+}
+
+func names(in []openLibraryName) []string {
+	out := make([]string, 0, len(in))
+	for _, n := range in {
+		if name := strings.TrimSpace(n.Name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const duneResponse = `{"ISBN:9780441013593": {
+	"title": "Dune",
+	"subtitle": "Deluxe Edition",
+	"authors": [{"name": "Frank Herbert"}, {"name": "  "}],
+	"publishers": [{"name": "Ace"}, {"name": "Chilton"}],
+	"publish_date": "August 2, 2005",
+	"number_of_pages": 528,
+	"subjects": [{"name": "Science fiction"}, {"name": "Arrakis"}],
+	"cover": {"small": "https://covers.example/s.jpg", "medium": "https://covers.example/m.jpg"}
+}}`
+
+func TestOpenLibraryLookupISBN(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		delay   time.Duration
+		want    Record
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name:   "found",
+			status: http.StatusOK,
+			body:   duneResponse,
+			want: Record{
+				Title:     "Dune: Deluxe Edition",
+				Authors:   []string{"Frank Herbert"},
+				Publisher: "Ace",
+				Year:      2005,
+				Pages:     528,
+				Subjects:  []string{"Science fiction", "Arrakis"},
+				CoverURL:  "https://covers.example/m.jpg",
+			},
+		},
+		{name: "not found", status: http.StatusOK, body: `{}`, wantErr: ErrNotFound},
+		{name: "malformed JSON", status: http.StatusOK, body: `{"ISBN:9780441013593": [`, anyErr: true},
+		{name: "server error", status: http.StatusBadGateway, body: `oops`, anyErr: true},
+		{name: "timeout", status: http.StatusOK, body: duneResponse, delay: 500 * time.Millisecond, anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/books" || r.URL.Query().Get("bibkeys") != "ISBN:9780441013593" || r.URL.Query().Get("jscmd") != "data" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				if tt.delay > 0 {
+					select {
+					case <-time.After(tt.delay):
+					case <-r.Context().Done():
+						return
+					}
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			client := NewOpenLibrary(srv.URL + "/")
+			client.Client.Timeout = 100 * time.Millisecond
+			got, err := client.LookupISBN(context.Background(), "9780441013593")
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil || errors.Is(err, ErrNotFound) {
+					t.Fatalf("err = %v, want a lookup failure", err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("record = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenLibraryHonoursContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := NewOpenLibrary(srv.URL).LookupISBN(ctx, "9780441013593"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context deadline", err)
+	}
+}
+
+func TestNewOpenLibraryDefaultURL(t *testing.T) {
+	if got := NewOpenLibrary("").BaseURL; got != DefaultOpenLibraryURL {
+		t.Errorf("BaseURL = %q, want %q", got, DefaultOpenLibraryURL)
+	}
+}
@@ -0,0 +1,3 @@
+-- Values stay in the normalized form the up migration gave them.
+DROP TABLE vocabulary_labels;
+DROP TABLE vocabulary_terms;
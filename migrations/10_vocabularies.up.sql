@@ -0,0 +1,277 @@
+-- Controlled vocabularies: the codes a book's type, a material's type, a
+-- user's class and the language of subjects and materials are taken from.
+-- Terms are labelled per locale, and inactive ones stay on the records that
+-- have them but cannot be given to others.
+CREATE TABLE vocabulary_terms (
+    id UUID PRIMARY KEY,
+    vocabulary TEXT NOT NULL CHECK (vocabulary IN ('book_type', 'material_type', 'user_class', 'language')),
+    code TEXT NOT NULL CHECK (code <> '' AND code = lower(code)),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (vocabulary, code)
+);
+
+CREATE TABLE vocabulary_labels (
+    term_id UUID NOT NULL REFERENCES vocabulary_terms(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    label TEXT NOT NULL,
+    PRIMARY KEY (term_id, locale)
+);
+
+-- ISO 639-1 languages by their lower-cased English names, for turning
+-- free-text languages into codes.
+CREATE TEMPORARY TABLE iso639 (code TEXT PRIMARY KEY, name TEXT NOT NULL, label TEXT NOT NULL);
+INSERT INTO iso639 (code, name, label) VALUES
+    ('aa', 'afar', 'Afar'),
+    ('ab', 'abkhazian', 'Abkhazian'),
+    ('ae', 'avestan', 'Avestan'),
+    ('af', 'afrikaans', 'Afrikaans'),
+    ('ak', 'akan', 'Akan'),
+    ('am', 'amharic', 'Amharic'),
+    ('an', 'aragonese', 'Aragonese'),
+    ('ar', 'arabic', 'Arabic'),
+    ('as', 'assamese', 'Assamese'),
+    ('av', 'avaric', 'Avaric'),
+    ('ay', 'aymara', 'Aymara'),
+    ('az', 'azerbaijani', 'Azerbaijani'),
+    ('ba', 'bashkir', 'Bashkir'),
+    ('be', 'belarusian', 'Belarusian'),
+    ('bg', 'bulgarian', 'Bulgarian'),
+    ('bi', 'bislama', 'Bislama'),
+    ('bm', 'bambara', 'Bambara'),
+    ('bn', 'bengali', 'Bengali'),
+    ('bo', 'tibetan', 'Tibetan'),
+    ('br', 'breton', 'Breton'),
+    ('bs', 'bosnian', 'Bosnian'),
+    ('ca', 'catalan', 'Catalan'),
+    ('ce', 'chechen', 'Chechen'),
+    ('ch', 'chamorro', 'Chamorro'),
+    ('co', 'corsican', 'Corsican'),
+    ('cr', 'cree', 'Cree'),
+    ('cs', 'czech', 'Czech'),
+    ('cu', 'church slavic', 'Church Slavic'),
+    ('cv', 'chuvash', 'Chuvash'),
+    ('cy', 'welsh', 'Welsh'),
+    ('da', 'danish', 'Danish'),
+    ('de', 'german', 'German'),
+    ('dv', 'divehi', 'Divehi'),
+    ('dz', 'dzongkha', 'Dzongkha'),
+    ('ee', 'ewe', 'Ewe'),
+    ('el', 'greek', 'Greek'),
+    ('en', 'english', 'English'),
+    ('eo', 'esperanto', 'Esperanto'),
+    ('es', 'spanish', 'Spanish'),
+    ('et', 'estonian', 'Estonian'),
+    ('eu', 'basque', 'Basque'),
+    ('fa', 'persian', 'Persian'),
+    ('ff', 'fulah', 'Fulah'),
+    ('fi', 'finnish', 'Finnish'),
+    ('fj', 'fijian', 'Fijian'),
+    ('fo', 'faroese', 'Faroese'),
+    ('fr', 'french', 'French'),
+    ('fy', 'western frisian', 'Western Frisian'),
+    ('ga', 'irish', 'Irish'),
+    ('gd', 'gaelic', 'Gaelic'),
+    ('gl', 'galician', 'Galician'),
+    ('gn', 'guarani', 'Guarani'),
+    ('gu', 'gujarati', 'Gujarati'),
+    ('gv', 'manx', 'Manx'),
+    ('ha', 'hausa', 'Hausa'),
+    ('he', 'hebrew', 'Hebrew'),
+    ('hi', 'hindi', 'Hindi'),
+    ('ho', 'hiri motu', 'Hiri Motu'),
+    ('hr', 'croatian', 'Croatian'),
+    ('ht', 'haitian', 'Haitian'),
+    ('hu', 'hungarian', 'Hungarian'),
+    ('hy', 'armenian', 'Armenian'),
+    ('hz', 'herero', 'Herero'),
+    ('ia', 'interlingua', 'Interlingua'),
+    ('id', 'indonesian', 'Indonesian'),
+    ('ie', 'interlingue', 'Interlingue'),
+    ('ig', 'igbo', 'Igbo'),
+    ('ii', 'sichuan yi', 'Sichuan Yi'),
+    ('ik', 'inupiaq', 'Inupiaq'),
+    ('io', 'ido', 'Ido'),
+    ('is', 'icelandic', 'Icelandic'),
+    ('it', 'italian', 'Italian'),
+    ('iu', 'inuktitut', 'Inuktitut'),
+    ('ja', 'japanese', 'Japanese'),
+    ('jv', 'javanese', 'Javanese'),
+    ('ka', 'georgian', 'Georgian'),
+    ('kg', 'kongo', 'Kongo'),
+    ('ki', 'kikuyu', 'Kikuyu'),
+    ('kj', 'kuanyama', 'Kuanyama'),
+    ('kk', 'kazakh', 'Kazakh'),
+    ('kl', 'kalaallisut', 'Kalaallisut'),
+    ('km', 'central khmer', 'Central Khmer'),
+    ('kn', 'kannada', 'Kannada'),
+    ('ko', 'korean', 'Korean'),
+    ('kr', 'kanuri', 'Kanuri'),
+    ('ks', 'kashmiri', 'Kashmiri'),
+    ('ku', 'kurdish', 'Kurdish'),
+    ('kv', 'komi', 'Komi'),
+    ('kw', 'cornish', 'Cornish'),
+    ('ky', 'kirghiz', 'Kirghiz'),
+    ('la', 'latin', 'Latin'),
+    ('lb', 'luxembourgish', 'Luxembourgish'),
+    ('lg', 'ganda', 'Ganda'),
+    ('li', 'limburgan', 'Limburgan'),
+    ('ln', 'lingala', 'Lingala'),
+    ('lo', 'lao', 'Lao'),
+    ('lt', 'lithuanian', 'Lithuanian'),
+    ('lu', 'luba-katanga', 'Luba-Katanga'),
+    ('lv', 'latvian', 'Latvian'),
+    ('mg', 'malagasy', 'Malagasy'),
+    ('mh', 'marshallese', 'Marshallese'),
+    ('mi', 'maori', 'Maori'),
+    ('mk', 'macedonian', 'Macedonian'),
+    ('ml', 'malayalam', 'Malayalam'),
+    ('mn', 'mongolian', 'Mongolian'),
+    ('mr', 'marathi', 'Marathi'),
+    ('ms', 'malay', 'Malay'),
+    ('mt', 'maltese', 'Maltese'),
+    ('my', 'burmese', 'Burmese'),
+    ('na', 'nauru', 'Nauru'),
+    ('nb', 'norwegian bokmål', 'Norwegian Bokmål'),
+    ('nd', 'north ndebele', 'North Ndebele'),
+    ('ne', 'nepali', 'Nepali'),
+    ('ng', 'ndonga', 'Ndonga'),
+    ('nl', 'dutch', 'Dutch'),
+    ('nn', 'norwegian nynorsk', 'Norwegian Nynorsk'),
+    ('no', 'norwegian', 'Norwegian'),
+    ('nr', 'south ndebele', 'South Ndebele'),
+    ('nv', 'navajo', 'Navajo'),
+    ('ny', 'chichewa', 'Chichewa'),
+    ('oc', 'occitan', 'Occitan'),
+    ('oj', 'ojibwa', 'Ojibwa'),
+    ('om', 'oromo', 'Oromo'),
+    ('or', 'oriya', 'Oriya'),
+    ('os', 'ossetian', 'Ossetian'),
+    ('pa', 'punjabi', 'Punjabi'),
+    ('pi', 'pali', 'Pali'),
+    ('pl', 'polish', 'Polish'),
+    ('ps', 'pashto', 'Pashto'),
+    ('pt', 'portuguese', 'Portuguese'),
+    ('qu', 'quechua', 'Quechua'),
+    ('rm', 'romansh', 'Romansh'),
+    ('rn', 'rundi', 'Rundi'),
+    ('ro', 'romanian', 'Romanian'),
+    ('ru', 'russian', 'Russian'),
+    ('rw', 'kinyarwanda', 'Kinyarwanda'),
+    ('sa', 'sanskrit', 'Sanskrit'),
+    ('sc', 'sardinian', 'Sardinian'),
+    ('sd', 'sindhi', 'Sindhi'),
+    ('se', 'northern sami', 'Northern Sami'),
+    ('sg', 'sango', 'Sango'),
+    ('si', 'sinhala', 'Sinhala'),
+    ('sk', 'slovak', 'Slovak'),
+    ('sl', 'slovenian', 'Slovenian'),
+    ('sm', 'samoan', 'Samoan'),
+    ('sn', 'shona', 'Shona'),
+    ('so', 'somali', 'Somali'),
+    ('sq', 'albanian', 'Albanian'),
+    ('sr', 'serbian', 'Serbian'),
+    ('ss', 'swati', 'Swati'),
+    ('st', 'southern sotho', 'Southern Sotho'),
+    ('su', 'sundanese', 'Sundanese'),
+    ('sv', 'swedish', 'Swedish'),
+    ('sw', 'swahili', 'Swahili'),
+    ('ta', 'tamil', 'Tamil'),
+    ('te', 'telugu', 'Telugu'),
+    ('tg', 'tajik', 'Tajik'),
+    ('th', 'thai', 'Thai'),
+    ('ti', 'tigrinya', 'Tigrinya'),
+    ('tk', 'turkmen', 'Turkmen'),
+    ('tl', 'tagalog', 'Tagalog'),
+    ('tn', 'tswana', 'Tswana'),
+    ('to', 'tonga', 'Tonga'),
+    ('tr', 'turkish', 'Turkish'),
+    ('ts', 'tsonga', 'Tsonga'),
+    ('tt', 'tatar', 'Tatar'),
+    ('tw', 'twi', 'Twi'),
+    ('ty', 'tahitian', 'Tahitian'),
+    ('ug', 'uighur', 'Uighur'),
+    ('uk', 'ukrainian', 'Ukrainian'),
+    ('ur', 'urdu', 'Urdu'),
+    ('uz', 'uzbek', 'Uzbek'),
+    ('ve', 'venda', 'Venda'),
+    ('vi', 'vietnamese', 'Vietnamese'),
+    ('vo', 'volapük', 'Volapük'),
+    ('wa', 'walloon', 'Walloon'),
+    ('wo', 'wolof', 'Wolof'),
+    ('xh', 'xhosa', 'Xhosa'),
+    ('yi', 'yiddish', 'Yiddish'),
+    ('yo', 'yoruba', 'Yoruba'),
+    ('za', 'zhuang', 'Zhuang'),
+    ('zh', 'chinese', 'Chinese'),
+    ('zu', 'zulu', 'Zulu');
+
+-- Codes are lower case with words joined by hyphens, as the application
+-- normalizes them; languages given by name become their ISO 639-1 code.
+CREATE FUNCTION pg_temp.term_code(s TEXT) RETURNS TEXT AS $$
+    SELECT regexp_replace(btrim(lower(s), E' \t\r\n_'), '[[:space:]_]+', '-', 'g')
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE FUNCTION pg_temp.language_code(s TEXT) RETURNS TEXT AS $$
+    SELECT COALESCE((SELECT code FROM iso639 WHERE name = lower(btrim(s))), pg_temp.term_code(s))
+$$ LANGUAGE sql STABLE;
+
+UPDATE books SET book_type = pg_temp.term_code(book_type), version = version + 1
+    WHERE book_type <> pg_temp.term_code(book_type);
+UPDATE materials SET type = pg_temp.term_code(type), version = version + 1
+    WHERE type <> pg_temp.term_code(type);
+UPDATE users SET class = pg_temp.term_code(class), version = version + 1
+    WHERE class <> pg_temp.term_code(class);
+UPDATE materials SET language = pg_temp.language_code(language), version = version + 1
+    WHERE language <> pg_temp.language_code(language);
+UPDATE subjects SET language = pg_temp.language_code(language), version = version + 1
+    WHERE language <> pg_temp.language_code(language);
+
+-- The standard terms, and every value already in use, start out active.
+CREATE TEMPORARY TABLE seed_terms (vocabulary TEXT, code TEXT, label TEXT);
+INSERT INTO seed_terms (vocabulary, code, label) VALUES
+    ('book_type', 'fiction', 'Fiction'),
+    ('book_type', 'non-fiction', 'Non-fiction'),
+    ('book_type', 'reference', 'Reference'),
+    ('book_type', 'textbook', 'Textbook'),
+    ('book_type', 'periodical', 'Periodical'),
+    ('material_type', 'article', 'Article'),
+    ('material_type', 'audio', 'Audio'),
+    ('material_type', 'document', 'Document'),
+    ('material_type', 'notes', 'Notes'),
+    ('material_type', 'video', 'Video'),
+    ('material_type', 'website', 'Website'),
+    ('user_class', '1', 'Class 1'),
+    ('user_class', '2', 'Class 2'),
+    ('user_class', '3', 'Class 3'),
+    ('user_class', '4', 'Class 4'),
+    ('user_class', '5', 'Class 5'),
+    ('user_class', '6', 'Class 6'),
+    ('user_class', '7', 'Class 7'),
+    ('user_class', '8', 'Class 8'),
+    ('user_class', '9', 'Class 9'),
+    ('user_class', '10', 'Class 10'),
+    ('user_class', '11', 'Class 11'),
+    ('user_class', '12', 'Class 12'),
+    ('user_class', 'staff', 'Staff'),
+    ('language', 'en', 'English');
+
+INSERT INTO vocabulary_terms (id, vocabulary, code)
+SELECT gen_random_uuid(), vocabulary, code FROM (
+    SELECT vocabulary, code FROM seed_terms
+    UNION SELECT 'book_type', book_type FROM books
+    UNION SELECT 'material_type', type FROM materials
+    UNION SELECT 'user_class', class FROM users
+    UNION SELECT 'language', language FROM materials
+    UNION SELECT 'language', language FROM subjects
+) used
+WHERE code <> '';
+
+INSERT INTO vocabulary_labels (term_id, locale, label)
+SELECT t.id, 'en', s.label FROM vocabulary_terms t
+JOIN seed_terms s ON s.vocabulary = t.vocabulary AND s.code = t.code;
+
+INSERT INTO vocabulary_labels (term_id, locale, label)
+SELECT t.id, 'en', i.label FROM vocabulary_terms t
+JOIN iso639 i ON t.vocabulary = 'language' AND i.code = t.code
+ON CONFLICT (term_id, locale) DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE material_subjects;
+DROP TABLE book_subjects;
+ALTER TABLE subjects DROP COLUMN parent_id;
@@ -0,0 +1,27 @@
+-- Subjects form a hierarchy, like Science > Physics > Optics. A parent
+-- cannot be purged while it has children, deleted or not.
+ALTER TABLE subjects ADD COLUMN parent_id UUID REFERENCES subjects(id) CHECK (parent_id <> id);
+
+CREATE INDEX idx_subjects_parent ON subjects (parent_id);
+
+-- Books and materials are filed under any number of subjects.
+CREATE TABLE book_subjects (
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    subject_id UUID NOT NULL REFERENCES subjects(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, subject_id)
+);
+
+CREATE INDEX idx_book_subjects_subject ON book_subjects (subject_id);
+
+CREATE TABLE material_subjects (
+    material_id UUID NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    subject_id UUID NOT NULL REFERENCES subjects(id) ON DELETE CASCADE,
+    PRIMARY KEY (material_id, subject_id)
+);
+
+CREATE INDEX idx_material_subjects_subject ON material_subjects (subject_id);
+
+-- Every material starts out filed under the subject it names, and stays
+-- filed there.
+INSERT INTO material_subjects (material_id, subject_id)
+SELECT m.id, s.id FROM materials m JOIN subjects s ON s.name = m.subject_name;
@@ -0,0 +1,6 @@
+ALTER TABLE materials ADD COLUMN subject_name TEXT REFERENCES subjects(name) ON DELETE CASCADE;
+
+UPDATE materials m SET subject_name = s.name FROM subjects s WHERE s.id = m.subject_id;
+
+ALTER TABLE materials ALTER COLUMN subject_name SET NOT NULL;
+ALTER TABLE materials DROP COLUMN subject_id;
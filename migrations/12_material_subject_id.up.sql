@@ -0,0 +1,11 @@
+-- Materials refer to their subject by ID rather than by name, so a subject
+-- can be renamed without touching its materials. Purging a subject no
+-- longer purges its materials; they must move to another subject first.
+ALTER TABLE materials ADD COLUMN subject_id UUID REFERENCES subjects(id);
+
+UPDATE materials m SET subject_id = s.id FROM subjects s WHERE s.name = m.subject_name;
+
+ALTER TABLE materials ALTER COLUMN subject_id SET NOT NULL;
+ALTER TABLE materials DROP COLUMN subject_name;
+
+CREATE INDEX idx_materials_subject ON materials (subject_id);
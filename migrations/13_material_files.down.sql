@@ -0,0 +1 @@
+DROP TABLE material_files;
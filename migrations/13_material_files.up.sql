@@ -0,0 +1,15 @@
+-- Files uploaded to materials, like a PDF or a slide deck. Their content
+-- lives in blob storage under its SHA-256 digest, so files with the same
+-- content share it.
+CREATE TABLE material_files (
+    id UUID PRIMARY KEY,
+    material_id UUID NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    sha256 TEXT NOT NULL CHECK (sha256 ~ '^[0-9a-f]{64}$'),
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL CHECK (size >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_material_files_material ON material_files (material_id);
+CREATE INDEX idx_material_files_sha256 ON material_files (sha256);
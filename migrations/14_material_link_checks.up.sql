@@ -0,0 +1,14 @@
+-- The outcome of the last check of each material's link. A check counts
+-- only while the material's link is still the url that was checked.
+CREATE TABLE material_link_checks (
+    material_id UUID PRIMARY KEY REFERENCES materials(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('ok', 'broken')),
+    status_code INT NOT NULL DEFAULT 0,
+    final_url TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    checked_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_material_link_checks_status ON material_link_checks (status);
+CREATE INDEX idx_material_link_checks_checked_at ON material_link_checks (checked_at);
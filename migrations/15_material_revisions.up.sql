@@ -0,0 +1,24 @@
+-- Every change to a material's content, kept for good: what the material
+-- said after the change, who made it and a summary of what changed.
+-- version is the material's version the revision was written at. The
+-- current content of existing materials becomes their first revision.
+CREATE TABLE material_revisions (
+    material_id UUID NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    revision INT NOT NULL CHECK (revision > 0),
+    version INT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    notes TEXT NOT NULL,
+    type TEXT NOT NULL,
+    link TEXT NOT NULL,
+    language TEXT NOT NULL,
+    subject_id UUID NOT NULL,
+    author TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (material_id, revision)
+);
+
+INSERT INTO material_revisions (material_id, revision, version, title, description, notes, type, link, language, subject_id, author, summary)
+SELECT id, 1, version, title, COALESCE(description, ''), COALESCE(notes, ''), type, link, language, subject_id, 'system', 'Revision history started'
+FROM materials;
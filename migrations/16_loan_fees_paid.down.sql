@@ -0,0 +1 @@
+ALTER TABLE issued_books DROP COLUMN fees_paid_at;
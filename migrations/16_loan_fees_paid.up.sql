@@ -0,0 +1,9 @@
+-- When the late fees charged on a returned loan were paid. Fees stay owed,
+-- and count against the patron, until then.
+ALTER TABLE issued_books ADD COLUMN fees_paid_at TIMESTAMP;
+
+-- Nothing tracked payment before, and fees were settled at the desk on
+-- return, so loans already returned count as paid then.
+UPDATE issued_books SET fees_paid_at = return_date WHERE return_date IS NOT NULL;
+
+CREATE INDEX idx_issued_books_unpaid ON issued_books (user_id) WHERE fees_paid_at IS NULL;
@@ -0,0 +1,9 @@
+-- One row for each content digest material files have. Uploads and deletes
+-- lock a digest's row while they add or remove files with its content, so
+-- content is only deleted from blob storage once no file has it, and no
+-- file can be added meanwhile.
+CREATE TABLE material_contents (
+    sha256 TEXT PRIMARY KEY CHECK (sha256 ~ '^[0-9a-f]{64}$')
+);
+
+INSERT INTO material_contents (sha256) SELECT DISTINCT sha256 FROM material_files;
@@ -0,0 +1,5 @@
+ALTER TABLE books DROP COLUMN barcode;
+ALTER TABLE users DROP COLUMN card_number;
+
+DROP SEQUENCE item_barcode_seq;
+DROP SEQUENCE patron_card_seq;
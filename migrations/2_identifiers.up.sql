@@ -0,0 +1,7 @@
+CREATE SEQUENCE patron_card_seq;
+CREATE SEQUENCE item_barcode_seq;
+
+-- Existing rows are numbered by the application on startup, using the
+-- configured schemes.
+ALTER TABLE users ADD COLUMN card_number TEXT UNIQUE;
+ALTER TABLE books ADD COLUMN barcode TEXT UNIQUE;
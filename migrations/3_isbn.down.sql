@@ -0,0 +1,4 @@
+DROP INDEX idx_books_isbn10;
+
+ALTER TABLE books DROP COLUMN isbn13;
+ALTER TABLE books DROP COLUMN isbn10;
@@ -0,0 +1,6 @@
+-- Both forms are stored for display and lookup; ISBN-13 identifies the
+-- edition, so it carries the uniqueness rule.
+ALTER TABLE books ADD COLUMN isbn10 TEXT;
+ALTER TABLE books ADD COLUMN isbn13 TEXT UNIQUE;
+
+CREATE INDEX idx_books_isbn10 ON books (isbn10);
@@ -0,0 +1,4 @@
+ALTER TABLE books DROP COLUMN cover_url;
+ALTER TABLE books DROP COLUMN page_count;
+ALTER TABLE books DROP COLUMN publication_year;
+ALTER TABLE books DROP COLUMN publisher;
@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN publisher TEXT;
+ALTER TABLE books ADD COLUMN publication_year INTEGER;
+ALTER TABLE books ADD COLUMN page_count INTEGER CHECK (page_count > 0);
+ALTER TABLE books ADD COLUMN cover_url TEXT;
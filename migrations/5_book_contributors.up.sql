@@ -0,0 +1,14 @@
+CREATE TABLE book_contributors (
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('author', 'editor', 'translator', 'illustrator')),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX idx_book_contributors_author ON book_contributors (author_id);
+
+-- books.author_id stays as the primary author; every book starts out with
+-- it as its only contributor.
+INSERT INTO book_contributors (book_id, author_id, role, position)
+SELECT id, author_id, 'author', 0 FROM books;
@@ -0,0 +1,10 @@
+CREATE TABLE author_merges (
+    id UUID PRIMARY KEY,
+    survivor_id UUID NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    merged_id UUID NOT NULL,
+    merged_name TEXT NOT NULL,
+    books_moved INTEGER NOT NULL,
+    merged_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_author_merges_survivor ON author_merges (survivor_id);
@@ -0,0 +1,14 @@
+DROP INDEX idx_books_isbn13;
+DROP INDEX idx_books_barcode;
+DROP INDEX idx_users_card_number;
+
+ALTER TABLE books ADD CONSTRAINT books_isbn13_key UNIQUE (isbn13);
+ALTER TABLE books ADD CONSTRAINT books_barcode_key UNIQUE (barcode);
+ALTER TABLE users ADD CONSTRAINT users_card_number_key UNIQUE (card_number);
+
+ALTER TABLE materials DROP COLUMN deleted_at;
+ALTER TABLE subjects DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE locations DROP COLUMN deleted_at;
+ALTER TABLE authors DROP COLUMN deleted_at;
+ALTER TABLE books DROP COLUMN deleted_at;
@@ -0,0 +1,17 @@
+ALTER TABLE books ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE authors ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE locations ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE subjects ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE materials ADD COLUMN deleted_at TIMESTAMP;
+
+-- Deleted records keep their rows until purged, but should not hold on to
+-- identifiers a new record may need. Subject names stay unique throughout
+-- because materials refer to subjects by name.
+ALTER TABLE users DROP CONSTRAINT users_card_number_key;
+ALTER TABLE books DROP CONSTRAINT books_barcode_key;
+ALTER TABLE books DROP CONSTRAINT books_isbn13_key;
+
+CREATE UNIQUE INDEX idx_users_card_number ON users (card_number) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_books_barcode ON books (barcode) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_books_isbn13 ON books (isbn13) WHERE deleted_at IS NULL;
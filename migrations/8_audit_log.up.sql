@@ -0,0 +1,27 @@
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id UUID NOT NULL,
+    old_values JSONB NOT NULL,
+    new_values JSONB NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX idx_audit_log_created ON audit_log (created_at);
+
+-- Entries outlive the records they describe, so there are no foreign keys,
+-- and they are never changed once written.
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,7 @@
+ALTER TABLE issued_books DROP COLUMN version;
+ALTER TABLE materials DROP COLUMN version;
+ALTER TABLE subjects DROP COLUMN version;
+ALTER TABLE users DROP COLUMN version;
+ALTER TABLE locations DROP COLUMN version;
+ALTER TABLE authors DROP COLUMN version;
+ALTER TABLE books DROP COLUMN version;
@@ -0,0 +1,9 @@
+-- Every write to a record bumps its version, so a client can make a write
+-- conditional on the version it last read.
+ALTER TABLE books ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE authors ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE locations ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE subjects ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE materials ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE issued_books ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -1,6 +1,10 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,22 +18,74 @@ type Book struct {
 	IsCheckedOut bool      `db:"is_checked_out"`
 	BookType     string    `db:"book_type"`
 	CreatedAt    time.Time `db:"created_at"`
+	Barcode      *string   `db:"barcode"`
+	ISBN10       *string   `db:"isbn10"`
+	ISBN13       *string   `db:"isbn13"`
+
+	Publisher       *string `db:"publisher"`
+	PublicationYear *int    `db:"publication_year"`
+	PageCount       *int    `db:"page_count"`
+	CoverURL        *string `db:"cover_url"`
+
+	// Contributors, when set, replace the book's contributors on create
+	// and update. Reads leave it empty; see BookStore.Contributors.
+	Contributors []Contributor `db:"-"`
+
+	Version   int        `db:"version"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+// Contributor roles, as allowed by book_contributors.
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+)
+
+// Contributor links an author to a book in a role. Position orders the
+// contributors of a book; Book.AuthorID is the first one in the author
+// role, or the first one if there is none.
+type Contributor struct {
+	BookID   uuid.UUID `db:"book_id"`
+	AuthorID uuid.UUID `db:"author_id"`
+	Name     string    `db:"name"`
+	Role     string    `db:"role"`
+	Position int       `db:"position"`
 }
 
 type Author struct {
-	ID   uuid.UUID `db:"id"`
-	Name string    `db:"name"`
+	ID        uuid.UUID  `db:"id"`
+	Name      string     `db:"name"`
+	Version   int        `db:"version"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+// AuthorMerge records an author folded into another as a duplicate. The
+// merged author no longer exists; its ID and name are kept here.
+type AuthorMerge struct {
+	ID         uuid.UUID `db:"id"`
+	SurvivorID uuid.UUID `db:"survivor_id"`
+	MergedID   uuid.UUID `db:"merged_id"`
+	MergedName string    `db:"merged_name"`
+	BooksMoved int       `db:"books_moved"`
+	MergedAt   time.Time `db:"merged_at"`
 }
 
 type Location struct {
-	ID   uuid.UUID `db:"id"`
-	Name string    `db:"name"`
+	ID        uuid.UUID  `db:"id"`
+	Name      string     `db:"name"`
+	Version   int        `db:"version"`
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 type User struct {
-	ID    uuid.UUID `db:"id"`
-	Name  string    `db:"name"`
-	Class string    `db:"class"`
+	ID         uuid.UUID  `db:"id"`
+	Name       string     `db:"name"`
+	Class      string     `db:"class"`
+	CardNumber *string    `db:"card_number"`
+	Version    int        `db:"version"`
+	DeletedAt  *time.Time `db:"deleted_at"`
 }
 
 type IssuedBook struct {
@@ -39,58 +95,348 @@ type IssuedBook struct {
 	IssueDate  time.Time  `db:"issue_date"`
 	ReturnDate *time.Time `db:"return_date"`
 	LateFees   float64    `db:"late_fees"`
+	FeesPaidAt *time.Time `db:"fees_paid_at"`
+	Version    int        `db:"version"`
 }
 
+// Subject is a node of the subject hierarchy, such as Physics under
+// Science. Top-level subjects have no ParentID.
 type Subject struct {
-	ID        uuid.UUID `db:"id"`
-	Name      string    `db:"name"`
-	Language  string    `db:"language"`
-	CreatedAt time.Time `db:"created_at"`
+	ID        uuid.UUID  `db:"id"`
+	ParentID  *uuid.UUID `db:"parent_id"`
+	Name      string     `db:"name"`
+	Language  string     `db:"language"`
+	CreatedAt time.Time  `db:"created_at"`
+	Version   int        `db:"version"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+// SubjectNode is a subject with counts over its subtree, which is the
+// subject and every subject below it. Books and Materials count each item
+// filed anywhere in the subtree once.
+type SubjectNode struct {
+	Subject
+	Children    int `db:"children"`
+	Descendants int `db:"descendants"`
+	Books       int `db:"books"`
+	Materials   int `db:"materials"`
 }
 
+// Material belongs to the subject with SubjectID. SubjectName is that
+// subject's current name, read along with the material and never written.
 type Material struct {
+	ID          uuid.UUID  `db:"id"`
+	Title       string     `db:"title"`
+	Description string     `db:"description"`
+	Notes       string     `db:"notes"`
+	Type        string     `db:"type"`
+	Link        string     `db:"link"`
+	Language    string     `db:"language"`
+	SubjectID   uuid.UUID  `db:"subject_id"`
+	SubjectName string     `db:"subject_name"`
+	CreatedAt   time.Time  `db:"created_at"`
+	Version     int        `db:"version"`
+	DeletedAt   *time.Time `db:"deleted_at"`
+}
+
+// MaterialFile is a file uploaded to a material. SHA256 is the key of its
+// content in blob storage, which files with the same content share.
+type MaterialFile struct {
 	ID          uuid.UUID `db:"id"`
+	MaterialID  uuid.UUID `db:"material_id"`
+	SHA256      string    `db:"sha256"`
+	Filename    string    `db:"filename"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// MaterialRevision is a material's content after one change to it. A
+// material's revisions are numbered from 1, and Version is the material's
+// version the revision was written at. Author is the actor who made the
+// change and Summary says what it changed.
+type MaterialRevision struct {
+	MaterialID  uuid.UUID `db:"material_id"`
+	Revision    int       `db:"revision"`
+	Version     int       `db:"version"`
 	Title       string    `db:"title"`
 	Description string    `db:"description"`
 	Notes       string    `db:"notes"`
 	Type        string    `db:"type"`
 	Link        string    `db:"link"`
 	Language    string    `db:"language"`
-	SubjectName string    `db:"subject_name"`
+	SubjectID   uuid.UUID `db:"subject_id"`
+	Author      string    `db:"author"`
+	Summary     string    `db:"summary"`
 	CreatedAt   time.Time `db:"created_at"`
 }
 
-// Interfaces for CRUD operations
+// FieldChange is a field of a record with different values in two of its
+// revisions, named by its db name.
+type FieldChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// LinkCheck is the outcome of the last check of a material's link: its
+// Status, the HTTP status code of the last response if there was one, the
+// URL any redirects ended at, and why it is broken. A check only counts
+// while the material's link is still URL; until a link has been checked
+// its status is LinkStatusUnchecked.
+type LinkCheck struct {
+	MaterialID uuid.UUID `db:"material_id"`
+	URL        string    `db:"url"`
+	Status     string    `db:"status"`
+	StatusCode int       `db:"status_code"`
+	FinalURL   string    `db:"final_url"`
+	Error      string    `db:"error"`
+	CheckedAt  time.Time `db:"checked_at"`
+}
+
+// Link statuses.
+const (
+	LinkStatusOK        = "ok"
+	LinkStatusBroken    = "broken"
+	LinkStatusUnchecked = "unchecked"
+)
+
+// LinkStatuses lists the statuses a material's link can have.
+var LinkStatuses = []string{LinkStatusOK, LinkStatusBroken, LinkStatusUnchecked}
+
+// Controlled vocabularies. A book's type, a material's type, a user's class
+// and the language of subjects and materials are codes of one of these.
+const (
+	VocabularyBookType     = "book_type"
+	VocabularyMaterialType = "material_type"
+	VocabularyUserClass    = "user_class"
+	VocabularyLanguage     = "language"
+)
+
+var Vocabularies = []string{VocabularyBookType, VocabularyMaterialType, VocabularyUserClass, VocabularyLanguage}
+
+// Term is a code of a controlled vocabulary, with a label for each locale
+// it has been named in. Codes are lower case with words joined by hyphens.
+// Records keep inactive terms they already have, but no write can give one
+// to a record.
+type Term struct {
+	ID         uuid.UUID `db:"id"`
+	Vocabulary string    `db:"vocabulary"`
+	Code       string    `db:"code"`
+	Active     bool      `db:"active"`
+	CreatedAt  time.Time `db:"created_at"`
+
+	Labels map[string]string `db:"-"`
+}
+
+// TermError is returned by a write that gives Field a code which is not an
+// active term of its vocabulary.
+type TermError struct {
+	Field      string
+	Vocabulary string
+	Code       string
+	Inactive   bool
+}
+
+func (e *TermError) Error() string {
+	if e.Inactive {
+		return fmt.Sprintf("%s %q is no longer in use", e.Vocabulary, e.Code)
+	}
+	return fmt.Sprintf("%q is not a %s", e.Code, e.Vocabulary)
+}
+
+// TermErrors is returned by a write that gives one or more fields codes
+// which are not active terms, with one TermError per field.
+type TermErrors []*TermError
+
+func (e TermErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Audit attributes writes to the request that makes them. Stores bound to
+// an Audit record each write in the audit log; an empty Actor is recorded
+// as the system itself.
+type Audit struct {
+	Actor     string
+	RequestID string
+	Reason    string
+}
+
+// Audit log entity types.
+const (
+	EntityBook     = "book"
+	EntityAuthor   = "author"
+	EntityLocation = "location"
+	EntityUser     = "user"
+	EntitySubject  = "subject"
+	EntityMaterial = "material"
+	EntityLoan     = "loan"
+	EntityTerm     = "vocabulary_term"
+)
+
+// AuditEntry is one write as recorded in the audit log. Before and After
+// hold the fields that changed, as JSON objects; Before is null for a
+// created record and After is null for a purged one.
+type AuditEntry struct {
+	ID         uuid.UUID       `db:"id"`
+	Actor      string          `db:"actor"`
+	Action     string          `db:"action"`
+	EntityType string          `db:"entity_type"`
+	EntityID   uuid.UUID       `db:"entity_id"`
+	Before     json.RawMessage `db:"old_values"`
+	After      json.RawMessage `db:"new_values"`
+	RequestID  string          `db:"request_id"`
+	Reason     string          `db:"reason"`
+	CreatedAt  time.Time       `db:"created_at"`
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   uuid.UUID
+	RequestID  string
+	Since      time.Time
+	Until      time.Time
+
+	// Oldest lists entries oldest first instead of newest first.
+	Oldest bool
+	Limit  int
+	Offset int
+}
+
+// ErrDuplicate is returned when a write would give a record the name or
+// identifier of another, possibly deleted, record.
+var ErrDuplicate = errors.New("duplicate of an existing record")
+
+// ErrInUse is returned when purging a record, or deleting a vocabulary
+// term, that other records, deleted or not, still refer to.
+var ErrInUse = errors.New("record is still referenced")
+
+// ErrInvalidParent is returned when a subject is given a parent that is
+// missing or deleted, or that is the subject itself or below it.
+var ErrInvalidParent = errors.New("invalid parent subject")
+
+// ErrSubjectGone is returned when reverting a material to a revision whose
+// subject has since been deleted.
+var ErrSubjectGone = errors.New("subject of the revision has been deleted")
+
+// ErrConflict is returned when a write names a version of the record that
+// is no longer current.
+var ErrConflict = errors.New("record has changed since it was read")
+
+// Interfaces for CRUD operations.
+//
+// Deletes are soft: the record is hidden from every read until it is
+// restored, or purged for good. Purge only takes deleted records.
+//
+// Every write bumps the record's version. Updates given a record with a
+// non-zero Version, and deletes given a non-zero version, only apply while
+// the record is at that version and return ErrConflict otherwise; a zero
+// version writes whatever is current. Updates leave the new version in the
+// record and return sql.ErrNoRows for a missing or deleted one.
+//
+// Updates write every field of the record, or with columns only the
+// fields with those db names. A book's IsCheckedOut is never updated this
+// way; only issuing and returning the book change it.
+//
+// Books and materials are filed under any number of subjects. Setting a
+// record's subjects replaces them all and is versioned like any other
+// update; a material always stays filed under its own subject. Lists
+// of what is in a subject cover its whole subtree, or with subtree false
+// only what is filed under the subject itself. SubjectNodes takes uuid.Nil
+// for the top-level subjects. Giving a subject a parent that is not a live
+// subject outside its subtree returns ErrInvalidParent.
+//
+// ReassignMaterials moves every material belonging to one subject to
+// another, refiling it there, and returns how many it moved.
+//
+// Adding and removing a material's files are versioned updates of the
+// material. Files of any material share content with the same digest.
+// LockContent locks a digest until the transaction ends, so that which
+// files have its content cannot change meanwhile; it is taken before a
+// file with the content is added or removed. ReleaseContent, with the
+// digest locked, forgets content no file has any more and reports whether
+// it did, so that the caller deletes it from storage before committing.
+//
+// Every write that changes a material's content (its title, description,
+// notes, type, link, language or subject) adds a revision, which is never
+// changed afterwards. DiffRevisions lists the fields that differ from one
+// revision to another. RevertMaterial is a versioned update that restores
+// the content of an earlier revision, adding a revision of its own.
+//
+// Link checks are not edits: recording one neither bumps the material's
+// version nor writes the audit log. LinksDue lists the live materials
+// whose link is unchecked or was last checked before the given time,
+// those never checked first.
 type BookStore interface {
 	Book(id uuid.UUID) (Book, error)
 	Books() ([]Book, error)
 	CreateBook(b *Book) error
-	UpdateBook(b *Book) error
-	DeleteBook(id uuid.UUID) error
+	UpdateBook(b *Book, columns ...string) error
+	DeleteBook(id uuid.UUID, version int) error
+	BookByBarcode(barcode string) (Book, error)
+	BooksWithoutBarcode() ([]Book, error)
+	NextBarcodeSequence() (int64, error)
+	SetBarcode(id uuid.UUID, barcode string) error
+	BookByISBN(isbn13 string) (Book, error)
+	EachBook(fn func(Book) error) error
+	Contributors(bookID uuid.UUID) ([]Contributor, error)
+	SetContributors(bookID uuid.UUID, version int, contributors []Contributor) error
+	RestoreBook(id uuid.UUID) error
+	PurgeBook(id uuid.UUID) error
+	DeletedBooks() ([]Book, error)
+	BooksAtLocation(locationID uuid.UUID) ([]Book, error)
+	Subjects(bookID uuid.UUID) ([]Subject, error)
+	SetSubjects(bookID uuid.UUID, version int, subjectIDs []uuid.UUID) error
+	BooksInSubject(subjectID uuid.UUID, subtree bool) ([]Book, error)
 }
 
 type AuthorStore interface {
 	Author(id uuid.UUID) (Author, error)
 	Authors() ([]Author, error)
 	CreateAuthor(a *Author) error
-	UpdateAuthor(a *Author) error
-	DeleteAuthor(id uuid.UUID) error
+	UpdateAuthor(a *Author, columns ...string) error
+	DeleteAuthor(id uuid.UUID, version int) error
+	Contributions(authorID uuid.UUID) ([]Contributor, error)
+	BookCounts() (map[uuid.UUID]int, error)
+	MergeAuthors(survivorID uuid.UUID, mergedIDs []uuid.UUID) ([]AuthorMerge, error)
+	AuthorMerges(survivorID uuid.UUID) ([]AuthorMerge, error)
+	RestoreAuthor(id uuid.UUID) error
+	PurgeAuthor(id uuid.UUID) error
+	DeletedAuthors() ([]Author, error)
 }
 
 type LocationStore interface {
 	Location(id uuid.UUID) (Location, error)
 	Locations() ([]Location, error)
 	CreateLocation(l *Location) error
-	UpdateLocation(l *Location) error
-	DeleteLocation(id uuid.UUID) error
+	UpdateLocation(l *Location, columns ...string) error
+	DeleteLocation(id uuid.UUID, version int) error
+	BookCounts() (map[uuid.UUID]int, error)
+	RestoreLocation(id uuid.UUID) error
+	PurgeLocation(id uuid.UUID) error
+	DeletedLocations() ([]Location, error)
 }
 
 type UserStore interface {
 	User(id uuid.UUID) (User, error)
 	Users() ([]User, error)
 	CreateUser(u *User) error
-	UpdateUser(u *User) error
-	DeleteUser(id uuid.UUID) error
+	UpdateUser(u *User, columns ...string) error
+	DeleteUser(id uuid.UUID, version int) error
+	UserByCardNumber(cardNumber string) (User, error)
+	UsersWithoutCardNumber() ([]User, error)
+	NextCardSequence() (int64, error)
+	SetCardNumber(id uuid.UUID, cardNumber string) error
+	RestoreUser(id uuid.UUID) error
+	PurgeUser(id uuid.UUID) error
+	DeletedUsers() ([]User, error)
 }
 
 type IssuedBookStore interface {
@@ -98,23 +444,102 @@ type IssuedBookStore interface {
 	ReturnBook(bookID uuid.UUID) (float64, error)
 	GetIssuedBookByBookID(bookID uuid.UUID) (IssuedBook, error)
 	IssuedBooks() ([]IssuedBook, error)
+	IssuedBooksByUser(userID uuid.UUID) ([]IssuedBook, error)
+
+	// UnpaidFees sums the late fees a user owes across all their loans:
+	// those accruing on open loans and those charged on returned loans
+	// that have not been paid.
+	UnpaidFees(userID uuid.UUID) (float64, error)
+	// PayFees marks the fees charged on a user's returned loans as paid
+	// and returns their total.
+	PayFees(userID uuid.UUID) (float64, error)
 }
 
 type SubjectStore interface {
 	Subject(id uuid.UUID) (Subject, error)
 	Subjects() ([]Subject, error)
 	CreateSubject(s *Subject) error
-	UpdateSubject(s *Subject) error
-	DeleteSubject(id uuid.UUID) error
+	UpdateSubject(s *Subject, columns ...string) error
+	DeleteSubject(id uuid.UUID, version int) error
 	SubjectByName(name string) (Subject, error)
+	RestoreSubject(id uuid.UUID) error
+	PurgeSubject(id uuid.UUID) error
+	DeletedSubjects() ([]Subject, error)
+	SubjectNode(id uuid.UUID) (SubjectNode, error)
+	SubjectNodes(parentID uuid.UUID) ([]SubjectNode, error)
+	Ancestors(id uuid.UUID) ([]Subject, error)
+	Children(id uuid.UUID) ([]Subject, error)
 }
 
 type MaterialStore interface {
 	Material(id uuid.UUID) (Material, error)
 	Materials() ([]Material, error)
 	CreateMaterial(material *Material) error
-	UpdateMaterial(material *Material) error
-	DeleteMaterial(id uuid.UUID) error
+	UpdateMaterial(material *Material, columns ...string) error
+	DeleteMaterial(id uuid.UUID, version int) error
 	GetMaterialsBySubject(subjectName string) ([]Material, error)
 	GetMaterialsByLanguage(language string) ([]Material, error)
+	RestoreMaterial(id uuid.UUID) error
+	PurgeMaterial(id uuid.UUID) error
+	DeletedMaterials() ([]Material, error)
+	Subjects(materialID uuid.UUID) ([]Subject, error)
+	SetSubjects(materialID uuid.UUID, version int, subjectIDs []uuid.UUID) error
+	MaterialsInSubject(subjectID uuid.UUID, subtree bool) ([]Material, error)
+	ReassignMaterials(fromSubjectID, toSubjectID uuid.UUID) (int, error)
+	Files(materialID uuid.UUID) ([]MaterialFile, error)
+	File(materialID, fileID uuid.UUID) (MaterialFile, error)
+	AddFile(f *MaterialFile, version int) error
+	RemoveFile(materialID, fileID uuid.UUID, version int) error
+	LockContent(sha256 string) error
+	ReleaseContent(sha256 string) (bool, error)
+	MaterialsByLinkStatus(status string) ([]Material, error)
+	LinkCheck(materialID uuid.UUID) (LinkCheck, error)
+	LinksDue(checkedBefore time.Time, limit int) ([]Material, error)
+	RecordLinkCheck(c *LinkCheck) error
+	Revisions(materialID uuid.UUID) ([]MaterialRevision, error)
+	Revision(materialID uuid.UUID, revision int) (MaterialRevision, error)
+	DiffRevisions(materialID uuid.UUID, from, to int) ([]FieldChange, error)
+	RevertMaterial(materialID uuid.UUID, revision, version int) error
+}
+
+// VocabularyStore manages the controlled vocabularies. Terms are read with
+// their labels, and updates write the term's Active flag and labels; codes
+// never change. Deleting a term that records use returns ErrInUse.
+//
+// The other stores normalize the codes they are given and return
+// TermErrors for the ones that are not active terms. Updates only check
+// the codes they change, so a record keeps a term that has since been
+// deactivated.
+type VocabularyStore interface {
+	Terms(vocabulary string) ([]Term, error)
+	Term(vocabulary, code string) (Term, error)
+	CreateTerm(t *Term) error
+	UpdateTerm(t *Term) error
+	DeleteTerm(vocabulary, code string) error
+}
+
+// AuditStore reads the audit log. Entries are written by the other stores
+// as part of each write and are never changed afterwards.
+type AuditStore interface {
+	AuditEntries(f AuditFilter) ([]AuditEntry, error)
+}
+
+// Stores groups the stores that can share a transaction.
+type Stores struct {
+	Books       BookStore
+	Authors     AuthorStore
+	Locations   LocationStore
+	Users       UserStore
+	Subjects    SubjectStore
+	Materials   MaterialStore
+	IssuedBooks IssuedBookStore
+
+	Vocabularies VocabularyStore
+}
+
+// TxRunner hands out stores whose writes are attributed to audit: bound
+// to one transaction by InTx, or writing on their own through Stores.
+type TxRunner interface {
+	InTx(audit Audit, fn func(Stores) error) error
+	Stores(audit Audit) Stores
 }
@@ -0,0 +1,128 @@
+// Package names normalizes the names of people and places and scores how
+// alike two of them are, for finding duplicate authors and locations.
+package names
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize folds a name to a comparison key. Accents, case and punctuation
+// are dropped, "Last, First" is turned around and runs of initials are
+// joined, so "Rowling, J.K." and "J. K. Rowling" both give "jk rowling".
+func Normalize(s string) string {
+	if last, first, ok := strings.Cut(s, ","); ok && !strings.Contains(first, ",") {
+		s = first + " " + last
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		case r == '\'' || r == '’':
+			// O'Brien and OBrien are the same name.
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	var words []string
+	initials := false // the last word is a run of initials
+	for _, w := range strings.Fields(b.String()) {
+		single := utf8.RuneCountInString(w) == 1 && !unicode.IsDigit([]rune(w)[0])
+		if single && initials {
+			words[len(words)-1] += w
+			continue
+		}
+		words = append(words, w)
+		initials = single
+	}
+	return strings.Join(words, " ")
+}
+
+// Similarity scores two names from 0 (nothing alike) to 1 (the same once
+// normalized). It is the Jaro-Winkler similarity of the normalized names,
+// or of their words in sorted order if that is higher, so word order does
+// not count against a pair. Names with different numbers in them, like
+// "Shelf 3" and "Shelf 4", score 0.
+func Similarity(a, b string) float64 {
+	return Compare(Normalize(a), Normalize(b))
+}
+
+// Compare is Similarity for names that have already been normalized, for
+// callers comparing many names against each other.
+func Compare(na, nb string) float64 {
+	if na == nb {
+		return 1
+	}
+	if numbers(na) != numbers(nb) {
+		return 0
+	}
+	return max(jaroWinkler(na, nb), jaroWinkler(sortWords(na), sortWords(nb)))
+}
+
+func numbers(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsDigit(r) }), " ")
+}
+
+func sortWords(s string) string {
+	words := strings.Fields(s)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// jaroWinkler is the Jaro similarity of a and b, raised for a common prefix
+// of up to four characters.
+func jaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	window = max(window, 0)
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i, r := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && rb[j] == r {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
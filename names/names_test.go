@@ -0,0 +1,85 @@
+package names
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"J. K. Rowling", "jk rowling"},
+		{"J.K. Rowling", "jk rowling"},
+		{"Rowling, J.K.", "jk rowling"},
+		{"  Gabriel  García Márquez ", "gabriel garcia marquez"},
+		{"Flannery O'Connor", "flannery oconnor"},
+		{"Smith, John, Jr.", "smith john jr"},
+		{"Shelf 3 B", "shelf 3 b"},
+		{"A 1 B", "a 1 b"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	// Reference values from Winkler's papers.
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "marhta", 0.9611},
+		{"dwayne", "duane", 0.8400},
+		{"dixon", "dicksonx", 0.8133},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+		{"a", "a", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := jaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+				t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+			}
+			if got, back := jaroWinkler(tt.a, tt.b), jaroWinkler(tt.b, tt.a); math.Abs(got-back) > 1e-9 {
+				t.Errorf("jaroWinkler is not symmetric: %.4f and %.4f", got, back)
+			}
+		})
+	}
+}
+
+// TestSimilarity checks the pairs the duplicate report's default threshold
+// of 0.92 is tuned for.
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		duplicate bool
+	}{
+		{"J.K. Rowling", "Rowling, J. K.", true},
+		{"J.R.R. Tolkien", "J.R.R. Tolkein", true},
+		{"Ursula K. Le Guin", "Le Guin Ursula K", true},
+		{"Jane Austen", "John Austin", false},
+		{"Shelf 3", "Shelf 4", false},
+		{"Room 12", "Room 21", false},
+		{"Frank Herbert", "Brian Herbert", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			score := Similarity(tt.a, tt.b)
+			if score < 0 || score > 1 {
+				t.Fatalf("Similarity = %f, outside 0..1", score)
+			}
+			if got := score >= 0.92; got != tt.duplicate {
+				t.Errorf("Similarity(%q, %q) = %.3f, duplicate %v, want %v", tt.a, tt.b, score, got, tt.duplicate)
+			}
+		})
+	}
+	if got := Similarity("Shelf 3", "Shelf 4"); got != 0 {
+		t.Errorf("names with different numbers score %f, want 0", got)
+	}
+}
@@ -0,0 +1,8 @@
+// Package views holds the librarian web UI: HTML templates and the static
+// assets they reference, embedded into the binary.
+package views
+
+import "embed"
+
+//go:embed *.html static
+var FS embed.FS
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Requests name who is making a change, and optionally why, in these
+// headers. The request ID is taken from the client when it sends one and
+// generated otherwise; either way it is echoed in the response.
+const (
+	actorHeader     = "X-Actor"
+	reasonHeader    = "X-Change-Reason"
+	requestIDHeader = "X-Request-ID"
+
+	// anonymousActor is recorded for requests that do not name an actor.
+	anonymousActor = "anonymous"
+
+	requestIDKey = "request_id"
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+var auditEntityTypes = []string{
+	model.EntityBook,
+	model.EntityAuthor,
+	model.EntityLocation,
+	model.EntityUser,
+	model.EntitySubject,
+	model.EntityMaterial,
+	model.EntityLoan,
+	model.EntityTerm,
+}
+
+// requestID gives every request an ID for the audit log and for matching
+// client reports to server logs.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(requestIDHeader))
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// audited runs fn on a copy of the handler whose stores attribute every
+// write to the request: its actor, request ID and reason. Write routes are
+// registered through it.
+func (h *Handler) audited(fn func(h *Handler, c *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fn(h.forRequest(c), c)
+	}
+}
+
+func (h *Handler) forRequest(c *gin.Context) *Handler {
+	actor := strings.TrimSpace(c.GetHeader(actorHeader))
+	if actor == "" {
+		actor = anonymousActor
+	}
+
+	rh := *h
+	rh.audit = model.Audit{
+		Actor:     actor,
+		RequestID: c.GetString(requestIDKey),
+		Reason:    strings.TrimSpace(c.GetHeader(reasonHeader)),
+	}
+	if h.Tx != nil {
+		rh.setStores(h.Tx.Stores(rh.audit))
+	}
+	return &rh
+}
+
+// HELPER FUNCTIONS
+
+// auditFilter reads the GET /audit query string.
+func auditFilter(c *gin.Context) (model.AuditFilter, *apiError) {
+	f := model.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		RequestID:  c.Query("request_id"),
+		Limit:      defaultAuditLimit,
+	}
+
+	if f.EntityType != "" && !slices.Contains(auditEntityTypes, f.EntityType) {
+		return f, newAPIError(http.StatusBadRequest, "entity_type must be one of "+strings.Join(auditEntityTypes, ", "))
+	}
+	if raw := c.Query("entity_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return f, newAPIError(http.StatusBadRequest, "Invalid entity_id")
+		}
+		f.EntityID = id
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if raw := c.Query(bound.name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return f, newAPIError(http.StatusBadRequest, bound.name+" must be an RFC 3339 timestamp")
+			}
+			*bound.dest = t.UTC()
+		}
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return f, newAPIError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+		}
+		f.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return f, newAPIError(http.StatusBadRequest, "offset must be a non-negative integer")
+		}
+		f.Offset = offset
+	}
+	return f, nil
+}
+
+func (h *Handler) auditEntries(f model.AuditFilter) ([]AuditEntryDTO, *apiError) {
+	if h.AuditStore == nil {
+		return nil, newAPIError(http.StatusServiceUnavailable, "The audit log is not configured")
+	}
+	entries, err := h.AuditStore.AuditEntries(f)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to retrieve audit log")
+	}
+	return mapSlice(entries, newAuditEntryDTO), nil
+}
+
+// GET HANDLERS
+
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	f, apiErr := auditFilter(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	entries, apiErr := h.auditEntries(f)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// HistoryHandler returns the handler listing the audit entries of one
+// record, oldest first. Deleted and purged records keep their history.
+func (h *Handler) HistoryHandler(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + entity + " ID"})
+			return
+		}
+
+		entries, apiErr := h.auditEntries(model.AuditFilter{EntityType: entity, EntityID: id, Oldest: true, Limit: maxAuditLimit})
+		if apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"history": entries})
+	}
+}
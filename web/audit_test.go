@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// fakeAuditStore records the filter it was last asked for.
+type fakeAuditStore struct {
+	filter  model.AuditFilter
+	entries []model.AuditEntry
+}
+
+func (s *fakeAuditStore) AuditEntries(f model.AuditFilter) ([]model.AuditEntry, error) {
+	s.filter = f
+	return s.entries, nil
+}
+
+// recordingTx remembers the audit details of the last stores it handed out.
+type recordingTx struct {
+	fakeTx
+	audit model.Audit
+}
+
+func (tx *recordingTx) Stores(audit model.Audit) model.Stores {
+	tx.audit = audit
+	return tx.fakeTx.Stores(audit)
+}
+
+func TestGetAuditLogFilter(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   model.AuditFilter
+	}{
+		{name: "defaults", status: http.StatusOK, want: model.AuditFilter{Limit: defaultAuditLimit}},
+		{
+			name:   "every filter",
+			query:  "?actor=ann&action=update&entity_type=book&entity_id=" + id.String() + "&request_id=r1&since=2024-01-02T03:04:05%2B02:00&until=2024-02-01T00:00:00Z&limit=5&offset=10",
+			status: http.StatusOK,
+			want: model.AuditFilter{
+				Actor: "ann", Action: "update", EntityType: model.EntityBook, EntityID: id, RequestID: "r1",
+				Since: time.Date(2024, time.January, 2, 1, 4, 5, 0, time.UTC), Until: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+				Limit: 5, Offset: 10,
+			},
+		},
+		{name: "unknown entity type", query: "?entity_type=shelf", status: http.StatusBadRequest},
+		{name: "bad entity ID", query: "?entity_id=42", status: http.StatusBadRequest},
+		{name: "bad since", query: "?since=yesterday", status: http.StatusBadRequest},
+		{name: "limit too high", query: "?limit=1001", status: http.StatusBadRequest},
+		{name: "zero limit", query: "?limit=0", status: http.StatusBadRequest},
+		{name: "negative offset", query: "?offset=-1", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeAuditStore{}
+			h := newFixture().handler()
+			h.AuditStore = store
+			w := serve(newTestRouter(h), http.MethodGet, "/api/v1/audit"+tt.query, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && store.filter != tt.want {
+				t.Errorf("filter = %+v, want %+v", store.filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	f := newFixture()
+	store := &fakeAuditStore{entries: []model.AuditEntry{{
+		ID: uuid.New(), Actor: "ann", Action: "update", EntityType: model.EntityUser, EntityID: f.userID,
+		Before: json.RawMessage(`{"class": "9"}`), After: json.RawMessage(`{"class": "10"}`),
+	}}}
+	h := f.handler()
+	h.AuditStore = store
+	r := newTestRouter(h)
+
+	w := serve(r, http.MethodGet, "/api/v1/users/"+f.userID.String()+"/history", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	want := model.AuditFilter{EntityType: model.EntityUser, EntityID: f.userID, Oldest: true, Limit: maxAuditLimit}
+	if store.filter != want {
+		t.Errorf("filter = %+v, want %+v", store.filter, want)
+	}
+	var resp struct {
+		History []AuditEntryDTO `json:"history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.History) != 1 || !reflect.DeepEqual(resp.History[0].After, map[string]any{"class": "10"}) {
+		t.Errorf("history = %+v", resp.History)
+	}
+
+	if w := serve(r, http.MethodGet, "/api/v1/users/42/history", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("bad ID: status = %d", w.Code)
+	}
+	h.AuditStore = nil
+	if w := serve(newTestRouter(h), http.MethodGet, "/api/v1/audit", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("no audit store: status = %d", w.Code)
+	}
+}
+
+func TestAuditedAttribution(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   []string
+		actor     string
+		reason    string
+		requestID string
+	}{
+		{name: "anonymous", actor: anonymousActor},
+		{
+			name:      "named",
+			headers:   []string{actorHeader, " ann ", reasonHeader, " typo ", requestIDHeader, "req-1"},
+			actor:     "ann",
+			reason:    "typo",
+			requestID: "req-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			tx := &recordingTx{fakeTx: fakeTx{f}}
+			h := f.handler()
+			h.Tx = tx
+			r := newTestRouter(h)
+
+			w := serve(r, http.MethodPut, "/api/v1/users/"+f.userID.String(), `{"name": "Ann", "class": "11"}`, tt.headers...)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if tx.audit.Actor != tt.actor || tx.audit.Reason != tt.reason {
+				t.Errorf("audit = %+v, want actor %q and reason %q", tx.audit, tt.actor, tt.reason)
+			}
+			echoed := w.Header().Get(requestIDHeader)
+			if tt.requestID != "" && echoed != tt.requestID {
+				t.Errorf("%s = %q, want %q", requestIDHeader, echoed, tt.requestID)
+			}
+			if echoed == "" || tx.audit.RequestID != echoed {
+				t.Errorf("audit request ID = %q, response carries %q", tx.audit.RequestID, echoed)
+			}
+		})
+	}
+}
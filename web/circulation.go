@@ -0,0 +1,341 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Circulation policy. The loan period and fee rate mirror the late fee
+// calculation in DBIssuedBookStore.
+const (
+	loanPeriodDays     = 15
+	maxLoansPerPatron  = 5
+	maxFeesBeforeBlock = 10.0
+	deskSessionIdle    = 30 * time.Minute
+)
+
+const (
+	scanIssue  = "issue"
+	scanReturn = "return"
+)
+
+// deskSession is one patron being served at the circulation desk. Sessions
+// are kept in memory; they only hold the patron and the scan log, loans are
+// always read from IssuedBookStore.
+type deskSession struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	StartedAt time.Time
+	LastSeen  time.Time
+	Scans     []ScanDTO
+}
+
+type circulationDesk struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*deskSession
+}
+
+func newCirculationDesk() *circulationDesk {
+	return &circulationDesk{sessions: map[uuid.UUID]*deskSession{}}
+}
+
+func (d *circulationDesk) start(userID uuid.UUID) *deskSession {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range d.sessions {
+		if now.Sub(s.LastSeen) > deskSessionIdle {
+			delete(d.sessions, id)
+		}
+	}
+
+	s := &deskSession{ID: uuid.New(), UserID: userID, StartedAt: now, LastSeen: now}
+	d.sessions[s.ID] = s
+	return s
+}
+
+func (d *circulationDesk) get(id uuid.UUID) (deskSession, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.sessions[id]
+	if !ok || time.Since(s.LastSeen) > deskSessionIdle {
+		delete(d.sessions, id)
+		return deskSession{}, false
+	}
+	s.LastSeen = time.Now()
+	copied := *s
+	copied.Scans = append([]ScanDTO(nil), s.Scans...)
+	return copied, true
+}
+
+func (d *circulationDesk) record(id uuid.UUID, scan ScanDTO) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s, ok := d.sessions[id]; ok {
+		s.Scans = append(s.Scans, scan)
+		s.LastSeen = time.Now()
+	}
+}
+
+func (d *circulationDesk) end(id uuid.UUID) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.sessions[id]
+	delete(d.sessions, id)
+	return ok
+}
+
+// HELPER FUNCTIONS
+
+// patronStatus collects a patron's current loans, the unpaid fees of all
+// their loans, returned ones included, and the blocks that prevent further
+// checkouts.
+func (h *Handler) patronStatus(user model.User) ([]LoanDTO, float64, []string, *apiError) {
+	issuedBooks, err := h.IssuedBookStore.IssuedBooksByUser(user.ID)
+	if err != nil {
+		return nil, 0, nil, newAPIError(http.StatusInternalServerError, "Failed to fetch loans")
+	}
+
+	fees, err := h.IssuedBookStore.UnpaidFees(user.ID)
+	if err != nil {
+		return nil, 0, nil, newAPIError(http.StatusInternalServerError, "Failed to fetch fees")
+	}
+
+	loans := make([]LoanDTO, 0, len(issuedBooks))
+	overdue := 0
+	for _, ib := range issuedBooks {
+		loan := newLoanDTO(ib)
+		if book, err := h.BookStore.Book(ib.BookID); err == nil {
+			loan.Title = book.Title
+		}
+		if loan.Overdue {
+			overdue++
+		}
+		loans = append(loans, loan)
+	}
+
+	blocks := []string{}
+	if overdue > 0 {
+		blocks = append(blocks, fmt.Sprintf("%d overdue item(s) must be returned first", overdue))
+	}
+	if fees > maxFeesBeforeBlock {
+		blocks = append(blocks, fmt.Sprintf("Outstanding fees of %.2f exceed the limit of %.2f", fees, maxFeesBeforeBlock))
+	}
+	if len(loans) >= maxLoansPerPatron {
+		blocks = append(blocks, fmt.Sprintf("Loan limit of %d items reached", maxLoansPerPatron))
+	}
+	return loans, fees, blocks, nil
+}
+
+func (h *Handler) sessionDTO(s deskSession) (CirculationSessionDTO, *apiError) {
+	user, err := h.UserStore.User(s.UserID)
+	if err != nil {
+		return CirculationSessionDTO{}, newAPIError(http.StatusInternalServerError, "Failed to retrieve patron")
+	}
+	loans, fees, blocks, apiErr := h.patronStatus(user)
+	if apiErr != nil {
+		return CirculationSessionDTO{}, apiErr
+	}
+	scans := s.Scans
+	if scans == nil {
+		scans = []ScanDTO{}
+	}
+	return CirculationSessionDTO{
+		ID:        s.ID,
+		Patron:    newUserDTO(user),
+		Loans:     loans,
+		Fees:      fees,
+		Blocks:    blocks,
+		Scans:     scans,
+		StartedAt: formatTime(s.StartedAt),
+	}, nil
+}
+
+// scan issues or returns one item for the session's patron. Without an
+// explicit action, an item already on loan to the patron is returned and
+// anything else is issued.
+func (h *Handler) scan(s deskSession, req CirculationScanRequest) ScanDTO {
+	result := ScanDTO{Item: req.Item, Action: req.Action, At: formatTime(time.Now())}
+	fail := func(apiErr *apiError) ScanDTO {
+		result.Message = apiErr.Error()
+		h.desk.record(s.ID, result)
+		return result
+	}
+
+	book, apiErr := h.resolveBook(req.Item)
+	if apiErr != nil {
+		return fail(apiErr)
+	}
+	result.BookID = &book.ID
+	result.Title = book.Title
+
+	if result.Action == "" {
+		result.Action = scanIssue
+		if existing, err := h.IssuedBookStore.GetIssuedBookByBookID(book.ID); err == nil &&
+			existing.ReturnDate == nil && existing.UserID == s.UserID {
+			result.Action = scanReturn
+		}
+	}
+
+	switch result.Action {
+	case scanIssue:
+		user, err := h.UserStore.User(s.UserID)
+		if err != nil {
+			return fail(newAPIError(http.StatusInternalServerError, "Failed to retrieve patron"))
+		}
+		_, _, blocks, apiErr := h.patronStatus(user)
+		if apiErr != nil {
+			return fail(apiErr)
+		}
+		if len(blocks) > 0 {
+			return fail(newAPIError(http.StatusConflict, "Patron is blocked: "+blocks[0]))
+		}
+		if _, apiErr := h.issueBook(IssueBookRequest{BookID: book.ID.String(), UserID: s.UserID.String()}); apiErr != nil {
+			return fail(apiErr)
+		}
+		result.Message = "Issued"
+	case scanReturn:
+		_, lateFees, apiErr := h.returnBook(ReturnBookRequest{BookID: book.ID.String(), UserID: s.UserID.String()})
+		if apiErr != nil {
+			return fail(apiErr)
+		}
+		result.LateFees = lateFees
+		result.Message = "Returned"
+		if lateFees > 0 {
+			result.Message = fmt.Sprintf("Returned, late fees %.2f", lateFees)
+		}
+	}
+
+	result.OK = true
+	h.desk.record(s.ID, result)
+	return result
+}
+
+func (h *Handler) deskSession(idParam string) (deskSession, *apiError) {
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		return deskSession{}, newAPIError(http.StatusBadRequest, "Invalid session ID")
+	}
+	s, ok := h.desk.get(id)
+	if !ok {
+		return deskSession{}, newAPIError(http.StatusNotFound, "Session not found or expired")
+	}
+	return s, nil
+}
+
+// CIRCULATION DESK HANDLERS
+
+func (h *Handler) StartCirculationSession(c *gin.Context) {
+	var req StartCirculationRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	user, apiErr := h.resolveUser(req.Patron)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	session, apiErr := h.sessionDTO(*h.desk.start(user.ID))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"session": session})
+}
+
+func (h *Handler) GetCirculationSession(c *gin.Context) {
+	s, apiErr := h.deskSession(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	session, apiErr := h.sessionDTO(s)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session": session})
+}
+
+func (h *Handler) ScanCirculationItem(c *gin.Context) {
+	s, apiErr := h.deskSession(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req CirculationScanRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	result := h.scan(s, req)
+
+	// The session may have expired or been ended while the item was
+	// being scanned.
+	s, ok := h.desk.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired", "scan": result})
+		return
+	}
+	session, apiErr := h.sessionDTO(s)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scan": result, "session": session})
+}
+
+func (h *Handler) EndCirculationSession(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if !h.desk.end(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+}
+
+// PayUserFees settles the late fees charged on a user's returned loans.
+// Fees still accruing on open loans are paid once the items are returned.
+func (h *Handler) PayUserFees(c *gin.Context) {
+	user, apiErr := h.resolveUser(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	paid, err := h.IssuedBookStore.PayFees(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Paid late fees of %.2f", paid),
+		"paid":    paid,
+	})
+}
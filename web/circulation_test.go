@@ -0,0 +1,170 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestPatronStatus(t *testing.T) {
+	now := time.Now()
+	recent, overdue := now.AddDate(0, 0, -3), now.AddDate(0, 0, -20)
+	returned := now.AddDate(0, 0, -1)
+
+	loan := func(issued time.Time, ret *time.Time, fees float64, paid *time.Time) model.IssuedBook {
+		return model.IssuedBook{ID: uuid.New(), BookID: uuid.New(), IssueDate: issued, ReturnDate: ret, LateFees: fees, FeesPaidAt: paid}
+	}
+	tests := []struct {
+		name   string
+		loans  []model.IssuedBook
+		open   int
+		fees   float64
+		blocks []string
+	}{
+		{"no loans", nil, 0, 0, nil},
+		{"open loan", []model.IssuedBook{loan(recent, nil, 0, nil)}, 1, 0, nil},
+		{"overdue loan", []model.IssuedBook{loan(overdue, nil, 10, nil)}, 1, 10, []string{"1 overdue item(s)"}},
+		{
+			"unpaid fees on returned loans",
+			[]model.IssuedBook{loan(overdue, &returned, 6, nil), loan(overdue, &returned, 6, nil)},
+			0, 12, []string{"Outstanding fees of 12.00"},
+		},
+		{"paid fees", []model.IssuedBook{loan(overdue, &returned, 40, &returned)}, 0, 0, nil},
+		{
+			"loan limit",
+			[]model.IssuedBook{loan(recent, nil, 0, nil), loan(recent, nil, 0, nil), loan(recent, nil, 0, nil), loan(recent, nil, 0, nil), loan(recent, nil, 0, nil)},
+			5, 0, []string{"Loan limit of 5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			for _, ib := range tt.loans {
+				ib.UserID = f.userID
+				f.issued.loans = append(f.issued.loans, ib)
+			}
+
+			loans, fees, blocks, apiErr := f.handler().patronStatus(f.users.users[f.userID])
+			if apiErr != nil {
+				t.Fatal(apiErr)
+			}
+			if len(loans) != tt.open {
+				t.Errorf("%d loans, want %d", len(loans), tt.open)
+			}
+			if fees != tt.fees {
+				t.Errorf("fees = %.2f, want %.2f", fees, tt.fees)
+			}
+			if len(blocks) != len(tt.blocks) {
+				t.Fatalf("blocks = %q, want %d", blocks, len(tt.blocks))
+			}
+			for i, prefix := range tt.blocks {
+				if !strings.HasPrefix(blocks[i], prefix) {
+					t.Errorf("blocks[%d] = %q, want it to start with %q", i, blocks[i], prefix)
+				}
+			}
+		})
+	}
+}
+
+func TestScanCirculationItem(t *testing.T) {
+	returned := time.Now().AddDate(0, 0, -1)
+	tests := []struct {
+		name       string
+		unpaidFees float64
+		endSession bool
+		status     int
+		ok         bool
+		message    string
+	}{
+		{"issues the item", 0, false, http.StatusOK, true, "Issued"},
+		{"blocked by fees on a returned loan", 25, false, http.StatusOK, false, "Patron is blocked: Outstanding fees"},
+		{"session ended during the scan", 0, true, http.StatusNotFound, true, "Issued"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.unpaidFees > 0 {
+				f.issued.loans = append(f.issued.loans, model.IssuedBook{ID: uuid.New(), BookID: uuid.New(), UserID: f.userID,
+					IssueDate: returned.AddDate(0, 0, -30), ReturnDate: &returned, LateFees: tt.unpaidFees})
+			}
+			h := f.handler()
+			r := newTestRouter(h)
+
+			w := serve(r, http.MethodPost, "/api/v1/circulation/sessions", `{"patron":"20001000000012"}`)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("start session = %d: %s", w.Code, w.Body)
+			}
+			var started struct {
+				Session CirculationSessionDTO `json:"session"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil {
+				t.Fatal(err)
+			}
+			if tt.endSession {
+				f.issued.created = func(model.IssuedBook) { h.desk.end(started.Session.ID) }
+			}
+
+			w = serve(r, http.MethodPost, "/api/v1/circulation/sessions/"+started.Session.ID.String()+"/scans", `{"item":"30001000000010"}`)
+			if w.Code != tt.status {
+				t.Fatalf("scan = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var scanned struct {
+				Scan ScanDTO `json:"scan"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &scanned); err != nil {
+				t.Fatal(err)
+			}
+			if scanned.Scan.OK != tt.ok || !strings.HasPrefix(scanned.Scan.Message, tt.message) {
+				t.Errorf("scan = %+v, want ok=%v and message %q", scanned.Scan, tt.ok, tt.message)
+			}
+		})
+	}
+}
+
+func TestPayUserFees(t *testing.T) {
+	f := newFixture()
+	returned := time.Now().AddDate(0, 0, -1)
+	f.issued.loans = []model.IssuedBook{
+		{ID: uuid.New(), BookID: uuid.New(), UserID: f.userID, IssueDate: returned.AddDate(0, 0, -30), ReturnDate: &returned, LateFees: 12},
+		{ID: uuid.New(), BookID: f.bookID, UserID: f.userID, IssueDate: time.Now().AddDate(0, 0, -20), LateFees: 10},
+	}
+	r := newTestRouter(f.handler())
+
+	tests := []struct {
+		name   string
+		user   string
+		status int
+		paid   float64
+		unpaid float64
+	}{
+		{"pays returned loans only", f.userID.String(), http.StatusOK, 12, 10},
+		{"nothing left to pay", "20001000000012", http.StatusOK, 0, 10},
+		{"unknown user", uuid.NewString(), http.StatusNotFound, 0, 10},
+		{"invalid reference", "nope", http.StatusBadRequest, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodPost, "/api/v1/users/"+tt.user+"/fees/pay", "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				var resp payFeesResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Paid != tt.paid {
+					t.Errorf("paid = %.2f, want %.2f", resp.Paid, tt.paid)
+				}
+			}
+			if unpaid, _ := f.issued.UnpaidFees(f.userID); unpaid != tt.unpaid {
+				t.Errorf("unpaid = %.2f, want %.2f", unpaid, tt.unpaid)
+			}
+		})
+	}
+}
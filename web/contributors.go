@@ -0,0 +1,158 @@
+package web
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HELPER FUNCTIONS
+
+// contributorRequests returns the contributors of a new or replaced book:
+// the given list, or authorName as the only author. Giving both is an
+// error, as is naming someone twice in the same role.
+func contributorRequests(authorName string, reqs []ContributorRequest) ([]ContributorRequest, *apiError) {
+	if len(reqs) == 0 {
+		return []ContributorRequest{{Name: authorName, Role: model.RoleAuthor}}, nil
+	}
+	if authorName != "" {
+		return nil, newAPIError(http.StatusBadRequest, "Give either author_name or contributors, not both")
+	}
+
+	out := make([]ContributorRequest, len(reqs))
+	seen := map[ContributorRequest]bool{}
+	for i, req := range reqs {
+		if req.Role == "" {
+			req.Role = model.RoleAuthor
+		}
+		if seen[req] {
+			return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("%s is listed twice as %s", req.Name, req.Role))
+		}
+		seen[req] = true
+		out[i] = req
+	}
+	return out, nil
+}
+
+// resolveContributors finds or creates the author behind each contributor.
+func (h *Handler) resolveContributors(reqs []ContributorRequest) ([]model.Contributor, *apiError) {
+	contributors := make([]model.Contributor, 0, len(reqs))
+	for i, req := range reqs {
+		author, err := h.getOrCreateAuthor(req.Name)
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to create or find author "+req.Name)
+		}
+		contributors = append(contributors, model.Contributor{
+			AuthorID: author.ID,
+			Name:     author.Name,
+			Role:     req.Role,
+			Position: i,
+		})
+	}
+	return contributors, nil
+}
+
+func (h *Handler) bookContributors(bookID uuid.UUID) ([]model.Contributor, *apiError) {
+	contributors, err := h.BookStore.Contributors(bookID)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to retrieve contributors")
+	}
+	return contributors, nil
+}
+
+// GET HANDLERS
+
+func (h *Handler) GetBookContributors(c *gin.Context) {
+	book, apiErr := h.resolveBook(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	contributors, apiErr := h.bookContributors(book.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"contributors": mapSlice(contributors, newContributorDTO)})
+}
+
+func (h *Handler) GetAuthorBooks(c *gin.Context) {
+	authorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return
+	}
+
+	if _, err := h.AuthorStore.Author(authorID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve author"})
+		return
+	}
+
+	contributions, err := h.AuthorStore.Contributions(authorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve books"})
+		return
+	}
+
+	books := make([]ContributionDTO, 0, len(contributions))
+	for _, contribution := range contributions {
+		book, err := h.BookStore.Book(contribution.BookID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve books"})
+			return
+		}
+		books = append(books, ContributionDTO{Role: contribution.Role, Position: contribution.Position, Book: newBookDTO(book)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"books": books})
+}
+
+// UPDATE HANDLERS
+
+func (h *Handler) SetBookContributors(c *gin.Context) {
+	book, apiErr := h.resolveBook(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Book", book.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req SetContributorsRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	reqs, apiErr := contributorRequests("", req.Contributors)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	contributors, apiErr := h.resolveContributors(reqs)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := h.BookStore.SetContributors(book.ID, version, contributors); err != nil {
+		apiErr := writeError(err, "Book", "update contributors of")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contributors updated successfully", "contributors": mapSlice(contributors, newContributorDTO)})
+}
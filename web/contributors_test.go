@@ -0,0 +1,175 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestContributorRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorName string
+		reqs       []ContributorRequest
+		want       []ContributorRequest
+		wantErr    bool
+	}{
+		{
+			name:       "author name only",
+			authorName: "Frank Herbert",
+			want:       []ContributorRequest{{Name: "Frank Herbert", Role: model.RoleAuthor}},
+		},
+		{
+			name: "roles default to author",
+			reqs: []ContributorRequest{{Name: "Frank Herbert"}, {Name: "Brian Herbert", Role: model.RoleEditor}},
+			want: []ContributorRequest{{Name: "Frank Herbert", Role: model.RoleAuthor}, {Name: "Brian Herbert", Role: model.RoleEditor}},
+		},
+		{
+			name: "same person in two roles",
+			reqs: []ContributorRequest{{Name: "Ann"}, {Name: "Ann", Role: model.RoleIllustrator}},
+			want: []ContributorRequest{{Name: "Ann", Role: model.RoleAuthor}, {Name: "Ann", Role: model.RoleIllustrator}},
+		},
+		{
+			name:    "listed twice",
+			reqs:    []ContributorRequest{{Name: "Ann"}, {Name: "Ann", Role: model.RoleAuthor}},
+			wantErr: true,
+		},
+		{
+			name:       "author name and contributors",
+			authorName: "Frank Herbert",
+			reqs:       []ContributorRequest{{Name: "Brian Herbert"}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, apiErr := contributorRequests(tt.authorName, tt.reqs)
+			if (apiErr != nil) != tt.wantErr {
+				t.Fatalf("contributorRequests error = %v, wantErr %v", apiErr, tt.wantErr)
+			}
+			if apiErr != nil && apiErr.Status != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", apiErr.Status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("contributorRequests = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBookContributors(t *testing.T) {
+	tests := []struct {
+		name    string
+		book    string
+		ifMatch string
+		body    string
+		status  int
+		want    []ContributorDTO
+		authors int
+	}{
+		{
+			name:    "replaces the list in order",
+			ifMatch: `"3"`,
+			body:    `{"contributors": [{"name": "Brian Herbert", "role": "editor"}, {"name": "Herbert, Frank"}]}`,
+			status:  http.StatusOK,
+			want:    []ContributorDTO{{Name: "Brian Herbert", Role: model.RoleEditor, Position: 0}, {Name: "Frank Herbert", Role: model.RoleAuthor, Position: 1}},
+			authors: 2,
+		},
+		{
+			name:    "by barcode",
+			book:    "30001000000010",
+			body:    `{"contributors": [{"name": "Frank Herbert", "role": "translator"}]}`,
+			status:  http.StatusOK,
+			want:    []ContributorDTO{{Name: "Frank Herbert", Role: model.RoleTranslator}},
+			authors: 1,
+		},
+		{name: "stale version", ifMatch: `"2"`, body: `{"contributors": [{"name": "Ann"}]}`, status: http.StatusPreconditionFailed, authors: 2},
+		{name: "listed twice", body: `{"contributors": [{"name": "Ann"}, {"name": "Ann", "role": "author"}]}`, status: http.StatusBadRequest, authors: 1},
+		{name: "empty list", body: `{"contributors": []}`, status: http.StatusBadRequest, authors: 1},
+		{name: "unknown role", body: `{"contributors": [{"name": "Ann", "role": "narrator"}]}`, status: http.StatusBadRequest, authors: 1},
+		{name: "unknown book", book: uuid.NewString(), body: `{"contributors": [{"name": "Ann"}]}`, status: http.StatusNotFound, authors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			book := tt.book
+			if book == "" {
+				book = f.bookID.String()
+			}
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+			w := serve(newTestRouter(f.handler()), http.MethodPut, "/api/v1/books/"+book+"/contributors", tt.body, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if len(f.authors.authors) != tt.authors {
+				t.Errorf("%d authors, want %d", len(f.authors.authors), tt.authors)
+			}
+			if tt.status != http.StatusOK {
+				if got := f.books.contributors[f.bookID]; len(got) != 1 || got[0].Name != "Frank Herbert" {
+					t.Errorf("contributors changed to %+v", got)
+				}
+				return
+			}
+
+			var resp struct {
+				Contributors []ContributorDTO `json:"contributors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			for i := range resp.Contributors {
+				if resp.Contributors[i].Name == "Frank Herbert" && resp.Contributors[i].AuthorID != f.authorID {
+					t.Errorf("Frank Herbert was not matched to the existing author")
+				}
+				resp.Contributors[i].AuthorID = uuid.Nil
+			}
+			if !reflect.DeepEqual(resp.Contributors, tt.want) {
+				t.Errorf("contributors = %+v, want %+v", resp.Contributors, tt.want)
+			}
+			if got := f.books.contributors[f.bookID]; len(got) != len(tt.want) {
+				t.Errorf("stored %d contributors, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetAuthorBooks(t *testing.T) {
+	f := newFixture()
+	r := newTestRouter(f.handler())
+	tests := []struct {
+		author string
+		status int
+		books  int
+	}{
+		{f.authorID.String(), http.StatusOK, 1},
+		{uuid.NewString(), http.StatusNotFound, 0},
+		{"frank", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.author, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/api/v1/authors/"+tt.author+"/books", "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Books []ContributionDTO `json:"books"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Books) != tt.books || resp.Books[0].Role != model.RoleAuthor || resp.Books[0].Book.ID != f.bookID {
+				t.Errorf("books = %+v", resp.Books)
+			}
+		})
+	}
+}
@@ -0,0 +1,734 @@
+package web
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/identifiers"
+	"github.com/arjunsaxaena/Library-Management/isbn"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+const (
+	csvContentType = "text/csv"
+
+	defaultCSVBatchSize = 100
+	maxCSVBatchSize     = 1000
+)
+
+// csvRow is one data row keyed by lower-case column name. Columns missing
+// from the file read as empty.
+type csvRow map[string]string
+
+func (r csvRow) number(column string) (int, error) {
+	if r[column] == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(r[column])
+	if err != nil {
+		return 0, fmt.Errorf("column %s: %q is not a number", column, r[column])
+	}
+	return n, nil
+}
+
+// csvPlan is a validated row waiting to be written. Apply is nil when the
+// row was rejected; its result then already says why.
+type csvPlan struct {
+	Result ImportResultDTO
+	Apply  func(tx *Handler) (uuid.UUID, *apiError)
+}
+
+// csvTable describes how one entity is read from and written to CSV. Rows
+// with an id update that record; rows without one create a new record.
+// ReadOnly columns are exported for reference and ignored on import.
+type csvTable struct {
+	Name     string
+	Columns  []string
+	ReadOnly []string
+	Prepare  func(row int, rec csvRow, seen map[string]int) csvPlan
+	Export   func(write func([]string) error) error
+}
+
+// HELPER FUNCTIONS
+
+func rejectRow(res ImportResultDTO, messages ...string) csvPlan {
+	res.Action = importError
+	res.Messages = append(res.Messages, messages...)
+	return csvPlan{Result: res}
+}
+
+func rejectRowAPI(res ImportResultDTO, apiErr *apiError) csvPlan {
+	plan := rejectRow(res, apiErr.Error())
+	if apiErr.Status == http.StatusConflict {
+		plan.Result.Action = importConflict
+	}
+	return plan
+}
+
+// validateRow runs the binding rules of the request type the row was read
+// into, giving one message per failed field.
+func validateRow(req any) []string {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return validationMessages(err)
+	}
+	return nil
+}
+
+// seenKey builds a key for seenBefore; empty values give no key.
+func seenKey(kind, value string) string {
+	if value == "" {
+		return ""
+	}
+	return kind + ":" + value
+}
+
+// seenBefore reports the earlier row of the file that shares one of keys
+// with this row, and otherwise records keys for the rows that follow.
+func seenBefore(seen map[string]int, row int, keys ...string) (int, bool) {
+	for _, key := range keys {
+		if earlier, ok := seen[key]; ok && key != "" {
+			return earlier, true
+		}
+	}
+	for _, key := range keys {
+		if key != "" {
+			seen[key] = row
+		}
+	}
+	return 0, false
+}
+
+// rowID parses the row's id column. ok is false for rows without one.
+func rowID(rec csvRow) (id uuid.UUID, ok bool, err error) {
+	if rec["id"] == "" {
+		return uuid.Nil, false, nil
+	}
+	id, err = uuid.Parse(rec["id"])
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("column id: %q is not a valid UUID", rec["id"])
+	}
+	return id, true, nil
+}
+
+func lookupError(entity string, id uuid.UUID, err error) string {
+	if err == sql.ErrNoRows {
+		return fmt.Sprintf("%s %s not found", entity, id)
+	}
+	return "Failed to look up " + strings.ToLower(entity)
+}
+
+// newCSVReader skips a UTF-8 byte order mark, which spreadsheet programs
+// like to put in front of the header.
+func newCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && string(b) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// csvHeader maps the header row to column names, rejecting unknown and
+// repeated columns.
+func csvHeader(t csvTable, header []string) ([]string, error) {
+	known := make(map[string]bool, len(t.Columns))
+	for _, column := range t.Columns {
+		known[column] = true
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q, expected some of %s", name, strings.Join(t.Columns, ", "))
+		}
+		for _, earlier := range columns[:i] {
+			if earlier == name {
+				return nil, fmt.Errorf("column %q appears twice", name)
+			}
+		}
+		columns[i] = name
+	}
+	return columns, nil
+}
+
+// applyCSVBatch writes a batch of plans in one transaction. When a row
+// fails the whole batch is rolled back and every row in it is reported.
+func (h *Handler) applyCSVBatch(batch []*csvPlan) {
+	var failed *csvPlan
+	var failure *apiError
+	err := h.inTx(func(tx *Handler) error {
+		for _, plan := range batch {
+			id, apiErr := plan.Apply(tx)
+			if apiErr != nil {
+				failed, failure = plan, apiErr
+				return apiErr
+			}
+			plan.Result.ID = &id
+		}
+		return nil
+	})
+	if err == nil {
+		return
+	}
+
+	for _, plan := range batch {
+		if plan.Result.Action == importCreate {
+			plan.Result.ID = nil
+		}
+		switch {
+		case plan == failed:
+			plan.Result = rejectRowAPI(plan.Result, failure).Result
+		case failed != nil:
+			plan.Result = rejectRow(plan.Result, fmt.Sprintf("Not written: row %d in the same batch failed", failed.Result.Row)).Result
+		default:
+			plan.Result = rejectRow(plan.Result, "Not written: "+err.Error()).Result
+		}
+	}
+}
+
+// CSV OPERATIONS
+
+func (h *Handler) importCSV(c *gin.Context, t csvTable) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run value"})
+		return
+	}
+	batchSize, err := strconv.Atoi(c.DefaultQuery("batch_size", strconv.Itoa(defaultCSVBatchSize)))
+	if err != nil || batchSize < 1 || batchSize > maxCSVBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("batch_size must be between 1 and %d", maxCSVBatchSize)})
+		return
+	}
+	if !dryRun && h.Tx == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Imports need transactions, which are not configured"})
+		return
+	}
+
+	reader := newCSVReader(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("file is empty")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV header", "details": err.Error()})
+		return
+	}
+	columns, err := csvHeader(t, header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV header", "details": err.Error()})
+		return
+	}
+
+	readOnly := map[string]bool{}
+	for _, column := range t.ReadOnly {
+		readOnly[column] = true
+	}
+
+	var plans []*csvPlan
+	seen := map[string]int{}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !(errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount)) {
+			// Broken quoting leaves no reliable row boundary, so the rest
+			// of the file is not read.
+			plan := rejectRow(ImportResultDTO{Row: row}, "Unreadable row, import stopped: "+err.Error())
+			plans = append(plans, &plan)
+			break
+		}
+		if err != nil {
+			plan := rejectRow(ImportResultDTO{Row: row}, fmt.Sprintf("Row has %d fields, the header has %d", len(record), len(columns)))
+			plans = append(plans, &plan)
+			continue
+		}
+
+		rec := make(csvRow, len(columns))
+		for i, column := range columns {
+			if !readOnly[column] {
+				rec[column] = strings.TrimSpace(record[i])
+			}
+		}
+		plan := t.Prepare(row, rec, seen)
+		plans = append(plans, &plan)
+	}
+
+	if !dryRun {
+		var batch []*csvPlan
+		for _, plan := range plans {
+			if plan.Apply == nil {
+				continue
+			}
+			if batch = append(batch, plan); len(batch) == batchSize {
+				h.applyCSVBatch(batch)
+				batch = nil
+			}
+		}
+		if len(batch) > 0 {
+			h.applyCSVBatch(batch)
+		}
+	}
+
+	report := ImportReportDTO{DryRun: dryRun, Results: []ImportResultDTO{}}
+	for _, plan := range plans {
+		report.add(plan.Result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func (h *Handler) exportCSV(c *gin.Context, t csvTable) {
+	c.Header("Content-Type", csvContentType+"; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, t.Name))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	count := 0
+	write := func(record []string) error {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		if count++; count%flushEvery == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		return w.Error()
+	}
+
+	err := write(t.Columns)
+	if err == nil {
+		err = t.Export(write)
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		// The status line has been sent; all that can be done is to cut
+		// the download short and leave a trace.
+		log.Printf("csv export %s: %v", t.Name, err)
+	}
+}
+
+// TABLES
+
+func (h *Handler) usersCSV() csvTable {
+	return csvTable{
+		Name:    "users",
+		Columns: []string{"id", "card_number", "name", "class"},
+		Prepare: func(row int, rec csvRow, seen map[string]int) csvPlan {
+			res := ImportResultDTO{Row: row, Label: rec["name"]}
+			id, update, err := rowID(rec)
+			if err != nil {
+				return rejectRow(res, err.Error())
+			}
+			req := CreateUserRequest{Name: rec["name"], Class: rec["class"], CardNumber: rec["card_number"]}
+			if messages := validateRow(req); len(messages) > 0 {
+				return rejectRow(res, messages...)
+			}
+			cardNumber := identifiers.Normalize(req.CardNumber)
+			if earlier, ok := seenBefore(seen, row, seenKey("id", rec["id"]), seenKey("name", req.Name), seenKey("card", cardNumber)); ok {
+				return rejectRow(res, fmt.Sprintf("Same user as row %d", earlier))
+			}
+
+			if !update {
+				if apiErr := h.checkNewUser(req); apiErr != nil {
+					return rejectRowAPI(res, apiErr)
+				}
+				res.Action = importCreate
+				return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+					user, apiErr := tx.createUser(req)
+					return user.ID, apiErr
+				}}
+			}
+
+			existing, err := h.UserStore.User(id)
+			if err != nil {
+				return rejectRow(res, lookupError("User", id, err))
+			}
+			changeCard := cardNumber != "" && (existing.CardNumber == nil || *existing.CardNumber != cardNumber)
+			if changeCard {
+				if _, apiErr := h.cardNumberFor(cardNumber); apiErr != nil {
+					return rejectRowAPI(res, apiErr)
+				}
+			}
+			res.ID = &id
+			res.Action = importUpdate
+			return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+				user := UpdateUserRequest{Name: req.Name, Class: req.Class}.toModel(id)
+				if err := tx.UserStore.UpdateUser(&user); err != nil {
+					return id, writeError(err, "User", "update")
+				}
+				if changeCard {
+					if err := tx.UserStore.SetCardNumber(id, cardNumber); err != nil {
+						return id, newAPIError(http.StatusInternalServerError, "Failed to update card number")
+					}
+				}
+				return id, nil
+			}}
+		},
+		Export: func(write func([]string) error) error {
+			users, err := h.UserStore.Users()
+			if err != nil {
+				return err
+			}
+			for _, u := range users {
+				if err := write([]string{u.ID.String(), derefString(u.CardNumber), u.Name, u.Class}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (h *Handler) booksCSV() csvTable {
+	return csvTable{
+		Name: "books",
+		Columns: []string{"id", "barcode", "isbn", "title", "author_name", "location_name", "book_type",
+			"publisher", "publication_year", "page_count", "cover_url", "is_checked_out"},
+		ReadOnly: []string{"is_checked_out"},
+		Prepare: func(row int, rec csvRow, seen map[string]int) csvPlan {
+			res := ImportResultDTO{Row: row, Label: rec["title"]}
+			id, update, err := rowID(rec)
+			if err != nil {
+				return rejectRow(res, err.Error())
+			}
+			year, yearErr := rec.number("publication_year")
+			pages, pagesErr := rec.number("page_count")
+			if err := errors.Join(yearErr, pagesErr); err != nil {
+				return rejectRow(res, strings.Split(err.Error(), "\n")...)
+			}
+			req := CreateBookRequest{
+				Title:           rec["title"],
+				AuthorName:      rec["author_name"],
+				LocationName:    rec["location_name"],
+				BookType:        rec["book_type"],
+				Barcode:         rec["barcode"],
+				ISBN:            rec["isbn"],
+				Publisher:       rec["publisher"],
+				PublicationYear: year,
+				PageCount:       pages,
+				CoverURL:        rec["cover_url"],
+			}
+			if messages := validateRow(req); len(messages) > 0 {
+				return rejectRow(res, messages...)
+			}
+
+			// Titles only identify books without an ISBN, as in createBook.
+			var isbnKey, titleKey string
+			if n, err := isbn.Parse(req.ISBN); err == nil {
+				isbnKey = seenKey("isbn", n.ISBN13)
+			} else if req.ISBN == "" && !update {
+				titleKey = seenKey("title", req.Title)
+			}
+			barcode := identifiers.Normalize(req.Barcode)
+			if earlier, ok := seenBefore(seen, row, seenKey("id", rec["id"]), isbnKey, seenKey("barcode", barcode), titleKey); ok {
+				return rejectRow(res, fmt.Sprintf("Same book as row %d", earlier))
+			}
+
+			if !update {
+				if _, apiErr := h.checkNewBook(req); apiErr != nil {
+					return rejectRowAPI(res, apiErr)
+				}
+				res.Action = importCreate
+				return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+					book, apiErr := tx.createBook(req)
+					return book.ID, apiErr
+				}}
+			}
+
+			existing, err := h.BookStore.Book(id)
+			if err != nil {
+				return rejectRow(res, lookupError("Book", id, err))
+			}
+			number, apiErr := h.bookISBN(req.ISBN, id)
+			if apiErr != nil {
+				return rejectRowAPI(res, apiErr)
+			}
+			changeBarcode := barcode != "" && (existing.Barcode == nil || *existing.Barcode != barcode)
+			if changeBarcode {
+				if _, apiErr := h.checkBarcode(barcode); apiErr != nil {
+					return rejectRowAPI(res, apiErr)
+				}
+			}
+			res.ID = &id
+			res.Action = importUpdate
+			return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+				return id, tx.updateBookFromCSV(id, req, number, changeBarcode)
+			}}
+		},
+		Export: func(write func([]string) error) error {
+			authors, err := h.AuthorStore.Authors()
+			if err != nil {
+				return err
+			}
+			locations, err := h.LocationStore.Locations()
+			if err != nil {
+				return err
+			}
+			authorNames := make(map[uuid.UUID]string, len(authors))
+			for _, a := range authors {
+				authorNames[a.ID] = a.Name
+			}
+			locationNames := make(map[uuid.UUID]string, len(locations))
+			for _, l := range locations {
+				locationNames[l.ID] = l.Name
+			}
+
+			return h.BookStore.EachBook(func(b model.Book) error {
+				return write([]string{
+					b.ID.String(),
+					derefString(b.Barcode),
+					derefString(b.ISBN13),
+					b.Title,
+					authorNames[b.AuthorID],
+					locationNames[b.LocationID],
+					b.BookType,
+					derefString(b.Publisher),
+					csvInt(b.PublicationYear),
+					csvInt(b.PageCount),
+					derefString(b.CoverURL),
+					strconv.FormatBool(b.IsCheckedOut),
+				})
+			})
+		},
+	}
+}
+
+// updateBookFromCSV replaces a book with a row's data, resolving author
+// and location names like createBook does. The loan status is kept.
+func (h *Handler) updateBookFromCSV(id uuid.UUID, req CreateBookRequest, number *isbn.ISBN, changeBarcode bool) *apiError {
+	if _, err := h.BookStore.Book(id); err != nil {
+		return newAPIError(http.StatusInternalServerError, lookupError("Book", id, err))
+	}
+	author, err := h.getOrCreateAuthor(req.AuthorName)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to create or find author")
+	}
+	location, err := h.getOrCreateLocation(req.LocationName)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to create or find location")
+	}
+
+	book := UpdateBookRequest{
+		Title:           req.Title,
+		AuthorID:        author.ID,
+		LocationID:      location.ID,
+		BookType:        req.BookType,
+		Publisher:       req.Publisher,
+		PublicationYear: req.PublicationYear,
+		PageCount:       req.PageCount,
+		CoverURL:        req.CoverURL,
+	}.toModel(id)
+	setISBN(&book, number)
+
+	if err := h.BookStore.UpdateBook(&book); err != nil {
+		return writeError(err, "Book", "update")
+	}
+	if changeBarcode {
+		if err := h.BookStore.SetBarcode(id, identifiers.Normalize(req.Barcode)); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update barcode")
+		}
+	}
+	return nil
+}
+
+func (h *Handler) subjectsCSV() csvTable {
+	return csvTable{
+		Name:     "subjects",
+		Columns:  []string{"id", "name", "language", "parent_name", "created_at"},
+		ReadOnly: []string{"created_at"},
+		Prepare: func(row int, rec csvRow, seen map[string]int) csvPlan {
+			res := ImportResultDTO{Row: row, Label: rec["name"]}
+			id, update, err := rowID(rec)
+			if err != nil {
+				return rejectRow(res, err.Error())
+			}
+			req := CreateSubjectRequest{Name: rec["name"], Language: rec["language"]}
+			if messages := validateRow(req); len(messages) > 0 {
+				return rejectRow(res, messages...)
+			}
+			if earlier, ok := seenBefore(seen, row, seenKey("id", rec["id"]), seenKey("name", req.Name)); ok {
+				return rejectRow(res, fmt.Sprintf("Same subject as row %d", earlier))
+			}
+
+			// The parent is looked up when the row is written, so it may
+			// come from an earlier row of the same file.
+			parent := func(tx *Handler) *apiError {
+				if rec["parent_name"] == "" {
+					return nil
+				}
+				subject, err := tx.SubjectStore.SubjectByName(rec["parent_name"])
+				if err != nil {
+					return newAPIError(http.StatusInternalServerError, "Failed to look up parent subject")
+				}
+				if subject.ID == uuid.Nil {
+					return newAPIError(http.StatusBadRequest, fmt.Sprintf("Parent subject %q not found", rec["parent_name"]))
+				}
+				req.ParentID = &subject.ID
+				return nil
+			}
+
+			if !update {
+				if apiErr := h.checkNewSubject(req); apiErr != nil {
+					return rejectRowAPI(res, apiErr)
+				}
+				res.Action = importCreate
+				return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+					if apiErr := parent(tx); apiErr != nil {
+						return uuid.Nil, apiErr
+					}
+					subject, apiErr := tx.createSubject(req)
+					return subject.ID, apiErr
+				}}
+			}
+
+			if _, err := h.SubjectStore.Subject(id); err != nil {
+				return rejectRow(res, lookupError("Subject", id, err))
+			}
+			res.ID = &id
+			if apiErr := h.checkSubjectName(id, req.Name); apiErr != nil {
+				return rejectRowAPI(res, apiErr)
+			}
+			res.Action = importUpdate
+			return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+				if apiErr := parent(tx); apiErr != nil {
+					return id, apiErr
+				}
+				if apiErr := tx.checkSubjectName(id, req.Name); apiErr != nil {
+					return id, apiErr
+				}
+				subject := UpdateSubjectRequest(req).toModel(id)
+				if err := tx.SubjectStore.UpdateSubject(&subject); err != nil {
+					return id, subjectWriteError(err, subject.Name, "update")
+				}
+				return id, nil
+			}}
+		},
+		Export: func(write func([]string) error) error {
+			subjects, err := h.SubjectStore.Subjects()
+			if err != nil {
+				return err
+			}
+			names := make(map[uuid.UUID]string, len(subjects))
+			for _, s := range subjects {
+				names[s.ID] = s.Name
+			}
+			for _, s := range subjects {
+				var parent string
+				if s.ParentID != nil {
+					parent = names[*s.ParentID]
+				}
+				if err := write([]string{s.ID.String(), s.Name, s.Language, parent, formatTime(s.CreatedAt)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (h *Handler) materialsCSV() csvTable {
+	return csvTable{
+		Name:     "materials",
+		Columns:  []string{"id", "title", "description", "notes", "type", "link", "language", "subject_name", "created_at"},
+		ReadOnly: []string{"created_at"},
+		Prepare: func(row int, rec csvRow, seen map[string]int) csvPlan {
+			res := ImportResultDTO{Row: row, Label: rec["title"]}
+			id, update, err := rowID(rec)
+			if err != nil {
+				return rejectRow(res, err.Error())
+			}
+			req := CreateMaterialRequest{
+				Title:       rec["title"],
+				Description: rec["description"],
+				Notes:       rec["notes"],
+				Type:        rec["type"],
+				Link:        rec["link"],
+				Language:    rec["language"],
+				SubjectName: rec["subject_name"],
+			}
+			if messages := validateRow(req); len(messages) > 0 {
+				return rejectRow(res, messages...)
+			}
+			if earlier, ok := seenBefore(seen, row, seenKey("id", rec["id"]), seenKey("title", req.Title)); ok {
+				return rejectRow(res, fmt.Sprintf("Same material as row %d", earlier))
+			}
+
+			if !update {
+				if apiErr := h.checkNewMaterial(req); apiErr != nil {
+					return rejectRowAPI(res, apiErr)
+				}
+				res.Action = importCreate
+				return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+					material, apiErr := tx.createMaterial(req)
+					return material.ID, apiErr
+				}}
+			}
+
+			if _, err := h.MaterialStore.Material(id); err != nil {
+				return rejectRow(res, lookupError("Material", id, err))
+			}
+			res.ID = &id
+			res.Action = importUpdate
+			return csvPlan{Result: res, Apply: func(tx *Handler) (uuid.UUID, *apiError) {
+				subject, apiErr := tx.materialSubject(req.SubjectName, req.Language)
+				if apiErr != nil {
+					return id, apiErr
+				}
+				material := UpdateMaterialRequest(req).toModel(id)
+				material.SubjectID = subject.ID
+				if err := tx.MaterialStore.UpdateMaterial(&material); err != nil {
+					return id, writeError(err, "Material", "update")
+				}
+				return id, nil
+			}}
+		},
+		Export: func(write func([]string) error) error {
+			materials, err := h.MaterialStore.Materials()
+			if err != nil {
+				return err
+			}
+			for _, m := range materials {
+				record := []string{m.ID.String(), m.Title, m.Description, m.Notes, m.Type, m.Link, m.Language, m.SubjectName, formatTime(m.CreatedAt)}
+				if err := write(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func csvInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// CSV HANDLERS
+
+func (h *Handler) ImportUsersCSV(c *gin.Context)     { h.importCSV(c, h.usersCSV()) }
+func (h *Handler) ExportUsersCSV(c *gin.Context)     { h.exportCSV(c, h.usersCSV()) }
+func (h *Handler) ImportBooksCSV(c *gin.Context)     { h.importCSV(c, h.booksCSV()) }
+func (h *Handler) ExportBooksCSV(c *gin.Context)     { h.exportCSV(c, h.booksCSV()) }
+func (h *Handler) ImportSubjectsCSV(c *gin.Context)  { h.importCSV(c, h.subjectsCSV()) }
+func (h *Handler) ExportSubjectsCSV(c *gin.Context)  { h.exportCSV(c, h.subjectsCSV()) }
+func (h *Handler) ImportMaterialsCSV(c *gin.Context) { h.importCSV(c, h.materialsCSV()) }
+func (h *Handler) ExportMaterialsCSV(c *gin.Context) { h.exportCSV(c, h.materialsCSV()) }
@@ -0,0 +1,287 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestImportUsersCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		body     string
+		noTx     bool
+		status   int
+		actions  []string
+		messages []string
+		users    []string
+	}{
+		{
+			name:    "dry run writes nothing",
+			query:   "?dry_run=true",
+			body:    "name,class\nBob,9\nAnn,10\n",
+			noTx:    true,
+			status:  http.StatusOK,
+			actions: []string{importCreate, importConflict},
+			users:   []string{"Ann/10"},
+		},
+		{
+			name:    "create and update",
+			body:    "id,name,class,card_number\n{user},Ann,11,\n,Bob,9,\n",
+			status:  http.StatusOK,
+			actions: []string{importUpdate, importCreate},
+			users:   []string{"Ann/11", "Bob/9"},
+		},
+		{
+			name:    "byte order mark and loose header",
+			body:    "\xef\xbb\xbf Name , CLASS\nBob,9\n",
+			status:  http.StatusOK,
+			actions: []string{importCreate},
+			users:   []string{"Ann/10", "Bob/9"},
+		},
+		{
+			name:     "row errors",
+			body:     "id,name,class,card_number\nnot-a-uuid,Bob,9,\n,Cat,,\n,Dan,9,20001000000013\n,Eve,9\n{missing},Fay,9,\n",
+			status:   http.StatusOK,
+			actions:  []string{importError, importError, importError, importError, importError},
+			messages: []string{`column id: "not-a-uuid" is not a valid UUID`, "", "Invalid card number", "Row has 3 fields, the header has 4", "not found"},
+			users:    []string{"Ann/10"},
+		},
+		{
+			name:     "card number taken",
+			body:     "name,class,card_number\nBob,9,20001000000012\n",
+			status:   http.StatusOK,
+			actions:  []string{importConflict},
+			messages: []string{"same card number"},
+			users:    []string{"Ann/10"},
+		},
+		{
+			name:     "repeated rows",
+			body:     "name,class\nBob,9\nBob,10\n",
+			status:   http.StatusOK,
+			actions:  []string{importCreate, importError},
+			messages: []string{"", "Same user as row 1"},
+			users:    []string{"Ann/10", "Bob/9"},
+		},
+		{
+			name:     "failed row rolls back its batch",
+			query:    "?batch_size=2",
+			body:     "name,class\nBob,9\nCarl,9\nDan,9\n",
+			status:   http.StatusOK,
+			actions:  []string{importError, importError, importCreate},
+			messages: []string{"Not written: row 2 in the same batch failed", "Failed to create user", ""},
+			users:    []string{"Ann/10", "Dan/9"},
+		},
+		{
+			name:     "broken quoting stops the import",
+			body:     "name,class\nBob,9\n\"Carl,9\nDan,9\n",
+			status:   http.StatusOK,
+			actions:  []string{importCreate, importError},
+			messages: []string{"", "Unreadable row, import stopped"},
+			users:    []string{"Ann/10", "Bob/9"},
+		},
+		{name: "unknown column", body: "name,grade\nBob,9\n", status: http.StatusBadRequest, users: []string{"Ann/10"}},
+		{name: "repeated column", body: "name,class,Name\nBob,9,Bob\n", status: http.StatusBadRequest, users: []string{"Ann/10"}},
+		{name: "empty file", body: "", status: http.StatusBadRequest, users: []string{"Ann/10"}},
+		{name: "bad batch size", query: "?batch_size=0", body: "name,class\n", status: http.StatusBadRequest, users: []string{"Ann/10"}},
+		{name: "bad dry run", query: "?dry_run=maybe", body: "name,class\n", status: http.StatusBadRequest, users: []string{"Ann/10"}},
+		{name: "no transactions", body: "name,class\nBob,9\n", noTx: true, status: http.StatusServiceUnavailable, users: []string{"Ann/10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			f.users.failOn = "Carl"
+			h := f.handler()
+			if !tt.noTx {
+				h.Tx = fakeTx{f}
+			}
+			body := strings.NewReplacer("{user}", f.userID.String(), "{missing}", uuid.NewString()).Replace(tt.body)
+			w := serve(newTestRouter(h), http.MethodPost, "/api/v1/users/csv"+tt.query, body, "Content-Type", csvContentType)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.status == http.StatusOK {
+				var resp struct {
+					Report ImportReportDTO `json:"report"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				var actions []string
+				for i, res := range resp.Report.Results {
+					actions = append(actions, res.Action)
+					if res.Row != i+1 {
+						t.Errorf("result %d is for row %d", i, res.Row)
+					}
+					if i < len(tt.messages) && !strings.Contains(strings.Join(res.Messages, "; "), tt.messages[i]) {
+						t.Errorf("row %d messages = %q, want %q", res.Row, res.Messages, tt.messages[i])
+					}
+					if (res.ID != nil) != (res.Action == importCreate && !resp.Report.DryRun || res.Action == importUpdate) {
+						t.Errorf("row %d: action %s with id %v", res.Row, res.Action, res.ID)
+					}
+				}
+				if !reflect.DeepEqual(actions, tt.actions) {
+					t.Errorf("actions = %v, want %v", actions, tt.actions)
+				}
+				if n := resp.Report.Created + resp.Report.Updated + resp.Report.Conflicts + resp.Report.Errors; n != len(tt.actions) {
+					t.Errorf("report counts %d rows, want %d", n, len(tt.actions))
+				}
+			}
+
+			var users []string
+			for _, u := range f.users.users {
+				users = append(users, u.Name+"/"+u.Class)
+			}
+			if !sameElements(users, tt.users) {
+				t.Errorf("users = %v, want %v", users, tt.users)
+			}
+		})
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	f := newFixture()
+	childID := uuid.New()
+	f.subjects.subjects[childID] = model.Subject{ID: childID, ParentID: &f.subjectID, Name: "Algebra", Language: "en", CreatedAt: f.subjects.subjects[f.subjectID].CreatedAt}
+	r := newTestRouter(f.handler())
+
+	tests := []struct {
+		path string
+		file string
+		rows [][]string
+	}{
+		{
+			path: "/api/v1/users/csv",
+			file: "users.csv",
+			rows: [][]string{
+				{"id", "card_number", "name", "class"},
+				{f.userID.String(), "20001000000012", "Ann", "10"},
+			},
+		},
+		{
+			path: "/api/v1/subjects/csv",
+			file: "subjects.csv",
+			rows: [][]string{
+				{"id", "name", "language", "parent_name", "created_at"},
+				{f.subjectID.String(), "Mathematics", "en", "", "2024-01-02T03:04:05Z"},
+				{childID.String(), "Algebra", "en", "Mathematics", "2024-01-02T03:04:05Z"},
+			},
+		},
+		{
+			path: "/api/v1/materials/csv",
+			file: "materials.csv",
+			rows: [][]string{
+				{"id", "title", "description", "notes", "type", "link", "language", "subject_name", "created_at"},
+				{f.materialID.String(), "Algebra notes", "", "", "pdf", "https://example.com/algebra.pdf", "en", "Mathematics", "2024-01-02T03:04:05Z"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			w := serve(r, http.MethodGet, tt.path, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, csvContentType) {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="`+tt.file+`"`) {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+			rows, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.rows) || !reflect.DeepEqual(rows[0], tt.rows[0]) {
+				t.Fatalf("rows = %q, want %q", rows, tt.rows)
+			}
+			for _, want := range tt.rows[1:] {
+				if !containsRow(rows[1:], want) {
+					t.Errorf("rows = %q, lack %q", rows, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	table := csvTable{Columns: []string{"id", "name", "class"}}
+	tests := []struct {
+		header  []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"name", "class"}, []string{"name", "class"}, false},
+		{[]string{" Class ", "ID"}, []string{"class", "id"}, false},
+		{[]string{"name", "grade"}, nil, true},
+		{[]string{"name", "NAME"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.header, ","), func(t *testing.T) {
+			got, err := csvHeader(table, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("csvHeader error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("csvHeader = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeenBefore(t *testing.T) {
+	seen := map[string]int{}
+	tests := []struct {
+		row     int
+		keys    []string
+		earlier int
+		found   bool
+	}{
+		{1, []string{seenKey("name", "Ann"), seenKey("card", "")}, 0, false},
+		{2, []string{seenKey("name", "Bob"), seenKey("card", "")}, 0, false},
+		{3, []string{seenKey("name", "Cat"), seenKey("id", "x")}, 0, false},
+		{4, []string{seenKey("name", "Dan"), seenKey("id", "x")}, 3, true},
+		{5, []string{seenKey("name", "Bob")}, 2, true},
+		{6, []string{seenKey("name", "Dan")}, 0, false},
+	}
+	for _, tt := range tests {
+		earlier, found := seenBefore(seen, tt.row, tt.keys...)
+		if earlier != tt.earlier || found != tt.found {
+			t.Errorf("row %d: seenBefore = %d, %v, want %d, %v", tt.row, earlier, found, tt.earlier, tt.found)
+		}
+	}
+}
+
+func containsRow(rows [][]string, row []string) bool {
+	for _, r := range rows {
+		if reflect.DeepEqual(r, row) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameElements compares two lists ignoring order.
+func sameElements(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, s := range got {
+		counts[s]++
+	}
+	for _, s := range want {
+		if counts[s]--; counts[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,897 @@
+package web
+
+import (
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/labels"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// API representations. Handlers never serialize model types directly, so the
+// database schema can change without breaking clients. All JSON uses
+// snake_case and timestamps are RFC 3339 strings in UTC.
+
+type BookDTO struct {
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	AuthorID     uuid.UUID `json:"author_id"`
+	LocationID   uuid.UUID `json:"location_id"`
+	IsCheckedOut bool      `json:"is_checked_out"`
+	BookType     string    `json:"book_type"`
+	Barcode      string    `json:"barcode,omitempty"`
+	ISBN10       string    `json:"isbn10,omitempty"`
+	ISBN13       string    `json:"isbn13,omitempty"`
+
+	Publisher       string `json:"publisher,omitempty"`
+	PublicationYear int    `json:"publication_year,omitempty"`
+	PageCount       int    `json:"page_count,omitempty"`
+	CoverURL        string `json:"cover_url,omitempty"`
+
+	// Contributors is included when a single book is returned.
+	Contributors []ContributorDTO `json:"contributors,omitempty"`
+
+	CreatedAt string  `json:"created_at" format:"date-time"`
+	Version   int     `json:"version"`
+	DeletedAt *string `json:"deleted_at,omitempty" format:"date-time"`
+}
+
+type ContributorDTO struct {
+	AuthorID uuid.UUID `json:"author_id"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	Position int       `json:"position"`
+}
+
+// ContributionDTO is a book an author contributes to, in one role.
+type ContributionDTO struct {
+	Role     string  `json:"role"`
+	Position int     `json:"position"`
+	Book     BookDTO `json:"book"`
+}
+
+type AuthorDTO struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	DeletedAt *string   `json:"deleted_at,omitempty" format:"date-time"`
+}
+
+// DuplicateGroupDTO is a set of authors or locations whose names are alike
+// enough to be the same. Members are ordered by book count, so the first
+// is the suggested survivor of a merge; Score is the lowest similarity
+// that links the group.
+type DuplicateGroupDTO struct {
+	Score   float64              `json:"score"`
+	Members []DuplicateMemberDTO `json:"members"`
+}
+
+type DuplicateMemberDTO struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Books int       `json:"books"`
+}
+
+type AuthorMergeDTO struct {
+	ID         uuid.UUID `json:"id"`
+	SurvivorID uuid.UUID `json:"survivor_id"`
+	MergedID   uuid.UUID `json:"merged_id"`
+	MergedName string    `json:"merged_name"`
+	BooksMoved int       `json:"books_moved"`
+	MergedAt   string    `json:"merged_at" format:"date-time"`
+}
+
+type LocationDTO struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	DeletedAt *string   `json:"deleted_at,omitempty" format:"date-time"`
+}
+
+type UserDTO struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Class      string    `json:"class"`
+	CardNumber string    `json:"card_number,omitempty"`
+	Version    int       `json:"version"`
+	DeletedAt  *string   `json:"deleted_at,omitempty" format:"date-time"`
+}
+
+type IssuedBookDTO struct {
+	ID         uuid.UUID `json:"id"`
+	BookID     uuid.UUID `json:"book_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	IssueDate  string    `json:"issue_date" format:"date-time"`
+	ReturnDate *string   `json:"return_date,omitempty" format:"date-time"`
+	LateFees   float64   `json:"late_fees"`
+	Version    int       `json:"version"`
+}
+
+type SubjectDTO struct {
+	ID        uuid.UUID  `json:"id"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	Name      string     `json:"name"`
+	Language  string     `json:"language"`
+	CreatedAt string     `json:"created_at" format:"date-time"`
+	Version   int        `json:"version"`
+	DeletedAt *string    `json:"deleted_at,omitempty" format:"date-time"`
+}
+
+// SubjectNodeDTO is a subject in the hierarchy with counts over its
+// subtree: Books and Materials count what is filed under the subject or
+// any subject below it.
+type SubjectNodeDTO struct {
+	ID          uuid.UUID  `json:"id"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name"`
+	Language    string     `json:"language"`
+	Children    int        `json:"children"`
+	Descendants int        `json:"descendants"`
+	Books       int        `json:"books"`
+	Materials   int        `json:"materials"`
+}
+
+// SubjectBrowseDTO is a subject as browsed: the subjects above it, top-level
+// first, the subjects directly below it, and what is filed in its subtree.
+type SubjectBrowseDTO struct {
+	Subject   SubjectNodeDTO   `json:"subject"`
+	Path      []SubjectDTO     `json:"path"`
+	Children  []SubjectNodeDTO `json:"children"`
+	Books     []BookDTO        `json:"books"`
+	Materials []MaterialDTO    `json:"materials"`
+}
+
+type MaterialFileDTO struct {
+	ID          uuid.UUID `json:"id"`
+	MaterialID  uuid.UUID `json:"material_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   string    `json:"created_at" format:"date-time"`
+}
+
+// MaterialRevisionDTO is a material's content after one change to it.
+type MaterialRevisionDTO struct {
+	MaterialID  uuid.UUID `json:"material_id"`
+	Revision    int       `json:"revision"`
+	Version     int       `json:"version"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Notes       string    `json:"notes"`
+	Type        string    `json:"type"`
+	Link        string    `json:"link"`
+	Language    string    `json:"language"`
+	SubjectID   uuid.UUID `json:"subject_id"`
+	Author      string    `json:"author"`
+	Summary     string    `json:"summary"`
+	CreatedAt   string    `json:"created_at" format:"date-time"`
+}
+
+// FieldChangeDTO is a field with different values in two revisions.
+type FieldChangeDTO struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// RevisionDiffDTO lists what changed from one revision of a material to
+// another.
+type RevisionDiffDTO struct {
+	MaterialID uuid.UUID        `json:"material_id"`
+	From       int              `json:"from"`
+	To         int              `json:"to"`
+	Changes    []FieldChangeDTO `json:"changes"`
+}
+
+// LinkCheckDTO is the outcome of the last check of a material's link.
+// CheckedAt is null while the link is unchecked.
+type LinkCheckDTO struct {
+	MaterialID uuid.UUID `json:"material_id"`
+	URL        string    `json:"url"`
+	Status     string    `json:"status"`
+	StatusCode int       `json:"status_code,omitempty"`
+	FinalURL   string    `json:"final_url,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  *string   `json:"checked_at" format:"date-time"`
+}
+
+// TermDTO is a term of a vocabulary. Label is its label in the requested
+// locale; Labels has every locale's.
+type TermDTO struct {
+	ID         uuid.UUID         `json:"id"`
+	Vocabulary string            `json:"vocabulary"`
+	Code       string            `json:"code"`
+	Label      string            `json:"label"`
+	Labels     map[string]string `json:"labels"`
+	Active     bool              `json:"active"`
+	CreatedAt  string            `json:"created_at" format:"date-time"`
+}
+
+type MaterialDTO struct {
+	ID          uuid.UUID `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Notes       string    `json:"notes"`
+	Type        string    `json:"type"`
+	Link        string    `json:"link"`
+	Language    string    `json:"language"`
+	SubjectID   uuid.UUID `json:"subject_id"`
+	SubjectName string    `json:"subject_name"`
+	CreatedAt   string    `json:"created_at" format:"date-time"`
+	Version     int       `json:"version"`
+	DeletedAt   *string   `json:"deleted_at,omitempty" format:"date-time"`
+}
+
+// BlockerDTO is a record that stops another from being deleted: a loan,
+// a balance, or a book or material that depends on it.
+type BlockerDTO struct {
+	Kind        string `json:"kind"`
+	ID          string `json:"id,omitempty"`
+	Description string `json:"description"`
+}
+
+// TrashDTO lists deleted records that can still be restored or purged.
+type TrashDTO struct {
+	Books     []BookDTO     `json:"books"`
+	Authors   []AuthorDTO   `json:"authors"`
+	Locations []LocationDTO `json:"locations"`
+	Users     []UserDTO     `json:"users"`
+	Subjects  []SubjectDTO  `json:"subjects"`
+	Materials []MaterialDTO `json:"materials"`
+}
+
+// AuditEntryDTO is one recorded write. Before and After are JSON objects
+// of the fields that changed, or null where the record did not exist.
+type AuditEntryDTO struct {
+	ID         uuid.UUID `json:"id"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   uuid.UUID `json:"entity_id"`
+	Before     any       `json:"before"`
+	After      any       `json:"after"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  string    `json:"created_at" format:"date-time"`
+}
+
+type LoanDTO struct {
+	ID        uuid.UUID `json:"id"`
+	BookID    uuid.UUID `json:"book_id"`
+	Title     string    `json:"title"`
+	IssueDate string    `json:"issue_date" format:"date-time"`
+	DueDate   string    `json:"due_date" format:"date-time"`
+	Overdue   bool      `json:"overdue"`
+	LateFees  float64   `json:"late_fees"`
+}
+
+type ScanDTO struct {
+	Item     string     `json:"item"`
+	Action   string     `json:"action"`
+	OK       bool       `json:"ok"`
+	Message  string     `json:"message"`
+	BookID   *uuid.UUID `json:"book_id,omitempty"`
+	Title    string     `json:"title,omitempty"`
+	LateFees float64    `json:"late_fees,omitempty"`
+	At       string     `json:"at" format:"date-time"`
+}
+
+type CirculationSessionDTO struct {
+	ID        uuid.UUID `json:"id"`
+	Patron    UserDTO   `json:"patron"`
+	Loans     []LoanDTO `json:"loans"`
+	Fees      float64   `json:"fees"`
+	Blocks    []string  `json:"blocks"`
+	Scans     []ScanDTO `json:"scans"`
+	StartedAt string    `json:"started_at" format:"date-time"`
+}
+
+type LabelLayoutDTO struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"label_width_mm"`
+	LabelHeight float64 `json:"label_height_mm"`
+}
+
+// ImportReportDTO summarizes a bulk import. In a dry run the actions are
+// the ones that would be taken; nothing is written.
+type ImportReportDTO struct {
+	DryRun    bool              `json:"dry_run"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Conflicts int               `json:"conflicts"`
+	Errors    int               `json:"errors"`
+	Results   []ImportResultDTO `json:"results"`
+}
+
+// ImportResultDTO is the outcome for one record or row, numbered from 1.
+type ImportResultDTO struct {
+	Row      int        `json:"row"`
+	Label    string     `json:"label,omitempty"`
+	Action   string     `json:"action"`
+	ID       *uuid.UUID `json:"id,omitempty"`
+	Messages []string   `json:"messages,omitempty"`
+}
+
+// FieldErrorDTO is one field of a request body that breaks a rule, named
+// by its path in the body, like contributors[1].name.
+type FieldErrorDTO struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Request bodies
+
+// Requests the web UI submits as forms carry form tags as well, so both go
+// through the same binding and validation.
+
+// IssueBookRequest and ReturnBookRequest accept a UUID or the human
+// identifier: the item barcode for book_id, the card number for user_id.
+type IssueBookRequest struct {
+	BookID string `json:"book_id" form:"book_id" binding:"required"`
+	UserID string `json:"user_id" form:"user_id" binding:"required"`
+}
+
+type ReturnBookRequest struct {
+	BookID string `json:"book_id" form:"book_id" binding:"required"`
+	UserID string `json:"user_id" form:"user_id" binding:"required"`
+}
+
+type StartCirculationRequest struct {
+	Patron string `json:"patron" form:"patron" binding:"required"`
+}
+
+type CirculationScanRequest struct {
+	Item   string `json:"item" form:"item" binding:"required"`
+	Action string `json:"action" form:"action" binding:"omitempty,oneof=issue return"`
+}
+
+// LabelsRequest selects the books or patrons to print and the sheet to
+// print on. IDs are UUIDs, barcodes or card numbers; several may be given in
+// one entry separated by commas or whitespace, as the web form sends them.
+type LabelsRequest struct {
+	IDs       []string `json:"ids" form:"ids" binding:"required,min=1"`
+	Layout    string   `json:"layout" form:"layout"`
+	Format    string   `json:"format" form:"format" binding:"omitempty,oneof=pdf svg"`
+	Symbology string   `json:"symbology" form:"symbology" binding:"omitempty,oneof=code128 codabar"`
+	Skip      int      `json:"skip" form:"skip" binding:"min=0"`
+	Outline   bool     `json:"outline" form:"outline"`
+}
+
+type CreateBookRequest struct {
+	Title        string `json:"title" form:"title" binding:"required,max=500"`
+	AuthorName   string `json:"author_name" form:"author_name" binding:"required_without=Contributors,max=200"`
+	LocationName string `json:"location_name" form:"location_name" binding:"required,max=200"`
+	BookType     string `json:"book_type" form:"book_type" binding:"required"`
+	Barcode      string `json:"barcode" form:"barcode" binding:"max=64"`
+	ISBN         string `json:"isbn" form:"isbn" binding:"max=20"`
+
+	Publisher       string `json:"publisher" form:"publisher" binding:"max=200"`
+	PublicationYear int    `json:"publication_year" form:"publication_year" binding:"omitempty,min=1000,max=9999"`
+	PageCount       int    `json:"page_count" form:"page_count" binding:"omitempty,min=1"`
+	CoverURL        string `json:"cover_url" form:"cover_url" binding:"omitempty,http_url,max=2048"`
+
+	// Contributors lists the authors, editors, translators and illustrators
+	// in order, in place of AuthorName.
+	Contributors []ContributorRequest `json:"contributors" form:"-" binding:"omitempty,dive"`
+}
+
+// ContributorRequest names a contributor; authors are created as needed.
+// The role defaults to author.
+type ContributorRequest struct {
+	Name string `json:"name" binding:"required,max=200"`
+	Role string `json:"role" binding:"omitempty,oneof=author editor translator illustrator"`
+}
+
+type SetContributorsRequest struct {
+	Contributors []ContributorRequest `json:"contributors" binding:"required,min=1,dive"`
+}
+
+// ImportBookRequest catalogues a book from the metadata provider's record
+// for its ISBN. Only the local details have to be given.
+type ImportBookRequest struct {
+	ISBN         string `json:"isbn" form:"isbn" binding:"required,max=20"`
+	LocationName string `json:"location_name" form:"location_name" binding:"required,max=200"`
+	BookType     string `json:"book_type" form:"book_type" binding:"required"`
+	Barcode      string `json:"barcode" form:"barcode" binding:"max=64"`
+
+	// SubjectLanguage is recorded on subjects that do not exist yet.
+	SubjectLanguage string `json:"subject_language" form:"subject_language"`
+}
+
+// ISBN accepts either form; both are stored.
+type UpdateBookRequest struct {
+	Title      string    `json:"title" binding:"required,max=500"`
+	AuthorID   uuid.UUID `json:"author_id" binding:"required_without=Contributors"`
+	LocationID uuid.UUID `json:"location_id" binding:"required"`
+	BookType   string    `json:"book_type" binding:"required"`
+	ISBN       string    `json:"isbn" binding:"max=20"`
+
+	Publisher       string `json:"publisher" binding:"max=200"`
+	PublicationYear int    `json:"publication_year" binding:"omitempty,min=1000,max=9999"`
+	PageCount       int    `json:"page_count" binding:"omitempty,min=1"`
+	CoverURL        string `json:"cover_url" binding:"omitempty,http_url,max=2048"`
+
+	// Contributors, if given, replace the book's contributors and decide
+	// its author_id.
+	Contributors []ContributorRequest `json:"contributors" binding:"omitempty,dive"`
+}
+
+// CreateSubjectRequest creates a subject below ParentID, or at the top of
+// the hierarchy without one.
+type CreateSubjectRequest struct {
+	Name     string     `json:"name" binding:"required,max=200"`
+	Language string     `json:"language" binding:"required"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+type UpdateSubjectRequest struct {
+	Name     string     `json:"name" binding:"required,max=200"`
+	Language string     `json:"language" binding:"required"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// SetSubjectsRequest lists every subject a book or material is filed
+// under.
+type SetSubjectsRequest struct {
+	SubjectIDs []uuid.UUID `json:"subject_ids" binding:"max=100"`
+}
+
+type CreateMaterialRequest struct {
+	Title       string `json:"title" binding:"required,max=500"`
+	Description string `json:"description" binding:"max=5000"`
+	Notes       string `json:"notes" binding:"max=5000"`
+	Type        string `json:"type" binding:"required"`
+	Link        string `json:"link" binding:"omitempty,http_url,max=2048"`
+	Language    string `json:"language" binding:"required"`
+	SubjectName string `json:"subject_name" binding:"required,max=200"`
+}
+
+type UpdateMaterialRequest struct {
+	Title       string `json:"title" binding:"required,max=500"`
+	Description string `json:"description" binding:"max=5000"`
+	Notes       string `json:"notes" binding:"max=5000"`
+	Type        string `json:"type" binding:"required"`
+	Link        string `json:"link" binding:"omitempty,http_url,max=2048"`
+	Language    string `json:"language" binding:"required"`
+	SubjectName string `json:"subject_name" binding:"required,max=200"`
+}
+
+type CreateUserRequest struct {
+	Name       string `json:"name" form:"name" binding:"required,max=200"`
+	Class      string `json:"class" form:"class" binding:"required"`
+	CardNumber string `json:"card_number" form:"card_number" binding:"max=64"`
+}
+
+type UpdateUserRequest struct {
+	Name  string `json:"name" binding:"required,max=200"`
+	Class string `json:"class" binding:"required"`
+}
+
+// MergeAuthorsRequest lists the duplicates to fold into the author named
+// in the path.
+type MergeAuthorsRequest struct {
+	MergeIDs []uuid.UUID `json:"merge_ids" binding:"required,min=1"`
+}
+
+// CreateTermRequest adds a term to a vocabulary. Terms are active unless
+// active is false.
+type CreateTermRequest struct {
+	Code   string            `json:"code" binding:"required,max=100"`
+	Active *bool             `json:"active"`
+	Labels map[string]string `json:"labels" binding:"omitempty,dive,keys,required,max=35,endkeys,required,max=200"`
+}
+
+// UpdateTermRequest replaces a term's labels and sets whether it is active.
+type UpdateTermRequest struct {
+	Active bool              `json:"active"`
+	Labels map[string]string `json:"labels" binding:"omitempty,dive,keys,required,max=35,endkeys,required,max=200"`
+}
+
+type CreateLocationRequest struct {
+	Name string `json:"name" form:"name" binding:"required,max=200"`
+}
+
+type UpdateLocationRequest struct {
+	Name string `json:"name" binding:"required,max=200"`
+}
+
+type CreateAuthorRequest struct {
+	Name string `json:"name" binding:"required,max=200"`
+}
+
+type UpdateAuthorRequest struct {
+	Name string `json:"name" binding:"required,max=200"`
+}
+
+// MAPPING BETWEEN MODEL AND DTO
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatTimePtr(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := formatTime(*t)
+	return &s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
+
+// optionalString and optionalInt map the zero value of an optional request
+// field to NULL.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optionalInt(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+// mapSlice converts a slice of model values to DTOs. It never returns nil so
+// empty collections encode as [] rather than null.
+func mapSlice[T, U any](in []T, f func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+func newBookDTO(b model.Book) BookDTO {
+	return BookDTO{
+		ID:           b.ID,
+		Title:        b.Title,
+		AuthorID:     b.AuthorID,
+		LocationID:   b.LocationID,
+		IsCheckedOut: b.IsCheckedOut,
+		BookType:     b.BookType,
+		Barcode:      derefString(b.Barcode),
+		ISBN10:       derefString(b.ISBN10),
+		ISBN13:       derefString(b.ISBN13),
+
+		Publisher:       derefString(b.Publisher),
+		PublicationYear: derefInt(b.PublicationYear),
+		PageCount:       derefInt(b.PageCount),
+		CoverURL:        derefString(b.CoverURL),
+
+		Contributors: mapSlice(b.Contributors, newContributorDTO),
+
+		CreatedAt: formatTime(b.CreatedAt),
+		Version:   b.Version,
+		DeletedAt: formatTimePtr(b.DeletedAt),
+	}
+}
+
+func newContributorDTO(c model.Contributor) ContributorDTO {
+	return ContributorDTO{AuthorID: c.AuthorID, Name: c.Name, Role: c.Role, Position: c.Position}
+}
+
+func newAuthorDTO(a model.Author) AuthorDTO {
+	return AuthorDTO{ID: a.ID, Name: a.Name, Version: a.Version, DeletedAt: formatTimePtr(a.DeletedAt)}
+}
+
+func newAuthorMergeDTO(m model.AuthorMerge) AuthorMergeDTO {
+	return AuthorMergeDTO{
+		ID:         m.ID,
+		SurvivorID: m.SurvivorID,
+		MergedID:   m.MergedID,
+		MergedName: m.MergedName,
+		BooksMoved: m.BooksMoved,
+		MergedAt:   formatTime(m.MergedAt),
+	}
+}
+
+func newLocationDTO(l model.Location) LocationDTO {
+	return LocationDTO{ID: l.ID, Name: l.Name, Version: l.Version, DeletedAt: formatTimePtr(l.DeletedAt)}
+}
+
+func newUserDTO(u model.User) UserDTO {
+	return UserDTO{ID: u.ID, Name: u.Name, Class: u.Class, CardNumber: derefString(u.CardNumber), Version: u.Version, DeletedAt: formatTimePtr(u.DeletedAt)}
+}
+
+func newIssuedBookDTO(ib model.IssuedBook) IssuedBookDTO {
+	return IssuedBookDTO{
+		ID:         ib.ID,
+		BookID:     ib.BookID,
+		UserID:     ib.UserID,
+		IssueDate:  formatTime(ib.IssueDate),
+		ReturnDate: formatTimePtr(ib.ReturnDate),
+		LateFees:   ib.LateFees,
+		Version:    ib.Version,
+	}
+}
+
+func newLoanDTO(ib model.IssuedBook) LoanDTO {
+	due := ib.IssueDate.AddDate(0, 0, loanPeriodDays)
+	return LoanDTO{
+		ID:        ib.ID,
+		BookID:    ib.BookID,
+		IssueDate: formatTime(ib.IssueDate),
+		DueDate:   formatTime(due),
+		Overdue:   ib.ReturnDate == nil && time.Now().After(due),
+		LateFees:  ib.LateFees,
+	}
+}
+
+func newAuditEntryDTO(e model.AuditEntry) AuditEntryDTO {
+	return AuditEntryDTO{
+		ID:         e.ID,
+		Actor:      e.Actor,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Before:     e.Before,
+		After:      e.After,
+		RequestID:  e.RequestID,
+		Reason:     e.Reason,
+		CreatedAt:  formatTime(e.CreatedAt),
+	}
+}
+
+func newLabelLayoutDTO(l labels.Layout) LabelLayoutDTO {
+	return LabelLayoutDTO{
+		Name:        l.Name,
+		Description: l.Description,
+		Columns:     l.Columns,
+		Rows:        l.Rows,
+		LabelWidth:  l.LabelWidth,
+		LabelHeight: l.LabelHeight,
+	}
+}
+
+func newSubjectDTO(s model.Subject) SubjectDTO {
+	return SubjectDTO{
+		ID:        s.ID,
+		ParentID:  s.ParentID,
+		Name:      s.Name,
+		Language:  s.Language,
+		CreatedAt: formatTime(s.CreatedAt),
+		Version:   s.Version,
+		DeletedAt: formatTimePtr(s.DeletedAt),
+	}
+}
+
+func newMaterialFileDTO(f model.MaterialFile) MaterialFileDTO {
+	return MaterialFileDTO{
+		ID:          f.ID,
+		MaterialID:  f.MaterialID,
+		Filename:    f.Filename,
+		ContentType: f.ContentType,
+		Size:        f.Size,
+		SHA256:      f.SHA256,
+		CreatedAt:   formatTime(f.CreatedAt),
+	}
+}
+
+func newMaterialRevisionDTO(r model.MaterialRevision) MaterialRevisionDTO {
+	return MaterialRevisionDTO{
+		MaterialID:  r.MaterialID,
+		Revision:    r.Revision,
+		Version:     r.Version,
+		Title:       r.Title,
+		Description: r.Description,
+		Notes:       r.Notes,
+		Type:        r.Type,
+		Link:        r.Link,
+		Language:    r.Language,
+		SubjectID:   r.SubjectID,
+		Author:      r.Author,
+		Summary:     r.Summary,
+		CreatedAt:   formatTime(r.CreatedAt),
+	}
+}
+
+func newFieldChangeDTO(c model.FieldChange) FieldChangeDTO {
+	return FieldChangeDTO{Field: c.Field, From: c.From, To: c.To}
+}
+
+func newLinkCheckDTO(lc model.LinkCheck) LinkCheckDTO {
+	dto := LinkCheckDTO{
+		MaterialID: lc.MaterialID,
+		URL:        lc.URL,
+		Status:     lc.Status,
+		StatusCode: lc.StatusCode,
+		FinalURL:   lc.FinalURL,
+		Error:      lc.Error,
+	}
+	if !lc.CheckedAt.IsZero() {
+		dto.CheckedAt = formatTimePtr(&lc.CheckedAt)
+	}
+	return dto
+}
+
+func newSubjectNodeDTO(n model.SubjectNode) SubjectNodeDTO {
+	return SubjectNodeDTO{
+		ID:          n.ID,
+		ParentID:    n.ParentID,
+		Name:        n.Name,
+		Language:    n.Language,
+		Children:    n.Children,
+		Descendants: n.Descendants,
+		Books:       n.Books,
+		Materials:   n.Materials,
+	}
+}
+
+func newTermDTO(t model.Term, locale string) TermDTO {
+	labels := t.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return TermDTO{
+		ID:         t.ID,
+		Vocabulary: t.Vocabulary,
+		Code:       t.Code,
+		Label:      termLabel(t, locale),
+		Labels:     labels,
+		Active:     t.Active,
+		CreatedAt:  formatTime(t.CreatedAt),
+	}
+}
+
+func newMaterialDTO(m model.Material) MaterialDTO {
+	return MaterialDTO{
+		ID:          m.ID,
+		Title:       m.Title,
+		Description: m.Description,
+		Notes:       m.Notes,
+		Type:        m.Type,
+		Link:        m.Link,
+		Language:    m.Language,
+		SubjectID:   m.SubjectID,
+		SubjectName: m.SubjectName,
+		CreatedAt:   formatTime(m.CreatedAt),
+		Version:     m.Version,
+		DeletedAt:   formatTimePtr(m.DeletedAt),
+	}
+}
+
+func (r UpdateBookRequest) toModel(id uuid.UUID) model.Book {
+	return model.Book{
+		ID:         id,
+		Title:      r.Title,
+		AuthorID:   r.AuthorID,
+		LocationID: r.LocationID,
+		BookType:   r.BookType,
+
+		Publisher:       optionalString(r.Publisher),
+		PublicationYear: optionalInt(r.PublicationYear),
+		PageCount:       optionalInt(r.PageCount),
+		CoverURL:        optionalString(r.CoverURL),
+	}
+}
+
+func (r UpdateUserRequest) toModel(id uuid.UUID) model.User {
+	return model.User{ID: id, Name: r.Name, Class: r.Class}
+}
+
+func (r UpdateLocationRequest) toModel(id uuid.UUID) model.Location {
+	return model.Location{ID: id, Name: r.Name}
+}
+
+func (r UpdateAuthorRequest) toModel(id uuid.UUID) model.Author {
+	return model.Author{ID: id, Name: r.Name}
+}
+
+func (r UpdateSubjectRequest) toModel(id uuid.UUID) model.Subject {
+	return model.Subject{ID: id, ParentID: r.ParentID, Name: r.Name, Language: r.Language}
+}
+
+func (r UpdateMaterialRequest) toModel(id uuid.UUID) model.Material {
+	return model.Material{
+		ID:          id,
+		Title:       r.Title,
+		Description: r.Description,
+		Notes:       r.Notes,
+		Type:        r.Type,
+		Link:        r.Link,
+		Language:    r.Language,
+		SubjectName: r.SubjectName,
+	}
+}
+
+// newUpdateBookRequest returns the update request that would leave the book
+// and its contributors as they are; PATCH applies its patches to it.
+func newUpdateBookRequest(b model.Book, contributors []model.Contributor) UpdateBookRequest {
+	req := UpdateBookRequest{
+		Title:      b.Title,
+		AuthorID:   b.AuthorID,
+		LocationID: b.LocationID,
+		BookType:   b.BookType,
+		ISBN:       derefString(b.ISBN13),
+
+		Publisher:       derefString(b.Publisher),
+		PublicationYear: derefInt(b.PublicationYear),
+		PageCount:       derefInt(b.PageCount),
+		CoverURL:        derefString(b.CoverURL),
+	}
+	for _, c := range contributors {
+		req.Contributors = append(req.Contributors, ContributorRequest{Name: c.Name, Role: c.Role})
+	}
+	return req
+}
+
+func newUpdateMaterialRequest(m model.Material) UpdateMaterialRequest {
+	return UpdateMaterialRequest{
+		Title:       m.Title,
+		Description: m.Description,
+		Notes:       m.Notes,
+		Type:        m.Type,
+		Link:        m.Link,
+		Language:    m.Language,
+		SubjectName: m.SubjectName,
+	}
+}
+
+// termParams give the codes of a request's vocabulary fields, to check
+// along with its binding rules.
+
+func (r CreateBookRequest) termParams() []termParam {
+	return []termParam{{"book_type", model.VocabularyBookType, r.BookType}}
+}
+
+func (r UpdateBookRequest) termParams() []termParam {
+	return []termParam{{"book_type", model.VocabularyBookType, r.BookType}}
+}
+
+func (r ImportBookRequest) termParams() []termParam {
+	return []termParam{
+		{"book_type", model.VocabularyBookType, r.BookType},
+		{"subject_language", model.VocabularyLanguage, r.SubjectLanguage},
+	}
+}
+
+func (r CreateSubjectRequest) termParams() []termParam {
+	return []termParam{{"language", model.VocabularyLanguage, r.Language}}
+}
+
+func (r UpdateSubjectRequest) termParams() []termParam {
+	return []termParam{{"language", model.VocabularyLanguage, r.Language}}
+}
+
+func (r CreateMaterialRequest) termParams() []termParam {
+	return []termParam{
+		{"type", model.VocabularyMaterialType, r.Type},
+		{"language", model.VocabularyLanguage, r.Language},
+	}
+}
+
+func (r UpdateMaterialRequest) termParams() []termParam {
+	return []termParam{
+		{"type", model.VocabularyMaterialType, r.Type},
+		{"language", model.VocabularyLanguage, r.Language},
+	}
+}
+
+func (r CreateUserRequest) termParams() []termParam {
+	return []termParam{{"class", model.VocabularyUserClass, r.Class}}
+}
+
+func (r UpdateUserRequest) termParams() []termParam {
+	return []termParam{{"class", model.VocabularyUserClass, r.Class}}
+}
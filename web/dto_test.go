@@ -0,0 +1,151 @@
+package web
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+var snakeCaseName = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// TestDTOFieldsAreSnakeCase walks every DTO and request body, and the
+// structs inside them, checking the JSON name of each field.
+func TestDTOFieldsAreSnakeCase(t *testing.T) {
+	types := []any{
+		BookDTO{}, ContributionDTO{}, AuthorDTO{}, DuplicateGroupDTO{}, AuthorMergeDTO{}, LocationDTO{},
+		UserDTO{}, IssuedBookDTO{}, SubjectDTO{}, SubjectNodeDTO{}, SubjectBrowseDTO{}, MaterialDTO{},
+		MaterialFileDTO{}, MaterialRevisionDTO{}, RevisionDiffDTO{}, LinkCheckDTO{}, TermDTO{},
+		BlockerDTO{}, TrashDTO{}, AuditEntryDTO{}, LoanDTO{}, ScanDTO{}, CirculationSessionDTO{},
+		LabelLayoutDTO{}, ImportReportDTO{}, FieldErrorDTO{},
+		IssueBookRequest{}, ReturnBookRequest{}, StartCirculationRequest{}, CirculationScanRequest{},
+		LabelsRequest{}, CreateBookRequest{}, SetContributorsRequest{}, ImportBookRequest{},
+		UpdateBookRequest{}, CreateSubjectRequest{}, UpdateSubjectRequest{}, SetSubjectsRequest{},
+		CreateMaterialRequest{}, UpdateMaterialRequest{}, CreateUserRequest{}, UpdateUserRequest{},
+		MergeAuthorsRequest{}, CreateTermRequest{}, UpdateTermRequest{}, CreateLocationRequest{},
+		UpdateLocationRequest{}, CreateAuthorRequest{}, UpdateAuthorRequest{},
+	}
+
+	seen := map[reflect.Type]bool{}
+	var check func(typ reflect.Type)
+	check = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != reflect.TypeOf(BookDTO{}).PkgPath() || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if !snakeCaseName.MatchString(name) {
+				t.Errorf("%s.%s has JSON name %q, want snake_case", typ.Name(), f.Name, name)
+			}
+			check(f.Type)
+		}
+	}
+	for _, v := range types {
+		check(reflect.TypeOf(v))
+	}
+}
+
+func TestNewBookDTO(t *testing.T) {
+	id := uuid.New()
+	created := time.Date(2024, time.March, 5, 14, 30, 0, 0, time.FixedZone("IST", 5*3600+1800))
+	deleted := created.Add(time.Hour)
+	barcode, isbn13, year := "31234000000019", "9780306406157", 1999
+
+	tests := []struct {
+		name string
+		book model.Book
+		want map[string]any
+		omit []string
+	}{
+		{
+			name: "live book without optional fields",
+			book: model.Book{ID: id, Title: "Dune", BookType: "fiction", CreatedAt: created, Version: 2},
+			want: map[string]any{
+				"id":             id.String(),
+				"title":          "Dune",
+				"book_type":      "fiction",
+				"is_checked_out": false,
+				"created_at":     "2024-03-05T09:00:00Z",
+				"version":        float64(2),
+			},
+			omit: []string{"barcode", "isbn10", "isbn13", "publication_year", "contributors", "deleted_at"},
+		},
+		{
+			name: "deleted book with identifiers",
+			book: model.Book{ID: id, Title: "Dune", Barcode: &barcode, ISBN13: &isbn13, PublicationYear: &year,
+				IsCheckedOut: true, CreatedAt: created, DeletedAt: &deleted},
+			want: map[string]any{
+				"barcode":          barcode,
+				"isbn13":           isbn13,
+				"publication_year": float64(1999),
+				"is_checked_out":   true,
+				"deleted_at":       "2024-03-05T10:00:00Z",
+			},
+			omit: []string{"isbn10", "publisher"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(newBookDTO(tt.book))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+			for _, key := range tt.omit {
+				if _, ok := got[key]; ok {
+					t.Errorf("%s is present, want it omitted", key)
+				}
+			}
+			for _, internal := range []string{"ID", "AuthorID", "IsCheckedOut", "Contributors"} {
+				if _, ok := got[internal]; ok {
+					t.Errorf("Go field name %s leaked into the JSON", internal)
+				}
+			}
+		})
+	}
+}
+
+func TestMapSliceEncodesEmptyAsArray(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []model.Author
+		want string
+	}{
+		{"nil", nil, `[]`},
+		{"empty", []model.Author{}, `[]`},
+		{"one", []model.Author{{Name: "Le Guin"}}, `[{"id":"00000000-0000-0000-0000-000000000000","name":"Le Guin","version":0}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(mapSlice(tt.in, newAuthorDTO))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/arjunsaxaena/Library-Management/names"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultDuplicateThreshold is the similarity from which two names are
+// reported as possible duplicates. It lets "Tolkien" and "Tolkein" through
+// but not "Jane Austen" and "John Austin".
+const defaultDuplicateThreshold = 0.92
+
+// HELPER FUNCTIONS
+
+// findDuplicates groups the named records whose names score at least
+// threshold against another member of the group.
+func findDuplicates(ids []uuid.UUID, recordNames []string, counts map[uuid.UUID]int, threshold float64) []DuplicateGroupDTO {
+	normalized := make([]string, len(recordNames))
+	for i, name := range recordNames {
+		normalized[i] = names.Normalize(name)
+	}
+
+	parent := make([]int, len(ids))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	type link struct {
+		i     int
+		score float64
+	}
+	var links []link
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			if score := names.Compare(normalized[i], normalized[j]); score >= threshold {
+				parent[find(j)] = find(i)
+				links = append(links, link{i, score})
+			}
+		}
+	}
+
+	byRoot := map[int]*DuplicateGroupDTO{}
+	for _, l := range links {
+		root := find(l.i)
+		group, ok := byRoot[root]
+		if !ok {
+			group = &DuplicateGroupDTO{Score: 1}
+			byRoot[root] = group
+		}
+		group.Score = min(group.Score, l.score)
+	}
+	for i, id := range ids {
+		if group, ok := byRoot[find(i)]; ok {
+			group.Members = append(group.Members, DuplicateMemberDTO{ID: id, Name: recordNames[i], Books: counts[id]})
+		}
+	}
+
+	groups := make([]DuplicateGroupDTO, 0, len(byRoot))
+	for _, group := range byRoot {
+		slices.SortFunc(group.Members, func(a, b DuplicateMemberDTO) int {
+			return cmp.Or(cmp.Compare(b.Books, a.Books), cmp.Compare(a.Name, b.Name))
+		})
+		groups = append(groups, *group)
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroupDTO) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Members[0].Name, b.Members[0].Name))
+	})
+	return groups
+}
+
+// findByName returns the record whose name equals name, or failing that
+// the first whose normalized name does, so "J.K. Rowling" finds an
+// existing "J. K. Rowling".
+func findByName[T any](records []T, name string, nameOf func(T) string) (T, bool) {
+	for _, r := range records {
+		if nameOf(r) == name {
+			return r, true
+		}
+	}
+	key := names.Normalize(name)
+	for _, r := range records {
+		if key != "" && names.Normalize(nameOf(r)) == key {
+			return r, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func authorName(a model.Author) string     { return a.Name }
+func locationName(l model.Location) string { return l.Name }
+
+func duplicateThreshold(c *gin.Context) (float64, *apiError) {
+	threshold, err := strconv.ParseFloat(c.DefaultQuery("threshold", strconv.FormatFloat(defaultDuplicateThreshold, 'f', -1, 64)), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return 0, newAPIError(http.StatusBadRequest, "threshold must be a number above 0 and at most 1")
+	}
+	return threshold, nil
+}
+
+// GET HANDLERS
+
+func (h *Handler) GetAuthorDuplicates(c *gin.Context) {
+	threshold, apiErr := duplicateThreshold(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	authors, err := h.AuthorStore.Authors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve authors"})
+		return
+	}
+	counts, err := h.AuthorStore.BookCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count books"})
+		return
+	}
+
+	ids := make([]uuid.UUID, len(authors))
+	authorNames := make([]string, len(authors))
+	for i, a := range authors {
+		ids[i], authorNames[i] = a.ID, a.Name
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": findDuplicates(ids, authorNames, counts, threshold)})
+}
+
+func (h *Handler) GetLocationDuplicates(c *gin.Context) {
+	threshold, apiErr := duplicateThreshold(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	locations, err := h.LocationStore.Locations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve locations"})
+		return
+	}
+	counts, err := h.LocationStore.BookCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count books"})
+		return
+	}
+
+	ids := make([]uuid.UUID, len(locations))
+	locationNames := make([]string, len(locations))
+	for i, l := range locations {
+		ids[i], locationNames[i] = l.ID, l.Name
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": findDuplicates(ids, locationNames, counts, threshold)})
+}
+
+func (h *Handler) GetAuthorMerges(c *gin.Context) {
+	authorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return
+	}
+
+	merges, err := h.AuthorStore.AuthorMerges(authorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"merges": mapSlice(merges, newAuthorMergeDTO)})
+}
+
+// MERGE HANDLERS
+
+func (h *Handler) MergeAuthors(c *gin.Context) {
+	survivorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return
+	}
+
+	var req MergeAuthorsRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	ids := append([]uuid.UUID{survivorID}, req.MergeIDs...)
+	for i, id := range ids {
+		if i > 0 && slices.Contains(ids[:i], id) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Author %s is listed twice or is the survivor", id)})
+			return
+		}
+		if _, err := h.AuthorStore.Author(id); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Author %s not found", id)})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve author"})
+			return
+		}
+	}
+
+	merges, err := h.AuthorStore.MergeAuthors(survivorID, req.MergeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge authors"})
+		return
+	}
+
+	survivor, err := h.AuthorStore.Author(survivorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve author"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Authors merged successfully",
+		"author":  newAuthorDTO(survivor),
+		"merges":  mapSlice(merges, newAuthorMergeDTO),
+	})
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		counts    []int
+		threshold float64
+		want      [][]string
+	}{
+		{
+			name:      "no duplicates",
+			names:     []string{"Jane Austen", "John Austin", "Frank Herbert"},
+			threshold: defaultDuplicateThreshold,
+			want:      [][]string{},
+		},
+		{
+			name:      "members ordered by books, groups by score",
+			names:     []string{"J. R. R. Tolkien", "Frank Herbert", "Tolkien, J.R.R.", "J.R.R. Tolkein", "Herbert, Frank"},
+			counts:    []int{1, 0, 5, 0, 2},
+			threshold: defaultDuplicateThreshold,
+			want:      [][]string{{"Herbert, Frank", "Frank Herbert"}, {"Tolkien, J.R.R.", "J. R. R. Tolkien", "J.R.R. Tolkein"}},
+		},
+		{
+			name:      "chained through a middle name",
+			names:     []string{"Shelf A", "Shelf AB", "Shelf ABC"},
+			threshold: 0.9,
+			want:      [][]string{{"Shelf A", "Shelf AB", "Shelf ABC"}},
+		},
+		{
+			name:      "lower threshold",
+			names:     []string{"Jane Austen", "John Austin"},
+			threshold: 0.5,
+			want:      [][]string{{"Jane Austen", "John Austin"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := make([]uuid.UUID, len(tt.names))
+			counts := map[uuid.UUID]int{}
+			for i := range ids {
+				ids[i] = uuid.New()
+				if i < len(tt.counts) {
+					counts[ids[i]] = tt.counts[i]
+				}
+			}
+			groups := findDuplicates(ids, tt.names, counts, tt.threshold)
+
+			got := [][]string{}
+			for i, g := range groups {
+				var members []string
+				for _, m := range g.Members {
+					members = append(members, m.Name)
+				}
+				got = append(got, members)
+				if g.Score < tt.threshold || g.Score > 1 {
+					t.Errorf("group %d score = %f", i, g.Score)
+				}
+				if i > 0 && g.Score > groups[i-1].Score {
+					t.Errorf("group %d scores %f, higher than the group before", i, g.Score)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("groups = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAuthorDuplicates(t *testing.T) {
+	f := newFixture()
+	dupID := uuid.New()
+	f.authors.authors[dupID] = model.Author{ID: dupID, Name: "Herbert, Frank", Version: 1}
+	r := newTestRouter(f.handler())
+
+	tests := []struct {
+		query  string
+		status int
+		groups int
+	}{
+		{"", http.StatusOK, 1},
+		{"?threshold=1", http.StatusOK, 1},
+		{"?threshold=0", http.StatusBadRequest, 0},
+		{"?threshold=1.5", http.StatusBadRequest, 0},
+		{"?threshold=high", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/api/v1/authors/duplicates"+tt.query, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Groups []DuplicateGroupDTO `json:"groups"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Groups) != tt.groups {
+				t.Fatalf("groups = %+v", resp.Groups)
+			}
+			if m := resp.Groups[0].Members; len(m) != 2 || m[0].ID != f.authorID || m[0].Books != 1 {
+				t.Errorf("members = %+v, want the author with a book first", m)
+			}
+		})
+	}
+}
+
+func TestMergeAuthors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		moved  int
+	}{
+		{name: "merges", body: `{"merge_ids": ["{dup}"]}`, status: http.StatusOK, moved: 1},
+		{name: "survivor merged into itself", body: `{"merge_ids": ["{survivor}"]}`, status: http.StatusBadRequest},
+		{name: "listed twice", body: `{"merge_ids": ["{dup}", "{dup}"]}`, status: http.StatusBadRequest},
+		{name: "unknown author", body: `{"merge_ids": ["{unknown}"]}`, status: http.StatusNotFound},
+		{name: "nothing to merge", body: `{"merge_ids": []}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			survivorID, dupID := uuid.New(), f.authorID
+			f.authors.authors[survivorID] = model.Author{ID: survivorID, Name: "Frank Herbert", Version: 1}
+
+			body := strings.NewReplacer("{dup}", dupID.String(), "{survivor}", survivorID.String(), "{unknown}", uuid.NewString()).Replace(tt.body)
+			w := serve(newTestRouter(f.handler()), http.MethodPost, fmt.Sprintf("/api/v1/authors/%s/merge", survivorID), body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if len(f.authors.authors) != 2 {
+					t.Errorf("%d authors left, want 2", len(f.authors.authors))
+				}
+				return
+			}
+
+			var resp struct {
+				Author AuthorDTO        `json:"author"`
+				Merges []AuthorMergeDTO `json:"merges"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Author.ID != survivorID || resp.Author.Version != 2 {
+				t.Errorf("author = %+v", resp.Author)
+			}
+			if len(resp.Merges) != 1 || resp.Merges[0].MergedID != dupID || resp.Merges[0].BooksMoved != tt.moved {
+				t.Errorf("merges = %+v", resp.Merges)
+			}
+			if got := f.books.contributors[f.bookID][0].AuthorID; got != survivorID {
+				t.Errorf("book still points at %s", got)
+			}
+		})
+	}
+}
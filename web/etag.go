@@ -0,0 +1,124 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// A record's ETag is its version. Clients send it back in If-Match to make
+// a PUT or DELETE apply only to the version they read, and get 412 if the
+// record has changed since.
+const ifMatchHeader = "If-Match"
+
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", etag(version))
+}
+
+// ifMatch returns the version named by the request's If-Match header, or
+// zero when the write is unconditional: without the header or with "*".
+// A header listing several ETags names the record's current version if any
+// of them matches it, which takes looking the record up.
+func (h *Handler) ifMatch(c *gin.Context, entity string, id uuid.UUID) (int, *apiError) {
+	header := strings.TrimSpace(c.GetHeader(ifMatchHeader))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	versions := ifMatchVersions(header)
+	switch len(versions) {
+	case 0:
+		return 0, preconditionFailed()
+	case 1:
+		// The write itself checks the version.
+		return versions[0], nil
+	}
+
+	current, err := currentVersions[entity](h, id)
+	if err != nil {
+		return 0, lookupAPIError(err, entity)
+	}
+	if !slices.Contains(versions, current) {
+		return 0, preconditionFailed()
+	}
+	return current, nil
+}
+
+// ifMatchVersions parses a comma-separated If-Match list into the distinct
+// versions it names. Weak tags never match under the strong comparison
+// If-Match uses, and neither does anything that is not one of our ETags, so
+// both are left out.
+func ifMatchVersions(header string) []int {
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		unquoted, err := strconv.Unquote(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+		version, err := strconv.Atoi(unquoted)
+		if err != nil || version < 1 || slices.Contains(versions, version) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// currentVersions looks up the version of a live record, by the entity
+// names ifMatch is given.
+var currentVersions = map[string]func(h *Handler, id uuid.UUID) (int, error){
+	"Book": func(h *Handler, id uuid.UUID) (int, error) {
+		b, err := h.BookStore.Book(id)
+		return b.Version, err
+	},
+	"Author": func(h *Handler, id uuid.UUID) (int, error) {
+		a, err := h.AuthorStore.Author(id)
+		return a.Version, err
+	},
+	"Location": func(h *Handler, id uuid.UUID) (int, error) {
+		l, err := h.LocationStore.Location(id)
+		return l.Version, err
+	},
+	"User": func(h *Handler, id uuid.UUID) (int, error) {
+		u, err := h.UserStore.User(id)
+		return u.Version, err
+	},
+	"Subject": func(h *Handler, id uuid.UUID) (int, error) {
+		s, err := h.SubjectStore.Subject(id)
+		return s.Version, err
+	},
+	"Material": func(h *Handler, id uuid.UUID) (int, error) {
+		m, err := h.MaterialStore.Material(id)
+		return m.Version, err
+	},
+}
+
+func preconditionFailed() *apiError {
+	return newAPIError(http.StatusPreconditionFailed, "The record has changed since it was read; fetch it again and retry")
+}
+
+// writeError maps a failed write of a record to 400 for a code outside
+// its vocabulary, or to 412, 404 or 500.
+func writeError(err error, entity, action string) *apiError {
+	if apiErr := termError(err); apiErr != nil {
+		return apiErr
+	}
+	switch {
+	case errors.Is(err, model.ErrConflict):
+		return preconditionFailed()
+	case errors.Is(err, sql.ErrNoRows):
+		return newAPIError(http.StatusNotFound, entity+" not found")
+	}
+	return newAPIError(http.StatusInternalServerError, "Failed to "+action+" "+strings.ToLower(entity))
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestIfMatchVersions(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []int
+	}{
+		{`"3"`, []int{3}},
+		{`"3", "4"`, []int{3, 4}},
+		{` "3" ,"4",`, []int{3, 4}},
+		{`"3", "3"`, []int{3}},
+		{`W/"3"`, nil},
+		{`W/"3", "4"`, []int{4}},
+		{`3`, nil},
+		{`"0", "-1", "x"`, nil},
+		{`"a,b"`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := ifMatchVersions(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("ifMatchVersions(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIfMatch writes a user whose current version is 1.
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		user    string
+		ifMatch string
+		status  int
+	}{
+		{name: "unconditional update", method: http.MethodPut, status: http.StatusOK},
+		{name: "any version", method: http.MethodPut, ifMatch: "*", status: http.StatusOK},
+		{name: "current version", method: http.MethodPut, ifMatch: `"1"`, status: http.StatusOK},
+		{name: "stale version", method: http.MethodPut, ifMatch: `"2"`, status: http.StatusPreconditionFailed},
+		{name: "list with the current version", method: http.MethodPut, ifMatch: `"2", "1"`, status: http.StatusOK},
+		{name: "list without it", method: http.MethodPut, ifMatch: `"2", "3"`, status: http.StatusPreconditionFailed},
+		{name: "weak tag", method: http.MethodPut, ifMatch: `W/"1"`, status: http.StatusPreconditionFailed},
+		{name: "weak and strong tag", method: http.MethodPut, ifMatch: `W/"2", "1"`, status: http.StatusOK},
+		{name: "not an ETag", method: http.MethodPut, ifMatch: `1`, status: http.StatusPreconditionFailed},
+		{name: "list for an unknown user", method: http.MethodPut, user: uuid.NewString(), ifMatch: `"1", "2"`, status: http.StatusNotFound},
+		{name: "delete with the current version", method: http.MethodDelete, ifMatch: `"1"`, status: http.StatusOK},
+		{name: "delete with a list", method: http.MethodDelete, ifMatch: `"5", "1"`, status: http.StatusOK},
+		{name: "delete with a stale list", method: http.MethodDelete, ifMatch: `"5", "6"`, status: http.StatusPreconditionFailed},
+		{name: "delete with a bad ID", method: http.MethodDelete, user: "42", ifMatch: `"1", "2"`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			user := tt.user
+			if user == "" {
+				user = f.userID.String()
+			}
+			var body string
+			if tt.method == http.MethodPut {
+				body = `{"name": "Ann", "class": "11"}`
+			}
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{ifMatchHeader, tt.ifMatch}
+			}
+			w := serve(newTestRouter(f.handler()), tt.method, "/api/v1/users/"+user, body, headers...)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.method == http.MethodPut && tt.status == http.StatusOK && w.Header().Get("ETag") != `"2"` {
+				t.Errorf("ETag = %q, want the next version", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,930 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/blob"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// In-memory stores for handler tests. Each embeds its interface, so a
+// handler that reaches a method a test did not expect panics instead of
+// silently succeeding.
+
+// fakeBookStore and fakeMaterialStore keep the subjects records are filed
+// under in filed, by record ID.
+type fakeBookStore struct {
+	model.BookStore
+	books        map[uuid.UUID]model.Book
+	contributors map[uuid.UUID][]model.Contributor
+	filed        map[uuid.UUID][]uuid.UUID
+	subjectStore *fakeSubjectStore
+}
+
+func (s *fakeBookStore) Book(id uuid.UUID) (model.Book, error) {
+	b, ok := s.books[id]
+	if !ok {
+		return model.Book{}, sql.ErrNoRows
+	}
+	return b, nil
+}
+
+func (s *fakeBookStore) Books() ([]model.Book, error) {
+	books := []model.Book{}
+	for _, b := range s.books {
+		books = append(books, b)
+	}
+	return books, nil
+}
+
+func (s *fakeBookStore) BookByBarcode(barcode string) (model.Book, error) {
+	for _, b := range s.books {
+		if b.Barcode != nil && *b.Barcode == barcode {
+			return b, nil
+		}
+	}
+	return model.Book{}, sql.ErrNoRows
+}
+
+func (s *fakeBookStore) BookByISBN(isbn13 string) (model.Book, error) {
+	for _, b := range s.books {
+		if b.ISBN13 != nil && *b.ISBN13 == isbn13 {
+			return b, nil
+		}
+	}
+	return model.Book{}, sql.ErrNoRows
+}
+
+func (s *fakeBookStore) Subjects(bookID uuid.UUID) ([]model.Subject, error) {
+	return s.subjectStore.filedSubjects(s.filed[bookID]), nil
+}
+
+func (s *fakeBookStore) SetSubjects(bookID uuid.UUID, version int, subjectIDs []uuid.UUID) error {
+	b, ok := s.books[bookID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != b.Version {
+		return model.ErrConflict
+	}
+	b.Version++
+	s.books[bookID] = b
+	s.filed[bookID] = subjectIDs
+	return nil
+}
+
+func (s *fakeBookStore) BooksInSubject(subjectID uuid.UUID, subtree bool) ([]model.Book, error) {
+	books := []model.Book{}
+	for _, id := range filedUnder(s.filed, s.subjectStore.under(subjectID, subtree)) {
+		books = append(books, s.books[id])
+	}
+	return books, nil
+}
+
+func (s *fakeBookStore) Contributors(bookID uuid.UUID) ([]model.Contributor, error) {
+	return s.contributors[bookID], nil
+}
+
+func (s *fakeBookStore) SetContributors(bookID uuid.UUID, version int, contributors []model.Contributor) error {
+	b, ok := s.books[bookID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != b.Version {
+		return model.ErrConflict
+	}
+	for i := range contributors {
+		contributors[i].BookID = bookID
+	}
+	s.contributors[bookID] = contributors
+	b.Version++
+	s.books[bookID] = b
+	return nil
+}
+
+// fakeAuthorStore finds contributions in the contributors of books.
+type fakeAuthorStore struct {
+	model.AuthorStore
+	authors map[uuid.UUID]model.Author
+	books   *fakeBookStore
+}
+
+func (s *fakeAuthorStore) Author(id uuid.UUID) (model.Author, error) {
+	a, ok := s.authors[id]
+	if !ok {
+		return model.Author{}, sql.ErrNoRows
+	}
+	return a, nil
+}
+
+func (s *fakeAuthorStore) Authors() ([]model.Author, error) {
+	authors := []model.Author{}
+	for _, a := range s.authors {
+		authors = append(authors, a)
+	}
+	return authors, nil
+}
+
+func (s *fakeAuthorStore) CreateAuthor(a *model.Author) error {
+	a.Version = 1
+	s.authors[a.ID] = *a
+	return nil
+}
+
+func (s *fakeAuthorStore) BookCounts() (map[uuid.UUID]int, error) {
+	counts := map[uuid.UUID]int{}
+	for _, contributors := range s.books.contributors {
+		for _, c := range contributors {
+			counts[c.AuthorID]++
+		}
+	}
+	return counts, nil
+}
+
+// MergeAuthors moves the contributions of the merged authors to the
+// survivor and deletes them.
+func (s *fakeAuthorStore) MergeAuthors(survivorID uuid.UUID, mergedIDs []uuid.UUID) ([]model.AuthorMerge, error) {
+	var merges []model.AuthorMerge
+	for _, id := range mergedIDs {
+		m := model.AuthorMerge{ID: uuid.New(), SurvivorID: survivorID, MergedID: id, MergedName: s.authors[id].Name, MergedAt: time.Now()}
+		for _, contributors := range s.books.contributors {
+			for i := range contributors {
+				if contributors[i].AuthorID == id {
+					contributors[i].AuthorID = survivorID
+					m.BooksMoved++
+				}
+			}
+		}
+		delete(s.authors, id)
+		merges = append(merges, m)
+	}
+	survivor := s.authors[survivorID]
+	survivor.Version++
+	s.authors[survivorID] = survivor
+	return merges, nil
+}
+
+func (s *fakeAuthorStore) Contributions(authorID uuid.UUID) ([]model.Contributor, error) {
+	var out []model.Contributor
+	for _, contributors := range s.books.contributors {
+		for _, c := range contributors {
+			if c.AuthorID == authorID {
+				out = append(out, c)
+			}
+		}
+	}
+	return out, nil
+}
+
+// fakeUserStore refuses to create a user named failOn, standing in for
+// a write the database rejects. Purges fail with purgeErr. Updates record
+// the columns they were asked to write.
+type fakeUserStore struct {
+	model.UserStore
+	users    map[uuid.UUID]model.User
+	failOn   string
+	seq      int64
+	purgeErr error
+	columns  []string
+}
+
+func (s *fakeUserStore) User(id uuid.UUID) (model.User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return model.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (s *fakeUserStore) Users() ([]model.User, error) {
+	users := []model.User{}
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (s *fakeUserStore) UserByCardNumber(cardNumber string) (model.User, error) {
+	for _, u := range s.users {
+		if u.CardNumber != nil && *u.CardNumber == cardNumber {
+			return u, nil
+		}
+	}
+	return model.User{}, sql.ErrNoRows
+}
+
+// fakeIssuedBookStore keeps every loan, open and returned. created, when
+// set, is called after a loan is recorded.
+func (s *fakeUserStore) CreateUser(u *model.User) error {
+	if u.Name == s.failOn {
+		return errors.New("insert failed")
+	}
+	u.Version = 1
+	s.users[u.ID] = *u
+	return nil
+}
+
+func (s *fakeUserStore) UpdateUser(u *model.User, columns ...string) error {
+	existing, ok := s.users[u.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if u.Version != 0 && u.Version != existing.Version {
+		return model.ErrConflict
+	}
+	s.columns = columns
+	existing.Name, existing.Class = u.Name, u.Class
+	existing.Version++
+	s.users[u.ID] = existing
+	u.Version = existing.Version
+	return nil
+}
+
+func (s *fakeUserStore) DeleteUser(id uuid.UUID, version int) error {
+	u, ok := s.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != u.Version {
+		return model.ErrConflict
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (s *fakeUserStore) PurgeUser(id uuid.UUID) error {
+	return s.purgeErr
+}
+
+func (s *fakeUserStore) NextCardSequence() (int64, error) {
+	s.seq++
+	return 100 + s.seq, nil
+}
+
+func (s *fakeUserStore) SetCardNumber(id uuid.UUID, cardNumber string) error {
+	u := s.users[id]
+	u.CardNumber = &cardNumber
+	s.users[id] = u
+	return nil
+}
+
+type fakeIssuedBookStore struct {
+	model.IssuedBookStore
+	loans   []model.IssuedBook
+	created func(model.IssuedBook)
+}
+
+func (s *fakeIssuedBookStore) CreateIssuedBook(ib *model.IssuedBook) error {
+	s.loans = append(s.loans, *ib)
+	if s.created != nil {
+		s.created(*ib)
+	}
+	return nil
+}
+
+func (s *fakeIssuedBookStore) ReturnBook(bookID uuid.UUID) (float64, error) {
+	for i, ib := range s.loans {
+		if ib.BookID == bookID && ib.ReturnDate == nil {
+			now := time.Now()
+			s.loans[i].ReturnDate = &now
+			return ib.LateFees, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (s *fakeIssuedBookStore) GetIssuedBookByBookID(bookID uuid.UUID) (model.IssuedBook, error) {
+	for i := len(s.loans) - 1; i >= 0; i-- {
+		if s.loans[i].BookID == bookID {
+			return s.loans[i], nil
+		}
+	}
+	return model.IssuedBook{}, sql.ErrNoRows
+}
+
+func (s *fakeIssuedBookStore) IssuedBooksByUser(userID uuid.UUID) ([]model.IssuedBook, error) {
+	loans := []model.IssuedBook{}
+	for _, ib := range s.loans {
+		if ib.UserID == userID && ib.ReturnDate == nil {
+			loans = append(loans, ib)
+		}
+	}
+	return loans, nil
+}
+
+func (s *fakeIssuedBookStore) UnpaidFees(userID uuid.UUID) (float64, error) {
+	var fees float64
+	for _, ib := range s.loans {
+		if ib.UserID == userID && ib.FeesPaidAt == nil {
+			fees += ib.LateFees
+		}
+	}
+	return fees, nil
+}
+
+func (s *fakeIssuedBookStore) PayFees(userID uuid.UUID) (float64, error) {
+	var paid float64
+	now := time.Now()
+	for i, ib := range s.loans {
+		if ib.UserID == userID && ib.ReturnDate != nil && ib.FeesPaidAt == nil && ib.LateFees > 0 {
+			s.loans[i].FeesPaidAt = &now
+			paid += ib.LateFees
+		}
+	}
+	return paid, nil
+}
+
+// fakeSubjectStore counts over subtrees from what the book and material
+// stores have filed.
+type fakeSubjectStore struct {
+	model.SubjectStore
+	subjects  map[uuid.UUID]model.Subject
+	books     *fakeBookStore
+	materials *fakeMaterialStore
+}
+
+func (s *fakeSubjectStore) Subject(id uuid.UUID) (model.Subject, error) {
+	sub, ok := s.subjects[id]
+	if !ok {
+		return model.Subject{}, sql.ErrNoRows
+	}
+	return sub, nil
+}
+
+func (s *fakeSubjectStore) Subjects() ([]model.Subject, error) {
+	subjects := []model.Subject{}
+	for _, sub := range s.subjects {
+		subjects = append(subjects, sub)
+	}
+	return subjects, nil
+}
+
+// UpdateSubject refuses names other subjects have and parents within the
+// subject's own subtree.
+func (s *fakeSubjectStore) UpdateSubject(subject *model.Subject, columns ...string) error {
+	existing, ok := s.subjects[subject.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if subject.Version != 0 && subject.Version != existing.Version {
+		return model.ErrConflict
+	}
+	writes := func(column string) bool { return len(columns) == 0 || slices.Contains(columns, column) }
+	if writes("name") {
+		for _, other := range s.subjects {
+			if other.ID != subject.ID && other.Name == subject.Name {
+				return model.ErrDuplicate
+			}
+		}
+		existing.Name = subject.Name
+	}
+	if writes("parent_id") {
+		if subject.ParentID != nil && slices.Contains(s.subtree(subject.ID), *subject.ParentID) {
+			return model.ErrInvalidParent
+		}
+		existing.ParentID = subject.ParentID
+	}
+	if writes("language") {
+		existing.Language = subject.Language
+	}
+	existing.Version++
+	s.subjects[subject.ID] = existing
+	*subject = existing
+	return nil
+}
+
+func (s *fakeSubjectStore) DeleteSubject(id uuid.UUID, version int) error {
+	sub, ok := s.subjects[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != sub.Version {
+		return model.ErrConflict
+	}
+	delete(s.subjects, id)
+	return nil
+}
+
+func (s *fakeSubjectStore) Children(id uuid.UUID) ([]model.Subject, error) {
+	var children []model.Subject
+	for _, child := range s.subjects {
+		if child.ParentID != nil && *child.ParentID == id {
+			children = append(children, child)
+		}
+	}
+	return children, nil
+}
+
+// subtree lists the subject and every subject below it.
+func (s *fakeSubjectStore) subtree(id uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{id}
+	for i := 0; i < len(ids); i++ {
+		for _, sub := range s.subjects {
+			if sub.ParentID != nil && *sub.ParentID == ids[i] {
+				ids = append(ids, sub.ID)
+			}
+		}
+	}
+	return ids
+}
+
+func (s *fakeSubjectStore) SubjectNode(id uuid.UUID) (model.SubjectNode, error) {
+	sub, ok := s.subjects[id]
+	if !ok {
+		return model.SubjectNode{}, sql.ErrNoRows
+	}
+	tree := s.subtree(id)
+	children, _ := s.SubjectNodes(id)
+	return model.SubjectNode{
+		Subject:     sub,
+		Children:    len(children),
+		Descendants: len(tree) - 1,
+		Books:       len(filedUnder(s.books.filed, tree)),
+		Materials:   len(filedUnder(s.materials.filed, tree)),
+	}, nil
+}
+
+func (s *fakeSubjectStore) SubjectNodes(parentID uuid.UUID) ([]model.SubjectNode, error) {
+	nodes := []model.SubjectNode{}
+	for _, sub := range s.subjects {
+		if (sub.ParentID == nil && parentID == uuid.Nil) || (sub.ParentID != nil && *sub.ParentID == parentID) {
+			node, _ := s.SubjectNode(sub.ID)
+			nodes = append(nodes, node)
+		}
+	}
+	slices.SortFunc(nodes, func(a, b model.SubjectNode) int { return strings.Compare(a.Name, b.Name) })
+	return nodes, nil
+}
+
+func (s *fakeSubjectStore) Ancestors(id uuid.UUID) ([]model.Subject, error) {
+	ancestors := []model.Subject{}
+	for sub := s.subjects[id]; sub.ParentID != nil; {
+		sub = s.subjects[*sub.ParentID]
+		ancestors = slices.Insert(ancestors, 0, sub)
+	}
+	return ancestors, nil
+}
+
+// under lists the subject and, with subtree, every subject below it.
+func (s *fakeSubjectStore) under(id uuid.UUID, subtree bool) []uuid.UUID {
+	if !subtree {
+		return []uuid.UUID{id}
+	}
+	return s.subtree(id)
+}
+
+// filedSubjects lists the subjects with the given IDs, sorted by name.
+func (s *fakeSubjectStore) filedSubjects(ids []uuid.UUID) []model.Subject {
+	subjects := []model.Subject{}
+	for _, id := range ids {
+		subjects = append(subjects, s.subjects[id])
+	}
+	slices.SortFunc(subjects, func(a, b model.Subject) int { return strings.Compare(a.Name, b.Name) })
+	return subjects
+}
+
+// filedUnder lists the records filed under any of the subjects, sorted.
+func filedUnder(filed map[uuid.UUID][]uuid.UUID, subjectIDs []uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for id, subjects := range filed {
+		if slices.ContainsFunc(subjects, func(s uuid.UUID) bool { return slices.Contains(subjectIDs, s) }) {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	return ids
+}
+
+type fakeMaterialStore struct {
+	model.MaterialStore
+	materials    map[uuid.UUID]model.Material
+	revisions    map[uuid.UUID][]model.MaterialRevision
+	filed        map[uuid.UUID][]uuid.UUID
+	subjectStore *fakeSubjectStore
+
+	// files are the materials' files by ID, and contents the digests
+	// recorded by LockContent. onLock, if set, runs as a digest is locked,
+	// standing in for whatever held the lock first.
+	files    map[uuid.UUID]model.MaterialFile
+	contents map[string]bool
+	locked   []string
+	onLock   func(sha256 string)
+}
+
+func (s *fakeMaterialStore) Material(id uuid.UUID) (model.Material, error) {
+	m, ok := s.materials[id]
+	if !ok {
+		return model.Material{}, sql.ErrNoRows
+	}
+	return m, nil
+}
+
+func (s *fakeMaterialStore) Materials() ([]model.Material, error) {
+	materials := []model.Material{}
+	for _, m := range s.materials {
+		materials = append(materials, m)
+	}
+	return materials, nil
+}
+
+func (s *fakeMaterialStore) Subjects(materialID uuid.UUID) ([]model.Subject, error) {
+	return s.subjectStore.filedSubjects(s.filed[materialID]), nil
+}
+
+// SetSubjects keeps the material filed under its own subject.
+func (s *fakeMaterialStore) SetSubjects(materialID uuid.UUID, version int, subjectIDs []uuid.UUID) error {
+	m, ok := s.materials[materialID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != m.Version {
+		return model.ErrConflict
+	}
+	m.Version++
+	s.materials[materialID] = m
+	if !slices.Contains(subjectIDs, m.SubjectID) {
+		subjectIDs = append(subjectIDs, m.SubjectID)
+	}
+	s.filed[materialID] = subjectIDs
+	return nil
+}
+
+func (s *fakeMaterialStore) MaterialsInSubject(subjectID uuid.UUID, subtree bool) ([]model.Material, error) {
+	materials := []model.Material{}
+	for _, id := range filedUnder(s.filed, s.subjectStore.under(subjectID, subtree)) {
+		materials = append(materials, s.materials[id])
+	}
+	return materials, nil
+}
+
+// GetMaterialsBySubject finds materials by the current name of their
+// subject, as the store's join does.
+func (s *fakeMaterialStore) GetMaterialsBySubject(subjectName string) ([]model.Material, error) {
+	var materials []model.Material
+	for _, m := range s.materials {
+		if s.subjectStore.subjects[m.SubjectID].Name == subjectName {
+			m.SubjectName = subjectName
+			materials = append(materials, m)
+		}
+	}
+	return materials, nil
+}
+
+func (s *fakeMaterialStore) ReassignMaterials(fromSubjectID, toSubjectID uuid.UUID) (int, error) {
+	moved := 0
+	for id, m := range s.materials {
+		if m.SubjectID != fromSubjectID {
+			continue
+		}
+		m.SubjectID, m.SubjectName = toSubjectID, s.subjectStore.subjects[toSubjectID].Name
+		m.Version++
+		s.materials[id] = m
+		filed := slices.DeleteFunc(slices.Clone(s.filed[id]), func(sub uuid.UUID) bool { return sub == fromSubjectID })
+		s.filed[id] = append(filed, toSubjectID)
+		moved++
+	}
+	return moved, nil
+}
+
+func (s *fakeMaterialStore) Revisions(materialID uuid.UUID) ([]model.MaterialRevision, error) {
+	return append([]model.MaterialRevision{}, s.revisions[materialID]...), nil
+}
+
+func (s *fakeMaterialStore) Revision(materialID uuid.UUID, revision int) (model.MaterialRevision, error) {
+	revisions := s.revisions[materialID]
+	if revision < 1 || revision > len(revisions) {
+		return model.MaterialRevision{}, sql.ErrNoRows
+	}
+	return revisions[revision-1], nil
+}
+
+func (s *fakeMaterialStore) DiffRevisions(materialID uuid.UUID, from, to int) ([]model.FieldChange, error) {
+	fromRevision, err := s.Revision(materialID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := s.Revision(materialID, to)
+	if err != nil {
+		return nil, err
+	}
+	changes := []model.FieldChange{}
+	for _, f := range [][3]string{
+		{"title", fromRevision.Title, toRevision.Title},
+		{"link", fromRevision.Link, toRevision.Link},
+		{"subject_id", fromRevision.SubjectID.String(), toRevision.SubjectID.String()},
+	} {
+		if f[1] != f[2] {
+			changes = append(changes, model.FieldChange{Field: f[0], From: f[1], To: f[2]})
+		}
+	}
+	return changes, nil
+}
+
+// RevertMaterial restores a revision's title, link and subject, and adds
+// a revision for it.
+func (s *fakeMaterialStore) RevertMaterial(materialID uuid.UUID, revision, version int) error {
+	r, err := s.Revision(materialID, revision)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.subjectStore.subjects[r.SubjectID]; !ok {
+		return model.ErrSubjectGone
+	}
+	if err := s.bumpVersion(materialID, version); err != nil {
+		return err
+	}
+	m := s.materials[materialID]
+	m.Title, m.Link, m.SubjectID = r.Title, r.Link, r.SubjectID
+	s.materials[materialID] = m
+
+	r.Revision, r.Version = len(s.revisions[materialID])+1, m.Version
+	r.Summary = fmt.Sprintf("Reverted to revision %d", revision)
+	s.revisions[materialID] = append(s.revisions[materialID], r)
+	return nil
+}
+
+func (s *fakeMaterialStore) Files(materialID uuid.UUID) ([]model.MaterialFile, error) {
+	files := []model.MaterialFile{}
+	for _, file := range s.files {
+		if file.MaterialID == materialID {
+			files = append(files, file)
+		}
+	}
+	slices.SortFunc(files, func(a, b model.MaterialFile) int { return strings.Compare(a.Filename, b.Filename) })
+	return files, nil
+}
+
+func (s *fakeMaterialStore) File(materialID, fileID uuid.UUID) (model.MaterialFile, error) {
+	file, ok := s.files[fileID]
+	if !ok || file.MaterialID != materialID {
+		return model.MaterialFile{}, sql.ErrNoRows
+	}
+	return file, nil
+}
+
+// bumpVersion is a versioned update of a material that changes nothing
+// else.
+func (s *fakeMaterialStore) bumpVersion(materialID uuid.UUID, version int) error {
+	m, ok := s.materials[materialID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != 0 && version != m.Version {
+		return model.ErrConflict
+	}
+	m.Version++
+	s.materials[materialID] = m
+	return nil
+}
+
+func (s *fakeMaterialStore) AddFile(f *model.MaterialFile, version int) error {
+	if err := s.bumpVersion(f.MaterialID, version); err != nil {
+		return err
+	}
+	s.files[f.ID] = *f
+	return nil
+}
+
+func (s *fakeMaterialStore) RemoveFile(materialID, fileID uuid.UUID, version int) error {
+	if _, err := s.File(materialID, fileID); err != nil {
+		return err
+	}
+	if err := s.bumpVersion(materialID, version); err != nil {
+		return err
+	}
+	delete(s.files, fileID)
+	return nil
+}
+
+func (s *fakeMaterialStore) LockContent(sha256 string) error {
+	if s.onLock != nil {
+		s.onLock(sha256)
+	}
+	s.contents[sha256] = true
+	s.locked = append(s.locked, sha256)
+	return nil
+}
+
+func (s *fakeMaterialStore) ReleaseContent(sha256 string) (bool, error) {
+	if !slices.Contains(s.locked, sha256) {
+		return false, errors.New("content released without its lock")
+	}
+	for _, file := range s.files {
+		if file.SHA256 == sha256 {
+			return false, nil
+		}
+	}
+	delete(s.contents, sha256)
+	return true, nil
+}
+
+// fakeBlobStore keeps content in memory by key.
+type fakeBlobStore struct {
+	blobs map[string][]byte
+}
+
+func (s *fakeBlobStore) Put(ctx context.Context, r io.Reader) (blob.Info, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return blob.Info{}, err
+	}
+	key, size, _ := blob.Key(bytes.NewReader(content))
+	s.blobs[key] = content
+	return blob.Info{Key: key, Size: size}, nil
+}
+
+func (s *fakeBlobStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	content, ok := s.blobs[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return readSeekNopCloser{bytes.NewReader(content)}, nil
+}
+
+func (s *fakeBlobStore) Stat(ctx context.Context, key string) (blob.Info, error) {
+	content, ok := s.blobs[key]
+	if !ok {
+		return blob.Info{}, blob.ErrNotFound
+	}
+	return blob.Info{Key: key, Size: int64(len(content))}, nil
+}
+
+func (s *fakeBlobStore) Delete(ctx context.Context, key string) error {
+	if _, ok := s.blobs[key]; !ok {
+		return blob.ErrNotFound
+	}
+	delete(s.blobs, key)
+	return nil
+}
+
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error { return nil }
+
+// fakeVocabularyStore holds terms by vocabulary and code.
+type fakeVocabularyStore struct {
+	model.VocabularyStore
+	terms map[string]map[string]bool
+}
+
+func (s *fakeVocabularyStore) Terms(vocabulary string) ([]model.Term, error) {
+	terms := []model.Term{}
+	for _, code := range slices.Sorted(maps.Keys(s.terms[vocabulary])) {
+		terms = append(terms, model.Term{Vocabulary: vocabulary, Code: code, Active: s.terms[vocabulary][code]})
+	}
+	return terms, nil
+}
+
+func (s *fakeVocabularyStore) Term(vocabulary, code string) (model.Term, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	active, ok := s.terms[vocabulary][code]
+	if !ok {
+		return model.Term{}, sql.ErrNoRows
+	}
+	return model.Term{Vocabulary: vocabulary, Code: code, Active: active}, nil
+}
+
+// fakeTx runs transactions over the fixture's stores. A failed
+// transaction restores the users, subjects and materials it may have
+// written, and every transaction ends by releasing its content locks.
+type fakeTx struct {
+	f *fixture
+}
+
+func (tx fakeTx) Stores(audit model.Audit) model.Stores {
+	return model.Stores{
+		Books:       tx.f.books,
+		Authors:     tx.f.authors,
+		Users:       tx.f.users,
+		Subjects:    tx.f.subjects,
+		Materials:   tx.f.materials,
+		IssuedBooks: tx.f.issued,
+	}
+}
+
+func (tx fakeTx) InTx(audit model.Audit, fn func(model.Stores) error) error {
+	users, subjects := maps.Clone(tx.f.users.users), maps.Clone(tx.f.subjects.subjects)
+	materials, filed := maps.Clone(tx.f.materials.materials), maps.Clone(tx.f.materials.filed)
+	files, contents := maps.Clone(tx.f.materials.files), maps.Clone(tx.f.materials.contents)
+	defer func() { tx.f.materials.locked = nil }()
+	if err := fn(tx.Stores(audit)); err != nil {
+		tx.f.users.users, tx.f.subjects.subjects = users, subjects
+		tx.f.materials.materials, tx.f.materials.filed = materials, filed
+		tx.f.materials.files, tx.f.materials.contents = files, contents
+		return err
+	}
+	return nil
+}
+
+// fixture is a small catalogue shared by the handler tests.
+type fixture struct {
+	books     *fakeBookStore
+	authors   *fakeAuthorStore
+	users     *fakeUserStore
+	issued    *fakeIssuedBookStore
+	subjects  *fakeSubjectStore
+	materials *fakeMaterialStore
+	terms     *fakeVocabularyStore
+
+	bookID, authorID, userID, subjectID, materialID uuid.UUID
+}
+
+func newFixture() *fixture {
+	created := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	f := &fixture{bookID: uuid.New(), authorID: uuid.New(), userID: uuid.New(), subjectID: uuid.New(), materialID: uuid.New()}
+	barcode, card, isbn13 := "30001000000010", "20001000000012", "9780306406157"
+
+	f.books = &fakeBookStore{
+		books: map[uuid.UUID]model.Book{
+			f.bookID: {ID: f.bookID, Title: "Dune", BookType: "fiction", Barcode: &barcode, ISBN13: &isbn13, CreatedAt: created, Version: 3},
+		},
+		contributors: map[uuid.UUID][]model.Contributor{
+			f.bookID: {{BookID: f.bookID, AuthorID: f.authorID, Name: "Frank Herbert", Role: "author", Position: 1}},
+		},
+	}
+	f.authors = &fakeAuthorStore{books: f.books, authors: map[uuid.UUID]model.Author{
+		f.authorID: {ID: f.authorID, Name: "Frank Herbert", Version: 1},
+	}}
+	f.users = &fakeUserStore{users: map[uuid.UUID]model.User{
+		f.userID: {ID: f.userID, Name: "Ann", Class: "10", CardNumber: &card, Version: 1},
+	}}
+	f.issued = &fakeIssuedBookStore{}
+	f.subjects = &fakeSubjectStore{subjects: map[uuid.UUID]model.Subject{
+		f.subjectID: {ID: f.subjectID, Name: "Mathematics", Language: "en", CreatedAt: created, Version: 1},
+	}}
+	f.materials = &fakeMaterialStore{
+		materials: map[uuid.UUID]model.Material{
+			f.materialID: {ID: f.materialID, Title: "Algebra notes", Type: "pdf", Link: "https://example.com/algebra.pdf",
+				Language: "en", SubjectID: f.subjectID, SubjectName: "Mathematics", CreatedAt: created, Version: 2},
+		},
+		revisions: map[uuid.UUID][]model.MaterialRevision{
+			f.materialID: {
+				{MaterialID: f.materialID, Revision: 1, Version: 1, Title: "Algebra", Type: "pdf", Link: "https://example.com/algebra.pdf",
+					Language: "en", SubjectID: f.subjectID, Author: "system", Summary: "Created", CreatedAt: created},
+				{MaterialID: f.materialID, Revision: 2, Version: 2, Title: "Algebra notes", Type: "pdf", Link: "https://example.com/algebra.pdf",
+					Language: "en", SubjectID: f.subjectID, Author: "ann", Summary: "Changed title", CreatedAt: created.Add(time.Hour)},
+			},
+		},
+		files:    map[uuid.UUID]model.MaterialFile{},
+		contents: map[string]bool{},
+	}
+	f.books.filed = map[uuid.UUID][]uuid.UUID{}
+	f.materials.filed = map[uuid.UUID][]uuid.UUID{f.materialID: {f.subjectID}}
+	f.books.subjectStore, f.materials.subjectStore = f.subjects, f.subjects
+	f.subjects.books, f.subjects.materials = f.books, f.materials
+	f.terms = &fakeVocabularyStore{terms: map[string]map[string]bool{
+		model.VocabularyBookType: {"fiction": true, "pamphlet": false},
+		model.VocabularyLanguage: {"en": true, "la": false},
+	}}
+	return f
+}
+
+func (f *fixture) handler() *Handler {
+	h := NewHandler(f.books, f.authors, nil, f.users, f.issued, f.subjects, f.materials)
+	h.VocabularyStore = f.terms
+	return h
+}
+
+// newTestRouter serves the API of h the way cmd/main.go does, with any
+// extra middleware in front.
+func newTestRouter(h *Handler, middleware ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware...)
+	RegisterAPI(r, h, DefaultAPIConfig())
+	return r
+}
+
+// serve sends a request to r. A body is sent as JSON unless headers set
+// another Content-Type.
+func serve(r http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
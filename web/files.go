@@ -0,0 +1,448 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/arjunsaxaena/Library-Management/blob"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Files are uploaded to a material one per multipart/form-data request,
+// in the "file" field. An optional "sha256" field carries the client's
+// hex SHA-256 digest of the file, and the upload is refused if the content
+// does not match it. The content type is sniffed from the content, never
+// taken from the client.
+//
+// Downloads from a store that can presign URLs, like S3, are redirected to
+// a URL the client fetches the content from directly.
+const (
+	maxMaterialFileSize = 100 << 20
+	maxFilenameLength   = 255
+	presignedURLExpiry  = 15 * time.Minute
+)
+
+// extensionTypes name the types of files that content sniffing can only
+// tell are ZIP archives, or cannot tell at all.
+var extensionTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".epub": "application/epub+zip",
+	".doc":  "application/msword",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".xls":  "application/vnd.ms-excel",
+}
+
+// HELPER FUNCTIONS
+
+// blobStore returns the store, or 503 if there is none.
+func (h *Handler) blobStore() (blob.Store, *apiError) {
+	if h.Blobs == nil {
+		return nil, newAPIError(http.StatusServiceUnavailable, "File storage is not configured")
+	}
+	return h.Blobs, nil
+}
+
+func (h *Handler) materialFileParam(c *gin.Context, materialID uuid.UUID) (model.MaterialFile, *apiError) {
+	fileID, err := uuid.Parse(c.Param("file_id"))
+	if err != nil {
+		return model.MaterialFile{}, newAPIError(http.StatusBadRequest, "Invalid file ID")
+	}
+	file, err := h.MaterialStore.File(materialID, fileID)
+	if err != nil {
+		return model.MaterialFile{}, lookupAPIError(err, "File")
+	}
+	return file, nil
+}
+
+func invalidField(field, rule, message string) *apiError {
+	apiErr := newAPIError(http.StatusBadRequest, "Invalid request body")
+	apiErr.Body["fields"] = []FieldErrorDTO{{Field: field, Rule: rule, Message: field + " " + message}}
+	return apiErr
+}
+
+// uploadError answers a multipart body that could not be read.
+func uploadError(err error) *apiError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newAPIError(http.StatusRequestEntityTooLarge, "file must be at most "+strconv.Itoa(maxMaterialFileSize>>20)+" MiB")
+	}
+	apiErr := newAPIError(http.StatusBadRequest, "Invalid multipart body")
+	apiErr.Body["details"] = err.Error()
+	return apiErr
+}
+
+// cleanFilename keeps the last element of a client's file name, without
+// control characters and at most maxFilenameLength bytes long.
+func cleanFilename(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name))
+	for len(name) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// sniffContentType tells a file's type from its first bytes, and from its
+// name where the bytes only show a ZIP archive or nothing in particular.
+func sniffContentType(head []byte, filename string) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed != "application/octet-stream" && sniffed != "application/zip" {
+		return sniffed
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if t, ok := extensionTypes[ext]; ok {
+		return t
+	}
+	if sniffed == "application/octet-stream" {
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+	}
+	return sniffed
+}
+
+// receiveFile stores the "file" part of an upload and reads the "sha256"
+// field, returning the file without an ID or material.
+func (h *Handler) receiveFile(ctx context.Context, blobs blob.Store, form *multipart.Reader) (model.MaterialFile, string, *apiError) {
+	var file model.MaterialFile
+	var checksum string
+	for {
+		part, err := form.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			h.releaseContent(ctx, file.SHA256)
+			return model.MaterialFile{}, "", uploadError(err)
+		}
+
+		switch part.FormName() {
+		case "file":
+			if file.SHA256 != "" {
+				h.releaseContent(ctx, file.SHA256)
+				return model.MaterialFile{}, "", invalidField("file", "max", "must be a single file; upload one per request")
+			}
+			content := http.MaxBytesReader(nil, part, maxMaterialFileSize)
+			head := make([]byte, 512)
+			n, err := io.ReadFull(content, head)
+			if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return model.MaterialFile{}, "", uploadError(err)
+			}
+			file.Filename = cleanFilename(part.FileName())
+			file.ContentType = sniffContentType(head[:n], file.Filename)
+
+			info, err := blobs.Put(ctx, io.MultiReader(bytes.NewReader(head[:n]), content))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return model.MaterialFile{}, "", uploadError(err)
+				}
+				return model.MaterialFile{}, "", newAPIError(http.StatusInternalServerError, "Failed to store file")
+			}
+			file.SHA256, file.Size = info.Key, info.Size
+		case "sha256":
+			value, err := io.ReadAll(io.LimitReader(part, 256))
+			if err != nil {
+				h.releaseContent(ctx, file.SHA256)
+				return model.MaterialFile{}, "", uploadError(err)
+			}
+			checksum = strings.ToLower(strings.TrimSpace(string(value)))
+		}
+	}
+
+	if file.SHA256 == "" {
+		return model.MaterialFile{}, "", invalidField("file", "required", "is required")
+	}
+	return file, checksum, nil
+}
+
+// checkUpload checks a received file against the client's checksum.
+func checkUpload(file model.MaterialFile, checksum string) *apiError {
+	switch {
+	case file.Size == 0:
+		return invalidField("file", "min", "must not be empty")
+	case checksum == "":
+		return nil
+	case !blob.ValidKey(checksum):
+		return invalidField("sha256", "sha256", "must be a hex SHA-256 digest")
+	case checksum != file.SHA256:
+		return invalidField("sha256", "checksum", "does not match the uploaded file, whose SHA-256 is "+file.SHA256)
+	}
+	return nil
+}
+
+// releaseContent deletes stored content that no file refers to any more.
+// Failing to is only logged; the content is left behind, not lost.
+func (h *Handler) releaseContent(ctx context.Context, keys ...string) {
+	if h.Blobs == nil {
+		return
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		err := h.inTx(func(tx *Handler) error {
+			if err := tx.MaterialStore.LockContent(key); err != nil {
+				return err
+			}
+			return tx.deleteUnusedContent(ctx, key)
+		})
+		if err != nil {
+			log.Printf("release blob %s: %v", key, err)
+		}
+	}
+}
+
+// deleteUnusedContent deletes content whose digest the transaction has
+// locked, if no file has it. With the lock held, an upload of the same
+// content waits until the content is gone and then finds it missing,
+// rather than adding a file whose content is deleted under it.
+func (h *Handler) deleteUnusedContent(ctx context.Context, key string) error {
+	if h.Blobs == nil {
+		return nil
+	}
+	released, err := h.MaterialStore.ReleaseContent(key)
+	if err != nil || !released {
+		return err
+	}
+	if err := h.Blobs.Delete(ctx, key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+		log.Printf("release blob %s: %v", key, err)
+	}
+	return nil
+}
+
+// GET HANDLERS
+
+func (h *Handler) GetMaterialFiles(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	files, err := h.MaterialStore.Files(material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": mapSlice(files, newMaterialFileDTO)})
+}
+
+// DownloadMaterialFile serves a file's content, whole or in the ranges the
+// Range header asks for, or redirects to a presigned URL that does. Its
+// ETag is the content's SHA-256 digest, which Repr-Digest also carries.
+// With ?verify=true the stored content is checked against the digest
+// before it is served.
+func (h *Handler) DownloadMaterialFile(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	file, apiErr := h.materialFileParam(c, material.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	blobs, apiErr := h.blobStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if c.Query("verify") == "true" {
+		var mismatch *blob.ChecksumError
+		if err := blob.Verify(ctx, blobs, file.SHA256); errors.As(err, &mismatch) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The stored file does not match its checksum", "sha256": file.SHA256, "actual": mismatch.Actual})
+			return
+		} else if err != nil && !errors.Is(err, blob.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify file"})
+			return
+		}
+	}
+	if presigner, ok := blobs.(blob.Presigner); ok {
+		location, err := presigner.PresignGet(ctx, file.SHA256, blob.PresignOptions{
+			Expires:     presignedURLExpiry,
+			ContentType: file.ContentType,
+			Filename:    file.Filename,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download URL"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusTemporaryRedirect, location)
+		return
+	}
+	content, err := blobs.Open(ctx, file.SHA256)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "The file's content is missing from storage"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer content.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	digest, _ := hex.DecodeString(file.SHA256)
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Disposition", disposition)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", strconv.Quote(file.SHA256))
+	c.Header("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest)+":")
+	http.ServeContent(c.Writer, c.Request, file.Filename, file.CreatedAt, content)
+}
+
+// CREATE HANDLERS
+
+// UploadMaterialFile adds a file to a material. Content already stored for
+// another file is shared rather than stored again.
+func (h *Handler) UploadMaterialFile(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Material", material.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	blobs, apiErr := h.blobStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if h.Tx == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Uploading files needs transactions, which are not configured"})
+		return
+	}
+
+	// The body may exceed the file by a little, for the field headers and
+	// the checksum.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMaterialFileSize+1<<20)
+	form, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected a multipart/form-data body", "details": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	file, checksum, apiErr := h.receiveFile(ctx, blobs, form)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := checkUpload(file, checksum); apiErr != nil {
+		h.releaseContent(ctx, file.SHA256)
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	file.ID = uuid.New()
+	file.MaterialID = material.ID
+	file.CreatedAt = time.Now()
+	err = h.inTx(func(tx *Handler) error {
+		if err := tx.MaterialStore.LockContent(file.SHA256); err != nil {
+			return err
+		}
+		// The content may have been released, by a delete that held the
+		// lock first, since it was stored.
+		if _, err := blobs.Stat(ctx, file.SHA256); err != nil {
+			return err
+		}
+		return tx.MaterialStore.AddFile(&file, version)
+	})
+	if errors.Is(err, blob.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The file's content was deleted while it was uploaded; upload it again"})
+		return
+	}
+	if err != nil {
+		h.releaseContent(ctx, file.SHA256)
+		apiErr := writeError(err, "Material", "add file to")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "file": newMaterialFileDTO(file)})
+}
+
+// DELETE HANDLERS
+
+func (h *Handler) DeleteMaterialFile(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	file, apiErr := h.materialFileParam(c, material.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Material", material.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if h.Tx == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Deleting files needs transactions, which are not configured"})
+		return
+	}
+
+	// The file goes in the same transaction as its content, if no other
+	// file has it, with the content's digest locked throughout.
+	err := h.inTx(func(tx *Handler) error {
+		if err := tx.MaterialStore.LockContent(file.SHA256); err != nil {
+			return err
+		}
+		if err := tx.MaterialStore.RemoveFile(material.ID, file.ID, version); err != nil {
+			return err
+		}
+		return tx.deleteUnusedContent(c.Request.Context(), file.SHA256)
+	})
+	if err != nil {
+		apiErr := writeError(err, "File", "delete")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "File deleted successfully"})
+}
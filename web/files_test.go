@@ -0,0 +1,226 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/blob"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// contentKey returns the blob key of content.
+func contentKey(content string) string {
+	key, _, _ := blob.Key(strings.NewReader(content))
+	return key
+}
+
+// uploadForm returns a multipart body with content in the "file" field
+// and checksum, if any, in the "sha256" field, and its Content-Type.
+func uploadForm(t *testing.T, content, checksum string) (string, string) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	if checksum != "" {
+		form.WriteField("sha256", checksum)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body.String(), form.FormDataContentType()
+}
+
+// storeFile gives the fixture's material a file with content, as an
+// earlier upload would have.
+func storeFile(f *fixture, blobs *fakeBlobStore, content string) model.MaterialFile {
+	file := model.MaterialFile{
+		ID: uuid.New(), MaterialID: f.materialID, SHA256: contentKey(content), Filename: "stored.txt",
+		ContentType: "text/plain; charset=utf-8", Size: int64(len(content)), CreatedAt: time.Now(),
+	}
+	f.materials.files[file.ID] = file
+	f.materials.contents[file.SHA256] = true
+	blobs.blobs[file.SHA256] = []byte(content)
+	return file
+}
+
+func TestUploadMaterialFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   string
+		content  string
+		checksum string
+		ifMatch  string
+		released bool
+		noTx     bool
+		status   int
+		files    int
+		blobs    int
+	}{
+		{name: "stored", content: "hello", status: http.StatusOK, files: 1, blobs: 1},
+		{name: "checked against its digest", content: "hello", checksum: strings.ToUpper(contentKey("hello")), ifMatch: `"2"`, status: http.StatusOK, files: 1, blobs: 1},
+		{name: "same content stored once", stored: "hello", content: "hello", status: http.StatusOK, files: 2, blobs: 1},
+		{name: "other content stored apart", stored: "hello", content: "world", status: http.StatusOK, files: 2, blobs: 2},
+		{name: "digest mismatch", content: "hello", checksum: contentKey("world"), status: http.StatusBadRequest},
+		{name: "digest mismatch keeps shared content", stored: "hello", content: "hello", checksum: contentKey("world"), status: http.StatusBadRequest, files: 1, blobs: 1},
+		{name: "empty file", content: "", status: http.StatusBadRequest},
+		{name: "stale version", content: "hello", ifMatch: `"1"`, status: http.StatusPreconditionFailed},
+		{name: "content released while waiting for the lock", content: "hello", released: true, status: http.StatusConflict},
+		{name: "without transactions", content: "hello", noTx: true, status: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			blobs := &fakeBlobStore{blobs: map[string][]byte{}}
+			if tt.stored != "" {
+				storeFile(f, blobs, tt.stored)
+			}
+			if tt.released {
+				f.materials.onLock = func(sha256 string) { delete(blobs.blobs, sha256) }
+			}
+			h := f.handler()
+			h.Blobs = blobs
+			if !tt.noTx {
+				h.Tx = fakeTx{f}
+			}
+
+			body, contentType := uploadForm(t, tt.content, tt.checksum)
+			headers := []string{"Content-Type", contentType}
+			if tt.ifMatch != "" {
+				headers = append(headers, "If-Match", tt.ifMatch)
+			}
+			w := serve(newTestRouter(h), http.MethodPost, "/api/v1/materials/"+f.materialID.String()+"/files", body, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if len(f.materials.files) != tt.files {
+				t.Errorf("%d files, want %d", len(f.materials.files), tt.files)
+			}
+			if len(blobs.blobs) != tt.blobs {
+				t.Errorf("%d blobs stored, want %d", len(blobs.blobs), tt.blobs)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				File MaterialFileDTO `json:"file"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			key := contentKey(tt.content)
+			if resp.File.SHA256 != key || resp.File.Size != int64(len(tt.content)) || resp.File.ContentType != "text/plain; charset=utf-8" {
+				t.Errorf("file = %+v", resp.File)
+			}
+			if !f.materials.contents[key] {
+				t.Errorf("content %s was not recorded", key)
+			}
+			if v := f.materials.materials[f.materialID].Version; v != 3 {
+				t.Errorf("material version = %d, want 3", v)
+			}
+		})
+	}
+}
+
+func TestDeleteMaterialFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		shared  bool
+		file    string
+		ifMatch string
+		noTx    bool
+		status  int
+		files   int
+		kept    bool
+	}{
+		{name: "last file with the content", ifMatch: `"2"`, status: http.StatusOK},
+		{name: "content another file has", shared: true, status: http.StatusOK, files: 1, kept: true},
+		{name: "stale version", ifMatch: `"1"`, status: http.StatusPreconditionFailed, files: 1, kept: true},
+		{name: "unknown file", file: uuid.NewString(), status: http.StatusNotFound, files: 1, kept: true},
+		{name: "without transactions", noTx: true, status: http.StatusServiceUnavailable, files: 1, kept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			blobs := &fakeBlobStore{blobs: map[string][]byte{}}
+			file := storeFile(f, blobs, "hello")
+			if tt.shared {
+				storeFile(f, blobs, "hello")
+			}
+			h := f.handler()
+			h.Blobs = blobs
+			if !tt.noTx {
+				h.Tx = fakeTx{f}
+			}
+			fileID := tt.file
+			if fileID == "" {
+				fileID = file.ID.String()
+			}
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+
+			w := serve(newTestRouter(h), http.MethodDelete, "/api/v1/materials/"+f.materialID.String()+"/files/"+fileID, "", headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if len(f.materials.files) != tt.files {
+				t.Errorf("%d files left, want %d", len(f.materials.files), tt.files)
+			}
+			if _, ok := blobs.blobs[file.SHA256]; ok != tt.kept {
+				t.Errorf("content kept = %v, want %v", ok, tt.kept)
+			}
+			if f.materials.contents[file.SHA256] != tt.kept {
+				t.Errorf("content recorded = %v, want %v", f.materials.contents[file.SHA256], tt.kept)
+			}
+		})
+	}
+}
+
+func TestReleaseContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		inUse bool
+		noTx  bool
+		kept  bool
+	}{
+		{name: "unused content is deleted"},
+		{name: "content a file has is kept", inUse: true, kept: true},
+		{name: "kept without transactions", noTx: true, kept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			blobs := &fakeBlobStore{blobs: map[string][]byte{}}
+			file := storeFile(f, blobs, "hello")
+			if !tt.inUse {
+				delete(f.materials.files, file.ID)
+			}
+			h := f.handler()
+			h.Blobs = blobs
+			if !tt.noTx {
+				h.Tx = fakeTx{f}
+			}
+
+			h.releaseContent(context.Background(), file.SHA256, "")
+			if _, ok := blobs.blobs[file.SHA256]; ok != tt.kept {
+				t.Errorf("content kept = %v, want %v", ok, tt.kept)
+			}
+			if len(f.materials.locked) != 0 {
+				t.Errorf("locks %v still held", f.materials.locked)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const flashCookie = "flash"
+
+const (
+	flashSuccess = "success"
+	flashError   = "error"
+)
+
+// flash is a one-time message shown on the next page the UI renders.
+type flash struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func setFlash(c *gin.Context, kind, message string) {
+	b, _ := json.Marshal(flash{Kind: kind, Message: message})
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(flashCookie, base64.RawURLEncoding.EncodeToString(b), 0, "/ui", "", false, true)
+}
+
+// popFlash returns the pending flash message, if any, and clears it.
+func popFlash(c *gin.Context) *flash {
+	value, err := c.Cookie(flashCookie)
+	if err != nil || value == "" {
+		return nil
+	}
+	c.SetCookie(flashCookie, "", -1, "/ui", "", false, true)
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	var f flash
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil
+	}
+	return &f
+}
@@ -2,10 +2,17 @@ package web
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/arjunsaxaena/Library-Management/blob"
+	"github.com/arjunsaxaena/Library-Management/identifiers"
+	"github.com/arjunsaxaena/Library-Management/isbn"
+	"github.com/arjunsaxaena/Library-Management/metadata"
 	"github.com/arjunsaxaena/Library-Management/model"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,6 +26,37 @@ type Handler struct {
 	IssuedBookStore model.IssuedBookStore
 	SubjectStore    model.SubjectStore
 	MaterialStore   model.MaterialStore
+
+	// CardNumbers and Barcodes generate identifiers for new users and books.
+	CardNumbers identifiers.Scheme
+	Barcodes    identifiers.Scheme
+
+	// Metadata looks up books by ISBN for import. Nil disables import.
+	Metadata metadata.Provider
+
+	// Tx runs bulk imports, restores and file uploads and deletes in
+	// transactions, and binds the stores of each write request to its audit
+	// details. Nil disables them all.
+	Tx model.TxRunner
+
+	// AuditStore reads the audit log. Nil disables the audit endpoints.
+	AuditStore model.AuditStore
+
+	// VocabularyStore manages the terms book types, material types, classes
+	// and languages are drawn from. Nil disables the vocabulary endpoints.
+	VocabularyStore model.VocabularyStore
+
+	// Blobs stores the content of files uploaded to materials, on local disk
+	// or in S3. Nil disables the file endpoints.
+	Blobs blob.Store
+
+	// AdminToken guards admin operations such as purge. Empty disables them.
+	AdminToken string
+
+	desk *circulationDesk
+
+	// audit attributes the writes of a request-bound copy; see audited.
+	audit model.Audit
 }
 
 func NewHandler(
@@ -38,120 +76,257 @@ func NewHandler(
 		IssuedBookStore: ibs,
 		SubjectStore:    ss,
 		MaterialStore:   ms,
+		CardNumbers:     identifiers.DefaultPatronScheme,
+		Barcodes:        identifiers.DefaultItemScheme,
+		desk:            newCirculationDesk(),
 	}
 }
 
+// apiError is a failed operation together with the status code and JSON
+// body the API answers with. The operations behind the JSON handlers return
+// it so the web UI can reuse them and report the same errors.
+type apiError struct {
+	Status int
+	Body   gin.H
+}
+
+func newAPIError(status int, message string) *apiError {
+	return &apiError{Status: status, Body: gin.H{"error": message}}
+}
+
+func (e *apiError) Error() string {
+	message, _ := e.Body["error"].(string)
+	return message
+}
+
 // ISSUE AND RETURN HANDLERS
 
 func (h *Handler) IssueBook(c *gin.Context) {
 	fmt.Println("Received issue book request")
 
-	type IssueBookRequest struct {
-		BookID uuid.UUID `json:"book_id" binding:"required"`
-		UserID uuid.UUID `json:"user_id" binding:"required"`
+	var request IssueBookRequest
+	if apiErr := h.bindJSON(c, &request); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	var request IssueBookRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+	issuedBook, apiErr := h.issueBook(request)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	existingIssuedBook, err := h.IssuedBookStore.GetIssuedBookByBookID(request.BookID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Book issued successfully",
+		"issued_book": newIssuedBookDTO(issuedBook),
+	})
+}
+
+func (h *Handler) issueBook(request IssueBookRequest) (model.IssuedBook, *apiError) {
+	book, apiErr := h.resolveBook(request.BookID)
+	if apiErr != nil {
+		return model.IssuedBook{}, apiErr
+	}
+	user, apiErr := h.resolveUser(request.UserID)
+	if apiErr != nil {
+		return model.IssuedBook{}, apiErr
+	}
+	bookID, userID := book.ID, user.ID
+
+	existingIssuedBook, err := h.IssuedBookStore.GetIssuedBookByBookID(bookID)
 	if err == nil && existingIssuedBook.ReturnDate == nil {
-		if existingIssuedBook.UserID == request.UserID {
-			c.JSON(http.StatusConflict, gin.H{"error": "This book is already issued to you."})
-		} else {
-			c.JSON(http.StatusConflict, gin.H{"error": "This book is currently issued to another user."})
+		if existingIssuedBook.UserID == userID {
+			return model.IssuedBook{}, newAPIError(http.StatusConflict, "This book is already issued to you.")
 		}
-		return
+		return model.IssuedBook{}, newAPIError(http.StatusConflict, "This book is currently issued to another user.")
 	}
 
 	issuedBook := model.IssuedBook{
 		ID:         uuid.New(),
-		BookID:     request.BookID,
-		UserID:     request.UserID,
+		BookID:     bookID,
+		UserID:     userID,
 		IssueDate:  time.Now(),
 		ReturnDate: nil,
 	}
 
 	err = h.IssuedBookStore.CreateIssuedBook(&issuedBook)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to issue book: %s", err.Error())})
-		return
+		return model.IssuedBook{}, newAPIError(http.StatusInternalServerError, fmt.Sprintf("Failed to issue book: %s", err.Error()))
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "Book issued successfully",
-		"issued_book": issuedBook,
-	})
+	return issuedBook, nil
 }
 
 func (h *Handler) ReturnBook(c *gin.Context) {
 	fmt.Println("Received return book request")
 
-	type ReturnBookRequest struct {
-		BookID uuid.UUID `json:"book_id" binding:"required"`
-		UserID uuid.UUID `json:"user_id" binding:"required"`
+	var request ReturnBookRequest
+	if apiErr := h.bindJSON(c, &request); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	var request ReturnBookRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	issuedBook, lateFees, apiErr := h.returnBook(request)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	issuedBook, err := h.IssuedBookStore.GetIssuedBookByBookID(request.BookID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Book returned successfully.",
+		"book_id":   issuedBook.BookID,
+		"user_id":   issuedBook.UserID,
+		"late_fees": lateFees,
+	})
+}
+
+func (h *Handler) returnBook(request ReturnBookRequest) (model.IssuedBook, float64, *apiError) {
+	book, apiErr := h.resolveBook(request.BookID)
+	if apiErr != nil {
+		return model.IssuedBook{}, 0, apiErr
+	}
+	user, apiErr := h.resolveUser(request.UserID)
+	if apiErr != nil {
+		return model.IssuedBook{}, 0, apiErr
+	}
+	bookID, userID := book.ID, user.ID
+
+	issuedBook, err := h.IssuedBookStore.GetIssuedBookByBookID(bookID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "The book is not currently issued."})
-			return
+			return model.IssuedBook{}, 0, newAPIError(http.StatusNotFound, "The book is not currently issued.")
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve issued book record."})
-		return
+		return model.IssuedBook{}, 0, newAPIError(http.StatusInternalServerError, "Failed to retrieve issued book record.")
 	}
 
-	if issuedBook.UserID != request.UserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "The book was not issued to the user and cannot be returned."})
-		return
+	if issuedBook.UserID != userID {
+		return model.IssuedBook{}, 0, newAPIError(http.StatusForbidden, "The book was not issued to the user and cannot be returned.")
 	}
 
 	if issuedBook.ReturnDate != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "The book has already been returned."})
-		return
+		return model.IssuedBook{}, 0, newAPIError(http.StatusBadRequest, "The book has already been returned.")
 	}
 
-	lateFees, err := h.IssuedBookStore.ReturnBook(request.BookID)
+	lateFees, err := h.IssuedBookStore.ReturnBook(bookID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to process the book return.",
-			"details": err.Error(),
-		})
-		return
+		apiErr := newAPIError(http.StatusInternalServerError, "Failed to process the book return.")
+		apiErr.Body["details"] = err.Error()
+		return model.IssuedBook{}, 0, apiErr
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Book returned successfully.",
-		"book_id":   request.BookID,
-		"user_id":   request.UserID,
-		"late_fees": lateFees,
-	})
+	return issuedBook, lateFees, nil
 }
 
 // HELPER FUNCTIONS
 
+// inTx runs fn with a copy of the handler whose stores are bound to one
+// transaction, committing only if fn returns nil.
+func (h *Handler) inTx(fn func(tx *Handler) error) error {
+	if h.Tx == nil {
+		return errors.New("transactions are not configured")
+	}
+	return h.Tx.InTx(h.audit, func(s model.Stores) error {
+		tx := *h
+		tx.setStores(s)
+		return fn(&tx)
+	})
+}
+
+func (h *Handler) setStores(s model.Stores) {
+	h.BookStore = s.Books
+	h.AuthorStore = s.Authors
+	h.LocationStore = s.Locations
+	h.UserStore = s.Users
+	h.SubjectStore = s.Subjects
+	h.MaterialStore = s.Materials
+	h.IssuedBookStore = s.IssuedBooks
+	if h.VocabularyStore != nil {
+		h.VocabularyStore = s.Vocabularies
+	}
+}
+
+// renamedMaterials counts the materials that took on a subject's new name:
+// all those belonging to it if it was renamed, and otherwise none.
+func (h *Handler) renamedMaterials(before, after model.Subject) (int, *apiError) {
+	if before.Name == after.Name {
+		return 0, nil
+	}
+	materials, err := h.MaterialStore.MaterialsInSubject(after.ID, false)
+	if err != nil {
+		return 0, newAPIError(http.StatusInternalServerError, "Failed to fetch materials")
+	}
+	renamed := 0
+	for _, m := range materials {
+		if m.SubjectID == after.ID {
+			renamed++
+		}
+	}
+	return renamed, nil
+}
+
+// updateSubject writes subject, which replaces current, and returns it as
+// stored with the number of materials that took on its new name. The name
+// check, the write and the count happen in one transaction.
+func (h *Handler) updateSubject(current, subject model.Subject, columns ...string) (model.Subject, int, *apiError) {
+	if h.Tx == nil {
+		return model.Subject{}, 0, newAPIError(http.StatusServiceUnavailable, "Updating subjects needs transactions, which are not configured")
+	}
+
+	var updated model.Subject
+	renamed := 0
+	var failure *apiError
+	err := h.inTx(func(tx *Handler) error {
+		if failure = tx.checkSubjectName(subject.ID, subject.Name); failure != nil {
+			return failure
+		}
+		if err := tx.SubjectStore.UpdateSubject(&subject, columns...); err != nil {
+			failure = subjectWriteError(err, subject.Name, "update")
+			return failure
+		}
+		var err error
+		if updated, err = tx.SubjectStore.Subject(subject.ID); err != nil {
+			return err
+		}
+		if renamed, failure = tx.renamedMaterials(current, updated); failure != nil {
+			return failure
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return updated, renamed, nil
+	case failure != nil:
+		return model.Subject{}, 0, failure
+	}
+	return model.Subject{}, 0, newAPIError(http.StatusInternalServerError, "Failed to update subject")
+}
+
+// subjectWriteError reports a failed subject write. Subject names stay
+// taken while a subject is deleted, so a clash means the name is in the
+// trash.
+func subjectWriteError(err error, name, action string) *apiError {
+	if errors.Is(err, model.ErrDuplicate) {
+		return newAPIError(http.StatusConflict, fmt.Sprintf("Subject %q is deleted; restore it or choose another name", name))
+	}
+	if errors.Is(err, model.ErrInvalidParent) {
+		apiErr := newAPIError(http.StatusBadRequest, "Invalid parent subject")
+		apiErr.Body["fields"] = []FieldErrorDTO{{
+			Field:   "parent_id",
+			Rule:    "parent",
+			Message: "parent_id must name a subject that is not deleted and not this subject or one below it",
+		}}
+		return apiErr
+	}
+	return writeError(err, "Subject", action)
+}
+
 func (h *Handler) getOrCreateAuthor(name string) (*model.Author, error) {
 	authors, err := h.AuthorStore.Authors()
 	if err != nil {
 		return nil, err
 	}
-	for _, a := range authors {
-		if a.Name == name {
-			return &a, nil
-		}
+	if a, ok := findByName(authors, name, authorName); ok {
+		return &a, nil
 	}
 	newAuthor := model.Author{
 		ID:   uuid.New(),
@@ -168,10 +343,8 @@ func (h *Handler) getOrCreateLocation(name string) (*model.Location, error) {
 	if err != nil {
 		return nil, err
 	}
-	for _, l := range locations {
-		if l.Name == name {
-			return &l, nil
-		}
+	if l, ok := findByName(locations, name, locationName); ok {
+		return &l, nil
 	}
 	newLocation := model.Location{
 		ID:   uuid.New(),
@@ -192,24 +365,24 @@ func (h *Handler) GetIssuedBooks(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"issued_books": issuedBooks})
+	c.JSON(http.StatusOK, gin.H{"issued_books": mapSlice(issuedBooks, newIssuedBookDTO)})
 }
 
 func (h *Handler) GetIssuedBook(c *gin.Context) {
-	bookIDParam := c.Param("id")
-	bookID, err := uuid.Parse(bookIDParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+	book, apiErr := h.resolveBook(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	issuedBook, err := h.IssuedBookStore.GetIssuedBookByBookID(bookID)
+	issuedBook, err := h.IssuedBookStore.GetIssuedBookByBookID(book.ID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Issued book not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"issued_book": issuedBook})
+	setETag(c, issuedBook.Version)
+	c.JSON(http.StatusOK, gin.H{"issued_book": newIssuedBookDTO(issuedBook)})
 }
 
 func (h *Handler) GetBooks(c *gin.Context) {
@@ -219,24 +392,25 @@ func (h *Handler) GetBooks(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, books)
+	c.JSON(http.StatusOK, mapSlice(books, newBookDTO))
 }
 
 func (h *Handler) GetBook(c *gin.Context) {
-	idParam := c.Param("id")
-	bookID, err := uuid.Parse(idParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+	book, apiErr := h.resolveBook(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	book, err := h.BookStore.Book(bookID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+	contributors, apiErr := h.bookContributors(book.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
+	book.Contributors = contributors
 
-	c.JSON(http.StatusOK, book)
+	setETag(c, book.Version)
+	c.JSON(http.StatusOK, newBookDTO(book))
 }
 
 func (h *Handler) GetSubjects(c *gin.Context) {
@@ -246,7 +420,7 @@ func (h *Handler) GetSubjects(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"subjects": subjects})
+	c.JSON(http.StatusOK, gin.H{"subjects": mapSlice(subjects, newSubjectDTO)})
 }
 
 func (h *Handler) GetSubject(c *gin.Context) {
@@ -263,7 +437,8 @@ func (h *Handler) GetSubject(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"subject": subject})
+	setETag(c, subject.Version)
+	c.JSON(http.StatusOK, gin.H{"subject": newSubjectDTO(subject)})
 }
 
 func (h *Handler) GetSubjectByName(c *gin.Context) {
@@ -274,21 +449,38 @@ func (h *Handler) GetSubjectByName(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Subject not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{" error": "Failed to retrieve subject"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve subject"})
+		return
+	}
+	if subject.ID == uuid.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subject not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"subject": subject})
+	setETag(c, subject.Version)
+	c.JSON(http.StatusOK, gin.H{"subject": newSubjectDTO(subject)})
 }
 
+// GetMaterials lists materials, with ?link_status= only those whose link
+// has that status.
 func (h *Handler) GetMaterials(c *gin.Context) {
-	materials, err := h.MaterialStore.Materials()
+	var materials []model.Material
+	var err error
+	if status := c.Query("link_status"); status != "" {
+		if !slices.Contains(model.LinkStatuses, status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "link_status must be one of " + strings.Join(model.LinkStatuses, ", ")})
+			return
+		}
+		materials, err = h.MaterialStore.MaterialsByLinkStatus(status)
+	} else {
+		materials, err = h.MaterialStore.Materials()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch materials"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"materials": materials})
+	c.JSON(http.StatusOK, gin.H{"materials": mapSlice(materials, newMaterialDTO)})
 }
 
 func (h *Handler) GetMaterial(c *gin.Context) {
@@ -305,7 +497,31 @@ func (h *Handler) GetMaterial(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"material": material})
+	setETag(c, material.Version)
+	c.JSON(http.StatusOK, gin.H{"material": newMaterialDTO(material)})
+}
+
+// GetMaterialLinkCheck reports the outcome of the last check of a
+// material's link.
+func (h *Handler) GetMaterialLinkCheck(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if material.Link == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material has no link"})
+		return
+	}
+
+	check, err := h.MaterialStore.LinkCheck(material.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		check = model.LinkCheck{MaterialID: material.ID, URL: material.Link, Status: model.LinkStatusUnchecked}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch link check"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"link_check": newLinkCheckDTO(check)})
 }
 
 func (h *Handler) GetMaterialsBySubject(c *gin.Context) {
@@ -315,7 +531,7 @@ func (h *Handler) GetMaterialsBySubject(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch materials by subject"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"materials": materials})
+	c.JSON(http.StatusOK, gin.H{"materials": mapSlice(materials, newMaterialDTO)})
 }
 
 func (h *Handler) GetMaterialsByLanguage(c *gin.Context) {
@@ -325,7 +541,7 @@ func (h *Handler) GetMaterialsByLanguage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch materials by language"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"materials": materials})
+	c.JSON(http.StatusOK, gin.H{"materials": mapSlice(materials, newMaterialDTO)})
 }
 
 func (h *Handler) GetAuthors(c *gin.Context) {
@@ -335,7 +551,7 @@ func (h *Handler) GetAuthors(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, authors)
+	c.JSON(http.StatusOK, mapSlice(authors, newAuthorDTO))
 }
 
 func (h *Handler) GetAuthor(c *gin.Context) {
@@ -352,7 +568,8 @@ func (h *Handler) GetAuthor(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, author)
+	setETag(c, author.Version)
+	c.JSON(http.StatusOK, newAuthorDTO(author))
 }
 
 func (h *Handler) GetLocations(c *gin.Context) {
@@ -362,16 +579,12 @@ func (h *Handler) GetLocations(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, locations)
+	c.JSON(http.StatusOK, mapSlice(locations, newLocationDTO))
 }
 
 func (h *Handler) GetLocation(c *gin.Context) {
 	fmt.Println("Received get location request")
 
-	type GetLocationResponse struct {
-		Location model.Location `json:"location"`
-	}
-
 	idParam := c.Param("id")
 	locationID, err := uuid.Parse(idParam)
 	if err != nil {
@@ -385,7 +598,8 @@ func (h *Handler) GetLocation(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, GetLocationResponse{Location: location})
+	setETag(c, location.Version)
+	c.JSON(http.StatusOK, gin.H{"location": newLocationDTO(location)})
 }
 
 func (h *Handler) GetUsers(c *gin.Context) {
@@ -395,203 +609,250 @@ func (h *Handler) GetUsers(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, mapSlice(users, newUserDTO))
 }
 
 func (h *Handler) GetUser(c *gin.Context) {
 	fmt.Println("Received get user request")
 
-	type GetUserResponse struct {
-		User model.User `json:"user"`
-	}
-
-	idParam := c.Param("id")
-	userID, err := uuid.Parse(idParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+	user, apiErr := h.resolveUser(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	user, err := h.UserStore.User(userID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, GetUserResponse{User: user})
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, gin.H{"user": newUserDTO(user)})
 }
 
 // CREATE HANDLERS
 
 func (h *Handler) CreateBook(c *gin.Context) {
-	type CreateBookRequest struct {
-		Title        string `json:"title"`
-		AuthorName   string `json:"author_name"`
-		LocationName string `json:"location_name"`
-		BookType     string `json:"book_type" binding:"required"`
-	}
-
 	var req CreateBookRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	existingBooks, err := h.BookStore.Books()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing books"})
+	newBook, apiErr := h.createBook(req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	for _, book := range existingBooks {
-		if book.Title == req.Title {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":   "A book with the same title already exists",
-				"book_id": book.ID,
-			})
-			return
+	c.JSON(http.StatusOK, gin.H{"message": "Book created successfully", "book": newBookDTO(newBook)})
+}
+
+// checkNewBook runs the duplicate checks of createBook without creating
+// anything, and returns the parsed ISBN.
+func (h *Handler) checkNewBook(req CreateBookRequest) (*isbn.ISBN, *apiError) {
+	if _, apiErr := contributorRequests(req.AuthorName, req.Contributors); apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Different editions may share a title, so titles are only compared
+	// when there is no ISBN to go by.
+	number, apiErr := h.bookISBN(req.ISBN, uuid.Nil)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if number == nil {
+		existingBooks, err := h.BookStore.Books()
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to check existing books")
+		}
+		for _, book := range existingBooks {
+			if book.Title == req.Title && book.ISBN13 == nil {
+				apiErr := newAPIError(http.StatusConflict, "A book with the same title already exists")
+				apiErr.Body["book_id"] = book.ID
+				return nil, apiErr
+			}
 		}
 	}
 
+	if req.Barcode != "" {
+		if _, apiErr := h.checkBarcode(req.Barcode); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+	return number, nil
+}
+
+func (h *Handler) createBook(req CreateBookRequest) (model.Book, *apiError) {
+	number, apiErr := h.checkNewBook(req)
+	if apiErr != nil {
+		return model.Book{}, apiErr
+	}
+
 	newUUID := uuid.New()
 
-	for _, book := range existingBooks {
-		if book.ID == newUUID {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":   "A book with the same UUID already exists",
-				"book_id": book.ID,
-			})
-			return
-		}
+	barcode, apiErr := h.barcodeFor(req.Barcode)
+	if apiErr != nil {
+		return model.Book{}, apiErr
 	}
 
-	author, err := h.getOrCreateAuthor(req.AuthorName)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create or find author"})
-		return
+	reqs, apiErr := contributorRequests(req.AuthorName, req.Contributors)
+	if apiErr != nil {
+		return model.Book{}, apiErr
+	}
+	contributors, apiErr := h.resolveContributors(reqs)
+	if apiErr != nil {
+		return model.Book{}, apiErr
 	}
 
 	location, err := h.getOrCreateLocation(req.LocationName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create or find location"})
-		return
+		return model.Book{}, newAPIError(http.StatusInternalServerError, "Failed to create or find location")
 	}
 
 	newBook := model.Book{
 		ID:           newUUID,
 		Title:        req.Title,
-		AuthorID:     author.ID,
+		Contributors: contributors,
 		LocationID:   location.ID,
 		IsCheckedOut: false,
 		BookType:     req.BookType,
 		CreatedAt:    time.Now(),
+		Barcode:      &barcode,
+
+		Publisher:       optionalString(req.Publisher),
+		PublicationYear: optionalInt(req.PublicationYear),
+		PageCount:       optionalInt(req.PageCount),
+		CoverURL:        optionalString(req.CoverURL),
 	}
+	setISBN(&newBook, number)
 
 	if err := h.BookStore.CreateBook(&newBook); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create book"})
-		return
+		return model.Book{}, writeError(err, "Book", "create")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Book created successfully", "book": newBook})
+	return newBook, nil
 }
 
 func (h *Handler) CreateSubject(c *gin.Context) {
-	type CreateSubjectRequest struct {
-		Name     string `json:"name" binding:"required"`
-		Language string `json:"language" binding:"required"`
+	var req CreateSubjectRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	var req CreateSubjectRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	newSubject, apiErr := h.createSubject(req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Subject created successfully", "subject": newSubjectDTO(newSubject)})
+}
+
+// checkNewSubject runs the duplicate check of createSubject without
+// creating anything.
+func (h *Handler) checkNewSubject(req CreateSubjectRequest) *apiError {
+	return h.checkSubjectName(uuid.Nil, req.Name)
+}
+
+// checkSubjectName answers a name that a live subject other than the one
+// with id already has.
+func (h *Handler) checkSubjectName(id uuid.UUID, name string) *apiError {
 	existingSubjects, err := h.SubjectStore.Subjects()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing subjects"})
-		return
+		return newAPIError(http.StatusInternalServerError, "Failed to check existing subjects")
 	}
 
 	for _, subject := range existingSubjects {
-		if subject.Name == req.Name {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":      "A subject with the same name already exists",
-				"subject_id": subject.ID,
-			})
-			return
+		if subject.Name == name && subject.ID != id {
+			apiErr := newAPIError(http.StatusConflict, "A subject with the same name already exists")
+			apiErr.Body["subject_id"] = subject.ID
+			return apiErr
 		}
 	}
+	return nil
+}
+
+func (h *Handler) createSubject(req CreateSubjectRequest) (model.Subject, *apiError) {
+	if apiErr := h.checkNewSubject(req); apiErr != nil {
+		return model.Subject{}, apiErr
+	}
 
 	newSubject := model.Subject{
 		ID:       uuid.New(),
+		ParentID: req.ParentID,
 		Name:     req.Name,
 		Language: req.Language,
 	}
 
 	if err := h.SubjectStore.CreateSubject(&newSubject); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subject"})
-		return
+		return model.Subject{}, subjectWriteError(err, newSubject.Name, "create")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Subject created successfully", "subject": newSubject})
+	return newSubject, nil
 }
 
 func (h *Handler) CreateMaterial(c *gin.Context) {
-	type CreateMaterialRequest struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description"`
-		Notes       string `json:"notes"`
-		Type        string `json:"type" binding:"required"`
-		Link        string `json:"link"`
-		Language    string `json:"language" binding:"required"`
-		SubjectName string `json:"subject_name" binding:"required"`
+	var req CreateMaterialRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	var req CreateMaterialRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	newMaterial, apiErr := h.createMaterial(req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Material created successfully", "material": newMaterialDTO(newMaterial)})
+}
+
+// checkNewMaterial runs the duplicate check of createMaterial without
+// creating anything.
+func (h *Handler) checkNewMaterial(req CreateMaterialRequest) *apiError {
 	existingMaterials, err := h.MaterialStore.Materials()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing materials"})
-		return
+		return newAPIError(http.StatusInternalServerError, "Failed to check existing materials")
 	}
 
 	for _, material := range existingMaterials {
 		if material.Title == req.Title {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":       "A material with the same title already exists",
-				"material_id": material.ID,
-			})
-			return
+			apiErr := newAPIError(http.StatusConflict, "A material with the same title already exists")
+			apiErr.Body["material_id"] = material.ID
+			return apiErr
 		}
 	}
+	return nil
+}
 
-	subject, err := h.SubjectStore.SubjectByName(req.SubjectName)
+// materialSubject finds the subject a material names, creating it in the
+// material's language if there is none.
+func (h *Handler) materialSubject(name, language string) (model.Subject, *apiError) {
+	subject, err := h.SubjectStore.SubjectByName(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing subjects"})
-		return
+		return model.Subject{}, newAPIError(http.StatusInternalServerError, "Failed to check existing subjects")
+	}
+	if subject.ID != uuid.Nil {
+		return subject, nil
 	}
 
-	if subject.ID == uuid.Nil {
-		// Create a new subject
-		newSubject := model.Subject{
-			ID:        uuid.New(),
-			Name:      req.SubjectName,
-			Language:  req.Language,
-			CreatedAt: time.Now(),
-		}
+	subject = model.Subject{
+		ID:        uuid.New(),
+		Name:      name,
+		Language:  language,
+		CreatedAt: time.Now(),
+	}
+	if err := h.SubjectStore.CreateSubject(&subject); err != nil {
+		return model.Subject{}, subjectWriteError(err, subject.Name, "create")
+	}
+	return subject, nil
+}
 
-		if err := h.SubjectStore.CreateSubject(&newSubject); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subject"})
-			return
-		}
-		req.SubjectName = newSubject.Name
-	} else {
-		req.SubjectName = subject.Name
+func (h *Handler) createMaterial(req CreateMaterialRequest) (model.Material, *apiError) {
+	if apiErr := h.checkNewMaterial(req); apiErr != nil {
+		return model.Material{}, apiErr
+	}
+
+	subject, apiErr := h.materialSubject(req.SubjectName, req.Language)
+	if apiErr != nil {
+		return model.Material{}, apiErr
 	}
 
 	newMaterial := model.Material{
@@ -602,90 +863,115 @@ func (h *Handler) CreateMaterial(c *gin.Context) {
 		Type:        req.Type,
 		Link:        req.Link,
 		Language:    req.Language,
-		SubjectName: req.SubjectName,
+		SubjectID:   subject.ID,
+		SubjectName: subject.Name,
 		CreatedAt:   time.Now(),
 	}
 
 	if err := h.MaterialStore.CreateMaterial(&newMaterial); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create material"})
-		return
+		return model.Material{}, writeError(err, "Material", "create")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Material created successfully", "material": newMaterial})
+	return newMaterial, nil
 }
 
 func (h *Handler) CreateUser(c *gin.Context) {
 	fmt.Println("Received create user request")
 
-	type CreateUserRequest struct {
-		Name  string `json:"name"`
-		Class string `json:"class" binding:"required"`
+	var req CreateUserRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	var req CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	newUser, apiErr := h.createUser(req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "User created successfully", "user": newUserDTO(newUser)})
+}
+
+// checkNewUser runs the duplicate checks of createUser without creating
+// anything.
+func (h *Handler) checkNewUser(req CreateUserRequest) *apiError {
 	existingUsers, err := h.UserStore.Users()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing users"})
-		return
+		return newAPIError(http.StatusInternalServerError, "Failed to check existing users")
 	}
 
 	for _, user := range existingUsers {
 		if user.Name == req.Name {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":   "A user with the same name already exists",
-				"user_id": user.ID,
-			})
-			return
+			apiErr := newAPIError(http.StatusConflict, "A user with the same name already exists")
+			apiErr.Body["user_id"] = user.ID
+			return apiErr
 		}
 	}
 
+	if req.CardNumber != "" {
+		if _, apiErr := h.cardNumberFor(req.CardNumber); apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+func (h *Handler) createUser(req CreateUserRequest) (model.User, *apiError) {
+	if apiErr := h.checkNewUser(req); apiErr != nil {
+		return model.User{}, apiErr
+	}
+
+	cardNumber, apiErr := h.cardNumberFor(req.CardNumber)
+	if apiErr != nil {
+		return model.User{}, apiErr
+	}
+
 	newUUID := uuid.New()
 
 	newUser := model.User{
-		ID:    newUUID,
-		Name:  req.Name,
-		Class: req.Class,
+		ID:         newUUID,
+		Name:       req.Name,
+		Class:      req.Class,
+		CardNumber: &cardNumber,
 	}
 
 	if err := h.UserStore.CreateUser(&newUser); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
+		return model.User{}, writeError(err, "User", "create")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User created successfully", "user": newUser})
+	return newUser, nil
 }
 
 func (h *Handler) CreateLocation(c *gin.Context) {
 	fmt.Println("Received create location request")
 
-	type CreateLocationRequest struct {
-		Name string `json:"name"`
+	var req CreateLocationRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	var req CreateLocationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	newLocation, apiErr := h.createLocation(req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Location created successfully", "location": newLocationDTO(newLocation)})
+}
+
+func (h *Handler) createLocation(req CreateLocationRequest) (model.Location, *apiError) {
 	existingLocations, err := h.LocationStore.Locations()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing locations"})
-		return
+		return model.Location{}, newAPIError(http.StatusInternalServerError, "Failed to check existing locations")
 	}
 
 	for _, location := range existingLocations {
 		if location.Name == req.Name {
-			c.JSON(http.StatusConflict, gin.H{
-				"error":       "A location with the same name already exists",
-				"location_id": location.ID,
-			})
-			return
+			apiErr := newAPIError(http.StatusConflict, "A location with the same name already exists")
+			apiErr.Body["location_id"] = location.ID
+			return model.Location{}, apiErr
 		}
 	}
 
@@ -695,23 +981,18 @@ func (h *Handler) CreateLocation(c *gin.Context) {
 	}
 
 	if err := h.LocationStore.CreateLocation(&newLocation); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create location"})
-		return
+		return model.Location{}, newAPIError(http.StatusInternalServerError, "Failed to create location")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Location created successfully", "location": newLocation})
+	return newLocation, nil
 }
 
 func (h *Handler) CreateAuthor(c *gin.Context) {
 	fmt.Println("Received create author request")
 
-	type CreateAuthorRequest struct {
-		Name string `json:"name"`
-	}
-
 	var req CreateAuthorRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
@@ -741,7 +1022,7 @@ func (h *Handler) CreateAuthor(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Author created successfully", "author": newAuthor})
+	c.JSON(http.StatusOK, gin.H{"message": "Author created successfully", "author": newAuthorDTO(newAuthor)})
 }
 
 // UPDATE HANDLERS
@@ -753,21 +1034,62 @@ func (h *Handler) UpdateBook(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 		return
 	}
+	version, apiErr := h.ifMatch(c, "Book", bookID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req UpdateBookRequest
+	stored := func() (termRequest, error) {
+		book, err := h.BookStore.Book(bookID)
+		return newUpdateBookRequest(book, nil), err
+	}
+	if apiErr := h.bindReplacing(c, &req, stored); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
 
-	var book model.Book
-	if err := c.ShouldBindJSON(&book); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	number, apiErr := h.bookISBN(req.ISBN, bookID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	book.ID = bookID
+	book := req.toModel(bookID)
+	book.Version = version
+	setISBN(&book, number)
+
+	if len(req.Contributors) > 0 {
+		reqs, apiErr := contributorRequests("", req.Contributors)
+		if apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+		if book.Contributors, apiErr = h.resolveContributors(reqs); apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
 
 	if err := h.BookStore.UpdateBook(&book); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
+		apiErr := writeError(err, "Book", "update")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	updated, err := h.BookStore.Book(bookID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if updated.Contributors, apiErr = h.bookContributors(bookID); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Book updated successfully", "book": book})
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Book updated successfully", "book": newBookDTO(updated)})
 }
 
 func (h *Handler) UpdateSubject(c *gin.Context) {
@@ -777,28 +1099,39 @@ func (h *Handler) UpdateSubject(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject ID"})
 		return
 	}
+	version, apiErr := h.ifMatch(c, "Subject", subjectID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
 
-	var req struct {
-		Name     string `json:"name" binding:"required"`
-		Language string `json:"language" binding:"required"`
+	var req UpdateSubjectRequest
+	stored := func() (termRequest, error) {
+		subject, err := h.SubjectStore.Subject(subjectID)
+		return UpdateSubjectRequest{Name: subject.Name, Language: subject.Language, ParentID: subject.ParentID}, err
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+	if apiErr := h.bindReplacing(c, &req, stored); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	subject := model.Subject{
-		ID:       subjectID,
-		Name:     req.Name,
-		Language: req.Language,
+	current, err := h.SubjectStore.Subject(subjectID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Subject")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
+	subject := req.toModel(subjectID)
+	subject.Version = version
 
-	if err := h.SubjectStore.UpdateSubject(&subject); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subject"})
+	updated, renamed, apiErr := h.updateSubject(current, subject)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Subject updated successfully", "subject": subject})
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Subject updated successfully", "subject": newSubjectDTO(updated), "materials_affected": renamed})
 }
 
 func (h *Handler) UpdateMaterial(c *gin.Context) {
@@ -808,40 +1141,45 @@ func (h *Handler) UpdateMaterial(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
 		return
 	}
-
-	var req struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description"`
-		Notes       string `json:"notes"`
-		Type        string `json:"type" binding:"required"`
-		Link        string `json:"link"`
-		Language    string `json:"language" binding:"required"`
-		SubjectName string `json:"subject_name" binding:"required"`
+	version, apiErr := h.ifMatch(c, "Material", materialID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+	var req UpdateMaterialRequest
+	stored := func() (termRequest, error) {
+		material, err := h.MaterialStore.Material(materialID)
+		return newUpdateMaterialRequest(material), err
+	}
+	if apiErr := h.bindReplacing(c, &req, stored); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	material := model.Material{
-		ID:          materialID,
-		Title:       req.Title,
-		Description: req.Description,
-		Notes:       req.Notes,
-		Type:        req.Type,
-		Link:        req.Link,
-		Language:    req.Language,
-		SubjectName: req.SubjectName,
-		CreatedAt:   time.Now(), // Assuming CreatedAt is updated in the handler
+	subject, apiErr := h.materialSubject(req.SubjectName, req.Language)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
 	}
+	material := req.toModel(materialID)
+	material.SubjectID = subject.ID
+	material.Version = version
 
 	if err := h.MaterialStore.UpdateMaterial(&material); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material"})
+		apiErr := writeError(err, "Material", "update")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	updated, err := h.MaterialStore.Material(materialID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Material updated successfully", "material": material})
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Material updated successfully", "material": newMaterialDTO(updated)})
 }
 
 func (h *Handler) UpdateUser(c *gin.Context) {
@@ -851,21 +1189,39 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
+	version, apiErr := h.ifMatch(c, "User", userID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
 
-	var user model.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	var req UpdateUserRequest
+	stored := func() (termRequest, error) {
+		user, err := h.UserStore.User(userID)
+		return UpdateUserRequest{Name: user.Name, Class: user.Class}, err
+	}
+	if apiErr := h.bindReplacing(c, &req, stored); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	user.ID = userID
+	user := req.toModel(userID)
+	user.Version = version
 
 	if err := h.UserStore.UpdateUser(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		apiErr := writeError(err, "User", "update")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	updated, err := h.UserStore.User(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": user})
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": newUserDTO(updated)})
 }
 
 func (h *Handler) UpdateLocation(c *gin.Context) {
@@ -875,21 +1231,35 @@ func (h *Handler) UpdateLocation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
 		return
 	}
+	version, apiErr := h.ifMatch(c, "Location", locationID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
 
-	var location model.Location
-	if err := c.ShouldBindJSON(&location); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	var req UpdateLocationRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	location.ID = locationID
+	location := req.toModel(locationID)
+	location.Version = version
 
 	if err := h.LocationStore.UpdateLocation(&location); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
+		apiErr := writeError(err, "Location", "update")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	updated, err := h.LocationStore.Location(locationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Location updated successfully", "location": location})
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Location updated successfully", "location": newLocationDTO(updated)})
 }
 
 func (h *Handler) UpdateAuthor(c *gin.Context) {
@@ -899,117 +1269,231 @@ func (h *Handler) UpdateAuthor(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
 		return
 	}
+	version, apiErr := h.ifMatch(c, "Author", authorID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
 
-	var author model.Author
-	if err := c.ShouldBindJSON(&author); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	var req UpdateAuthorRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	author.ID = authorID
+	author := req.toModel(authorID)
+	author.Version = version
 
 	if err := h.AuthorStore.UpdateAuthor(&author); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update author"})
+		apiErr := writeError(err, "Author", "update")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	updated, err := h.AuthorStore.Author(authorID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Author updated successfully", "author": author})
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Author updated successfully", "author": newAuthorDTO(updated)})
 }
 
 // DELETE HANDLERS
 
+// Deletes are soft: see trash.go for what blocks them and how records are
+// restored or purged.
+
 func (h *Handler) DeleteBook(c *gin.Context) {
-	idParam := c.Param("id")
-	bookID, err := uuid.Parse(idParam)
+	bookID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 		return
 	}
-
-	if err := h.BookStore.DeleteBook(bookID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete book"})
+	version, apiErr := h.ifMatch(c, "Book", bookID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := h.deleteBook(c.Param("id"), version); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Book deleted successfully"})
 }
 
+func (h *Handler) deleteBook(idParam string, version int) *apiError {
+	return h.softDelete("books", idParam, version)
+}
+
 func (h *Handler) DeleteUser(c *gin.Context) {
-	idParam := c.Param("id")
-	userID, err := uuid.Parse(idParam)
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
-
-	if err := h.UserStore.DeleteUser(userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+	version, apiErr := h.ifMatch(c, "User", userID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := h.deleteUser(c.Param("id"), version); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+func (h *Handler) deleteUser(idParam string, version int) *apiError {
+	return h.softDelete("users", idParam, version)
+}
+
 func (h *Handler) DeleteLocation(c *gin.Context) {
-	idParam := c.Param("id")
-	locationID, err := uuid.Parse(idParam)
+	locationID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
 		return
 	}
-
-	if err := h.LocationStore.DeleteLocation(locationID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+	version, apiErr := h.ifMatch(c, "Location", locationID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := h.deleteLocation(c.Param("id"), version); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Location deleted successfully"})
 }
 
+func (h *Handler) deleteLocation(idParam string, version int) *apiError {
+	return h.softDelete("locations", idParam, version)
+}
+
 func (h *Handler) DeleteAuthor(c *gin.Context) {
-	idParam := c.Param("id")
-	authorID, err := uuid.Parse(idParam)
+	authorID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
 		return
 	}
-
-	if err := h.AuthorStore.DeleteAuthor(authorID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete author"})
+	version, apiErr := h.ifMatch(c, "Author", authorID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := h.deleteAuthor(c.Param("id"), version); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Author deleted successfully"})
 }
 
+func (h *Handler) deleteAuthor(idParam string, version int) *apiError {
+	return h.softDelete("authors", idParam, version)
+}
+
 func (h *Handler) DeleteMaterial(c *gin.Context) {
-	idParam := c.Param("id")
-	materialID, err := uuid.Parse(idParam)
+	materialID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
 		return
 	}
-
-	if err := h.MaterialStore.DeleteMaterial(materialID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete material"})
+	version, apiErr := h.ifMatch(c, "Material", materialID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := h.deleteMaterial(c.Param("id"), version); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Material deleted successfully"})
 }
 
+func (h *Handler) deleteMaterial(idParam string, version int) *apiError {
+	return h.softDelete("materials", idParam, version)
+}
+
+// DeleteSubject deletes a subject. A subject that materials belong to
+// cannot be deleted, unless ?reassign_to= names a subject to move them to
+// first.
 func (h *Handler) DeleteSubject(c *gin.Context) {
-	idParam := c.Param("id")
-	subjectID, err := uuid.Parse(idParam)
+	subjectID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject ID"})
 		return
 	}
+	version, apiErr := h.ifMatch(c, "Subject", subjectID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
 
-	if err := h.SubjectStore.DeleteSubject(subjectID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subject"})
+	reassigned := 0
+	if target := c.Query("reassign_to"); target != "" {
+		reassigned, apiErr = h.reassignAndDeleteSubject(c.Param("id"), target, version)
+	} else {
+		apiErr = h.deleteSubject(c.Param("id"), version)
+	}
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Subject deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Subject deleted successfully", "materials_affected": reassigned})
+}
+
+func (h *Handler) deleteSubject(idParam string, version int) *apiError {
+	return h.softDelete("subjects", idParam, version)
+}
+
+// reassignAndDeleteSubject moves the subject's materials to the target
+// subject and deletes it, all or nothing, and returns how many materials
+// moved.
+func (h *Handler) reassignAndDeleteSubject(idParam, targetParam string, version int) (int, *apiError) {
+	subjectID, err := uuid.Parse(idParam)
+	if err != nil {
+		return 0, newAPIError(http.StatusBadRequest, "Invalid subject ID")
+	}
+	targetID, err := uuid.Parse(targetParam)
+	if err != nil {
+		return 0, newAPIError(http.StatusBadRequest, "Invalid reassign_to subject ID")
+	}
+	if targetID == subjectID {
+		return 0, newAPIError(http.StatusBadRequest, "reassign_to must name another subject")
+	}
+	if h.Tx == nil {
+		return 0, newAPIError(http.StatusServiceUnavailable, "Reassigning needs transactions, which are not configured")
+	}
+
+	moved := 0
+	var failure *apiError
+	err = h.inTx(func(tx *Handler) error {
+		if _, err := tx.SubjectStore.Subject(targetID); err != nil {
+			if failure = lookupAPIError(err, "Subject"); failure.Status == http.StatusNotFound {
+				failure = newAPIError(http.StatusBadRequest, "reassign_to must name a subject that is not deleted")
+			}
+			return failure
+		}
+		if moved, err = tx.MaterialStore.ReassignMaterials(subjectID, targetID); err != nil {
+			return err
+		}
+		if failure = tx.deleteSubject(idParam, version); failure != nil {
+			return failure
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return moved, nil
+	case failure != nil:
+		return 0, failure
+	}
+	return 0, newAPIError(http.StatusInternalServerError, "Failed to reassign materials")
 }
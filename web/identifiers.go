@@ -0,0 +1,155 @@
+package web
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/identifiers"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// HELPER FUNCTIONS
+
+// resolveUser finds a patron by UUID or by library card number.
+func (h *Handler) resolveUser(ref string) (model.User, *apiError) {
+	ref = strings.TrimSpace(ref)
+
+	var user model.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = h.UserStore.User(id)
+	} else {
+		cardNumber := identifiers.Normalize(ref)
+		if identifiers.Validate(cardNumber) != nil {
+			return model.User{}, newAPIError(http.StatusBadRequest, "Invalid user ID or card number")
+		}
+		user, err = h.UserStore.UserByCardNumber(cardNumber)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.User{}, newAPIError(http.StatusNotFound, "User not found")
+		}
+		return model.User{}, newAPIError(http.StatusInternalServerError, "Failed to retrieve user")
+	}
+	return user, nil
+}
+
+// resolveBook finds a book by UUID or by item barcode.
+func (h *Handler) resolveBook(ref string) (model.Book, *apiError) {
+	ref = strings.TrimSpace(ref)
+
+	var book model.Book
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		book, err = h.BookStore.Book(id)
+	} else {
+		barcode := identifiers.Normalize(ref)
+		if identifiers.Validate(barcode) != nil {
+			return model.Book{}, newAPIError(http.StatusBadRequest, "Invalid book ID or barcode")
+		}
+		book, err = h.BookStore.BookByBarcode(barcode)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Book{}, newAPIError(http.StatusNotFound, "Book not found")
+		}
+		return model.Book{}, newAPIError(http.StatusInternalServerError, "Failed to retrieve book")
+	}
+	return book, nil
+}
+
+// cardNumberFor returns the validated card number supplied with a new user,
+// or generates the next one from the patron scheme.
+func (h *Handler) cardNumberFor(requested string) (string, *apiError) {
+	if requested != "" {
+		cardNumber := identifiers.Normalize(requested)
+		if err := identifiers.Validate(cardNumber); err != nil {
+			return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid card number: %v", err))
+		}
+		if existing, err := h.UserStore.UserByCardNumber(cardNumber); err == nil {
+			apiErr := newAPIError(http.StatusConflict, "A user with the same card number already exists")
+			apiErr.Body["user_id"] = existing.ID
+			return "", apiErr
+		}
+		return cardNumber, nil
+	}
+
+	seq, err := h.UserStore.NextCardSequence()
+	if err != nil {
+		return "", newAPIError(http.StatusInternalServerError, "Failed to generate card number")
+	}
+	cardNumber, err := h.CardNumbers.Format(seq)
+	if err != nil {
+		return "", newAPIError(http.StatusInternalServerError, fmt.Sprintf("Failed to generate card number: %v", err))
+	}
+	return cardNumber, nil
+}
+
+// checkBarcode normalizes and validates a barcode supplied for a new book
+// and checks that no book has it yet.
+func (h *Handler) checkBarcode(requested string) (string, *apiError) {
+	barcode := identifiers.Normalize(requested)
+	if err := identifiers.Validate(barcode); err != nil {
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid barcode: %v", err))
+	}
+	if existing, err := h.BookStore.BookByBarcode(barcode); err == nil {
+		apiErr := newAPIError(http.StatusConflict, "A book with the same barcode already exists")
+		apiErr.Body["book_id"] = existing.ID
+		return "", apiErr
+	}
+	return barcode, nil
+}
+
+// barcodeFor returns the validated barcode supplied with a new book, or
+// generates the next one from the item scheme.
+func (h *Handler) barcodeFor(requested string) (string, *apiError) {
+	if requested != "" {
+		return h.checkBarcode(requested)
+	}
+
+	seq, err := h.BookStore.NextBarcodeSequence()
+	if err != nil {
+		return "", newAPIError(http.StatusInternalServerError, "Failed to generate barcode")
+	}
+	barcode, err := h.Barcodes.Format(seq)
+	if err != nil {
+		return "", newAPIError(http.StatusInternalServerError, fmt.Sprintf("Failed to generate barcode: %v", err))
+	}
+	return barcode, nil
+}
+
+// AssignMissingIdentifiers gives card numbers and barcodes to users and
+// books created before identifiers existed.
+func (h *Handler) AssignMissingIdentifiers() error {
+	users, err := h.UserStore.UsersWithoutCardNumber()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		cardNumber, apiErr := h.cardNumberFor("")
+		if apiErr != nil {
+			return apiErr
+		}
+		if err := h.UserStore.SetCardNumber(u.ID, cardNumber); err != nil {
+			return err
+		}
+	}
+
+	books, err := h.BookStore.BooksWithoutBarcode()
+	if err != nil {
+		return err
+	}
+	for _, b := range books {
+		barcode, apiErr := h.barcodeFor("")
+		if apiErr != nil {
+			return apiErr
+		}
+		if err := h.BookStore.SetBarcode(b.ID, barcode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/arjunsaxaena/Library-Management/isbn"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HELPER FUNCTIONS
+
+// bookISBN parses the ISBN given for book self and checks that no other
+// book has the same edition. An empty ISBN returns nil.
+func (h *Handler) bookISBN(raw string, self uuid.UUID) (*isbn.ISBN, *apiError) {
+	if raw == "" {
+		return nil, nil
+	}
+	number, err := isbn.Parse(raw)
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid ISBN: %v", err))
+	}
+
+	existing, err := h.BookStore.BookByISBN(number.ISBN13)
+	switch {
+	case err == nil && existing.ID != self:
+		apiErr := newAPIError(http.StatusConflict, "A book with the same ISBN already exists")
+		apiErr.Body["book_id"] = existing.ID
+		return nil, apiErr
+	case err != nil && err != sql.ErrNoRows:
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to check existing books")
+	}
+	return &number, nil
+}
+
+func setISBN(b *model.Book, number *isbn.ISBN) {
+	b.ISBN10, b.ISBN13 = nil, nil
+	if number == nil {
+		return
+	}
+	b.ISBN13 = &number.ISBN13
+	if number.ISBN10 != "" {
+		b.ISBN10 = &number.ISBN10
+	}
+}
+
+// GET HANDLERS
+
+func (h *Handler) GetBookByISBN(c *gin.Context) {
+	number, err := isbn.Parse(c.Param("isbn"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid ISBN: %v", err)})
+		return
+	}
+
+	book, err := h.BookStore.BookByISBN(number.ISBN13)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve book"})
+		return
+	}
+
+	setETag(c, book.Version)
+	c.JSON(http.StatusOK, newBookDTO(book))
+}
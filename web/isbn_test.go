@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetBookByISBN(t *testing.T) {
+	f := newFixture()
+	r := newTestRouter(f.handler())
+
+	tests := []struct {
+		name   string
+		isbn   string
+		status int
+	}{
+		{"ISBN-13", "9780306406157", http.StatusOK},
+		{"ISBN-10 of the same edition", "0306406152", http.StatusOK},
+		{"hyphenated", "978-0-306-40615-7", http.StatusOK},
+		{"unknown edition", "9780804429573", http.StatusNotFound},
+		{"bad checksum", "9780306406158", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/api/v1/books/isbn/"+tt.isbn, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && w.Header().Get("ETag") != `"3"` {
+				t.Errorf("ETag = %q, want \"3\"", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestBookISBN(t *testing.T) {
+	f := newFixture()
+	h := f.handler()
+
+	tests := []struct {
+		name   string
+		raw    string
+		self   uuid.UUID
+		status int
+		isbn10 string
+	}{
+		{"no ISBN", "", uuid.New(), 0, ""},
+		{"new edition", "080442957X", uuid.New(), 0, "080442957X"},
+		{"the book's own edition", "0306406152", f.bookID, 0, "0306406152"},
+		{"another book's edition", "0306406152", uuid.New(), http.StatusConflict, ""},
+		{"979 edition", "9791090636071", uuid.New(), 0, ""},
+		{"invalid", "12345", uuid.New(), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, apiErr := h.bookISBN(tt.raw, tt.self)
+			if tt.status != 0 {
+				if apiErr == nil || apiErr.Status != tt.status {
+					t.Fatalf("bookISBN = %v, want status %d", apiErr, tt.status)
+				}
+				return
+			}
+			if apiErr != nil {
+				t.Fatal(apiErr)
+			}
+			if tt.raw == "" {
+				if number != nil {
+					t.Errorf("bookISBN = %+v, want nil", number)
+				}
+				return
+			}
+			if number.ISBN10 != tt.isbn10 {
+				t.Errorf("ISBN10 = %q, want %q", number.ISBN10, tt.isbn10)
+			}
+		})
+	}
+}
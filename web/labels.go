@@ -0,0 +1,170 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/arjunsaxaena/Library-Management/labels"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HELPER FUNCTIONS
+
+// labelRefs splits the IDs of a labels request into single references.
+func labelRefs(ids []string) []string {
+	return strings.FieldsFunc(strings.Join(ids, "\n"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+func (h *Handler) bookLabels(refs []string) ([]labels.Label, *apiError) {
+	locations := map[uuid.UUID]string{}
+	var out []labels.Label
+	for _, ref := range refs {
+		book, apiErr := h.resolveBook(ref)
+		if apiErr != nil {
+			apiErr.Body["id"] = ref
+			return nil, apiErr
+		}
+		if book.Barcode == nil {
+			return nil, newAPIError(http.StatusConflict, fmt.Sprintf("Book %q has no barcode", book.Title))
+		}
+
+		location, ok := locations[book.LocationID]
+		if !ok {
+			if l, err := h.LocationStore.Location(book.LocationID); err == nil {
+				location = l.Name
+			}
+			locations[book.LocationID] = location
+		}
+
+		label := labels.Label{Heading: book.Title, Code: *book.Barcode}
+		if location != "" {
+			label.Lines = []string{location}
+		}
+		out = append(out, label)
+	}
+	return out, nil
+}
+
+func (h *Handler) patronLabels(refs []string) ([]labels.Label, *apiError) {
+	var out []labels.Label
+	for _, ref := range refs {
+		user, apiErr := h.resolveUser(ref)
+		if apiErr != nil {
+			apiErr.Body["id"] = ref
+			return nil, apiErr
+		}
+		if user.CardNumber == nil {
+			return nil, newAPIError(http.StatusConflict, fmt.Sprintf("User %q has no card number", user.Name))
+		}
+		out = append(out, labels.Label{
+			Heading: user.Name,
+			Lines:   []string{"Class " + user.Class, "Library card"},
+			Code:    *user.CardNumber,
+		})
+	}
+	return out, nil
+}
+
+// renderLabels resolves the requested books or patrons with build and
+// renders them on the requested sheet.
+func renderLabels(req LabelsRequest, defaultLayout string, build func([]string) ([]labels.Label, *apiError)) ([]byte, labels.Format, *apiError) {
+	layoutName := req.Layout
+	if layoutName == "" {
+		layoutName = defaultLayout
+	}
+	layout, err := labels.LayoutByName(layoutName)
+	if err != nil {
+		return nil, "", newAPIError(http.StatusBadRequest, err.Error())
+	}
+	format := labels.Format(req.Format)
+	if format == "" {
+		format = labels.PDF
+	}
+	symbology := labels.Symbology(req.Symbology)
+	if symbology == "" {
+		symbology = labels.Code128
+	}
+
+	refs := labelRefs(req.IDs)
+	if len(refs) == 0 {
+		return nil, "", newAPIError(http.StatusBadRequest, "No IDs given")
+	}
+	items, apiErr := build(refs)
+	if apiErr != nil {
+		return nil, "", apiErr
+	}
+
+	var buf bytes.Buffer
+	opts := labels.Options{Layout: layout, Symbology: symbology, Skip: req.Skip, Outline: req.Outline}
+	if err := labels.Render(&buf, format, opts, items); err != nil {
+		return nil, "", newAPIError(http.StatusUnprocessableEntity, fmt.Sprintf("Failed to render labels: %v", err))
+	}
+	return buf.Bytes(), format, nil
+}
+
+func sendLabels(c *gin.Context, name string, doc []byte, format labels.Format) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	c.Data(http.StatusOK, format.ContentType(), doc)
+}
+
+// LABEL HANDLERS
+
+func (h *Handler) GetLabelLayouts(c *gin.Context) {
+	layouts := make([]LabelLayoutDTO, 0, len(labels.Layouts))
+	for _, name := range labels.LayoutNames() {
+		layouts = append(layouts, newLabelLayoutDTO(labels.Layouts[name]))
+	}
+	c.JSON(http.StatusOK, gin.H{"layouts": layouts})
+}
+
+func (h *Handler) PrintBookLabels(c *gin.Context) {
+	h.printLabels(c, "book-labels", labels.DefaultBookLayout, h.bookLabels)
+}
+
+func (h *Handler) PrintPatronCards(c *gin.Context) {
+	h.printLabels(c, "patron-cards", labels.DefaultPatronLayout, h.patronLabels)
+}
+
+func (h *Handler) printLabels(c *gin.Context, name, defaultLayout string, build func([]string) ([]labels.Label, *apiError)) {
+	var req LabelsRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	doc, format, apiErr := renderLabels(req, defaultLayout, build)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	sendLabels(c, name, doc, format)
+}
+
+// uiPrintLabels serves the web UI's label form. Errors go back to the form
+// as a flash message, a rendered sheet is sent as a download.
+func (h *Handler) uiPrintLabels(name, defaultLayout string, build func([]string) ([]labels.Label, *apiError)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LabelsRequest
+		if err := c.ShouldBind(&req); err != nil {
+			setFlash(c, flashError, "Invalid input: "+strings.Join(validationMessages(err), "; "))
+			c.Redirect(http.StatusSeeOther, "/ui/labels")
+			return
+		}
+
+		doc, format, apiErr := renderLabels(req, defaultLayout, build)
+		if apiErr != nil {
+			setFlash(c, flashError, apiErr.Error())
+			c.Redirect(http.StatusSeeOther, "/ui/labels")
+			return
+		}
+
+		sendLabels(c, name, doc, format)
+	}
+}
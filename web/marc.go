@@ -0,0 +1,427 @@
+package web
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/arjunsaxaena/Library-Management/identifiers"
+	"github.com/arjunsaxaena/Library-Management/isbn"
+	"github.com/arjunsaxaena/Library-Management/marc"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	marcFormatISO = "marc"
+	marcFormatXML = "marcxml"
+
+	marcContentType    = "application/marc"
+	marcXMLContentType = "application/marcxml+xml"
+
+	maxImportSize = 64 << 20
+	flushEvery    = 100
+)
+
+// Import actions reported per record or row.
+const (
+	importCreate   = "create"
+	importUpdate   = "update"
+	importConflict = "conflict"
+	importError    = "error"
+)
+
+func (r *ImportReportDTO) add(result ImportResultDTO) {
+	switch result.Action {
+	case importCreate:
+		r.Created++
+	case importUpdate:
+		r.Updated++
+	case importConflict:
+		r.Conflicts++
+	default:
+		r.Errors++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// marcImportOptions come from the query string. The book type and
+// language must be active terms of their vocabularies.
+type marcImportOptions struct {
+	DryRun          bool
+	Location        string
+	BookType        string
+	SubjectLanguage string
+}
+
+type marcRecordReader interface {
+	Read() (marc.Record, error)
+}
+
+// HELPER FUNCTIONS
+
+// newMARCReader picks the codec for format, or detects it from the first
+// byte when format is empty: MARCXML starts with "<", ISO 2709 with digits.
+func newMARCReader(format string, r io.Reader) (marcRecordReader, error) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		format = marcFormatISO
+		for {
+			b, err := br.Peek(1)
+			if err != nil {
+				break
+			}
+			if b[0] == ' ' || b[0] == '\n' || b[0] == '\r' || b[0] == '\t' || b[0] == 0xEF {
+				br.ReadByte()
+				continue
+			}
+			if b[0] == '<' {
+				format = marcFormatXML
+			}
+			break
+		}
+	}
+	switch format {
+	case marcFormatISO:
+		return marc.NewReader(br), nil
+	case marcFormatXML:
+		return marc.NewXMLReader(br), nil
+	}
+	return nil, fmt.Errorf("unknown MARC format %q, expected %s or %s", format, marcFormatISO, marcFormatXML)
+}
+
+// importMARCEntry creates or updates the book described by one record.
+// Books are matched by control number (a book ID from an earlier export),
+// then by ISBN; anything else is created. seen holds the keys of earlier
+// records in the same file.
+func (h *Handler) importMARCEntry(row int, e marc.Entry, opts marcImportOptions, seen map[string]int) ImportResultDTO {
+	res := ImportResultDTO{Row: row, Label: e.Title}
+	fail := func(action, message string) ImportResultDTO {
+		res.Action = action
+		res.Messages = append(res.Messages, message)
+		return res
+	}
+	failAPI := func(apiErr *apiError) ImportResultDTO {
+		if apiErr.Status == http.StatusConflict {
+			return fail(importConflict, apiErr.Error())
+		}
+		return fail(importError, apiErr.Error())
+	}
+
+	if e.Title == "" {
+		return fail(importError, "Record has no title (245 $a)")
+	}
+	var number *isbn.ISBN
+	if e.ISBN != "" {
+		n, err := isbn.Parse(e.ISBN)
+		if err != nil {
+			return fail(importError, fmt.Sprintf("Invalid ISBN %s: %v", e.ISBN, err))
+		}
+		number = &n
+	}
+
+	var keys []string
+	if e.ControlNumber != "" {
+		keys = append(keys, "001:"+e.ControlNumber)
+	}
+	if number != nil {
+		keys = append(keys, "isbn:"+number.ISBN13)
+	}
+	for _, key := range keys {
+		if earlier, ok := seen[key]; ok {
+			return fail(importConflict, fmt.Sprintf("Same book as record %d", earlier))
+		}
+	}
+	for _, key := range keys {
+		seen[key] = row
+	}
+
+	var existing *model.Book
+	if id, err := uuid.Parse(e.ControlNumber); err == nil {
+		book, err := h.BookStore.Book(id)
+		switch {
+		case err == nil:
+			existing = &book
+		case err != sql.ErrNoRows:
+			return fail(importError, "Failed to look up book")
+		}
+	}
+	if number != nil {
+		book, err := h.BookStore.BookByISBN(number.ISBN13)
+		switch {
+		case err == nil && existing != nil && book.ID != existing.ID:
+			res.ID = &book.ID
+			return fail(importConflict, "Control number and ISBN match different books")
+		case err == nil:
+			existing = &book
+		case err != sql.ErrNoRows:
+			return fail(importError, "Failed to look up book")
+		}
+	}
+
+	location := e.Location
+	if location == "" {
+		location = opts.Location
+	}
+	if location == "" {
+		return fail(importError, "No location in 852 $b and no default location given")
+	}
+	bookType := e.BookType
+	if bookType == "" {
+		bookType = opts.BookType
+	}
+	if bookType == "" {
+		return fail(importError, "No book type in 655 $a and no default book type given")
+	}
+	// The stores check the book type as they write; a dry run writes
+	// nothing, so it checks it here.
+	if opts.DryRun {
+		if apiErr := h.checkTerms(termParam{"book_type", model.VocabularyBookType, bookType}); apiErr != nil {
+			return failAPI(apiErr)
+		}
+	}
+
+	// Barcodes from other systems are kept when they carry a valid check
+	// digit; otherwise the book gets a new one.
+	var barcode string
+	if e.Barcode != "" {
+		normalized := identifiers.Normalize(e.Barcode)
+		if identifiers.Validate(normalized) != nil {
+			res.Messages = append(res.Messages, fmt.Sprintf("Barcode %s has no valid check digit and is not kept", e.Barcode))
+		} else if other, err := h.BookStore.BookByBarcode(normalized); err == nil && (existing == nil || other.ID != existing.ID) {
+			res.ID = &other.ID
+			return fail(importConflict, fmt.Sprintf("Barcode %s belongs to another book", normalized))
+		} else {
+			barcode = normalized
+		}
+	}
+
+	contributors := marcContributors(e)
+
+	if existing == nil {
+		req := CreateBookRequest{
+			Title:           e.Title,
+			Contributors:    contributors,
+			LocationName:    location,
+			BookType:        bookType,
+			Barcode:         barcode,
+			ISBN:            e.ISBN,
+			Publisher:       e.Publisher,
+			PublicationYear: publicationYear(e.Year),
+			PageCount:       max(e.Pages, 0),
+			CoverURL:        e.CoverURL,
+		}
+		if opts.DryRun {
+			if _, apiErr := h.checkNewBook(req); apiErr != nil {
+				return failAPI(apiErr)
+			}
+			res.Action = importCreate
+			return res
+		}
+		book, apiErr := h.createBook(req)
+		if apiErr != nil {
+			return failAPI(apiErr)
+		}
+		res.ID = &book.ID
+		res.Action = importCreate
+	} else {
+		res.ID = &existing.ID
+		res.Action = importUpdate
+		if opts.DryRun {
+			return res
+		}
+		if apiErr := h.updateFromMARC(*existing, e, contributors, location, bookType, barcode, number); apiErr != nil {
+			return failAPI(apiErr)
+		}
+	}
+
+	subjects := make([]model.Subject, 0, len(e.Subjects))
+	for _, name := range e.Subjects {
+		subject, err := h.getOrCreateSubject(name, opts.SubjectLanguage)
+		if err != nil {
+			return fail(importError, "Failed to create or find subject "+name)
+		}
+		subjects = append(subjects, subject)
+	}
+	if err := h.fileBook(*res.ID, subjects); err != nil {
+		return fail(importError, "Failed to file the book under its subjects")
+	}
+	return res
+}
+
+// marcContributors lists the record's contributors for a book, dropping
+// repeats and falling back to an unknown author.
+func marcContributors(e marc.Entry) []ContributorRequest {
+	contributors := make([]ContributorRequest, 0, len(e.Contributors))
+	seen := map[ContributorRequest]bool{}
+	for _, c := range e.Contributors {
+		req := ContributorRequest{Name: c.Name, Role: c.Role}
+		if !seen[req] {
+			seen[req] = true
+			contributors = append(contributors, req)
+		}
+	}
+	if len(contributors) == 0 {
+		contributors = append(contributors, ContributorRequest{Name: unknownAuthor, Role: model.RoleAuthor})
+	}
+	return contributors
+}
+
+// updateFromMARC overwrites a book with the record's data. Fields the
+// record leaves empty keep their current value; the loan status is never
+// touched.
+func (h *Handler) updateFromMARC(book model.Book, e marc.Entry, contributors []ContributorRequest, location, bookType, barcode string, number *isbn.ISBN) *apiError {
+	var apiErr *apiError
+	if book.Contributors, apiErr = h.resolveContributors(contributors); apiErr != nil {
+		return apiErr
+	}
+	loc, err := h.getOrCreateLocation(location)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to create or find location")
+	}
+
+	book.Title = e.Title
+	book.LocationID = loc.ID
+	book.BookType = bookType
+	if number != nil {
+		setISBN(&book, number)
+	}
+	if e.Publisher != "" {
+		book.Publisher = &e.Publisher
+	}
+	if year := publicationYear(e.Year); year != 0 {
+		book.PublicationYear = &year
+	}
+	if e.Pages > 0 {
+		book.PageCount = &e.Pages
+	}
+	if e.CoverURL != "" {
+		book.CoverURL = &e.CoverURL
+	}
+
+	if err := h.BookStore.UpdateBook(&book); err != nil {
+		return writeError(err, "Book", "update")
+	}
+	if barcode != "" && (book.Barcode == nil || *book.Barcode != barcode) {
+		if err := h.BookStore.SetBarcode(book.ID, barcode); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update barcode")
+		}
+	}
+	return nil
+}
+
+// MARC HANDLERS
+
+func (h *Handler) ImportMARC(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run value"})
+		return
+	}
+	opts := marcImportOptions{
+		DryRun:          dryRun,
+		Location:        c.Query("location"),
+		BookType:        c.Query("book_type"),
+		SubjectLanguage: c.DefaultQuery("subject_language", defaultSubjectLang),
+	}
+	if apiErr := h.checkTerms(
+		termParam{"book_type", model.VocabularyBookType, opts.BookType},
+		termParam{"subject_language", model.VocabularyLanguage, opts.SubjectLanguage},
+	); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	reader, err := newMARCReader(c.Query("format"), http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := ImportReportDTO{DryRun: dryRun, Results: []ImportResultDTO{}}
+	seen := map[string]int{}
+	for row := 1; ; row++ {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// A damaged record leaves the reader without a reliable
+			// position, so the rest of the file is not read.
+			if row == 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read MARC records", "details": err.Error()})
+				return
+			}
+			report.add(ImportResultDTO{Row: row, Action: importError, Messages: []string{"Unreadable record, import stopped: " + err.Error()}})
+			break
+		}
+		report.add(h.importMARCEntry(row, marc.ToEntry(rec), opts, seen))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func (h *Handler) ExportMARC(c *gin.Context) {
+	format := c.DefaultQuery("format", marcFormatISO)
+	if format != marcFormatISO && format != marcFormatXML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown MARC format %q, expected %s or %s", format, marcFormatISO, marcFormatXML)})
+		return
+	}
+
+	locations, err := h.LocationStore.Locations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve locations"})
+		return
+	}
+	locationsByID := make(map[uuid.UUID]model.Location, len(locations))
+	for _, l := range locations {
+		locationsByID[l.ID] = l
+	}
+
+	var write func(marc.Record) error
+	finish := func() error { return nil }
+	if format == marcFormatXML {
+		xw := marc.NewXMLWriter(c.Writer)
+		write, finish = xw.Write, xw.Close
+		c.Header("Content-Type", marcXMLContentType)
+		c.Header("Content-Disposition", `attachment; filename="catalog.xml"`)
+	} else {
+		write = marc.NewWriter(c.Writer).Write
+		c.Header("Content-Type", marcContentType)
+		c.Header("Content-Disposition", `attachment; filename="catalog.mrc"`)
+	}
+	c.Status(http.StatusOK)
+
+	count := 0
+	err = h.BookStore.EachBook(func(b model.Book) error {
+		contributors, err := h.BookStore.Contributors(b.ID)
+		if err != nil {
+			return err
+		}
+		subjects, err := h.BookStore.Subjects(b.ID)
+		if err != nil {
+			return err
+		}
+		if err := write(marc.FromBook(b, contributors, locationsByID[b.LocationID], subjects)); err != nil {
+			return err
+		}
+		if count++; count%flushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// The status line has been sent; all that can be done is to cut
+		// the download short and leave a trace.
+		log.Printf("marc export: %v", err)
+	}
+}
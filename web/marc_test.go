@@ -0,0 +1,151 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/marc"
+)
+
+func TestImportMARC(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      url.Values
+		genre      string // 655 $a, if any
+		isbn       string // 020 $a, if any
+		noTerms    bool
+		status     int
+		fields     []string
+		created    int
+		updated    int
+		failedRows int
+	}{
+		{
+			name:    "defaults from the vocabularies",
+			query:   url.Values{"book_type": {"Fiction"}, "subject_language": {"en"}},
+			status:  http.StatusOK,
+			created: 1,
+		},
+		{
+			name:    "default subject language",
+			query:   url.Values{"book_type": {"fiction"}},
+			status:  http.StatusOK,
+			created: 1,
+		},
+		{
+			name:       "no book type",
+			status:     http.StatusOK,
+			failedRows: 1,
+		},
+		{
+			name:   "unknown book type",
+			query:  url.Values{"book_type": {"poetry"}},
+			status: http.StatusBadRequest,
+			fields: []string{"book_type"},
+		},
+		{
+			name:   "deactivated book type",
+			query:  url.Values{"book_type": {"pamphlet"}},
+			status: http.StatusBadRequest,
+			fields: []string{"book_type"},
+		},
+		{
+			name:   "every bad option at once",
+			query:  url.Values{"book_type": {"poetry"}, "subject_language": {"la"}},
+			status: http.StatusBadRequest,
+			fields: []string{"book_type", "subject_language"},
+		},
+		{
+			name:    "left to the stores without vocabularies",
+			query:   url.Values{"book_type": {"poetry"}},
+			noTerms: true,
+			status:  http.StatusOK,
+			created: 1,
+		},
+		{
+			name:    "book type from the record",
+			genre:   "Fiction.",
+			status:  http.StatusOK,
+			created: 1,
+		},
+		{
+			name:       "unknown book type in the record",
+			query:      url.Values{"book_type": {"fiction"}},
+			genre:      "Novels",
+			status:     http.StatusOK,
+			failedRows: 1,
+		},
+		{
+			name:       "unknown book type in a record for a catalogued book",
+			query:      url.Values{"book_type": {"fiction"}},
+			genre:      "Novels",
+			isbn:       "9780306406157",
+			status:     http.StatusOK,
+			failedRows: 1,
+		},
+		{
+			name:    "catalogued book",
+			query:   url.Values{"book_type": {"fiction"}},
+			isbn:    "9780306406157",
+			status:  http.StatusOK,
+			updated: 1,
+		},
+		{
+			name:   "unknown format",
+			query:  url.Values{"book_type": {"fiction"}, "format": {"mods"}},
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec marc.Record
+			if tt.isbn != "" {
+				rec.AddData("020", ' ', ' ', "a", tt.isbn)
+			}
+			rec.AddData("100", '1', ' ', "a", "Herbert, Frank,")
+			rec.AddData("245", '1', '0', "a", "Children of Dune /")
+			if tt.genre != "" {
+				rec.AddData("655", ' ', '4', "a", tt.genre)
+			}
+			data, err := marc.Encode(rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := newFixture()
+			h := f.handler()
+			if tt.noTerms {
+				h.VocabularyStore = nil
+			}
+			query := url.Values{"dry_run": {"true"}, "location": {"Shelf 3"}}
+			for key, values := range tt.query {
+				query[key] = values
+			}
+
+			w := serve(newTestRouter(h), http.MethodPost, "/api/v1/books/marc/import?"+query.Encode(), string(data), "Content-Type", marcContentType)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var resp struct {
+				Report ImportReportDTO `json:"report"`
+				Fields []FieldErrorDTO `json:"fields"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, fe := range resp.Fields {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", fields, tt.fields)
+			}
+			if resp.Report.Created != tt.created || resp.Report.Updated != tt.updated || resp.Report.Errors != tt.failedRows {
+				t.Errorf("report = %+v", resp.Report)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/arjunsaxaena/Library-Management/metadata"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxImportedSubjects caps the subjects taken from a record; providers
+	// often return dozens of loosely related headings.
+	maxImportedSubjects = 10
+	defaultSubjectLang  = "en"
+	unknownAuthor       = "Unknown"
+)
+
+type importedBook struct {
+	Book     model.Book
+	Authors  []model.Author
+	Subjects []model.Subject
+}
+
+// HELPER FUNCTIONS
+
+// publicationYear drops years outside the range CreateBookRequest accepts,
+// which catalogue records sometimes contain.
+func publicationYear(year int) int {
+	if year < 1000 || year > 9999 {
+		return 0
+	}
+	return year
+}
+
+func (h *Handler) getOrCreateSubject(name, language string) (model.Subject, error) {
+	subject, err := h.SubjectStore.SubjectByName(name)
+	if err != nil {
+		return model.Subject{}, err
+	}
+	if subject.ID != uuid.Nil {
+		return subject, nil
+	}
+	subject = model.Subject{ID: uuid.New(), Name: name, Language: language}
+	if err := h.SubjectStore.CreateSubject(&subject); err != nil {
+		return model.Subject{}, err
+	}
+	return subject, nil
+}
+
+// importBook looks the ISBN up with the metadata provider and creates the
+// book, its authors and subjects in one transaction. All authors become
+// contributors, the first one the book's primary author.
+func (h *Handler) importBook(ctx context.Context, req ImportBookRequest) (importedBook, *apiError) {
+	if h.Metadata == nil {
+		return importedBook{}, newAPIError(http.StatusServiceUnavailable, "No metadata provider is configured")
+	}
+	if h.Tx == nil {
+		return importedBook{}, newAPIError(http.StatusServiceUnavailable, "Importing needs transactions, which are not configured")
+	}
+
+	if apiErr := h.checkTerms(termParam{"subject_language", model.VocabularyLanguage, req.SubjectLanguage}); apiErr != nil {
+		return importedBook{}, apiErr
+	}
+	number, apiErr := h.bookISBN(req.ISBN, uuid.Nil)
+	if apiErr != nil {
+		return importedBook{}, apiErr
+	}
+
+	record, err := h.Metadata.LookupISBN(ctx, number.ISBN13)
+	if err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			return importedBook{}, newAPIError(http.StatusNotFound, "No metadata found for ISBN "+number.ISBN13)
+		}
+		return importedBook{}, newAPIError(http.StatusBadGateway, fmt.Sprintf("Metadata lookup failed: %v", err))
+	}
+	if record.Title == "" {
+		return importedBook{}, newAPIError(http.StatusUnprocessableEntity, "Metadata record has no title")
+	}
+
+	authors := record.Authors
+	if len(authors) == 0 {
+		authors = []string{unknownAuthor}
+	}
+	contributors := make([]ContributorRequest, 0, len(authors))
+	for _, name := range authors {
+		req := ContributorRequest{Name: name, Role: model.RoleAuthor}
+		if !slices.Contains(contributors, req) {
+			contributors = append(contributors, req)
+		}
+	}
+	language := req.SubjectLanguage
+	if language == "" {
+		language = defaultSubjectLang
+	}
+	subjects := record.Subjects
+	if len(subjects) > maxImportedSubjects {
+		subjects = subjects[:maxImportedSubjects]
+	}
+
+	var result importedBook
+	var failure *apiError
+	err = h.inTx(func(tx *Handler) error {
+		book, apiErr := tx.createBook(CreateBookRequest{
+			Title:           record.Title,
+			Contributors:    contributors,
+			LocationName:    req.LocationName,
+			BookType:        req.BookType,
+			Barcode:         req.Barcode,
+			ISBN:            number.ISBN13,
+			Publisher:       record.Publisher,
+			PublicationYear: publicationYear(record.Year),
+			PageCount:       max(record.Pages, 0),
+			CoverURL:        record.CoverURL,
+		})
+		if apiErr != nil {
+			failure = apiErr
+			return failure
+		}
+		result.Book = book
+		for _, c := range book.Contributors {
+			result.Authors = append(result.Authors, model.Author{ID: c.AuthorID, Name: c.Name})
+		}
+
+		for _, name := range subjects {
+			subject, err := tx.getOrCreateSubject(name, language)
+			if err != nil {
+				failure = newAPIError(http.StatusInternalServerError, "Failed to create or find subject "+name)
+				return failure
+			}
+			result.Subjects = append(result.Subjects, subject)
+		}
+		if err := tx.fileBook(book.ID, result.Subjects); err != nil {
+			failure = newAPIError(http.StatusInternalServerError, "Failed to file the book under its subjects")
+			return failure
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return result, nil
+	case failure != nil:
+		return importedBook{}, failure
+	}
+	return importedBook{}, newAPIError(http.StatusInternalServerError, "Failed to import book")
+}
+
+// CREATE HANDLERS
+
+func (h *Handler) ImportBookByISBN(c *gin.Context) {
+	var req ImportBookRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	imported, apiErr := h.importBook(c.Request.Context(), req)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Book imported successfully",
+		"book":     newBookDTO(imported.Book),
+		"authors":  mapSlice(imported.Authors, newAuthorDTO),
+		"subjects": mapSlice(imported.Subjects, newSubjectDTO),
+	})
+}
+
+func (h *Handler) uiImportBook(c *gin.Context) {
+	uiSubmit(c, "/ui/books/import", "/ui?view=books", func(req ImportBookRequest) (string, *apiError) {
+		imported, apiErr := h.importBook(c.Request.Context(), req)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		return fmt.Sprintf("Book %q imported successfully", imported.Book.Title), nil
+	})
+}
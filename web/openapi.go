@@ -0,0 +1,721 @@
+package web
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// schema is a JSON Schema object as used by OpenAPI 3.
+type schema map[string]any
+
+// apiOperation describes one route for the OpenAPI document. Request and
+// response values are either Go values (reflected into component schemas)
+// or literal schemas.
+type apiOperation struct {
+	Method    string
+	Path      string
+	Summary   string
+	Tag       string
+	Request   any
+	Responses map[int]any
+}
+
+type errorResponse struct {
+	Error   string          `json:"error"`
+	Details string          `json:"details,omitempty"`
+	Fields  []FieldErrorDTO `json:"fields,omitempty"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type returnBookResponse struct {
+	Message  string    `json:"message"`
+	BookID   uuid.UUID `json:"book_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	LateFees float64   `json:"late_fees"`
+}
+
+type payFeesResponse struct {
+	Message string  `json:"message"`
+	Paid    float64 `json:"paid"`
+}
+
+type scanResponse struct {
+	Scan    ScanDTO               `json:"scan"`
+	Session CirculationSessionDTO `json:"session"`
+}
+
+type importBookResponse struct {
+	Message  string       `json:"message"`
+	Book     BookDTO      `json:"book"`
+	Authors  []AuthorDTO  `json:"authors"`
+	Subjects []SubjectDTO `json:"subjects"`
+}
+
+type mergeAuthorsResponse struct {
+	Message string           `json:"message"`
+	Author  AuthorDTO        `json:"author"`
+	Merges  []AuthorMergeDTO `json:"merges"`
+}
+
+// updateSubjectResponse and deleteSubjectResponse count the materials that
+// took on the subject's new name or moved to another subject.
+type updateSubjectResponse struct {
+	Message           string     `json:"message"`
+	Subject           SubjectDTO `json:"subject"`
+	MaterialsAffected int        `json:"materials_affected"`
+}
+
+type deleteSubjectResponse struct {
+	Message           string `json:"message"`
+	MaterialsAffected int    `json:"materials_affected"`
+}
+
+type uploadFileForm struct {
+	File   string `json:"file" format:"binary"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// blockedResponse is the 409 of a delete refused because other records
+// depend on the one being deleted.
+type blockedResponse struct {
+	Error    string       `json:"error"`
+	Blockers []BlockerDTO `json:"blockers"`
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// envelope describes the {"<key>": <value>} bodies most handlers return.
+// WithMessage adds the "message" field used by write handlers.
+type envelope struct {
+	Key         string
+	Value       any
+	WithMessage bool
+}
+
+// download describes a response that is a generated file rather than JSON;
+// upload does the same for request bodies.
+type download struct {
+	ContentTypes []string
+}
+
+type upload struct {
+	ContentTypes []string
+	Form         any
+}
+
+func file(contentTypes ...string) download { return download{ContentTypes: contentTypes} }
+func raw(contentTypes ...string) upload    { return upload{ContentTypes: contentTypes} }
+
+// multipartForm describes a multipart/form-data body with the fields of
+// form, whose binary fields are files.
+func multipartForm(form any) upload {
+	return upload{ContentTypes: []string{"multipart/form-data"}, Form: form}
+}
+
+// patchOf describes a JSON merge patch body against Value: any of Value's
+// fields, with null clearing one.
+type patchOf struct {
+	Value any
+}
+
+// queryParam documents a query string parameter of an operation.
+type queryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// operationQuery lists the query parameters of the operations that take
+// any, keyed by operationKey.
+var operationQuery = map[string][]queryParam{
+	operationKey(http.MethodGet, "/authors/duplicates"):   duplicateQuery,
+	operationKey(http.MethodGet, "/locations/duplicates"): duplicateQuery,
+	operationKey(http.MethodPost, "/books/marc/import"): {
+		{"format", "string", "marc (ISO 2709) or marcxml; detected from the body when omitted"},
+		{"dry_run", "boolean", "Report what would be created, updated or rejected without writing"},
+		{"location", "string", "Location for records without 852 $b"},
+		{"book_type", "string", "Book type for records without 655 $a"},
+		{"subject_language", "string", "Language recorded on new subjects (default en)"},
+	},
+	operationKey(http.MethodGet, "/books/marc/export"): {
+		{"format", "string", "marc (ISO 2709, default) or marcxml"},
+	},
+	operationKey(http.MethodGet, "/audit"): {
+		{"actor", "string", "Only writes by this actor, as sent in X-Actor"},
+		{"action", "string", "Only this action: create, update, delete, restore, purge, issue, return or merge"},
+		{"entity_type", "string", "Only this kind of record: book, author, location, user, subject, material or loan"},
+		{"entity_id", "string", "Only writes to this record"},
+		{"request_id", "string", "Only writes made by this request, as echoed in X-Request-ID"},
+		{"since", "string", "Only writes at or after this RFC 3339 time"},
+		{"until", "string", "Only writes before this RFC 3339 time"},
+		{"limit", "integer", "Maximum number of entries (default 100, at most 1000)"},
+		{"offset", "integer", "Number of entries to skip"},
+	},
+	operationKey(http.MethodDelete, "/subjects/:id"): {
+		{"reassign_to", "string", "Move the subject's materials to this subject, then delete it"},
+	},
+	operationKey(http.MethodGet, "/materials"): {
+		{"link_status", "string", "Only materials whose link is ok, broken or unchecked; broken links are found by the background link checker"},
+	},
+	operationKey(http.MethodGet, "/materials/:id/revisions/diff"): {
+		{"from", "integer", "Revision to compare from (default the one before to)"},
+		{"to", "integer", "Revision to compare to (default the latest)"},
+	},
+	operationKey(http.MethodGet, "/materials/:id/files/:file_id"): {
+		{"verify", "boolean", "Check the stored content against its SHA-256 digest before serving it"},
+	},
+	operationKey(http.MethodPost, "/books/csv"):     csvImportQuery,
+	operationKey(http.MethodPost, "/users/csv"):     csvImportQuery,
+	operationKey(http.MethodPost, "/subjects/csv"):  csvImportQuery,
+	operationKey(http.MethodPost, "/materials/csv"): csvImportQuery,
+}
+
+var duplicateQuery = []queryParam{
+	{"threshold", "number", "Name similarity from 0 to 1 at which records are grouped (default 0.92)"},
+}
+
+var csvImportQuery = []queryParam{
+	{"dry_run", "boolean", "Validate every row and report what would be written without writing"},
+	{"batch_size", "integer", "Rows written per transaction (default 100, at most 1000); a failing row rolls back its batch"},
+}
+
+func wrapped(key string, v any) envelope     { return envelope{Key: key, Value: v} }
+func withMessage(key string, v any) envelope { return envelope{Key: key, Value: v, WithMessage: true} }
+
+var errResp = errorResponse{}
+
+func apiOperations() []apiOperation {
+	return []apiOperation{
+		// Books
+		{http.MethodGet, "/books", "List books that are not checked out", "Books", nil, map[int]any{200: []BookDTO{}, 500: errResp}},
+		{http.MethodGet, "/books/:id", "Get a book by ID or barcode", "Books", nil, map[int]any{200: BookDTO{}, 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPost, "/books/import-by-isbn", "Create a book from the metadata provider's record for an ISBN", "Books", ImportBookRequest{}, map[int]any{200: importBookResponse{}, 400: errResp, 404: errResp, 409: errResp, 422: errResp, 500: errResp, 502: errResp, 503: errResp}},
+		{http.MethodPost, "/books/marc/import", "Import MARC 21 or MARCXML records", "Books", raw("application/marc", "application/marcxml+xml"), map[int]any{200: wrapped("report", ImportReportDTO{}), 400: errResp}},
+		{http.MethodGet, "/books/marc/export", "Export the whole catalogue as MARC 21 or MARCXML", "Books", nil, map[int]any{200: file("application/marc", "application/marcxml+xml"), 400: errResp, 500: errResp}},
+		{http.MethodPost, "/books/csv", "Import books from CSV", "Books", raw("text/csv"), map[int]any{200: wrapped("report", ImportReportDTO{}), 400: errResp, 503: errResp}},
+		{http.MethodGet, "/books/csv", "Export all books as CSV", "Books", nil, map[int]any{200: file("text/csv")}},
+		{http.MethodGet, "/books/isbn/:isbn", "Get a book by ISBN-10 or ISBN-13", "Books", nil, map[int]any{200: BookDTO{}, 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPost, "/books", "Create a book", "Books", CreateBookRequest{}, map[int]any{200: withMessage("book", BookDTO{}), 400: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPut, "/books/:id", "Replace a book", "Books", UpdateBookRequest{}, map[int]any{200: withMessage("book", BookDTO{}), 400: errResp, 404: errResp, 409: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPatch, "/books/:id", "Change some of a book's fields", "Books", patchOf{UpdateBookRequest{}}, map[int]any{200: withMessage("book", BookDTO{}), 400: errResp, 404: errResp, 409: errResp, 412: errResp, 415: errResp, 500: errResp}},
+		{http.MethodGet, "/books/:id/contributors", "List a book's contributors in order", "Books", nil, map[int]any{200: wrapped("contributors", []ContributorDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/books/:id/subjects", "List the subjects a book is filed under", "Books", nil, map[int]any{200: wrapped("subjects", []SubjectDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPut, "/books/:id/subjects", "Replace the subjects a book is filed under", "Books", SetSubjectsRequest{}, map[int]any{200: withMessage("subjects", []SubjectDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPut, "/books/:id/contributors", "Replace a book's contributors", "Books", SetContributorsRequest{}, map[int]any{200: withMessage("contributors", []ContributorDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodDelete, "/books/:id", "Delete a book, unless other records depend on it", "Books", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: blockedResponse{}, 412: errResp, 500: errResp}},
+		{http.MethodPost, "/books/:id/restore", "Restore a deleted book", "Books", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/books/:id/history", "List the audit entries of a book, oldest first", "Books", nil, map[int]any{200: wrapped("history", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Users
+		{http.MethodGet, "/users", "List users", "Users", nil, map[int]any{200: []UserDTO{}, 500: errResp}},
+		{http.MethodGet, "/users/:id", "Get a user by ID or card number", "Users", nil, map[int]any{200: wrapped("user", UserDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPost, "/users", "Create a user", "Users", CreateUserRequest{}, map[int]any{200: withMessage("user", UserDTO{}), 400: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPost, "/users/csv", "Import users from CSV", "Users", raw("text/csv"), map[int]any{200: wrapped("report", ImportReportDTO{}), 400: errResp, 503: errResp}},
+		{http.MethodGet, "/users/csv", "Export all users as CSV", "Users", nil, map[int]any{200: file("text/csv")}},
+		{http.MethodPut, "/users/:id", "Replace a user", "Users", UpdateUserRequest{}, map[int]any{200: withMessage("user", UserDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPatch, "/users/:id", "Change some of a user's fields", "Users", patchOf{UpdateUserRequest{}}, map[int]any{200: withMessage("user", UserDTO{}), 400: errResp, 404: errResp, 412: errResp, 415: errResp, 500: errResp}},
+		{http.MethodDelete, "/users/:id", "Delete a user, unless other records depend on it", "Users", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: blockedResponse{}, 412: errResp, 500: errResp}},
+		{http.MethodPost, "/users/:id/restore", "Restore a deleted user", "Users", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodPost, "/users/:id/fees/pay", "Pay the late fees charged on a user's returned loans", "Users", nil, map[int]any{200: payFeesResponse{}, 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/users/:id/history", "List the audit entries of a user, oldest first", "Users", nil, map[int]any{200: wrapped("history", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Locations
+		{http.MethodGet, "/locations", "List locations", "Locations", nil, map[int]any{200: []LocationDTO{}, 500: errResp}},
+		{http.MethodGet, "/locations/duplicates", "Group locations whose names look like duplicates", "Locations", nil, map[int]any{200: wrapped("groups", []DuplicateGroupDTO{}), 400: errResp, 500: errResp}},
+		{http.MethodGet, "/locations/:id", "Get a location", "Locations", nil, map[int]any{200: wrapped("location", LocationDTO{}), 400: errResp, 404: errResp}},
+		{http.MethodPost, "/locations", "Create a location", "Locations", CreateLocationRequest{}, map[int]any{200: withMessage("location", LocationDTO{}), 400: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPut, "/locations/:id", "Replace a location", "Locations", UpdateLocationRequest{}, map[int]any{200: withMessage("location", LocationDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPatch, "/locations/:id", "Change some of a location's fields", "Locations", patchOf{UpdateLocationRequest{}}, map[int]any{200: withMessage("location", LocationDTO{}), 400: errResp, 404: errResp, 412: errResp, 415: errResp, 500: errResp}},
+		{http.MethodDelete, "/locations/:id", "Delete a location, unless other records depend on it", "Locations", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: blockedResponse{}, 412: errResp, 500: errResp}},
+		{http.MethodPost, "/locations/:id/restore", "Restore a deleted location", "Locations", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/locations/:id/history", "List the audit entries of a location, oldest first", "Locations", nil, map[int]any{200: wrapped("history", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Authors
+		{http.MethodGet, "/authors", "List authors", "Authors", nil, map[int]any{200: []AuthorDTO{}, 500: errResp}},
+		{http.MethodGet, "/authors/:id", "Get an author", "Authors", nil, map[int]any{200: AuthorDTO{}, 400: errResp, 404: errResp}},
+		{http.MethodGet, "/authors/:id/books", "List the books an author contributes to, with their roles", "Authors", nil, map[int]any{200: wrapped("books", []ContributionDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/authors/duplicates", "Group authors whose names look like duplicates", "Authors", nil, map[int]any{200: wrapped("groups", []DuplicateGroupDTO{}), 400: errResp, 500: errResp}},
+		{http.MethodPost, "/authors/:id/merge", "Merge duplicate authors into this one", "Authors", MergeAuthorsRequest{}, map[int]any{200: mergeAuthorsResponse{}, 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/authors/:id/merges", "List the authors merged into this one", "Authors", nil, map[int]any{200: wrapped("merges", []AuthorMergeDTO{}), 400: errResp, 500: errResp}},
+		{http.MethodPost, "/authors", "Create an author", "Authors", CreateAuthorRequest{}, map[int]any{200: withMessage("author", AuthorDTO{}), 400: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPut, "/authors/:id", "Replace an author", "Authors", UpdateAuthorRequest{}, map[int]any{200: withMessage("author", AuthorDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPatch, "/authors/:id", "Change some of an author's fields", "Authors", patchOf{UpdateAuthorRequest{}}, map[int]any{200: withMessage("author", AuthorDTO{}), 400: errResp, 404: errResp, 412: errResp, 415: errResp, 500: errResp}},
+		{http.MethodDelete, "/authors/:id", "Delete an author, unless other records depend on it", "Authors", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: blockedResponse{}, 412: errResp, 500: errResp}},
+		{http.MethodPost, "/authors/:id/restore", "Restore a deleted author", "Authors", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/authors/:id/history", "List the audit entries of a author, oldest first", "Authors", nil, map[int]any{200: wrapped("history", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Circulation
+		{http.MethodGet, "/books/issue/:id", "Get the loan record of a book by ID or barcode", "Circulation", nil, map[int]any{200: wrapped("issued_book", IssuedBookDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/books/issue", "List active loans", "Circulation", nil, map[int]any{200: wrapped("issued_books", []IssuedBookDTO{}), 500: errResp}},
+		{http.MethodPost, "/books/issue", "Issue a book to a user", "Circulation", IssueBookRequest{}, map[int]any{200: withMessage("issued_book", IssuedBookDTO{}), 400: errResp, 404: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPost, "/books/return", "Return an issued book", "Circulation", ReturnBookRequest{}, map[int]any{200: returnBookResponse{}, 400: errResp, 403: errResp, 404: errResp, 500: errResp}},
+
+		// Circulation desk
+		{http.MethodPost, "/circulation/sessions", "Start serving a patron at the desk", "Circulation Desk", StartCirculationRequest{}, map[int]any{201: wrapped("session", CirculationSessionDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/circulation/sessions/:id", "Get a desk session with the patron's loans, fees and blocks", "Circulation Desk", nil, map[int]any{200: wrapped("session", CirculationSessionDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPost, "/circulation/sessions/:id/scans", "Scan an item to issue or return it", "Circulation Desk", CirculationScanRequest{}, map[int]any{200: scanResponse{}, 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodDelete, "/circulation/sessions/:id", "End a desk session", "Circulation Desk", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp}},
+
+		// Labels
+		{http.MethodGet, "/labels/layouts", "List label and card sheet layouts", "Labels", nil, map[int]any{200: wrapped("layouts", []LabelLayoutDTO{})}},
+		{http.MethodPost, "/labels/books", "Render barcode labels for books", "Labels", LabelsRequest{}, map[int]any{200: file("application/pdf", "image/svg+xml"), 400: errResp, 404: errResp, 409: errResp, 422: errResp, 500: errResp}},
+		{http.MethodPost, "/labels/patrons", "Render library cards for patrons", "Labels", LabelsRequest{}, map[int]any{200: file("application/pdf", "image/svg+xml"), 400: errResp, 404: errResp, 409: errResp, 422: errResp, 500: errResp}},
+
+		// Materials
+		{http.MethodGet, "/materials", "List materials", "Materials", nil, map[int]any{200: wrapped("materials", []MaterialDTO{}), 400: errResp, 500: errResp}},
+		{http.MethodGet, "/materials/:id", "Get a material", "Materials", nil, map[int]any{200: wrapped("material", MaterialDTO{}), 400: errResp, 404: errResp}},
+		{http.MethodPost, "/materials", "Create a material", "Materials", CreateMaterialRequest{}, map[int]any{200: withMessage("material", MaterialDTO{}), 400: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPost, "/materials/csv", "Import materials from CSV", "Materials", raw("text/csv"), map[int]any{200: wrapped("report", ImportReportDTO{}), 400: errResp, 503: errResp}},
+		{http.MethodGet, "/materials/csv", "Export all materials as CSV", "Materials", nil, map[int]any{200: file("text/csv")}},
+		{http.MethodGet, "/materials/subject/:subject_name", "List materials of a subject", "Materials", nil, map[int]any{200: wrapped("materials", []MaterialDTO{}), 500: errResp}},
+		{http.MethodGet, "/materials/language/:language", "List materials in a language", "Materials", nil, map[int]any{200: wrapped("materials", []MaterialDTO{}), 500: errResp}},
+		{http.MethodPut, "/materials/:id", "Replace a material", "Materials", UpdateMaterialRequest{}, map[int]any{200: withMessage("material", MaterialDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPatch, "/materials/:id", "Change some of a material's fields", "Materials", patchOf{UpdateMaterialRequest{}}, map[int]any{200: withMessage("material", MaterialDTO{}), 400: errResp, 404: errResp, 412: errResp, 415: errResp, 500: errResp}},
+		{http.MethodDelete, "/materials/:id", "Delete a material", "Materials", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodPost, "/materials/:id/restore", "Restore a deleted material", "Materials", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/materials/:id/link", "Get the outcome of the last check of a material's link", "Materials", nil, map[int]any{200: wrapped("link_check", LinkCheckDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/materials/:id/revisions", "List the revisions of a material's content, oldest first", "Materials", nil, map[int]any{200: wrapped("revisions", []MaterialRevisionDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/materials/:id/revisions/diff", "List the fields that changed between two revisions of a material", "Materials", nil, map[int]any{200: wrapped("diff", RevisionDiffDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/materials/:id/revisions/:revision", "Get a revision of a material", "Materials", nil, map[int]any{200: wrapped("revision", MaterialRevisionDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPost, "/materials/:id/revisions/:revision/revert", "Restore a material's content from an earlier revision, adding a new revision", "Materials", nil, map[int]any{200: withMessage("material", MaterialDTO{}), 400: errResp, 404: errResp, 409: errResp, 412: errResp, 500: errResp}},
+		{http.MethodGet, "/materials/:id/subjects", "List the subjects a material is filed under", "Materials", nil, map[int]any{200: wrapped("subjects", []SubjectDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPut, "/materials/:id/subjects", "Replace the subjects a material is filed under; it stays filed under its own subject", "Materials", SetSubjectsRequest{}, map[int]any{200: withMessage("subjects", []SubjectDTO{}), 400: errResp, 404: errResp, 412: errResp, 500: errResp}},
+		{http.MethodGet, "/materials/:id/files", "List the files uploaded to a material", "Materials", nil, map[int]any{200: wrapped("files", []MaterialFileDTO{}), 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodPost, "/materials/:id/files", "Upload a file to a material, checked against an optional SHA-256 digest", "Materials", multipartForm(uploadFileForm{}), map[int]any{200: withMessage("file", MaterialFileDTO{}), 400: errResp, 404: errResp, 409: errResp, 412: errResp, 413: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/materials/:id/files/:file_id", "Download a file of a material, whole or in ranges, or be redirected to a presigned URL that serves it", "Materials", nil, map[int]any{200: file("application/octet-stream"), 206: file("application/octet-stream"), 304: file(), 307: file(), 400: errResp, 404: errResp, 416: file("text/plain"), 500: errResp, 503: errResp}},
+		{http.MethodDelete, "/materials/:id/files/:file_id", "Delete a file of a material", "Materials", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 412: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/materials/:id/history", "List the audit entries of a material, oldest first", "Materials", nil, map[int]any{200: wrapped("history", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Subjects
+		{http.MethodGet, "/subjects", "List subjects", "Subjects", nil, map[int]any{200: wrapped("subjects", []SubjectDTO{}), 500: errResp}},
+		{http.MethodGet, "/subjects/browse", "List the top-level subjects with counts over their subtrees", "Subjects", nil, map[int]any{200: wrapped("subjects", []SubjectNodeDTO{}), 500: errResp}},
+		{http.MethodGet, "/subjects/:id/browse", "Browse a subject: the subjects above and below it and what is filed in its subtree, or with ?direct=true under it alone", "Subjects", nil, map[int]any{200: SubjectBrowseDTO{}, 400: errResp, 404: errResp, 500: errResp}},
+		{http.MethodGet, "/subjects/:id", "Get a subject", "Subjects", nil, map[int]any{200: wrapped("subject", SubjectDTO{}), 400: errResp, 404: errResp}},
+		{http.MethodPost, "/subjects", "Create a subject", "Subjects", CreateSubjectRequest{}, map[int]any{200: withMessage("subject", SubjectDTO{}), 400: errResp, 409: errResp, 500: errResp}},
+		{http.MethodPost, "/subjects/csv", "Import subjects from CSV", "Subjects", raw("text/csv"), map[int]any{200: wrapped("report", ImportReportDTO{}), 400: errResp, 503: errResp}},
+		{http.MethodGet, "/subjects/csv", "Export all subjects as CSV", "Subjects", nil, map[int]any{200: file("text/csv")}},
+		{http.MethodGet, "/subjects/name/:name", "Get a subject by name", "Subjects", nil, map[int]any{200: wrapped("subject", SubjectDTO{}), 404: errResp, 500: errResp}},
+		{http.MethodPut, "/subjects/:id", "Replace a subject; renaming it renames it for its materials too", "Subjects", UpdateSubjectRequest{}, map[int]any{200: updateSubjectResponse{}, 400: errResp, 404: errResp, 409: errResp, 412: errResp, 500: errResp, 503: errResp}},
+		{http.MethodPatch, "/subjects/:id", "Change some of a subject's fields", "Subjects", patchOf{UpdateSubjectRequest{}}, map[int]any{200: updateSubjectResponse{}, 400: errResp, 404: errResp, 409: errResp, 412: errResp, 415: errResp, 500: errResp, 503: errResp}},
+		{http.MethodDelete, "/subjects/:id", "Delete a subject, unless other records depend on it; materials can be moved to another subject first", "Subjects", nil, map[int]any{200: deleteSubjectResponse{}, 400: errResp, 404: errResp, 409: blockedResponse{}, 412: errResp, 500: errResp, 503: errResp}},
+		{http.MethodPost, "/subjects/:id/restore", "Restore a deleted subject", "Subjects", nil, map[int]any{200: messageResponse{}, 400: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/subjects/:id/history", "List the audit entries of a subject, oldest first", "Subjects", nil, map[int]any{200: wrapped("history", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Vocabularies
+		{http.MethodGet, "/vocabularies", "List the vocabularies", "Vocabularies", nil, map[int]any{200: wrapped("vocabularies", []string{})}},
+		{http.MethodGet, "/vocabularies/:vocabulary", "List a vocabulary's terms, labelled in the locale of ?locale= or Accept-Language", "Vocabularies", nil, map[int]any{200: wrapped("terms", []TermDTO{}), 404: errResp, 500: errResp, 503: errResp}},
+		{http.MethodGet, "/vocabularies/:vocabulary/:code", "Get a term of a vocabulary", "Vocabularies", nil, map[int]any{200: wrapped("term", TermDTO{}), 404: errResp, 500: errResp, 503: errResp}},
+		{http.MethodPost, "/admin/vocabularies/:vocabulary", "Add a term to a vocabulary; needs the X-Admin-Token header", "Vocabularies", CreateTermRequest{}, map[int]any{200: withMessage("term", TermDTO{}), 400: errResp, 401: errResp, 403: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+		{http.MethodPut, "/admin/vocabularies/:vocabulary/:code", "Activate or deactivate a term and replace its labels; needs the X-Admin-Token header", "Vocabularies", UpdateTermRequest{}, map[int]any{200: withMessage("term", TermDTO{}), 400: errResp, 401: errResp, 403: errResp, 404: errResp, 500: errResp, 503: errResp}},
+		{http.MethodDelete, "/admin/vocabularies/:vocabulary/:code", "Delete a term no record uses; needs the X-Admin-Token header", "Vocabularies", nil, map[int]any{200: messageResponse{}, 401: errResp, 403: errResp, 404: errResp, 409: errResp, 500: errResp, 503: errResp}},
+
+		// Audit
+		{http.MethodGet, "/audit", "Search the audit log of writes, newest first", "Audit", nil, map[int]any{200: wrapped("entries", []AuditEntryDTO{}), 400: errResp, 500: errResp, 503: errResp}},
+
+		// Trash
+		{http.MethodGet, "/trash", "List deleted records that can be restored or purged", "Trash", nil, map[int]any{200: wrapped("trash", TrashDTO{}), 500: errResp}},
+		{http.MethodDelete, "/admin/trash/:kind/:id", "Purge a deleted record permanently; needs the X-Admin-Token header", "Trash", nil, map[int]any{200: messageResponse{}, 400: errResp, 401: errResp, 403: errResp, 404: errResp, 409: errResp, 500: errResp}},
+
+		// Meta
+		{http.MethodGet, "/health", "Health check", "Meta", nil, map[int]any{200: healthResponse{}}},
+		{http.MethodGet, "/openapi.json", "This OpenAPI document", "Meta", nil, map[int]any{200: schema{"type": "object"}}},
+		{http.MethodGet, "/docs", "Interactive API documentation", "Meta", nil, map[int]any{200: schema{"type": "string"}}},
+	}
+}
+
+// etagOperations return a single record with its version in the ETag
+// header.
+var etagOperations = map[string]bool{
+	operationKey(http.MethodGet, "/books/:id"):           true,
+	operationKey(http.MethodGet, "/books/isbn/:isbn"):    true,
+	operationKey(http.MethodPut, "/books/:id"):           true,
+	operationKey(http.MethodPatch, "/books/:id"):         true,
+	operationKey(http.MethodGet, "/users/:id"):           true,
+	operationKey(http.MethodPut, "/users/:id"):           true,
+	operationKey(http.MethodPatch, "/users/:id"):         true,
+	operationKey(http.MethodGet, "/locations/:id"):       true,
+	operationKey(http.MethodPut, "/locations/:id"):       true,
+	operationKey(http.MethodPatch, "/locations/:id"):     true,
+	operationKey(http.MethodGet, "/authors/:id"):         true,
+	operationKey(http.MethodPut, "/authors/:id"):         true,
+	operationKey(http.MethodPatch, "/authors/:id"):       true,
+	operationKey(http.MethodGet, "/books/issue/:id"):     true,
+	operationKey(http.MethodGet, "/materials/:id"):       true,
+	operationKey(http.MethodPut, "/materials/:id"):       true,
+	operationKey(http.MethodPatch, "/materials/:id"):     true,
+	operationKey(http.MethodGet, "/subjects/:id"):        true,
+	operationKey(http.MethodGet, "/subjects/name/:name"): true,
+	operationKey(http.MethodPut, "/subjects/:id"):        true,
+	operationKey(http.MethodPatch, "/subjects/:id"):      true,
+
+	// Reverting a material returns it at its new version.
+	operationKey(http.MethodPost, "/materials/:id/revisions/:revision/revert"): true,
+}
+
+// unversionedPaths are served at the root rather than under /api/<version>.
+var unversionedPaths = map[string]bool{
+	"/health":       true,
+	"/openapi.json": true,
+	"/docs":         true,
+}
+
+// OpenAPISpec is the generated OpenAPI 3 document together with the lookup
+// tables used to check routes and responses against it.
+type OpenAPISpec struct {
+	Document   map[string]any
+	basePath   string
+	operations map[string]apiOperation
+	builder    *schemaBuilder
+}
+
+func operationKey(method, path string) string {
+	return method + " " + path
+}
+
+// NewOpenAPISpec builds the OpenAPI document of the v1 API from
+// apiOperations.
+func NewOpenAPISpec() *OpenAPISpec {
+	b := &schemaBuilder{components: map[string]schema{}}
+	spec := &OpenAPISpec{basePath: versionPrefix("v1"), operations: map[string]apiOperation{}, builder: b}
+
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations() {
+		spec.operations[operationKey(op.Method, op.Path)] = op
+
+		oasPath, params := openAPIPath(op.Path)
+		item, ok := paths[oasPath]
+		if !ok {
+			item = map[string]any{}
+			paths[oasPath] = item
+		}
+
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"operationId": operationID(op.Method, op.Path),
+		}
+		if unversionedPaths[op.Path] {
+			operation["servers"] = []map[string]any{{"url": "/"}}
+		}
+		var parameters []map[string]any
+		for _, p := range params {
+			parameters = append(parameters, map[string]any{
+				"name":     p,
+				"in":       "path",
+				"required": true,
+				"schema":   schema{"type": "string"},
+			})
+		}
+		for _, q := range operationQuery[operationKey(op.Method, op.Path)] {
+			parameters = append(parameters, map[string]any{
+				"name":        q.Name,
+				"in":          "query",
+				"description": q.Description,
+				"schema":      schema{"type": q.Type},
+			})
+		}
+		if _, ok := op.Responses[http.StatusPreconditionFailed]; ok {
+			parameters = append(parameters, map[string]any{
+				"name":        ifMatchHeader,
+				"in":          "header",
+				"description": "Apply the write only to this version of the record, as returned in its ETag, or to any of a comma-separated list of them; a book's contributors take the book's ETag",
+				"schema":      schema{"type": "string"},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if p, ok := op.Request.(patchOf); ok {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					mergePatchContentType: map[string]any{"schema": b.schemaFor(p.Value)},
+				},
+			}
+		} else if u, ok := op.Request.(upload); ok {
+			content := map[string]any{}
+			for _, ct := range u.ContentTypes {
+				if u.Form != nil {
+					content[ct] = map[string]any{"schema": b.schemaFor(u.Form)}
+				} else {
+					content[ct] = map[string]any{"schema": schema{"type": "string", "format": "binary"}}
+				}
+			}
+			operation["requestBody"] = map[string]any{"required": true, "content": content}
+		} else if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": b.schemaFor(op.Request)},
+				},
+			}
+		}
+		responses := map[string]any{}
+		for status, body := range op.Responses {
+			content := map[string]any{}
+			if d, ok := body.(download); ok {
+				for _, ct := range d.ContentTypes {
+					content[ct] = map[string]any{"schema": schema{"type": "string", "format": "binary"}}
+				}
+			} else {
+				content["application/json"] = map[string]any{"schema": b.schemaFor(body)}
+			}
+			response := map[string]any{
+				"description": http.StatusText(status),
+				"content":     content,
+			}
+			if status == http.StatusOK && etagOperations[operationKey(op.Method, op.Path)] {
+				response["headers"] = map[string]any{
+					"ETag": map[string]any{"description": "Version of the record, for " + ifMatchHeader, "schema": schema{"type": "string"}},
+				}
+			}
+			responses[fmt.Sprint(status)] = response
+		}
+		operation["responses"] = responses
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	spec.Document = map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Library Management API",
+			"version": "1.0.0",
+			"description": "Write requests name who is making them in the " + actorHeader + " header and may give a reason in " +
+				reasonHeader + ". Both are recorded in the audit log together with the request's " + requestIDHeader + ". " +
+				"Records carry a version, returned as their ETag; send it in " + ifMatchHeader + " to update or delete a record " +
+				"only if nobody has changed it since, and get 412 otherwise. " +
+				"PATCH takes a JSON merge patch (" + mergePatchContentType + ") of the body PUT would take and writes only " +
+				"the fields it changes; a book's is_checked_out only changes when the book is issued or returned. " +
+				"Book types, material types, user classes and languages must be active terms of their vocabularies " +
+				"(see /vocabularies); any other value gets 400.",
+		},
+		"servers": []map[string]any{{"url": spec.basePath}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": b.components,
+		},
+	}
+	return spec
+}
+
+// operation finds the operation for a registered Gin route. Versioned
+// routes and their unversioned legacy aliases share one operation.
+func (s *OpenAPISpec) operation(method, route string) (apiOperation, bool) {
+	if trimmed := strings.TrimPrefix(route, s.basePath); trimmed != route {
+		if trimmed == "" {
+			trimmed = "/"
+		}
+		route = trimmed
+	}
+	op, ok := s.operations[operationKey(method, route)]
+	return op, ok
+}
+
+// MissingRoutes lists registered Gin routes that have no operation in the
+// spec. Web UI routes and routes of other API versions are ignored.
+func (s *OpenAPISpec) MissingRoutes(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, r := range routes {
+		if isUIRoute(r.Path) {
+			continue
+		}
+		if strings.HasPrefix(r.Path, "/api/") && !strings.HasPrefix(r.Path, s.basePath+"/") {
+			continue
+		}
+		if _, ok := s.operation(r.Method, r.Path); !ok {
+			missing = append(missing, operationKey(r.Method, r.Path))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// ServeJSON writes the OpenAPI document.
+func (s *OpenAPISpec) ServeJSON(c *gin.Context) {
+	c.JSON(http.StatusOK, s.Document)
+}
+
+//go:embed docs.html
+var docsPage []byte
+
+// ServeDocs renders a browsable page for the OpenAPI document.
+func (s *OpenAPISpec) ServeDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// openAPIPath converts a Gin path ("/books/:id") to OpenAPI form
+// ("/books/{id}") and returns its path parameters.
+func openAPIPath(ginPath string) (string, []string) {
+	var params []string
+	segments := strings.Split(ginPath, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func operationID(method, ginPath string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(ginPath, "/") {
+		seg = strings.TrimLeft(seg, ":*")
+		for _, part := range strings.FieldsFunc(seg, func(r rune) bool { return r == '_' || r == '.' || r == '-' }) {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// SCHEMA GENERATION
+
+type schemaBuilder struct {
+	components map[string]schema
+}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+func (b *schemaBuilder) schemaFor(v any) schema {
+	switch v := v.(type) {
+	case schema:
+		return v
+	case envelope:
+		props := schema{v.Key: b.schemaFor(v.Value)}
+		required := []string{v.Key}
+		if v.WithMessage {
+			props["message"] = schema{"type": "string"}
+			required = append(required, "message")
+		}
+		return schema{"type": "object", "properties": props, "required": required}
+	}
+	return b.schemaForType(reflect.TypeOf(v))
+}
+
+func (b *schemaBuilder) schemaForType(t reflect.Type) schema {
+	switch t {
+	case uuidType:
+		return schema{"type": "string", "format": "uuid"}
+	case timeType:
+		return schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schemaForType(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return schema{"allOf": []schema{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": b.schemaForType(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": b.schemaForType(t.Elem())}
+	case reflect.Interface:
+		return schema{}
+	case reflect.Struct:
+		return b.structRef(t)
+	}
+	return schema{}
+}
+
+// bindingEnum returns the values a binding rule limits a field to, if any.
+func bindingEnum(rules string) []string {
+	for _, rule := range strings.Split(rules, ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return strings.Fields(values)
+		}
+	}
+	return nil
+}
+
+// structRef registers t as a component schema and returns a reference to
+// it. Request fields are required when they carry binding:"required" and
+// list the values a oneof rule allows; response fields are required unless
+// they are omitempty. A format tag (format:"date-time") annotates string
+// fields.
+func (b *schemaBuilder) structRef(t reflect.Type) schema {
+	name := t.Name()
+	ref := schema{"$ref": "#/components/schemas/" + name}
+	if _, ok := b.components[name]; ok {
+		return ref
+	}
+	// Reserve the name first so recursive types terminate.
+	b.components[name] = schema{}
+
+	props := schema{}
+	var required []string
+	isRequest := strings.HasSuffix(name, "Request")
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		fieldName := parts[0]
+		if fieldName == "" {
+			fieldName = f.Name
+		}
+		omitempty := false
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				omitempty = true
+			}
+		}
+		fieldSchema := b.schemaForType(f.Type)
+		if format := f.Tag.Get("format"); format != "" {
+			fieldSchema["format"] = format
+		}
+		if enum := bindingEnum(f.Tag.Get("binding")); enum != nil {
+			fieldSchema["enum"] = enum
+		}
+		props[fieldName] = fieldSchema
+
+		if isRequest {
+			if strings.Contains(f.Tag.Get("binding"), "required") {
+				required = append(required, fieldName)
+			}
+		} else if !omitempty {
+			required = append(required, fieldName)
+		}
+	}
+
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	b.components[name] = s
+	return ref
+}
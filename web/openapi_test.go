@@ -0,0 +1,146 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestEveryRouteIsDocumented builds the router the way cmd/main.go does
+// and checks that the spec covers every API route.
+func TestEveryRouteIsDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	spec := NewOpenAPISpec()
+	if err := RegisterUI(r, newFixture().handler()); err != nil {
+		t.Fatal(err)
+	}
+	RegisterAPI(r, newFixture().handler(), DefaultAPIConfig())
+	r.GET("/health", func(c *gin.Context) {})
+	r.GET("/openapi.json", spec.ServeJSON)
+	r.GET("/docs", spec.ServeDocs)
+
+	if missing := spec.MissingRoutes(r.Routes()); len(missing) > 0 {
+		t.Errorf("routes missing from the spec: %v", missing)
+	}
+
+	// Every versioned route must also be served at its legacy alias.
+	routes := map[string]bool{}
+	for _, route := range r.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	for _, route := range r.Routes() {
+		if legacy, ok := strings.CutPrefix(route.Path, versionPrefix("v1")); ok && !routes[route.Method+" "+legacy] {
+			t.Errorf("%s %s has no legacy alias", route.Method, route.Path)
+		}
+	}
+}
+
+// TestMissingRoutesReportsUndocumentedRoutes guards the check above
+// against passing vacuously.
+func TestMissingRoutesReportsUndocumentedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	noop := func(c *gin.Context) {}
+	r.GET("/api/v1/books", noop)
+	r.GET("/api/v1/widgets", noop)
+	r.GET("/widgets/:id", noop)
+	r.GET("/api/v2/widgets", noop)
+	r.GET("/ui/widgets", noop)
+
+	missing := NewOpenAPISpec().MissingRoutes(r.Routes())
+	want := []string{"GET /api/v1/widgets", "GET /widgets/:id"}
+	if len(missing) != len(want) {
+		t.Fatalf("MissingRoutes = %v, want %v", missing, want)
+	}
+	for i := range want {
+		if missing[i] != want[i] {
+			t.Errorf("MissingRoutes[%d] = %q, want %q", i, missing[i], want[i])
+		}
+	}
+}
+
+// TestResponsesMatchSpec runs representative handlers, successes and
+// errors, and checks each response against the schema declared for it.
+func TestResponsesMatchSpec(t *testing.T) {
+	f := newFixture()
+	spec := NewOpenAPISpec()
+
+	var problems []string
+	validate := func(c *gin.Context) {
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+		if err := spec.ValidateResponse(c.Request.Method, c.FullPath(), c.Writer.Status(), c.Writer.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	r := newTestRouter(f.handler(), validate)
+
+	material := "/api/v1/materials/" + f.materialID.String()
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"list books", http.MethodGet, "/api/v1/books", "", http.StatusOK},
+		{"get book", http.MethodGet, "/api/v1/books/" + f.bookID.String(), "", http.StatusOK},
+		{"get book by barcode", http.MethodGet, "/api/v1/books/30001000000010", "", http.StatusOK},
+		{"get book contributors", http.MethodGet, "/api/v1/books/" + f.bookID.String() + "/contributors", "", http.StatusOK},
+		{"book not found", http.MethodGet, "/api/v1/books/" + f.userID.String(), "", http.StatusNotFound},
+		{"invalid book reference", http.MethodGet, "/api/v1/books/nope", "", http.StatusBadRequest},
+		{"create book with invalid body", http.MethodPost, "/api/v1/books", `{"title":""}`, http.StatusBadRequest},
+		{"list users", http.MethodGet, "/api/v1/users", "", http.StatusOK},
+		{"get user", http.MethodGet, "/api/v1/users/" + f.userID.String(), "", http.StatusOK},
+		{"list subjects", http.MethodGet, "/api/v1/subjects", "", http.StatusOK},
+		{"get material", http.MethodGet, material, "", http.StatusOK},
+		{"list material revisions", http.MethodGet, material + "/revisions", "", http.StatusOK},
+		{"get material revision", http.MethodGet, material + "/revisions/1", "", http.StatusOK},
+		{"revision not found", http.MethodGet, material + "/revisions/9", "", http.StatusNotFound},
+		{"legacy alias", http.MethodGet, "/materials/" + f.materialID.String(), "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems = nil
+			w := serve(r, tt.method, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			for _, p := range problems {
+				t.Error(p)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := NewOpenAPISpec()
+	tests := []struct {
+		name   string
+		route  string
+		status int
+		body   string
+		ok     bool
+	}{
+		{"matching error", "/books/:id", http.StatusNotFound, `{"error":"Book not found"}`, true},
+		{"undeclared status", "/books/:id", http.StatusTeapot, `{"error":"x"}`, false},
+		{"missing required field", "/books/:id", http.StatusOK, `{"title":"Dune"}`, false},
+		{"wrong type", "/books/:id", http.StatusNotFound, `{"error":5}`, false},
+		{"bad uuid", "/users/:id", http.StatusOK, `{"user":{"id":"x","name":"Ann","class":"10","version":1}}`, false},
+		{"not JSON", "/books/:id", http.StatusNotFound, `{`, false},
+		{"versioned route", "/api/v1/books/:id", http.StatusNotFound, `{"error":"Book not found"}`, true},
+		{"UI route is skipped", "/ui/books/new", http.StatusTeapot, `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := spec.ValidateResponse(http.MethodGet, tt.route, tt.status, "application/json; charset=utf-8", []byte(tt.body))
+			if (err == nil) != tt.ok {
+				t.Errorf("ValidateResponse = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// bodyRecorder tees the response body so it can be inspected after the
+// handler has run.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ValidateResponses returns middleware that checks every JSON response
+// against the schema declared for its route and status code and logs any
+// mismatch. It is meant for development, where GO_ENV=development.
+func (s *OpenAPISpec) ValidateResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		if err := s.ValidateResponse(c.Request.Method, c.FullPath(), c.Writer.Status(), c.Writer.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			log.Printf("openapi: %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+	}
+}
+
+// ValidateResponse checks a single response body against the spec.
+func (s *OpenAPISpec) ValidateResponse(method, route string, status int, contentType string, body []byte) error {
+	if route == "" || isUIRoute(route) {
+		return nil
+	}
+	op, ok := s.operation(method, route)
+	if !ok {
+		return fmt.Errorf("route %s %s is not in the spec", method, route)
+	}
+	declared, ok := op.Responses[status]
+	if !ok {
+		return fmt.Errorf("status %d is not declared", status)
+	}
+	if !strings.HasPrefix(contentType, "application/json") {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if problems := s.validateValue(value, s.builder.schemaFor(declared), "$"); len(problems) > 0 {
+		return fmt.Errorf("response does not match schema: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *OpenAPISpec) resolve(sch schema) schema {
+	for {
+		ref, ok := sch["$ref"].(string)
+		if !ok {
+			return sch
+		}
+		sch = s.builder.components[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+}
+
+// validateValue implements the subset of JSON Schema produced by
+// schemaBuilder: type, format, properties, required, items, allOf and
+// nullable.
+func (s *OpenAPISpec) validateValue(value any, sch schema, path string) []string {
+	sch = s.resolve(sch)
+
+	if value == nil {
+		if nullable, _ := sch["nullable"].(bool); nullable {
+			return nil
+		}
+		if _, typed := sch["type"]; !typed && sch["allOf"] == nil {
+			return nil
+		}
+		return []string{path + ": must not be null"}
+	}
+
+	if all, ok := sch["allOf"].([]schema); ok {
+		var problems []string
+		for _, sub := range all {
+			problems = append(problems, s.validateValue(value, sub, path)...)
+		}
+		return problems
+	}
+
+	switch sch["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{path + ": expected object"}
+		}
+		var problems []string
+		if required, ok := sch["required"].([]string); ok {
+			for _, name := range required {
+				if _, present := obj[name]; !present {
+					problems = append(problems, fmt.Sprintf("%s.%s: is required", path, name))
+				}
+			}
+		}
+		if props, ok := sch["properties"].(schema); ok {
+			for name, v := range obj {
+				if propSchema, declared := props[name].(schema); declared {
+					problems = append(problems, s.validateValue(v, propSchema, path+"."+name)...)
+				}
+			}
+		}
+		if additional, ok := sch["additionalProperties"].(schema); ok {
+			for name, v := range obj {
+				problems = append(problems, s.validateValue(v, additional, path+"."+name)...)
+			}
+		}
+		return problems
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return []string{path + ": expected array"}
+		}
+		var problems []string
+		items, _ := sch["items"].(schema)
+		for i, v := range arr {
+			problems = append(problems, s.validateValue(v, items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{path + ": expected string"}
+		}
+		switch sch["format"] {
+		case "uuid":
+			if _, err := uuid.Parse(str); err != nil {
+				return []string{path + ": expected uuid"}
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return []string{path + ": expected RFC 3339 date-time"}
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{path + ": expected integer"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{path + ": expected number"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{path + ": expected boolean"}
+		}
+	}
+	return nil
+}
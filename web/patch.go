@@ -0,0 +1,422 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// PATCH routes take RFC 7396 JSON merge patches against the body a PUT of
+// the record would take. The patched body is validated like a PUT body and
+// only the fields it changes are written.
+const mergePatchContentType = "application/merge-patch+json"
+
+// mergePatch applies patch to target: objects are merged key by key, null
+// removes a key and any other value replaces the target.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatch(t[key], value)
+	}
+	return t
+}
+
+// jsonObject returns v as it appears in JSON.
+func jsonObject(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]any
+	err = json.Unmarshal(data, &object)
+	return object, err
+}
+
+// patchRequest applies the request's merge patch to current, the update
+// request that would leave the record as it is. It returns the patched
+// request and the JSON fields whose values it changed, sorted. Fields an
+// update request does not have, like is_checked_out, cannot be patched.
+func patchRequest[T any](h *Handler, c *gin.Context, current T) (T, []string, *apiError) {
+	var patched T
+	if ct := c.ContentType(); ct != mergePatchContentType && ct != binding.MIMEJSON {
+		return patched, nil, newAPIError(http.StatusUnsupportedMediaType, "PATCH takes "+mergePatchContentType)
+	}
+
+	var patch map[string]any
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		return patched, nil, newAPIError(http.StatusBadRequest, "The body must be a JSON merge patch object")
+	}
+
+	before, err := jsonObject(current)
+	if err != nil {
+		return patched, nil, newAPIError(http.StatusInternalServerError, "Failed to apply patch")
+	}
+	fields := make([]string, 0, len(before))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	for field := range patch {
+		if !slices.Contains(fields, field) {
+			return patched, nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("%s cannot be changed with PATCH", field))
+		}
+	}
+
+	target, err := jsonObject(current)
+	if err != nil {
+		return patched, nil, newAPIError(http.StatusInternalServerError, "Failed to apply patch")
+	}
+	data, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return patched, nil, newAPIError(http.StatusInternalServerError, "Failed to apply patch")
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&patched); err != nil {
+		return patched, nil, invalidRequest(err)
+	}
+	if err := binding.Validator.ValidateStruct(patched); err != nil {
+		stored := func() (termRequest, error) {
+			terms, _ := any(current).(termRequest)
+			return terms, nil
+		}
+		return patched, nil, h.withTermErrors(invalidRequest(err), patched, stored)
+	}
+
+	after, err := jsonObject(patched)
+	if err != nil {
+		return patched, nil, newAPIError(http.StatusInternalServerError, "Failed to apply patch")
+	}
+	var changed []string
+	for _, field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changed = append(changed, field)
+		}
+	}
+	return patched, changed, nil
+}
+
+// PATCH HANDLERS
+
+func (h *Handler) PatchBook(c *gin.Context) {
+	bookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Book", bookID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	current, err := h.BookStore.Book(bookID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Book")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	contributors, apiErr := h.bookContributors(bookID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	req, changed, apiErr := patchRequest(h, c, newUpdateBookRequest(current, contributors))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if len(changed) > 0 {
+		book := req.toModel(bookID)
+		book.Version = version
+		var columns []string
+		for _, field := range changed {
+			switch field {
+			case "isbn":
+				number, apiErr := h.bookISBN(req.ISBN, bookID)
+				if apiErr != nil {
+					c.JSON(apiErr.Status, apiErr.Body)
+					return
+				}
+				setISBN(&book, number)
+				columns = append(columns, "isbn10", "isbn13")
+			case "contributors":
+				reqs, apiErr := contributorRequests("", req.Contributors)
+				if apiErr != nil {
+					c.JSON(apiErr.Status, apiErr.Body)
+					return
+				}
+				if book.Contributors, apiErr = h.resolveContributors(reqs); apiErr != nil {
+					c.JSON(apiErr.Status, apiErr.Body)
+					return
+				}
+				if !slices.Contains(changed, "author_id") {
+					columns = append(columns, "author_id")
+				}
+			default:
+				columns = append(columns, field)
+			}
+		}
+
+		if err := h.BookStore.UpdateBook(&book, columns...); err != nil {
+			apiErr := writeError(err, "Book", "update")
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
+
+	updated, err := h.BookStore.Book(bookID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if updated.Contributors, apiErr = h.bookContributors(bookID); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Book updated successfully", "book": newBookDTO(updated)})
+}
+
+func (h *Handler) PatchUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	version, apiErr := h.ifMatch(c, "User", userID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	current, err := h.UserStore.User(userID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "User")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	req, changed, apiErr := patchRequest(h, c, UpdateUserRequest{Name: current.Name, Class: current.Class})
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if len(changed) > 0 {
+		user := req.toModel(userID)
+		user.Version = version
+		if err := h.UserStore.UpdateUser(&user, changed...); err != nil {
+			apiErr := writeError(err, "User", "update")
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
+
+	updated, err := h.UserStore.User(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": newUserDTO(updated)})
+}
+
+func (h *Handler) PatchLocation(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Location", locationID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	current, err := h.LocationStore.Location(locationID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Location")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	req, changed, apiErr := patchRequest(h, c, UpdateLocationRequest{Name: current.Name})
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if len(changed) > 0 {
+		location := req.toModel(locationID)
+		location.Version = version
+		if err := h.LocationStore.UpdateLocation(&location, changed...); err != nil {
+			apiErr := writeError(err, "Location", "update")
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
+
+	updated, err := h.LocationStore.Location(locationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Location updated successfully", "location": newLocationDTO(updated)})
+}
+
+func (h *Handler) PatchAuthor(c *gin.Context) {
+	authorID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Author", authorID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	current, err := h.AuthorStore.Author(authorID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Author")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	req, changed, apiErr := patchRequest(h, c, UpdateAuthorRequest{Name: current.Name})
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if len(changed) > 0 {
+		author := req.toModel(authorID)
+		author.Version = version
+		if err := h.AuthorStore.UpdateAuthor(&author, changed...); err != nil {
+			apiErr := writeError(err, "Author", "update")
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
+
+	updated, err := h.AuthorStore.Author(authorID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+		return
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Author updated successfully", "author": newAuthorDTO(updated)})
+}
+
+func (h *Handler) PatchMaterial(c *gin.Context) {
+	materialID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid material ID"})
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Material", materialID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	current, err := h.MaterialStore.Material(materialID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Material")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	req, changed, apiErr := patchRequest(h, c, newUpdateMaterialRequest(current))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if len(changed) > 0 {
+		material := req.toModel(materialID)
+		material.SubjectID = current.SubjectID
+		material.Version = version
+		if i := slices.Index(changed, "subject_name"); i >= 0 {
+			subject, apiErr := h.materialSubject(req.SubjectName, req.Language)
+			if apiErr != nil {
+				c.JSON(apiErr.Status, apiErr.Body)
+				return
+			}
+			material.SubjectID = subject.ID
+			changed[i] = "subject_id"
+		}
+		if err := h.MaterialStore.UpdateMaterial(&material, changed...); err != nil {
+			apiErr := writeError(err, "Material", "update")
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
+
+	updated, err := h.MaterialStore.Material(materialID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+		return
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Material updated successfully", "material": newMaterialDTO(updated)})
+}
+
+func (h *Handler) PatchSubject(c *gin.Context) {
+	subjectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject ID"})
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Subject", subjectID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	current, err := h.SubjectStore.Subject(subjectID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Subject")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	req, changed, apiErr := patchRequest(h, c, UpdateSubjectRequest{Name: current.Name, Language: current.Language, ParentID: current.ParentID})
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	updated, renamed := current, 0
+	if len(changed) > 0 {
+		subject := req.toModel(subjectID)
+		subject.Version = version
+		if updated, renamed, apiErr = h.updateSubject(current, subject, changed...); apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Subject updated successfully", "subject": newSubjectDTO(updated), "materials_affected": renamed})
+}
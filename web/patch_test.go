@@ -0,0 +1,226 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TestMergePatch runs the examples from RFC 7396, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target+" "+tt.patch, func(t *testing.T) {
+			var target, patch, want any
+			for _, v := range []struct {
+				doc string
+				dst *any
+			}{{tt.target, &target}, {tt.patch, &patch}, {tt.want, &want}} {
+				if err := json.Unmarshal([]byte(v.doc), v.dst); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPatchRequest(t *testing.T) {
+	authorID, locationID := uuid.New(), uuid.New()
+	current := UpdateBookRequest{
+		Title:      "Dune",
+		AuthorID:   authorID,
+		LocationID: locationID,
+		BookType:   "fiction",
+		Publisher:  "Chilton",
+		PageCount:  412,
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		changed     []string
+		check       func(UpdateBookRequest) bool
+	}{
+		{
+			name:    "one field",
+			patch:   `{"title": "Dune Messiah"}`,
+			changed: []string{"title"},
+			check:   func(r UpdateBookRequest) bool { return r.Title == "Dune Messiah" && r.AuthorID == authorID },
+		},
+		{
+			name:        "merge patch content type",
+			contentType: mergePatchContentType,
+			patch:       `{"page_count": 500, "publisher": "Ace"}`,
+			changed:     []string{"page_count", "publisher"},
+			check:       func(r UpdateBookRequest) bool { return r.PageCount == 500 && r.Publisher == "Ace" },
+		},
+		{
+			name:    "null clears an optional field",
+			patch:   `{"publisher": null}`,
+			changed: []string{"publisher"},
+			check:   func(r UpdateBookRequest) bool { return r.Publisher == "" && r.PageCount == 412 },
+		},
+		{
+			name:  "same value",
+			patch: `{"title": "Dune"}`,
+			check: func(r UpdateBookRequest) bool { return reflect.DeepEqual(r, current) },
+		},
+		{
+			name:  "empty patch",
+			patch: `{}`,
+			check: func(r UpdateBookRequest) bool { return reflect.DeepEqual(r, current) },
+		},
+		{name: "null clears a required field", patch: `{"title": null}`, status: http.StatusBadRequest},
+		{name: "invalid value", patch: `{"page_count": -1}`, status: http.StatusBadRequest},
+		{name: "wrong type", patch: `{"title": 7}`, status: http.StatusBadRequest},
+		{name: "circulation field", patch: `{"is_checked_out": true}`, status: http.StatusBadRequest},
+		{name: "unknown field", patch: `{"shelf": "3"}`, status: http.StatusBadRequest},
+		{name: "not an object", patch: `["title"]`, status: http.StatusBadRequest},
+		{name: "null document", patch: `null`, status: http.StatusBadRequest},
+		{name: "malformed", patch: `{"title":`, status: http.StatusBadRequest},
+		{name: "JSON Patch", contentType: "application/json-patch+json", patch: `[]`, status: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.patch))
+			c.Request.Header.Set("Content-Type", contentType)
+
+			got, changed, apiErr := patchRequest(&Handler{}, c, current)
+			if apiErr != nil {
+				if apiErr.Status != tt.status {
+					t.Errorf("status = %d, want %d: %v", apiErr.Status, tt.status, apiErr.Body)
+				}
+				return
+			}
+			if tt.status != 0 {
+				t.Fatalf("patch applied, want status %d", tt.status)
+			}
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Errorf("changed = %q, want %q", changed, tt.changed)
+			}
+			if !tt.check(got) {
+				t.Errorf("patched request = %+v", got)
+			}
+		})
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		ifMatch string
+		status  int
+		columns []string
+		class   string
+	}{
+		{name: "changes one column", patch: `{"class": "11"}`, status: http.StatusOK, columns: []string{"class"}, class: "11"},
+		{name: "unchanged value writes nothing", patch: `{"name": "Ann"}`, status: http.StatusOK, class: "10"},
+		{name: "current version", patch: `{"class": "11"}`, ifMatch: `"1"`, status: http.StatusOK, columns: []string{"class"}, class: "11"},
+		{name: "stale version", patch: `{"class": "11"}`, ifMatch: `"4"`, status: http.StatusPreconditionFailed, class: "10"},
+		{name: "required field removed", patch: `{"name": null}`, status: http.StatusBadRequest, class: "10"},
+		{name: "card number is not patchable", patch: `{"card_number": "20001000000012"}`, status: http.StatusBadRequest, class: "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			headers := []string{"Content-Type", mergePatchContentType}
+			if tt.ifMatch != "" {
+				headers = append(headers, ifMatchHeader, tt.ifMatch)
+			}
+			w := serve(newTestRouter(f.handler()), http.MethodPatch, "/api/v1/users/"+f.userID.String(), tt.patch, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !reflect.DeepEqual(f.users.columns, tt.columns) {
+				t.Errorf("wrote columns %q, want %q", f.users.columns, tt.columns)
+			}
+			if got := f.users.users[f.userID]; got.Class != tt.class || got.Name != "Ann" {
+				t.Errorf("user = %+v", got)
+			}
+		})
+	}
+}
+
+// TestUpdateUser checks that a replaced user is answered with what was
+// stored, card number included, not just what the request set.
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch string
+		status  int
+		class   string
+	}{
+		{name: "replaced", body: `{"name": "Ann", "class": "11"}`, status: http.StatusOK, class: "11"},
+		{name: "current version", body: `{"name": "Ann", "class": "11"}`, ifMatch: `"1"`, status: http.StatusOK, class: "11"},
+		{name: "stale version", body: `{"name": "Ann", "class": "11"}`, ifMatch: `"4"`, status: http.StatusPreconditionFailed, class: "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{ifMatchHeader, tt.ifMatch}
+			}
+			w := serve(newTestRouter(f.handler()), http.MethodPut, "/api/v1/users/"+f.userID.String(), tt.body, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := f.users.users[f.userID].Class; got != tt.class {
+				t.Errorf("class = %q, want %q", got, tt.class)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				User UserDTO `json:"user"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			want := UserDTO{ID: f.userID, Name: "Ann", Class: tt.class, CardNumber: "20001000000012", Version: 2}
+			if resp.User != want {
+				t.Errorf("user = %+v, want %+v", resp.User, want)
+			}
+			if got := w.Header().Get("ETag"); got != `"2"` {
+				t.Errorf("ETag = %s, want \"2\"", got)
+			}
+		})
+	}
+}
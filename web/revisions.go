@@ -0,0 +1,166 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Every change to a material's content is kept as a numbered revision,
+// attributed to the X-Actor of the request and summarized with the fields
+// it changed and any X-Change-Reason.
+
+// HELPER FUNCTIONS
+
+// revisionNumber parses a revision number from the path or query.
+func revisionNumber(raw, name string) (int, *apiError) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, newAPIError(http.StatusBadRequest, name+" must be a revision number")
+	}
+	return n, nil
+}
+
+func (h *Handler) revisionParam(c *gin.Context, material model.Material) (model.MaterialRevision, *apiError) {
+	n, apiErr := revisionNumber(c.Param("revision"), "revision")
+	if apiErr != nil {
+		return model.MaterialRevision{}, apiErr
+	}
+	revision, err := h.MaterialStore.Revision(material.ID, n)
+	if err != nil {
+		return model.MaterialRevision{}, lookupAPIError(err, "Revision")
+	}
+	return revision, nil
+}
+
+// GET HANDLERS
+
+// GetMaterialRevisions lists a material's revisions, oldest first.
+func (h *Handler) GetMaterialRevisions(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	revisions, err := h.MaterialStore.Revisions(material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revisions": mapSlice(revisions, newMaterialRevisionDTO)})
+}
+
+func (h *Handler) GetMaterialRevision(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	revision, apiErr := h.revisionParam(c, material)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revision": newMaterialRevisionDTO(revision)})
+}
+
+// DiffMaterialRevisions lists the fields that changed from revision ?from=
+// to revision ?to=. to defaults to the latest revision and from to the
+// one before to.
+func (h *Handler) DiffMaterialRevisions(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var to int
+	if raw := c.Query("to"); raw != "" {
+		if to, apiErr = revisionNumber(raw, "to"); apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	} else {
+		revisions, err := h.MaterialStore.Revisions(material.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+			return
+		}
+		if len(revisions) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+			return
+		}
+		to = revisions[len(revisions)-1].Revision
+	}
+	from := to - 1
+	if raw := c.Query("from"); raw != "" {
+		if from, apiErr = revisionNumber(raw, "from"); apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+	} else if from < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Revision 1 has no earlier revision; give from"})
+		return
+	}
+
+	changes, err := h.MaterialStore.DiffRevisions(material.ID, from, to)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Revision")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"diff": RevisionDiffDTO{
+		MaterialID: material.ID,
+		From:       from,
+		To:         to,
+		Changes:    mapSlice(changes, newFieldChangeDTO),
+	}})
+}
+
+// UPDATE HANDLERS
+
+// RevertMaterial restores the content of an earlier revision, which adds
+// a revision of its own.
+func (h *Handler) RevertMaterial(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	revision, apiErr := h.revisionParam(c, material)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Material", material.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := h.MaterialStore.RevertMaterial(material.ID, revision.Revision, version); err != nil {
+		if errors.Is(err, model.ErrSubjectGone) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The subject of revision " + strconv.Itoa(revision.Revision) + " has been deleted; restore it first"})
+			return
+		}
+		apiErr := writeError(err, "Material", "revert")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	reverted, err := h.MaterialStore.Material(material.ID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Material")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	setETag(c, reverted.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material reverted to revision " + strconv.Itoa(revision.Revision),
+		"material": newMaterialDTO(reverted),
+	})
+}
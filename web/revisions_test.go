@@ -0,0 +1,201 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetMaterialRevisions(t *testing.T) {
+	f := newFixture()
+	r := newTestRouter(f.handler())
+
+	tests := []struct {
+		material string
+		status   int
+		titles   []string
+	}{
+		{f.materialID.String(), http.StatusOK, []string{"Algebra", "Algebra notes"}},
+		{uuid.NewString(), http.StatusNotFound, nil},
+		{"algebra", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.material, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/api/v1/materials/"+tt.material+"/revisions", "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Revisions []MaterialRevisionDTO `json:"revisions"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var titles []string
+			for i, rev := range resp.Revisions {
+				titles = append(titles, rev.Title)
+				if rev.Revision != i+1 || rev.MaterialID != f.materialID {
+					t.Errorf("revision %d = %+v", i, rev)
+				}
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("titles = %q, want %q", titles, tt.titles)
+			}
+		})
+	}
+}
+
+func TestGetMaterialRevision(t *testing.T) {
+	f := newFixture()
+	r := newTestRouter(f.handler())
+
+	tests := []struct {
+		revision string
+		status   int
+		title    string
+		author   string
+	}{
+		{"1", http.StatusOK, "Algebra", "system"},
+		{"2", http.StatusOK, "Algebra notes", "ann"},
+		{"3", http.StatusNotFound, "", ""},
+		{"0", http.StatusBadRequest, "", ""},
+		{"latest", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.revision, func(t *testing.T) {
+			w := serve(r, http.MethodGet, "/api/v1/materials/"+f.materialID.String()+"/revisions/"+tt.revision, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Revision MaterialRevisionDTO `json:"revision"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Revision.Title != tt.title || resp.Revision.Author != tt.author {
+				t.Errorf("revision = %+v, want title %q by %s", resp.Revision, tt.title, tt.author)
+			}
+		})
+	}
+}
+
+func TestDiffMaterialRevisions(t *testing.T) {
+	renamed := []FieldChangeDTO{{Field: "title", From: "Algebra", To: "Algebra notes"}}
+	tests := []struct {
+		name        string
+		query       string
+		noRevisions bool
+		status      int
+		from, to    int
+		changes     []FieldChangeDTO
+	}{
+		{name: "latest against the one before", status: http.StatusOK, from: 1, to: 2, changes: renamed},
+		{name: "to with the one before", query: "?to=2", status: http.StatusOK, from: 1, to: 2, changes: renamed},
+		{name: "backwards", query: "?from=2&to=1", status: http.StatusOK, from: 2, to: 1,
+			changes: []FieldChangeDTO{{Field: "title", From: "Algebra notes", To: "Algebra"}}},
+		{name: "a revision with itself", query: "?from=1&to=1", status: http.StatusOK, from: 1, to: 1, changes: []FieldChangeDTO{}},
+		{name: "from against the latest", query: "?from=1", status: http.StatusOK, from: 1, to: 2, changes: renamed},
+		{name: "nothing before revision 1", query: "?to=1", status: http.StatusBadRequest},
+		{name: "unknown revision", query: "?to=9", status: http.StatusNotFound},
+		{name: "invalid from", query: "?from=first", status: http.StatusBadRequest},
+		{name: "from zero", query: "?from=0&to=2", status: http.StatusBadRequest},
+		{name: "no revisions", noRevisions: true, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.noRevisions {
+				delete(f.materials.revisions, f.materialID)
+			}
+			w := serve(newTestRouter(f.handler()), http.MethodGet, "/api/v1/materials/"+f.materialID.String()+"/revisions/diff"+tt.query, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Diff RevisionDiffDTO `json:"diff"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			want := RevisionDiffDTO{MaterialID: f.materialID, From: tt.from, To: tt.to, Changes: tt.changes}
+			if !reflect.DeepEqual(resp.Diff, want) {
+				t.Errorf("diff = %+v, want %+v", resp.Diff, want)
+			}
+		})
+	}
+}
+
+func TestRevertMaterial(t *testing.T) {
+	tests := []struct {
+		name        string
+		revision    string
+		ifMatch     string
+		subjectGone bool
+		status      int
+		title       string
+		revisions   int
+	}{
+		{name: "reverted", revision: "1", ifMatch: `"2"`, status: http.StatusOK, title: "Algebra", revisions: 3},
+		{name: "unconditional", revision: "1", status: http.StatusOK, title: "Algebra", revisions: 3},
+		{name: "stale version", revision: "1", ifMatch: `"1"`, status: http.StatusPreconditionFailed, title: "Algebra notes", revisions: 2},
+		{name: "unknown revision", revision: "9", status: http.StatusNotFound, title: "Algebra notes", revisions: 2},
+		{name: "invalid revision", revision: "first", status: http.StatusBadRequest, title: "Algebra notes", revisions: 2},
+		{name: "subject deleted", revision: "1", subjectGone: true, status: http.StatusConflict, title: "Algebra notes", revisions: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.subjectGone {
+				delete(f.subjects.subjects, f.subjectID)
+			}
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+
+			w := serve(newTestRouter(f.handler()), http.MethodPost, "/api/v1/materials/"+f.materialID.String()+"/revisions/"+tt.revision+"/revert", "", headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := f.materials.materials[f.materialID].Title; got != tt.title {
+				t.Errorf("title = %q, want %q", got, tt.title)
+			}
+			revisions := f.materials.revisions[f.materialID]
+			if len(revisions) != tt.revisions {
+				t.Fatalf("%d revisions, want %d", len(revisions), tt.revisions)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Material MaterialDTO `json:"material"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Material.Title != tt.title || resp.Material.Version != 3 {
+				t.Errorf("material = %+v", resp.Material)
+			}
+			if got := w.Header().Get("ETag"); got != `"3"` {
+				t.Errorf("ETag = %s, want \"3\"", got)
+			}
+			if latest := revisions[len(revisions)-1]; latest.Summary != "Reverted to revision 1" || latest.Version != 3 {
+				t.Errorf("latest revision = %+v", latest)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion is one representation of the API mounted under /api/<Name>.
+// Versions live side by side; a new version registers its own routes and
+// may reuse handlers from older ones.
+type APIVersion struct {
+	Name   string
+	Routes func(h *Handler, r gin.IRoutes)
+
+	// Deprecated and Sunset, when set, are announced on every response of
+	// the version through the Deprecation and Sunset headers.
+	Deprecated time.Time
+	Sunset     time.Time
+}
+
+// APIConfig selects the versions to serve and how the unversioned legacy
+// paths (/books, /users, ...) behave during the transition.
+type APIConfig struct {
+	Versions []APIVersion
+
+	// LegacyAlias names the version also served at the root, without the
+	// /api/<version> prefix. Empty disables the aliases.
+	LegacyAlias      string
+	LegacyDeprecated time.Time
+	LegacySunset     time.Time
+}
+
+// DefaultAPIConfig serves v1 and keeps the unversioned paths as deprecated
+// aliases of it.
+func DefaultAPIConfig() APIConfig {
+	return APIConfig{
+		Versions:         []APIVersion{{Name: "v1", Routes: (*Handler).RoutesV1}},
+		LegacyAlias:      "v1",
+		LegacyDeprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		LegacySunset:     time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func versionPrefix(name string) string {
+	return "/api/" + name
+}
+
+// RegisterAPI mounts every configured version and the legacy aliases.
+func RegisterAPI(router *gin.Engine, h *Handler, cfg APIConfig) {
+	for _, v := range cfg.Versions {
+		group := router.Group(versionPrefix(v.Name), requestID(), deprecation(v.Deprecated, v.Sunset, ""))
+		v.Routes(h, group)
+
+		if v.Name == cfg.LegacyAlias {
+			legacy := router.Group("/", requestID(), deprecation(cfg.LegacyDeprecated, cfg.LegacySunset, versionPrefix(v.Name)))
+			v.Routes(h, legacy)
+		}
+	}
+}
+
+// deprecation sets the Deprecation (RFC 9745) and Sunset (RFC 8594)
+// headers. When successor is set, a Link header points clients at the same
+// path under that prefix.
+func deprecation(deprecated, sunset time.Time, successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !deprecated.IsZero() {
+			c.Header("Deprecation", "@"+strconv.FormatInt(deprecated.Unix(), 10))
+		}
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor != "" {
+			c.Header("Link", "<"+successor+c.Request.URL.Path+`>; rel="successor-version"`)
+		}
+		c.Next()
+	}
+}
+
+// RoutesV1 registers the v1 API.
+func (h *Handler) RoutesV1(r gin.IRoutes) {
+	// Book routes
+	r.GET("/books", h.GetBooks)
+	r.GET("/books/:id", h.GetBook)
+	r.GET("/books/isbn/:isbn", h.GetBookByISBN)
+	r.POST("/books", h.audited((*Handler).CreateBook))
+	r.POST("/books/import-by-isbn", h.audited((*Handler).ImportBookByISBN))
+	r.POST("/books/marc/import", h.audited((*Handler).ImportMARC))
+	r.GET("/books/marc/export", h.ExportMARC)
+	r.POST("/books/csv", h.audited((*Handler).ImportBooksCSV))
+	r.GET("/books/csv", h.ExportBooksCSV)
+	r.PUT("/books/:id", h.audited((*Handler).UpdateBook))
+	r.PATCH("/books/:id", h.audited((*Handler).PatchBook))
+	r.GET("/books/:id/contributors", h.GetBookContributors)
+	r.GET("/books/:id/subjects", h.GetBookSubjects)
+	r.PUT("/books/:id/subjects", h.audited((*Handler).SetBookSubjects))
+	r.GET("/books/:id/history", h.HistoryHandler(model.EntityBook))
+	r.PUT("/books/:id/contributors", h.audited((*Handler).SetBookContributors))
+	r.DELETE("/books/:id", h.audited((*Handler).DeleteBook))
+	r.POST("/books/:id/restore", h.audited(restoreHandler("books")))
+
+	// User routes
+	r.GET("/users", h.GetUsers)
+	r.GET("/users/:id", h.GetUser)
+	r.GET("/users/:id/history", h.HistoryHandler(model.EntityUser))
+	r.POST("/users", h.audited((*Handler).CreateUser))
+	r.POST("/users/csv", h.audited((*Handler).ImportUsersCSV))
+	r.GET("/users/csv", h.ExportUsersCSV)
+	r.PUT("/users/:id", h.audited((*Handler).UpdateUser))
+	r.PATCH("/users/:id", h.audited((*Handler).PatchUser))
+	r.DELETE("/users/:id", h.audited((*Handler).DeleteUser))
+	r.POST("/users/:id/restore", h.audited(restoreHandler("users")))
+	r.POST("/users/:id/fees/pay", h.audited((*Handler).PayUserFees))
+
+	// Location routes
+	r.GET("/locations", h.GetLocations)
+	r.GET("/locations/duplicates", h.GetLocationDuplicates)
+	r.GET("/locations/:id", h.GetLocation)
+	r.GET("/locations/:id/history", h.HistoryHandler(model.EntityLocation))
+	r.POST("/locations", h.audited((*Handler).CreateLocation))
+	r.PUT("/locations/:id", h.audited((*Handler).UpdateLocation))
+	r.PATCH("/locations/:id", h.audited((*Handler).PatchLocation))
+	r.DELETE("/locations/:id", h.audited((*Handler).DeleteLocation))
+	r.POST("/locations/:id/restore", h.audited(restoreHandler("locations")))
+
+	// Author routes
+	r.GET("/authors", h.GetAuthors)
+	r.GET("/authors/duplicates", h.GetAuthorDuplicates)
+	r.GET("/authors/:id", h.GetAuthor)
+	r.GET("/authors/:id/books", h.GetAuthorBooks)
+	r.GET("/authors/:id/merges", h.GetAuthorMerges)
+	r.GET("/authors/:id/history", h.HistoryHandler(model.EntityAuthor))
+	r.POST("/authors/:id/merge", h.audited((*Handler).MergeAuthors))
+	r.POST("/authors", h.audited((*Handler).CreateAuthor))
+	r.PUT("/authors/:id", h.audited((*Handler).UpdateAuthor))
+	r.PATCH("/authors/:id", h.audited((*Handler).PatchAuthor))
+	r.DELETE("/authors/:id", h.audited((*Handler).DeleteAuthor))
+	r.POST("/authors/:id/restore", h.audited(restoreHandler("authors")))
+
+	// Issued Book routes
+	r.GET("/books/issue/:id", h.GetIssuedBook)
+	r.GET("/books/issue", h.GetIssuedBooks)
+	r.POST("/books/issue", h.audited((*Handler).IssueBook))
+	r.POST("/books/return", h.audited((*Handler).ReturnBook))
+
+	// Circulation desk routes
+	r.POST("/circulation/sessions", h.audited((*Handler).StartCirculationSession))
+	r.GET("/circulation/sessions/:id", h.GetCirculationSession)
+	r.POST("/circulation/sessions/:id/scans", h.audited((*Handler).ScanCirculationItem))
+	r.DELETE("/circulation/sessions/:id", h.audited((*Handler).EndCirculationSession))
+
+	// Label routes
+	r.GET("/labels/layouts", h.GetLabelLayouts)
+	r.POST("/labels/books", h.audited((*Handler).PrintBookLabels))
+	r.POST("/labels/patrons", h.audited((*Handler).PrintPatronCards))
+
+	// Material routes
+	r.GET("/materials", h.GetMaterials)
+	r.GET("/materials/:id", h.GetMaterial)
+	r.GET("/materials/:id/history", h.HistoryHandler(model.EntityMaterial))
+	r.GET("/materials/:id/link", h.GetMaterialLinkCheck)
+	r.GET("/materials/:id/revisions", h.GetMaterialRevisions)
+	r.GET("/materials/:id/revisions/diff", h.DiffMaterialRevisions)
+	r.GET("/materials/:id/revisions/:revision", h.GetMaterialRevision)
+	r.POST("/materials/:id/revisions/:revision/revert", h.audited((*Handler).RevertMaterial))
+	r.GET("/materials/:id/subjects", h.GetMaterialSubjects)
+	r.PUT("/materials/:id/subjects", h.audited((*Handler).SetMaterialSubjects))
+	r.GET("/materials/:id/files", h.GetMaterialFiles)
+	r.POST("/materials/:id/files", h.audited((*Handler).UploadMaterialFile))
+	r.GET("/materials/:id/files/:file_id", h.DownloadMaterialFile)
+	r.DELETE("/materials/:id/files/:file_id", h.audited((*Handler).DeleteMaterialFile))
+	r.POST("/materials", h.audited((*Handler).CreateMaterial))
+	r.POST("/materials/csv", h.audited((*Handler).ImportMaterialsCSV))
+	r.GET("/materials/csv", h.ExportMaterialsCSV)
+	r.GET("/materials/subject/:subject_name", h.GetMaterialsBySubject)
+	r.GET("/materials/language/:language", h.GetMaterialsByLanguage)
+	r.PUT("/materials/:id", h.audited((*Handler).UpdateMaterial))
+	r.PATCH("/materials/:id", h.audited((*Handler).PatchMaterial))
+	r.DELETE("/materials/:id", h.audited((*Handler).DeleteMaterial))
+	r.POST("/materials/:id/restore", h.audited(restoreHandler("materials")))
+
+	// Subject routes
+	r.GET("/subjects", h.GetSubjects)
+	r.GET("/subjects/browse", h.BrowseSubjects)
+	r.GET("/subjects/:id/browse", h.BrowseSubject)
+	r.GET("/subjects/:id", h.GetSubject)
+	r.GET("/subjects/:id/history", h.HistoryHandler(model.EntitySubject))
+	r.POST("/subjects", h.audited((*Handler).CreateSubject))
+	r.POST("/subjects/csv", h.audited((*Handler).ImportSubjectsCSV))
+	r.GET("/subjects/csv", h.ExportSubjectsCSV)
+	r.GET("/subjects/name/:name", h.GetSubjectByName)
+	r.PUT("/subjects/:id", h.audited((*Handler).UpdateSubject))
+	r.PATCH("/subjects/:id", h.audited((*Handler).PatchSubject))
+	r.DELETE("/subjects/:id", h.audited((*Handler).DeleteSubject))
+	r.POST("/subjects/:id/restore", h.audited(restoreHandler("subjects")))
+
+	// Vocabulary routes
+	r.GET("/vocabularies", h.GetVocabularies)
+	r.GET("/vocabularies/:vocabulary", h.GetVocabularyTerms)
+	r.GET("/vocabularies/:vocabulary/:code", h.GetVocabularyTerm)
+	r.POST("/admin/vocabularies/:vocabulary", h.requireAdmin, h.audited((*Handler).CreateVocabularyTerm))
+	r.PUT("/admin/vocabularies/:vocabulary/:code", h.requireAdmin, h.audited((*Handler).UpdateVocabularyTerm))
+	r.DELETE("/admin/vocabularies/:vocabulary/:code", h.requireAdmin, h.audited((*Handler).DeleteVocabularyTerm))
+
+	// Audit routes
+	r.GET("/audit", h.GetAuditLog)
+
+	// Trash routes
+	r.GET("/trash", h.GetTrash)
+	r.DELETE("/admin/trash/:kind/:id", h.requireAdmin, h.audited((*Handler).PurgeRecord))
+}
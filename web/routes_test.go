@@ -0,0 +1,91 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterAPIDeprecationHeaders(t *testing.T) {
+	f := newFixture()
+	book := "/books/" + f.bookID.String()
+
+	tests := []struct {
+		name        string
+		path        string
+		deprecation string
+		sunset      string
+		link        string
+	}{
+		{"current version", "/api/v1" + book, "", "", ""},
+		{"legacy alias", book, "@1792368000", "Fri, 30 Apr 2027 00:00:00 GMT", "</api/v1" + book + `>; rel="successor-version"`},
+	}
+	r := newTestRouter(f.handler())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodGet, tt.path, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			for header, want := range map[string]string{"Deprecation": tt.deprecation, "Sunset": tt.sunset, "Link": tt.link} {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if w.Header().Get(requestIDHeader) == "" {
+				t.Errorf("%s is not set", requestIDHeader)
+			}
+		})
+	}
+}
+
+// TestRegisterAPIVersionsSideBySide mounts a second version that reuses a
+// v1 handler and deprecates v1, the way a v2 would be introduced.
+func TestRegisterAPIVersionsSideBySide(t *testing.T) {
+	f := newFixture()
+	deprecated := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cfg := APIConfig{
+		Versions: []APIVersion{
+			{Name: "v1", Routes: (*Handler).RoutesV1, Deprecated: deprecated},
+			{Name: "v2", Routes: func(h *Handler, r gin.IRoutes) {
+				r.GET("/books/:id", h.GetBook)
+			}},
+		},
+		LegacyAlias: "v2",
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterAPI(r, f.handler(), cfg)
+
+	book := "/books/" + f.bookID.String()
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		deprecation string
+		link        string
+	}{
+		{"deprecated v1", http.MethodGet, "/api/v1" + book, http.StatusOK, "@1798761600", ""},
+		{"v2", http.MethodGet, "/api/v2" + book, http.StatusOK, "", ""},
+		{"route not in v2", http.MethodGet, "/api/v2/users", http.StatusNotFound, "", ""},
+		{"legacy alias of v2", http.MethodGet, book, http.StatusOK, "", "</api/v2" + book + `>; rel="successor-version"`},
+		{"v1 route without an alias", http.MethodGet, "/users", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, tt.method, tt.path, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+			if got := w.Header().Get("Link"); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+}
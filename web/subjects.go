@@ -0,0 +1,240 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HELPER FUNCTIONS
+
+// filingSubjects checks that every subject a record is to be filed under
+// exists and is not deleted, and drops repeats.
+func (h *Handler) filingSubjects(ids []uuid.UUID) ([]uuid.UUID, *apiError) {
+	out := make([]uuid.UUID, 0, len(ids))
+	seen := map[uuid.UUID]bool{}
+	for i, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if _, err := h.SubjectStore.Subject(id); err != nil {
+			apiErr := lookupAPIError(err, "Subject")
+			if apiErr.Status == http.StatusNotFound {
+				field := fmt.Sprintf("subject_ids[%d]", i)
+				apiErr = newAPIError(http.StatusBadRequest, "Invalid request body")
+				apiErr.Body["fields"] = []FieldErrorDTO{{Field: field, Rule: "subject", Message: field + " must name a subject that is not deleted"}}
+			}
+			return nil, apiErr
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// fileBook files a new or imported book under subjects, keeping those it
+// is already filed under.
+func (h *Handler) fileBook(bookID uuid.UUID, subjects []model.Subject) error {
+	if len(subjects) == 0 {
+		return nil
+	}
+	current, err := h.BookStore.Subjects(bookID)
+	if err != nil {
+		return err
+	}
+	ids := make([]uuid.UUID, 0, len(current)+len(subjects))
+	for _, s := range append(current, subjects...) {
+		ids = append(ids, s.ID)
+	}
+	return h.BookStore.SetSubjects(bookID, 0, ids)
+}
+
+func (h *Handler) materialParam(c *gin.Context) (model.Material, *apiError) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return model.Material{}, newAPIError(http.StatusBadRequest, "Invalid material ID")
+	}
+	material, err := h.MaterialStore.Material(id)
+	if err != nil {
+		return model.Material{}, lookupAPIError(err, "Material")
+	}
+	return material, nil
+}
+
+// GET HANDLERS
+
+// BrowseSubjects lists the top-level subjects with counts over their
+// subtrees.
+func (h *Handler) BrowseSubjects(c *gin.Context) {
+	nodes, err := h.SubjectStore.SubjectNodes(uuid.Nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subjects": mapSlice(nodes, newSubjectNodeDTO)})
+}
+
+// BrowseSubject shows a subject with the subjects above and below it and
+// the books and materials filed anywhere in its subtree, or with
+// ?direct=true only those filed under the subject itself.
+func (h *Handler) BrowseSubject(c *gin.Context) {
+	subjectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject ID"})
+		return
+	}
+
+	node, err := h.SubjectStore.SubjectNode(subjectID)
+	if err != nil {
+		apiErr := lookupAPIError(err, "Subject")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	path, err := h.SubjectStore.Ancestors(subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+	children, err := h.SubjectStore.SubjectNodes(subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	subtree := c.Query("direct") != "true"
+	books, err := h.BookStore.BooksInSubject(subjectID, subtree)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch books"})
+		return
+	}
+	materials, err := h.MaterialStore.MaterialsInSubject(subjectID, subtree)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch materials"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SubjectBrowseDTO{
+		Subject:   newSubjectNodeDTO(node),
+		Path:      mapSlice(path, newSubjectDTO),
+		Children:  mapSlice(children, newSubjectNodeDTO),
+		Books:     mapSlice(books, newBookDTO),
+		Materials: mapSlice(materials, newMaterialDTO),
+	})
+}
+
+func (h *Handler) GetBookSubjects(c *gin.Context) {
+	book, apiErr := h.resolveBook(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	subjects, err := h.BookStore.Subjects(book.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subjects": mapSlice(subjects, newSubjectDTO)})
+}
+
+func (h *Handler) GetMaterialSubjects(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	subjects, err := h.MaterialStore.Subjects(material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subjects": mapSlice(subjects, newSubjectDTO)})
+}
+
+// UPDATE HANDLERS
+
+func (h *Handler) SetBookSubjects(c *gin.Context) {
+	book, apiErr := h.resolveBook(c.Param("id"))
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Book", book.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req SetSubjectsRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	subjectIDs, apiErr := h.filingSubjects(req.SubjectIDs)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := h.BookStore.SetSubjects(book.ID, version, subjectIDs); err != nil {
+		apiErr := writeError(err, "Book", "update subjects of")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	subjects, err := h.BookStore.Subjects(book.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subjects updated successfully", "subjects": mapSlice(subjects, newSubjectDTO)})
+}
+
+// SetMaterialSubjects files a material under the given subjects. It stays
+// filed under its own subject whether or not that one is listed.
+func (h *Handler) SetMaterialSubjects(c *gin.Context) {
+	material, apiErr := h.materialParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	version, apiErr := h.ifMatch(c, "Material", material.ID)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req SetSubjectsRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	subjectIDs, apiErr := h.filingSubjects(req.SubjectIDs)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := h.MaterialStore.SetSubjects(material.ID, version, subjectIDs); err != nil {
+		apiErr := writeError(err, "Material", "update subjects of")
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	subjects, err := h.MaterialStore.Subjects(material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subjects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subjects updated successfully", "subjects": mapSlice(subjects, newSubjectDTO)})
+}
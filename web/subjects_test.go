@@ -0,0 +1,497 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+// subjectTree puts the fixture's subjects in a hierarchy:
+//
+//	Art
+//	Science > Mathematics (with the material)
+//	Science > Physics > Optics (with the book, also filed under Physics)
+func subjectTree(f *fixture) map[string]uuid.UUID {
+	ids := map[string]uuid.UUID{"Mathematics": f.subjectID}
+	for _, s := range []struct{ name, parent string }{
+		{"Art", ""}, {"Science", ""}, {"Physics", "Science"}, {"Optics", "Physics"},
+	} {
+		id := uuid.New()
+		ids[s.name] = id
+		subject := model.Subject{ID: id, Name: s.name, Language: "en", Version: 1}
+		if s.parent != "" {
+			parent := ids[s.parent]
+			subject.ParentID = &parent
+		}
+		f.subjects.subjects[id] = subject
+	}
+	maths := f.subjects.subjects[f.subjectID]
+	science := ids["Science"]
+	maths.ParentID = &science
+	f.subjects.subjects[f.subjectID] = maths
+	f.books.filed[f.bookID] = []uuid.UUID{ids["Optics"], ids["Physics"]}
+	return ids
+}
+
+func subjectNames[T SubjectDTO | SubjectNodeDTO](subjects []T) []string {
+	names := []string{}
+	for _, s := range subjects {
+		switch s := any(s).(type) {
+		case SubjectDTO:
+			names = append(names, s.Name)
+		case SubjectNodeDTO:
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+func TestBrowseSubject(t *testing.T) {
+	tests := []struct {
+		subject   string
+		query     string
+		status    int
+		node      SubjectNodeDTO
+		path      []string
+		children  []string
+		books     int
+		materials int
+	}{
+		{
+			subject:   "Science",
+			status:    http.StatusOK,
+			node:      SubjectNodeDTO{Name: "Science", Children: 2, Descendants: 3, Books: 1, Materials: 1},
+			path:      []string{},
+			children:  []string{"Mathematics", "Physics"},
+			books:     1,
+			materials: 1,
+		},
+		{
+			subject:  "Science",
+			query:    "?direct=true",
+			status:   http.StatusOK,
+			node:     SubjectNodeDTO{Name: "Science", Children: 2, Descendants: 3, Books: 1, Materials: 1},
+			path:     []string{},
+			children: []string{"Mathematics", "Physics"},
+		},
+		{
+			subject:  "Physics",
+			query:    "?direct=true",
+			status:   http.StatusOK,
+			node:     SubjectNodeDTO{Name: "Physics", Children: 1, Descendants: 1, Books: 1},
+			path:     []string{"Science"},
+			children: []string{"Optics"},
+			books:    1,
+		},
+		{
+			subject:  "Optics",
+			status:   http.StatusOK,
+			node:     SubjectNodeDTO{Name: "Optics", Books: 1},
+			path:     []string{"Science", "Physics"},
+			children: []string{},
+			books:    1,
+		},
+		{
+			subject:   "Mathematics",
+			status:    http.StatusOK,
+			node:      SubjectNodeDTO{Name: "Mathematics", Materials: 1},
+			path:      []string{"Science"},
+			children:  []string{},
+			materials: 1,
+		},
+		{
+			subject:  "Art",
+			status:   http.StatusOK,
+			node:     SubjectNodeDTO{Name: "Art"},
+			path:     []string{},
+			children: []string{},
+		},
+		{subject: "unknown", status: http.StatusNotFound},
+		{subject: "science", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject+tt.query, func(t *testing.T) {
+			f := newFixture()
+			ids := subjectTree(f)
+			id := tt.subject
+			switch {
+			case ids[tt.subject] != uuid.Nil:
+				id = ids[tt.subject].String()
+			case tt.subject == "unknown":
+				id = uuid.NewString()
+			}
+
+			w := serve(newTestRouter(f.handler()), http.MethodGet, "/api/v1/subjects/"+id+"/browse"+tt.query, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp SubjectBrowseDTO
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			node := resp.Subject
+			node.ID, node.ParentID, node.Language = uuid.Nil, nil, ""
+			if node != tt.node {
+				t.Errorf("subject = %+v, want %+v", node, tt.node)
+			}
+			if got := subjectNames(resp.Path); !reflect.DeepEqual(got, tt.path) {
+				t.Errorf("path = %q, want %q", got, tt.path)
+			}
+			if got := subjectNames(resp.Children); !reflect.DeepEqual(got, tt.children) {
+				t.Errorf("children = %q, want %q", got, tt.children)
+			}
+			if len(resp.Books) != tt.books || len(resp.Materials) != tt.materials {
+				t.Errorf("%d books and %d materials, want %d and %d", len(resp.Books), len(resp.Materials), tt.books, tt.materials)
+			}
+		})
+	}
+}
+
+func TestBrowseSubjects(t *testing.T) {
+	f := newFixture()
+	subjectTree(f)
+
+	w := serve(newTestRouter(f.handler()), http.MethodGet, "/api/v1/subjects/browse", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Subjects []SubjectNodeDTO `json:"subjects"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := subjectNames(resp.Subjects); !reflect.DeepEqual(got, []string{"Art", "Science"}) {
+		t.Fatalf("subjects = %q", got)
+	}
+	if science := resp.Subjects[1]; science.Descendants != 3 || science.Books != 1 || science.Materials != 1 {
+		t.Errorf("Science = %+v", science)
+	}
+}
+
+func TestSetSubjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		ifMatch  string
+		subjects []string
+		status   int
+		want     []string
+	}{
+		{
+			name:     "book",
+			path:     "/api/v1/books/{book}/subjects",
+			subjects: []string{"Physics", "Art", "Physics"},
+			status:   http.StatusOK,
+			want:     []string{"Art", "Physics"},
+		},
+		{
+			name:     "book by barcode",
+			path:     "/api/v1/books/30001000000010/subjects",
+			ifMatch:  `"3"`,
+			subjects: []string{"Art"},
+			status:   http.StatusOK,
+			want:     []string{"Art"},
+		},
+		{
+			name:   "book out of every subject",
+			path:   "/api/v1/books/{book}/subjects",
+			status: http.StatusOK,
+			want:   []string{},
+		},
+		{
+			name:     "stale book version",
+			path:     "/api/v1/books/{book}/subjects",
+			ifMatch:  `"2"`,
+			subjects: []string{"Art"},
+			status:   http.StatusPreconditionFailed,
+		},
+		{
+			name:     "unknown subject",
+			path:     "/api/v1/books/{book}/subjects",
+			subjects: []string{"Art", "unknown"},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "material stays under its own subject",
+			path:     "/api/v1/materials/{material}/subjects",
+			ifMatch:  `"1", "2"`,
+			subjects: []string{"Physics"},
+			status:   http.StatusOK,
+			want:     []string{"Mathematics", "Physics"},
+		},
+		{
+			name:     "unknown material",
+			path:     "/api/v1/materials/" + uuid.NewString() + "/subjects",
+			subjects: []string{"Physics"},
+			status:   http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			ids := subjectTree(f)
+			var subjectIDs []string
+			for _, name := range tt.subjects {
+				id, ok := ids[name]
+				if !ok {
+					id = uuid.New()
+				}
+				subjectIDs = append(subjectIDs, `"`+id.String()+`"`)
+			}
+			body := `{"subject_ids": [` + strings.Join(subjectIDs, ", ") + `]}`
+			path := strings.NewReplacer("{book}", f.bookID.String(), "{material}", f.materialID.String()).Replace(tt.path)
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{ifMatchHeader, tt.ifMatch}
+			}
+
+			w := serve(newTestRouter(f.handler()), http.MethodPut, path, body, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusBadRequest && !strings.Contains(w.Body.String(), `"subject_ids[1]"`) {
+				t.Errorf("body = %s, want the unknown subject's field", w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if got := f.books.filed[f.bookID]; len(got) != 2 {
+					t.Errorf("book filed under %v", got)
+				}
+				return
+			}
+			var resp struct {
+				Subjects []SubjectDTO `json:"subjects"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if got := subjectNames(resp.Subjects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		ifMatch  string
+		noTx     bool
+		status   int
+		affected int
+		subject  string
+		taken    string // the subject a clash names
+	}{
+		{
+			name:     "rename keeps materials",
+			method:   http.MethodPut,
+			body:     `{"name": "Algebra", "language": "en", "parent_id": "{science}"}`,
+			status:   http.StatusOK,
+			affected: 1,
+			subject:  "Algebra",
+		},
+		{
+			name:    "same name",
+			method:  http.MethodPut,
+			body:    `{"name": "Mathematics", "language": "de", "parent_id": "{science}"}`,
+			status:  http.StatusOK,
+			subject: "Mathematics",
+		},
+		{
+			name:     "rename by patch",
+			method:   http.MethodPatch,
+			body:     `{"name": "Algebra"}`,
+			ifMatch:  `"1"`,
+			status:   http.StatusOK,
+			affected: 1,
+			subject:  "Algebra",
+		},
+		{
+			name:    "move by patch",
+			method:  http.MethodPatch,
+			body:    `{"parent_id": "{art}"}`,
+			status:  http.StatusOK,
+			subject: "Mathematics",
+		},
+		{
+			name:    "name of another subject",
+			method:  http.MethodPatch,
+			body:    `{"name": "Physics"}`,
+			status:  http.StatusConflict,
+			subject: "Mathematics",
+			taken:   "Physics",
+		},
+		{
+			name:    "name of another subject replaced",
+			method:  http.MethodPut,
+			body:    `{"name": "Art", "language": "en"}`,
+			status:  http.StatusConflict,
+			subject: "Mathematics",
+			taken:   "Art",
+		},
+		{
+			name:    "no transactions",
+			method:  http.MethodPut,
+			body:    `{"name": "Algebra", "language": "en"}`,
+			noTx:    true,
+			status:  http.StatusServiceUnavailable,
+			subject: "Mathematics",
+		},
+		{
+			name:    "below itself",
+			method:  http.MethodPatch,
+			body:    `{"parent_id": "{maths}"}`,
+			status:  http.StatusBadRequest,
+			subject: "Mathematics",
+		},
+		{
+			name:    "stale version",
+			method:  http.MethodPut,
+			body:    `{"name": "Algebra", "language": "en"}`,
+			ifMatch: `"3"`,
+			status:  http.StatusPreconditionFailed,
+			subject: "Mathematics",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			ids := subjectTree(f)
+			// Filed under Mathematics too, but named after Physics.
+			otherID := uuid.New()
+			f.materials.materials[otherID] = model.Material{ID: otherID, Title: "Optics notes", SubjectID: ids["Physics"], Version: 1}
+			f.materials.filed[otherID] = []uuid.UUID{ids["Physics"], f.subjectID}
+			body := strings.NewReplacer("{science}", ids["Science"].String(), "{art}", ids["Art"].String(), "{maths}", f.subjectID.String()).Replace(tt.body)
+			headers := []string{"Content-Type", mergePatchContentType}
+			if tt.method == http.MethodPut {
+				headers = nil
+			}
+			if tt.ifMatch != "" {
+				headers = append(headers, ifMatchHeader, tt.ifMatch)
+			}
+
+			h := f.handler()
+			if !tt.noTx {
+				h.Tx = fakeTx{f}
+			}
+
+			w := serve(newTestRouter(h), tt.method, "/api/v1/subjects/"+f.subjectID.String(), body, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := f.subjects.subjects[f.subjectID].Name; got != tt.subject {
+				t.Errorf("subject named %q, want %q", got, tt.subject)
+			}
+			if m := f.materials.materials[f.materialID]; m.SubjectID != f.subjectID {
+				t.Errorf("material moved to %s", m.SubjectID)
+			}
+			var resp struct {
+				Error     string    `json:"error"`
+				SubjectID uuid.UUID `json:"subject_id"`
+				Affected  int       `json:"materials_affected"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if tt.taken != "" && (resp.Error != "A subject with the same name already exists" || resp.SubjectID != ids[tt.taken]) {
+				t.Errorf("clash answered with %s", w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if resp.Affected != tt.affected {
+				t.Errorf("materials_affected = %d, want %d", resp.Affected, tt.affected)
+			}
+		})
+	}
+}
+
+func TestDeleteSubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		subject  string
+		query    string
+		ifMatch  string
+		noTx     bool
+		status   int
+		affected int
+		movedTo  string
+	}{
+		{name: "nothing below it", subject: "Art", status: http.StatusOK, movedTo: "Mathematics"},
+		{name: "materials block it", subject: "Mathematics", status: http.StatusConflict, movedTo: "Mathematics"},
+		{name: "subjects block it", subject: "Physics", query: "?reassign_to={art}", status: http.StatusConflict, movedTo: "Mathematics"},
+		{
+			name:     "materials reassigned",
+			subject:  "Mathematics",
+			query:    "?reassign_to={art}",
+			ifMatch:  `"1"`,
+			status:   http.StatusOK,
+			affected: 1,
+			movedTo:  "Art",
+		},
+		{
+			name:    "stale version rolls the move back",
+			subject: "Mathematics",
+			query:   "?reassign_to={art}",
+			ifMatch: `"2"`,
+			status:  http.StatusPreconditionFailed,
+			movedTo: "Mathematics",
+		},
+		{name: "reassigned to itself", subject: "Mathematics", query: "?reassign_to={maths}", status: http.StatusBadRequest, movedTo: "Mathematics"},
+		{name: "reassigned to an unknown subject", subject: "Mathematics", query: "?reassign_to={unknown}", status: http.StatusBadRequest, movedTo: "Mathematics"},
+		{name: "reassigned to a bad ID", subject: "Mathematics", query: "?reassign_to=art", status: http.StatusBadRequest, movedTo: "Mathematics"},
+		{name: "without transactions", subject: "Mathematics", query: "?reassign_to={art}", noTx: true, status: http.StatusServiceUnavailable, movedTo: "Mathematics"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			ids := subjectTree(f)
+			h := f.handler()
+			if !tt.noTx {
+				h.Tx = fakeTx{f}
+			}
+			query := strings.NewReplacer("{art}", ids["Art"].String(), "{maths}", f.subjectID.String(), "{unknown}", uuid.NewString()).Replace(tt.query)
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{ifMatchHeader, tt.ifMatch}
+			}
+
+			w := serve(newTestRouter(h), http.MethodDelete, "/api/v1/subjects/"+ids[tt.subject].String()+query, "", headers...)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if _, ok := f.subjects.subjects[ids[tt.subject]]; ok != (tt.status != http.StatusOK) {
+				t.Errorf("subject kept = %v after status %d", ok, w.Code)
+			}
+			m := f.materials.materials[f.materialID]
+			if m.SubjectID != ids[tt.movedTo] {
+				t.Errorf("material belongs to %s, want %s", m.SubjectName, tt.movedTo)
+			}
+			if filed := f.materials.filed[f.materialID]; !reflect.DeepEqual(filed, []uuid.UUID{ids[tt.movedTo]}) {
+				t.Errorf("material filed under %v", filed)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Affected int `json:"materials_affected"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Affected != tt.affected {
+				t.Errorf("materials_affected = %d, want %d", resp.Affected, tt.affected)
+			}
+		})
+	}
+}
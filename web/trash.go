@@ -0,0 +1,475 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// adminTokenHeader carries the token that admin operations such as purge
+// require.
+const adminTokenHeader = "X-Admin-Token"
+
+// trashKind describes how one kind of record is deleted, restored and
+// purged. Blockers lists what keeps a live record from being deleted;
+// Restorable checks, inside the restoring transaction, that a restored
+// record has everything it refers to. Either may be nil.
+type trashKind struct {
+	Entity     string
+	Delete     func(h *Handler, id uuid.UUID, version int) error
+	Restore    func(h *Handler, id uuid.UUID) error
+	Purge      func(h *Handler, id uuid.UUID) error
+	Blockers   func(h *Handler, id uuid.UUID) ([]BlockerDTO, *apiError)
+	Restorable func(h *Handler, id uuid.UUID) *apiError
+}
+
+// trashKinds is keyed by the collection name used in paths.
+var trashKinds = map[string]trashKind{
+	"books": {
+		Entity:     "Book",
+		Delete:     func(h *Handler, id uuid.UUID, version int) error { return h.BookStore.DeleteBook(id, version) },
+		Restore:    func(h *Handler, id uuid.UUID) error { return h.BookStore.RestoreBook(id) },
+		Purge:      func(h *Handler, id uuid.UUID) error { return h.BookStore.PurgeBook(id) },
+		Blockers:   (*Handler).bookBlockers,
+		Restorable: (*Handler).bookRestorable,
+	},
+	"users": {
+		Entity:   "User",
+		Delete:   func(h *Handler, id uuid.UUID, version int) error { return h.UserStore.DeleteUser(id, version) },
+		Restore:  func(h *Handler, id uuid.UUID) error { return h.UserStore.RestoreUser(id) },
+		Purge:    func(h *Handler, id uuid.UUID) error { return h.UserStore.PurgeUser(id) },
+		Blockers: (*Handler).userBlockers,
+	},
+	"authors": {
+		Entity:   "Author",
+		Delete:   func(h *Handler, id uuid.UUID, version int) error { return h.AuthorStore.DeleteAuthor(id, version) },
+		Restore:  func(h *Handler, id uuid.UUID) error { return h.AuthorStore.RestoreAuthor(id) },
+		Purge:    func(h *Handler, id uuid.UUID) error { return h.AuthorStore.PurgeAuthor(id) },
+		Blockers: (*Handler).authorBlockers,
+	},
+	"locations": {
+		Entity:   "Location",
+		Delete:   func(h *Handler, id uuid.UUID, version int) error { return h.LocationStore.DeleteLocation(id, version) },
+		Restore:  func(h *Handler, id uuid.UUID) error { return h.LocationStore.RestoreLocation(id) },
+		Purge:    func(h *Handler, id uuid.UUID) error { return h.LocationStore.PurgeLocation(id) },
+		Blockers: (*Handler).locationBlockers,
+	},
+	"subjects": {
+		Entity:     "Subject",
+		Delete:     func(h *Handler, id uuid.UUID, version int) error { return h.SubjectStore.DeleteSubject(id, version) },
+		Restore:    func(h *Handler, id uuid.UUID) error { return h.SubjectStore.RestoreSubject(id) },
+		Purge:      func(h *Handler, id uuid.UUID) error { return h.SubjectStore.PurgeSubject(id) },
+		Blockers:   (*Handler).subjectBlockers,
+		Restorable: (*Handler).subjectRestorable,
+	},
+	"materials": {
+		Entity:     "Material",
+		Delete:     func(h *Handler, id uuid.UUID, version int) error { return h.MaterialStore.DeleteMaterial(id, version) },
+		Restore:    func(h *Handler, id uuid.UUID) error { return h.MaterialStore.RestoreMaterial(id) },
+		Purge:      (*Handler).purgeMaterial,
+		Restorable: (*Handler).materialRestorable,
+	},
+}
+
+// HELPER FUNCTIONS
+
+func bookBlocker(b model.Book) BlockerDTO {
+	return BlockerDTO{Kind: "book", ID: b.ID.String(), Description: fmt.Sprintf("Book %q", b.Title)}
+}
+
+// blocked is the 409 returned when blockers keep a record from being
+// deleted. The message names the first blocker; the body lists them all.
+func blocked(entity string, blockers []BlockerDTO) *apiError {
+	message := fmt.Sprintf("%s cannot be deleted: %s", entity, blockers[0].Description)
+	if len(blockers) > 1 {
+		message += fmt.Sprintf(" and %d more", len(blockers)-1)
+	}
+	return &apiError{Status: http.StatusConflict, Body: gin.H{"error": message, "blockers": blockers}}
+}
+
+// bookBlockers refuses deleting a book that is on loan.
+func (h *Handler) bookBlockers(id uuid.UUID) ([]BlockerDTO, *apiError) {
+	if _, err := h.BookStore.Book(id); err != nil {
+		return nil, lookupAPIError(err, "Book")
+	}
+
+	loan, err := h.IssuedBookStore.GetIssuedBookByBookID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to fetch loans")
+	}
+	if loan.ReturnDate != nil {
+		return nil, nil
+	}
+
+	description := "On loan since " + loan.IssueDate.Format("2006-01-02")
+	if user, err := h.UserStore.User(loan.UserID); err == nil {
+		description = fmt.Sprintf("On loan to %s since %s", user.Name, loan.IssueDate.Format("2006-01-02"))
+	}
+	return []BlockerDTO{{Kind: "loan", ID: loan.ID.String(), Description: description}}, nil
+}
+
+// userBlockers refuses deleting a patron with books on loan or unpaid
+// fees on any loan, returned or not.
+func (h *Handler) userBlockers(id uuid.UUID) ([]BlockerDTO, *apiError) {
+	user, err := h.UserStore.User(id)
+	if err != nil {
+		return nil, lookupAPIError(err, "User")
+	}
+
+	loans, fees, _, apiErr := h.patronStatus(user)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	var blockers []BlockerDTO
+	for _, loan := range loans {
+		title := loan.Title
+		if title == "" {
+			title = loan.BookID.String()
+		}
+		blockers = append(blockers, BlockerDTO{
+			Kind:        "loan",
+			ID:          loan.ID.String(),
+			Description: fmt.Sprintf("Has %q on loan, due %s", title, loan.DueDate[:len("2006-01-02")]),
+		})
+	}
+	if fees > 0 {
+		blockers = append(blockers, BlockerDTO{Kind: "balance", Description: fmt.Sprintf("Outstanding fees of %.2f", fees)})
+	}
+	return blockers, nil
+}
+
+// authorBlockers refuses deleting an author credited on live books, which
+// would otherwise be left without them.
+func (h *Handler) authorBlockers(id uuid.UUID) ([]BlockerDTO, *apiError) {
+	if _, err := h.AuthorStore.Author(id); err != nil {
+		return nil, lookupAPIError(err, "Author")
+	}
+
+	contributions, err := h.AuthorStore.Contributions(id)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to retrieve books")
+	}
+
+	var blockers []BlockerDTO
+	seen := map[uuid.UUID]bool{}
+	for _, contribution := range contributions {
+		if seen[contribution.BookID] {
+			continue
+		}
+		seen[contribution.BookID] = true
+
+		book, err := h.BookStore.Book(contribution.BookID)
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to retrieve books")
+		}
+		blockers = append(blockers, bookBlocker(book))
+	}
+	return blockers, nil
+}
+
+// locationBlockers refuses deleting a location books are shelved at.
+func (h *Handler) locationBlockers(id uuid.UUID) ([]BlockerDTO, *apiError) {
+	if _, err := h.LocationStore.Location(id); err != nil {
+		return nil, lookupAPIError(err, "Location")
+	}
+
+	books, err := h.BookStore.BooksAtLocation(id)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to retrieve books")
+	}
+	return mapSlice(books, bookBlocker), nil
+}
+
+// subjectBlockers refuses deleting a subject that still has materials
+// belonging to it or subjects below it. Books and materials merely filed
+// under it are not blockers; they drop the subject until it is restored.
+func (h *Handler) subjectBlockers(id uuid.UUID) ([]BlockerDTO, *apiError) {
+	subject, err := h.SubjectStore.Subject(id)
+	if err != nil {
+		return nil, lookupAPIError(err, "Subject")
+	}
+
+	materials, err := h.MaterialStore.GetMaterialsBySubject(subject.Name)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to fetch materials")
+	}
+	children, err := h.SubjectStore.Children(id)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to fetch subjects")
+	}
+
+	blockers := mapSlice(materials, func(m model.Material) BlockerDTO {
+		return BlockerDTO{Kind: "material", ID: m.ID.String(), Description: fmt.Sprintf("Material %q", m.Title)}
+	})
+	for _, child := range children {
+		blockers = append(blockers, BlockerDTO{Kind: "subject", ID: child.ID.String(), Description: fmt.Sprintf("Subject %q below it", child.Name)})
+	}
+	return blockers, nil
+}
+
+// bookRestorable requires the restored book's contributors and location to
+// be live.
+func (h *Handler) bookRestorable(id uuid.UUID) *apiError {
+	book, err := h.BookStore.Book(id)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to retrieve book")
+	}
+
+	contributors, apiErr := h.bookContributors(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	for _, c := range contributors {
+		if _, err := h.AuthorStore.Author(c.AuthorID); err != nil {
+			return restoreFirst(err, "author "+c.Name)
+		}
+	}
+	if book.LocationID != uuid.Nil {
+		if _, err := h.LocationStore.Location(book.LocationID); err != nil {
+			return restoreFirst(err, "its location")
+		}
+	}
+	return nil
+}
+
+// materialRestorable requires the restored material's subject to be live.
+func (h *Handler) materialRestorable(id uuid.UUID) *apiError {
+	material, err := h.MaterialStore.Material(id)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to retrieve material")
+	}
+
+	if _, err := h.SubjectStore.Subject(material.SubjectID); err != nil {
+		return restoreFirst(err, "subject "+material.SubjectName)
+	}
+	return nil
+}
+
+// purgeMaterial purges the material with its files, and the stored
+// content no other file shares.
+func (h *Handler) purgeMaterial(id uuid.UUID) error {
+	files, err := h.MaterialStore.Files(id)
+	if err != nil {
+		return err
+	}
+	if err := h.MaterialStore.PurgeMaterial(id); err != nil {
+		return err
+	}
+	for _, f := range files {
+		h.releaseContent(context.Background(), f.SHA256)
+	}
+	return nil
+}
+
+// subjectRestorable requires the restored subject's parent to be live.
+func (h *Handler) subjectRestorable(id uuid.UUID) *apiError {
+	subject, err := h.SubjectStore.Subject(id)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to retrieve subject")
+	}
+
+	if subject.ParentID != nil {
+		if _, err := h.SubjectStore.Subject(*subject.ParentID); err != nil {
+			return restoreFirst(err, "its parent subject")
+		}
+	}
+	return nil
+}
+
+func restoreFirst(err error, what string) *apiError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return newAPIError(http.StatusConflict, "Restore "+what+" first; it is deleted")
+	}
+	return newAPIError(http.StatusInternalServerError, "Failed to check "+what)
+}
+
+// lookupAPIError maps a failed lookup of a live record to 404 or 500.
+func lookupAPIError(err error, entity string) *apiError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return newAPIError(http.StatusNotFound, entity+" not found")
+	}
+	return newAPIError(http.StatusInternalServerError, "Failed to retrieve "+strings.ToLower(entity))
+}
+
+// TRASH OPERATIONS
+
+// softDelete deletes a live record unless something blocks it. A non-zero
+// version must be the record's current one.
+func (h *Handler) softDelete(kind, idParam string, version int) *apiError {
+	k := trashKinds[kind]
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "Invalid "+strings.ToLower(k.Entity)+" ID")
+	}
+
+	if k.Blockers != nil {
+		blockers, apiErr := k.Blockers(h, id)
+		if apiErr != nil {
+			return apiErr
+		}
+		if len(blockers) > 0 {
+			return blocked(k.Entity, blockers)
+		}
+	}
+
+	if err := k.Delete(h, id, version); err != nil {
+		return writeError(err, k.Entity, "delete")
+	}
+	return nil
+}
+
+func (h *Handler) restore(kind, idParam string) *apiError {
+	k := trashKinds[kind]
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "Invalid "+strings.ToLower(k.Entity)+" ID")
+	}
+	if h.Tx == nil {
+		return newAPIError(http.StatusServiceUnavailable, "Restoring needs transactions, which are not configured")
+	}
+
+	var failure *apiError
+	err = h.inTx(func(tx *Handler) error {
+		if err := k.Restore(tx, id); err != nil {
+			return err
+		}
+		if k.Restorable != nil {
+			if failure = k.Restorable(tx, id); failure != nil {
+				return failure
+			}
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return nil
+	case failure != nil:
+		return failure
+	case errors.Is(err, sql.ErrNoRows):
+		return newAPIError(http.StatusNotFound, "No deleted "+strings.ToLower(k.Entity)+" with this ID")
+	case errors.Is(err, model.ErrDuplicate):
+		return newAPIError(http.StatusConflict, k.Entity+" cannot be restored: a live record now has its name or identifier")
+	}
+	return newAPIError(http.StatusInternalServerError, "Failed to restore "+strings.ToLower(k.Entity))
+}
+
+func (h *Handler) purge(kind, idParam string) *apiError {
+	k, ok := trashKinds[kind]
+	if !ok {
+		return newAPIError(http.StatusNotFound, "Unknown record kind "+kind)
+	}
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "Invalid "+strings.ToLower(k.Entity)+" ID")
+	}
+
+	if err := k.Purge(h, id); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return newAPIError(http.StatusNotFound, "No deleted "+strings.ToLower(k.Entity)+" with this ID; delete it before purging")
+		case errors.Is(err, model.ErrInUse):
+			return newAPIError(http.StatusConflict, k.Entity+" cannot be purged: other records, deleted or not, still refer to it")
+		}
+		return newAPIError(http.StatusInternalServerError, "Failed to purge "+strings.ToLower(k.Entity))
+	}
+	return nil
+}
+
+func (h *Handler) trash() (TrashDTO, *apiError) {
+	failed := func() (TrashDTO, *apiError) {
+		return TrashDTO{}, newAPIError(http.StatusInternalServerError, "Failed to retrieve deleted records")
+	}
+
+	books, err := h.BookStore.DeletedBooks()
+	if err != nil {
+		return failed()
+	}
+	authors, err := h.AuthorStore.DeletedAuthors()
+	if err != nil {
+		return failed()
+	}
+	locations, err := h.LocationStore.DeletedLocations()
+	if err != nil {
+		return failed()
+	}
+	users, err := h.UserStore.DeletedUsers()
+	if err != nil {
+		return failed()
+	}
+	subjects, err := h.SubjectStore.DeletedSubjects()
+	if err != nil {
+		return failed()
+	}
+	materials, err := h.MaterialStore.DeletedMaterials()
+	if err != nil {
+		return failed()
+	}
+
+	return TrashDTO{
+		Books:     mapSlice(books, newBookDTO),
+		Authors:   mapSlice(authors, newAuthorDTO),
+		Locations: mapSlice(locations, newLocationDTO),
+		Users:     mapSlice(users, newUserDTO),
+		Subjects:  mapSlice(subjects, newSubjectDTO),
+		Materials: mapSlice(materials, newMaterialDTO),
+	}, nil
+}
+
+// requireAdmin lets a request through only when it carries the configured
+// admin token. Without a configured token admin operations are disabled.
+func (h *Handler) requireAdmin(c *gin.Context) {
+	if h.AdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin operations are disabled"})
+		return
+	}
+	token := c.GetHeader(adminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid " + adminTokenHeader})
+		return
+	}
+	c.Next()
+}
+
+// HANDLERS
+
+// restoreHandler returns the handler that restores a deleted record of the
+// given kind.
+func restoreHandler(kind string) func(h *Handler, c *gin.Context) {
+	entity := trashKinds[kind].Entity
+	return func(h *Handler, c *gin.Context) {
+		if apiErr := h.restore(kind, c.Param("id")); apiErr != nil {
+			c.JSON(apiErr.Status, apiErr.Body)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": entity + " restored successfully"})
+	}
+}
+
+func (h *Handler) GetTrash(c *gin.Context) {
+	trash, apiErr := h.trash()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"trash": trash})
+}
+
+func (h *Handler) PurgeRecord(c *gin.Context) {
+	if apiErr := h.purge(c.Param("kind"), c.Param("id")); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Record purged permanently"})
+}
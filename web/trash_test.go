@@ -0,0 +1,120 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/google/uuid"
+)
+
+func TestDeleteUserBlockers(t *testing.T) {
+	longAgo := time.Now().AddDate(0, -2, 0)
+	returned := longAgo.AddDate(0, 0, 30)
+	paid := returned.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		loans    []model.IssuedBook
+		status   int
+		blockers []string
+	}{
+		{name: "no loans", status: http.StatusOK},
+		{
+			name:   "fees paid",
+			loans:  []model.IssuedBook{{IssueDate: longAgo, ReturnDate: &returned, LateFees: 30, FeesPaidAt: &paid}},
+			status: http.StatusOK,
+		},
+		{
+			name:     "book on loan",
+			loans:    []model.IssuedBook{{IssueDate: time.Now()}},
+			status:   http.StatusConflict,
+			blockers: []string{"loan"},
+		},
+		{
+			name:     "unpaid fees on a returned loan",
+			loans:    []model.IssuedBook{{IssueDate: longAgo, ReturnDate: &returned, LateFees: 30}},
+			status:   http.StatusConflict,
+			blockers: []string{"balance"},
+		},
+		{
+			name: "unpaid fees below the borrowing limit",
+			loans: []model.IssuedBook{
+				{IssueDate: longAgo, ReturnDate: &returned, LateFees: 0.5},
+				{IssueDate: longAgo, ReturnDate: &returned, LateFees: 30, FeesPaidAt: &paid},
+			},
+			status:   http.StatusConflict,
+			blockers: []string{"balance"},
+		},
+		{
+			name: "overdue loan and an unpaid return",
+			loans: []model.IssuedBook{
+				{IssueDate: longAgo, LateFees: 60},
+				{IssueDate: longAgo, ReturnDate: &returned, LateFees: 30},
+			},
+			status:   http.StatusConflict,
+			blockers: []string{"loan", "balance"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			for _, loan := range tt.loans {
+				loan.ID, loan.UserID, loan.BookID = uuid.New(), f.userID, f.bookID
+				f.issued.loans = append(f.issued.loans, loan)
+			}
+
+			w := serve(newTestRouter(f.handler()), http.MethodDelete, "/api/v1/users/"+f.userID.String(), "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var resp struct {
+				Blockers []BlockerDTO `json:"blockers"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Blockers) != len(tt.blockers) {
+				t.Fatalf("blockers = %+v, want kinds %v", resp.Blockers, tt.blockers)
+			}
+			for i, kind := range tt.blockers {
+				if resp.Blockers[i].Kind != kind {
+					t.Errorf("blocker %d = %+v, want kind %s", i, resp.Blockers[i], kind)
+				}
+			}
+			if _, ok := f.users.users[f.userID]; ok != (tt.status != http.StatusOK) {
+				t.Errorf("user kept = %v after status %d", ok, w.Code)
+			}
+		})
+	}
+}
+
+func TestPurgeUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		err    error
+		status int
+	}{
+		{name: "purged", token: "secret", status: http.StatusOK},
+		{name: "loans or unpaid fees", token: "secret", err: model.ErrInUse, status: http.StatusConflict},
+		{name: "not deleted", token: "secret", err: sql.ErrNoRows, status: http.StatusNotFound},
+		{name: "wrong token", token: "guess", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			f.users.purgeErr = tt.err
+			h := f.handler()
+			h.AdminToken = "secret"
+
+			w := serve(newTestRouter(h), http.MethodDelete, "/api/v1/admin/trash/users/"+f.userID.String(), "", adminTokenHeader, tt.token)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/labels"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/arjunsaxaena/Library-Management/views"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RegisterUI mounts the librarian web UI under /ui and its static assets
+// under /static. Forms bind into the same request types as the JSON API and
+// run the same operations, so validation and errors match.
+func RegisterUI(router *gin.Engine, h *Handler) error {
+	tmpl, err := template.ParseFS(views.FS, "*.html")
+	if err != nil {
+		return err
+	}
+	router.SetHTMLTemplate(tmpl)
+
+	static, err := fs.Sub(views.FS, "static")
+	if err != nil {
+		return err
+	}
+	router.StaticFS("/static", http.FS(static))
+
+	router.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/ui")
+	})
+
+	ui := router.Group("/ui", csrfProtect(), requestID())
+	ui.GET("", h.uiIndex)
+
+	ui.GET("/books/new", h.uiPage("create_book.html", model.VocabularyBookType))
+	ui.POST("/books", h.audited((*Handler).uiCreateBook))
+	ui.GET("/books/import", h.uiPage("import_book.html", model.VocabularyBookType))
+	ui.POST("/books/import", h.audited((*Handler).uiImportBook))
+	ui.POST("/books/:id/delete", h.audited(uiDelete("/ui?view=books", "Book", (*Handler).deleteBook)))
+
+	ui.GET("/users/new", h.uiPage("create_user.html", model.VocabularyUserClass))
+	ui.POST("/users", h.audited((*Handler).uiCreateUser))
+	ui.POST("/users/:id/delete", h.audited(uiDelete("/ui?view=users", "User", (*Handler).deleteUser)))
+
+	ui.GET("/locations/new", h.uiPage("create_location.html"))
+	ui.POST("/locations", h.audited((*Handler).uiCreateLocation))
+	ui.POST("/locations/:id/delete", h.audited(uiDelete("/ui?view=locations", "Location", (*Handler).deleteLocation)))
+
+	ui.GET("/issue", h.uiPage("issue_book.html"))
+	ui.POST("/issue", h.audited((*Handler).uiIssueBook))
+	ui.GET("/return", h.uiPage("return_book.html"))
+	ui.POST("/return", h.audited((*Handler).uiReturnBook))
+
+	ui.GET("/labels", h.uiLabels)
+	ui.POST("/labels/books", h.uiPrintLabels("book-labels", labels.DefaultBookLayout, h.bookLabels))
+	ui.POST("/labels/patrons", h.uiPrintLabels("patron-cards", labels.DefaultPatronLayout, h.patronLabels))
+
+	ui.GET("/circulation", h.uiCirculation)
+	ui.POST("/circulation", h.audited((*Handler).uiStartCirculation))
+	ui.POST("/circulation/:id/scan", h.audited((*Handler).uiScanCirculation))
+	ui.POST("/circulation/:id/end", h.audited((*Handler).uiEndCirculation))
+
+	return nil
+}
+
+// isUIRoute reports whether a registered route belongs to the web UI rather
+// than the JSON API.
+func isUIRoute(path string) bool {
+	return path == "/" || path == "/ui" || strings.HasPrefix(path, "/ui/") || strings.HasPrefix(path, "/static/")
+}
+
+func (h *Handler) render(c *gin.Context, status int, name string, data gin.H) {
+	if data == nil {
+		data = gin.H{}
+	}
+	data["csrf_token"] = c.GetString(csrfCookie)
+	data["flash"] = popFlash(c)
+	c.HTML(status, name, data)
+}
+
+// uiPage renders a page. Forms get the active terms of the vocabularies
+// their fields take, keyed by vocabulary name.
+func (h *Handler) uiPage(name string, vocabularies ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := gin.H{}
+		for _, vocabulary := range vocabularies {
+			data[vocabulary] = h.termOptions(vocabulary, requestLocale(c))
+		}
+		h.render(c, http.StatusOK, name, data)
+	}
+}
+
+func (h *Handler) uiIndex(c *gin.Context) {
+	view := c.DefaultQuery("view", "books")
+	data := gin.H{"view": view}
+
+	switch view {
+	case "users":
+		users, err := h.UserStore.Users()
+		if err != nil {
+			setFlash(c, flashError, "Failed to retrieve users")
+		}
+		data["users"] = mapSlice(users, newUserDTO)
+	case "locations":
+		locations, err := h.LocationStore.Locations()
+		if err != nil {
+			setFlash(c, flashError, "Failed to retrieve locations")
+		}
+		data["locations"] = mapSlice(locations, newLocationDTO)
+	default:
+		books, err := h.BookStore.Books()
+		if err != nil {
+			setFlash(c, flashError, "Failed to retrieve books")
+		}
+		data["books"] = mapSlice(books, newBookDTO)
+	}
+
+	h.render(c, http.StatusOK, "index.html", data)
+}
+
+// uiSubmit binds a form into req, runs the operation and redirects with a
+// flash message: back to the form on failure, to next on success.
+func uiSubmit[T any](c *gin.Context, back, next string, op func(T) (string, *apiError)) {
+	var req T
+	if err := c.ShouldBind(&req); err != nil {
+		setFlash(c, flashError, "Invalid input: "+strings.Join(validationMessages(err), "; "))
+		c.Redirect(http.StatusSeeOther, back)
+		return
+	}
+
+	message, apiErr := op(req)
+	if apiErr != nil {
+		setFlash(c, flashError, apiErr.Error())
+		c.Redirect(http.StatusSeeOther, back)
+		return
+	}
+
+	setFlash(c, flashSuccess, message)
+	c.Redirect(http.StatusSeeOther, next)
+}
+
+func (h *Handler) uiCreateBook(c *gin.Context) {
+	uiSubmit(c, "/ui/books/new", "/ui?view=books", func(req CreateBookRequest) (string, *apiError) {
+		book, apiErr := h.createBook(req)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		return fmt.Sprintf("Book %q created successfully", book.Title), nil
+	})
+}
+
+func (h *Handler) uiCreateUser(c *gin.Context) {
+	uiSubmit(c, "/ui/users/new", "/ui?view=users", func(req CreateUserRequest) (string, *apiError) {
+		user, apiErr := h.createUser(req)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		return fmt.Sprintf("User %q created successfully", user.Name), nil
+	})
+}
+
+func (h *Handler) uiCreateLocation(c *gin.Context) {
+	uiSubmit(c, "/ui/locations/new", "/ui?view=locations", func(req CreateLocationRequest) (string, *apiError) {
+		location, apiErr := h.createLocation(req)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		return fmt.Sprintf("Location %q created successfully", location.Name), nil
+	})
+}
+
+func (h *Handler) uiIssueBook(c *gin.Context) {
+	uiSubmit(c, "/ui/issue", "/ui/issue", func(req IssueBookRequest) (string, *apiError) {
+		if _, apiErr := h.issueBook(req); apiErr != nil {
+			return "", apiErr
+		}
+		return "Book issued successfully", nil
+	})
+}
+
+func (h *Handler) uiReturnBook(c *gin.Context) {
+	uiSubmit(c, "/ui/return", "/ui/return", func(req ReturnBookRequest) (string, *apiError) {
+		_, lateFees, apiErr := h.returnBook(req)
+		if apiErr != nil {
+			return "", apiErr
+		}
+		return fmt.Sprintf("Book returned successfully. Late fees: %.2f", lateFees), nil
+	})
+}
+
+// uiDelete deletes whatever version of the record is current; the forms
+// carry no ETag.
+func uiDelete(next, entity string, op func(h *Handler, id string, version int) *apiError) func(h *Handler, c *gin.Context) {
+	return func(h *Handler, c *gin.Context) {
+		if apiErr := op(h, c.Param("id"), 0); apiErr != nil {
+			setFlash(c, flashError, apiErr.Error())
+		} else {
+			setFlash(c, flashSuccess, entity+" deleted successfully")
+		}
+		c.Redirect(http.StatusSeeOther, next)
+	}
+}
+
+func (h *Handler) uiLabels(c *gin.Context) {
+	layouts := mapSlice(labels.LayoutNames(), func(name string) LabelLayoutDTO {
+		return newLabelLayoutDTO(labels.Layouts[name])
+	})
+	h.render(c, http.StatusOK, "labels.html", gin.H{
+		"layouts":       layouts,
+		"book_layout":   labels.DefaultBookLayout,
+		"patron_layout": labels.DefaultPatronLayout,
+	})
+}
+
+func (h *Handler) uiCirculation(c *gin.Context) {
+	data := gin.H{}
+	if sessionID := c.Query("session"); sessionID != "" {
+		s, apiErr := h.deskSession(sessionID)
+		if apiErr != nil {
+			setFlash(c, flashError, apiErr.Error())
+			c.Redirect(http.StatusSeeOther, "/ui/circulation")
+			return
+		}
+		session, apiErr := h.sessionDTO(s)
+		if apiErr != nil {
+			setFlash(c, flashError, apiErr.Error())
+			c.Redirect(http.StatusSeeOther, "/ui/circulation")
+			return
+		}
+		data["session"] = session
+	}
+	h.render(c, http.StatusOK, "circulation.html", data)
+}
+
+func (h *Handler) uiStartCirculation(c *gin.Context) {
+	var req StartCirculationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		setFlash(c, flashError, "Invalid input: "+strings.Join(validationMessages(err), "; "))
+		c.Redirect(http.StatusSeeOther, "/ui/circulation")
+		return
+	}
+
+	user, apiErr := h.resolveUser(req.Patron)
+	if apiErr != nil {
+		setFlash(c, flashError, apiErr.Error())
+		c.Redirect(http.StatusSeeOther, "/ui/circulation")
+		return
+	}
+
+	s := h.desk.start(user.ID)
+	c.Redirect(http.StatusSeeOther, "/ui/circulation?session="+s.ID.String())
+}
+
+func (h *Handler) uiScanCirculation(c *gin.Context) {
+	s, apiErr := h.deskSession(c.Param("id"))
+	if apiErr != nil {
+		setFlash(c, flashError, apiErr.Error())
+		c.Redirect(http.StatusSeeOther, "/ui/circulation")
+		return
+	}
+
+	back := "/ui/circulation?session=" + s.ID.String()
+	var req CirculationScanRequest
+	if err := c.ShouldBind(&req); err != nil {
+		setFlash(c, flashError, "Invalid input: "+strings.Join(validationMessages(err), "; "))
+		c.Redirect(http.StatusSeeOther, back)
+		return
+	}
+
+	result := h.scan(s, req)
+	if result.OK {
+		setFlash(c, flashSuccess, fmt.Sprintf("%s: %s", req.Item, result.Message))
+	} else {
+		setFlash(c, flashError, fmt.Sprintf("%s: %s", req.Item, result.Message))
+	}
+	c.Redirect(http.StatusSeeOther, back)
+}
+
+func (h *Handler) uiEndCirculation(c *gin.Context) {
+	if id, err := uuid.Parse(c.Param("id")); err == nil {
+		h.desk.end(id)
+	}
+	setFlash(c, flashSuccess, "Session ended")
+	c.Redirect(http.StatusSeeOther, "/ui/circulation")
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postForm posts a form with the given cookies.
+func postForm(r http.Handler, path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestCSRFProtect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(csrfProtect())
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	r.GET("/ui", ok)
+	r.POST("/ui", ok)
+
+	token := &http.Cookie{Name: csrfCookie, Value: "secret"}
+	tests := []struct {
+		name      string
+		method    string
+		cookie    *http.Cookie
+		field     string
+		status    int
+		newCookie bool
+	}{
+		{"GET issues a token", http.MethodGet, nil, "", http.StatusNoContent, true},
+		{"GET keeps the token", http.MethodGet, token, "", http.StatusNoContent, false},
+		{"POST without cookie", http.MethodPost, nil, "secret", http.StatusForbidden, true},
+		{"POST without field", http.MethodPost, token, "", http.StatusForbidden, false},
+		{"POST with wrong token", http.MethodPost, token, "guess", http.StatusForbidden, false},
+		{"POST with matching token", http.MethodPost, token, "secret", http.StatusNoContent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w *httptest.ResponseRecorder
+			var cookies []*http.Cookie
+			if tt.cookie != nil {
+				cookies = append(cookies, tt.cookie)
+			}
+			if tt.method == http.MethodGet {
+				req := httptest.NewRequest(http.MethodGet, "/ui", nil)
+				for _, cookie := range cookies {
+					req.AddCookie(cookie)
+				}
+				w = httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+			} else {
+				form := url.Values{}
+				if tt.field != "" {
+					form.Set(csrfField, tt.field)
+				}
+				w = postForm(r, "/ui", form, cookies...)
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			issued := responseCookie(w, csrfCookie)
+			if (issued != nil) != tt.newCookie {
+				t.Fatalf("new token cookie = %v, want %v", issued != nil, tt.newCookie)
+			}
+			if issued != nil && (len(issued.Value) < 40 || !issued.HttpOnly || issued.SameSite != http.SameSiteStrictMode) {
+				t.Errorf("token cookie = %+v, want a long HttpOnly SameSite=Strict token", issued)
+			}
+		})
+	}
+}
+
+func TestFlash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ui/set", func(c *gin.Context) { setFlash(c, flashError, `Title "x" is taken`) })
+	var popped *flash
+	r.GET("/ui/pop", func(c *gin.Context) { popped = popFlash(c) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ui/set", nil))
+	set := responseCookie(w, flashCookie)
+	if set == nil {
+		t.Fatal("no flash cookie was set")
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   *flash
+	}{
+		{"round trip", set, &flash{Kind: flashError, Message: `Title "x" is taken`}},
+		{"no cookie", nil, nil},
+		{"not base64", &http.Cookie{Name: flashCookie, Value: "!!!"}, nil},
+		{"not JSON", &http.Cookie{Name: flashCookie, Value: "bm9wZQ"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			popped = nil
+			req := httptest.NewRequest(http.MethodGet, "/ui/pop", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			switch {
+			case tt.want == nil && popped != nil:
+				t.Errorf("popFlash = %+v, want nil", popped)
+			case tt.want != nil && (popped == nil || *popped != *tt.want):
+				t.Errorf("popFlash = %+v, want %+v", popped, tt.want)
+			}
+			if tt.cookie != nil {
+				if cleared := responseCookie(w, flashCookie); cleared == nil || cleared.MaxAge >= 0 {
+					t.Errorf("flash cookie was not cleared")
+				}
+			}
+		})
+	}
+}
+
+// TestUIFormFlow submits a UI form through the JSON API's validation and
+// follows the redirect to the flash message it leaves.
+func TestUIFormFlow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := RegisterUI(r, newFixture().handler()); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Dune") {
+		t.Fatalf("GET /ui = %d, want the book list", w.Code)
+	}
+	token := responseCookie(w, csrfCookie)
+	if token == nil || !strings.Contains(w.Body.String(), token.Value) {
+		t.Fatal("the page does not carry the CSRF token")
+	}
+
+	w = postForm(r, "/ui/users", url.Values{"name": {""}})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("POST without a token = %d, want 403", w.Code)
+	}
+
+	w = postForm(r, "/ui/users", url.Values{csrfField: {token.Value}, "name": {""}}, token)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/ui/users/new" {
+		t.Fatalf("invalid POST = %d to %q, want 303 back to the form", w.Code, w.Header().Get("Location"))
+	}
+	message := responseCookie(w, flashCookie)
+	if message == nil {
+		t.Fatal("no flash message was set")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/users/new", nil)
+	req.AddCookie(token)
+	req.AddCookie(message)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Invalid input") {
+		t.Errorf("the form does not show the flash message:\n%s", w.Body)
+	}
+}
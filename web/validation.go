@@ -0,0 +1,222 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Request types declare their rules in binding tags. A request that breaks
+// any rule is answered with 400 and every failing field at once.
+//
+// Book types, material types, classes and languages must also be active
+// terms of their vocabularies. The stores check that, and TermErrors are
+// answered the same way; a body that breaks its rules has its codes
+// checked along with them.
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(fieldName)
+}
+
+// fieldName names fields the way clients send them: by their JSON name, or
+// their form name for fields only forms carry.
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// termRequest is a request with fields that take vocabulary codes.
+type termRequest interface {
+	termParams() []termParam
+}
+
+// bindJSON binds the request's JSON body into req and checks its rules.
+// If fields break them, the codes a termRequest gives are checked too, so
+// that every failing field is answered at once.
+func (h *Handler) bindJSON(c *gin.Context, req any) *apiError {
+	return h.bindReplacing(c, req, nil)
+}
+
+// bindReplacing binds a request that replaces a record, like bindJSON.
+// stored reads the record as a request of the same kind: codes it already
+// has are not reported, as the record may keep terms deactivated since.
+func (h *Handler) bindReplacing(c *gin.Context, req any, stored func() (termRequest, error)) *apiError {
+	if err := c.ShouldBindJSON(req); err != nil {
+		return h.withTermErrors(invalidRequest(err), req, stored)
+	}
+	return nil
+}
+
+// withTermErrors adds to apiErr, answering req for breaking its binding
+// rules, the fields whose codes are not active terms, apart from those
+// stored, if not nil, already has.
+func (h *Handler) withTermErrors(apiErr *apiError, req any, stored func() (termRequest, error)) *apiError {
+	fields, ok := apiErr.Body["fields"].([]FieldErrorDTO)
+	terms, hasTerms := req.(termRequest)
+	if !ok || !hasTerms {
+		return apiErr
+	}
+
+	termErrs, lookupErr := h.termErrors(terms.termParams()...)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if len(termErrs) > 0 && stored != nil {
+		current, err := stored()
+		if err != nil {
+			// The write fails on the record anyway, once the body binds.
+			return apiErr
+		}
+		kept := map[string]string{}
+		for _, p := range current.termParams() {
+			kept[p.field] = p.code
+		}
+		termErrs = slices.DeleteFunc(termErrs, func(e *model.TermError) bool {
+			return e.Code == kept[e.Field]
+		})
+	}
+	apiErr.Body["fields"] = append(fields, termFields(termErrs)...)
+	return apiErr
+}
+
+// invalidRequest describes a body that could not be bound: the fields that
+// break their rules, or why it could not be read at all.
+func invalidRequest(err error) *apiError {
+	apiErr := newAPIError(http.StatusBadRequest, "Invalid request body")
+	if fields := fieldErrors(err); fields != nil {
+		apiErr.Body["fields"] = fields
+	} else {
+		apiErr.Body["details"] = err.Error()
+	}
+	return apiErr
+}
+
+// fieldErrors lists the fields that broke their rules, or returns nil if
+// err is not a validation error.
+func fieldErrors(err error) []FieldErrorDTO {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	fields := make([]FieldErrorDTO, 0, len(errs))
+	for _, fe := range errs {
+		field := fieldPath(fe)
+		fields = append(fields, FieldErrorDTO{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Message: field + " " + ruleMessage(fe),
+		})
+	}
+	return fields
+}
+
+// termError answers a write that gave fields codes outside their
+// vocabularies, or returns nil for any other error.
+func termError(err error) *apiError {
+	var termErrs model.TermErrors
+	if !errors.As(err, &termErrs) {
+		return nil
+	}
+	apiErr := newAPIError(http.StatusBadRequest, termErrs.Error())
+	apiErr.Body["fields"] = termFields(termErrs)
+	return apiErr
+}
+
+// termFields describes each term error as a failed field.
+func termFields(termErrs model.TermErrors) []FieldErrorDTO {
+	fields := make([]FieldErrorDTO, len(termErrs))
+	for i, termErr := range termErrs {
+		fields[i] = FieldErrorDTO{
+			Field:   termErr.Field,
+			Rule:    "vocabulary",
+			Message: fmt.Sprintf("%s must be an active term of the %s vocabulary", termErr.Field, termErr.Vocabulary),
+		}
+	}
+	return fields
+}
+
+// validationMessages gives one message per failed field, for forms and
+// import reports, or err's own message if it is not a validation error.
+func validationMessages(err error) []string {
+	fields := fieldErrors(err)
+	if fields == nil {
+		return []string{err.Error()}
+	}
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Message
+	}
+	return messages
+}
+
+// fieldPath is the field's path from the top of the request, like
+// contributors[1].name.
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required when " + snakeCase(fe.Param()) + " is not given"
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must have %s %s entries", bound, fe.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, fe.Param())
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "url":
+		return "must be a URL"
+	case "http_url":
+		return "must be an http or https URL"
+	}
+	return "breaks the " + fe.Tag() + " rule"
+}
+
+// snakeCase turns a Go field name into its JSON name, so AuthorName gives
+// author_name.
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
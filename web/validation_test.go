@@ -0,0 +1,237 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestFieldErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  any
+		want []FieldErrorDTO
+	}{
+		{
+			name: "every failing field at once",
+			req:  &CreateBookRequest{BookType: "fiction"},
+			want: []FieldErrorDTO{
+				{Field: "title", Rule: "required", Message: "title is required"},
+				{Field: "author_name", Rule: "required_without", Message: "author_name is required when contributors is not given"},
+				{Field: "location_name", Rule: "required", Message: "location_name is required"},
+			},
+		},
+		{
+			name: "lengths and ranges",
+			req: &CreateBookRequest{
+				Title: strings.Repeat("t", 501), AuthorName: "Ann", LocationName: "Shelf 3", BookType: "fiction",
+				PublicationYear: 99, CoverURL: "ftp://covers.example/1.jpg",
+			},
+			want: []FieldErrorDTO{
+				{Field: "title", Rule: "max", Message: "title must be at most 500 characters long"},
+				{Field: "publication_year", Rule: "min", Message: "publication_year must be at least 1000"},
+				{Field: "cover_url", Rule: "http_url", Message: "cover_url must be an http or https URL"},
+			},
+		},
+		{
+			name: "nested fields by path",
+			req:  &SetContributorsRequest{Contributors: []ContributorRequest{{Name: "Ann"}, {Role: "narrator"}}},
+			want: []FieldErrorDTO{
+				{Field: "contributors[1].name", Rule: "required", Message: "contributors[1].name is required"},
+				{Field: "contributors[1].role", Rule: "oneof", Message: "contributors[1].role must be one of: author, editor, translator, illustrator"},
+			},
+		},
+		{
+			name: "entry counts",
+			req:  &SetContributorsRequest{Contributors: []ContributorRequest{}},
+			want: []FieldErrorDTO{{Field: "contributors", Rule: "min", Message: "contributors must have at least 1 entries"}},
+		},
+		{
+			name: "valid",
+			req:  &UpdateUserRequest{Name: "Ann", Class: "10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := binding.Validator.ValidateStruct(tt.req)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateStruct = %v", err)
+				}
+				return
+			}
+			if got := fieldErrors(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fieldErrors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := fieldErrors(errors.New("unexpected EOF")); got != nil {
+		t.Errorf("fieldErrors of a decoding error = %+v, want nil", got)
+	}
+}
+
+func TestTermError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		message string
+		fields  []FieldErrorDTO
+	}{
+		{
+			name:    "unknown code",
+			err:     model.TermErrors{{Field: "book_type", Vocabulary: "book type", Code: "poetry"}},
+			message: `"poetry" is not a book type`,
+			fields:  []FieldErrorDTO{{Field: "book_type", Rule: "vocabulary", Message: "book_type must be an active term of the book type vocabulary"}},
+		},
+		{
+			name: "every field",
+			err: fmt.Errorf("create material: %w", model.TermErrors{
+				{Field: "type", Vocabulary: "material type", Code: "podcast"},
+				{Field: "language", Vocabulary: "language", Code: "la", Inactive: true},
+			}),
+			message: `"podcast" is not a material type; language "la" is no longer in use`,
+			fields: []FieldErrorDTO{
+				{Field: "type", Rule: "vocabulary", Message: "type must be an active term of the material type vocabulary"},
+				{Field: "language", Rule: "vocabulary", Message: "language must be an active term of the language vocabulary"},
+			},
+		},
+		{name: "other error", err: model.ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := termError(tt.err)
+			if tt.message == "" {
+				if apiErr != nil {
+					t.Errorf("termError = %+v, want nil", apiErr)
+				}
+				return
+			}
+			if apiErr == nil || apiErr.Status != http.StatusBadRequest || apiErr.Body["error"] != tt.message {
+				t.Fatalf("termError = %+v", apiErr)
+			}
+			if fields := apiErr.Body["fields"]; !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %+v, want %+v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"Contributors": "contributors",
+		"AuthorName":   "author_name",
+		"title":        "title",
+	} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestInvalidRequestBody(t *testing.T) {
+	f := newFixture()
+	r := newTestRouter(f.handler())
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{name: "missing fields", body: `{}`, fields: []string{"name", "class"}},
+		{name: "too long", body: `{"name": "` + strings.Repeat("a", 201) + `", "class": "10"}`, fields: []string{"name"}},
+		{name: "not JSON", body: `{"name":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodPut, "/api/v1/users/"+f.userID.String(), tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Fields  []FieldErrorDTO `json:"fields"`
+				Details string          `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, fe := range resp.Fields {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", fields, tt.fields)
+			}
+			if (tt.fields == nil) != (resp.Details != "") {
+				t.Errorf("details = %q", resp.Details)
+			}
+		})
+	}
+}
+
+// TestInvalidRequestTerms checks that a body breaking its binding rules is
+// answered with its vocabulary errors too, in one response.
+func TestInvalidRequestTerms(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string // {id} is the fixture's subject
+		body    string
+		stored  string // the subject's language, if not en
+		noTerms bool
+		fields  []string
+	}{
+		{name: "create", method: http.MethodPost, path: "/api/v1/subjects", body: `{"language": "xx"}`, fields: []string{"name", "language"}},
+		{name: "create with a deactivated term", method: http.MethodPost, path: "/api/v1/subjects", body: `{"language": "la"}`, fields: []string{"name", "language"}},
+		{name: "create with an active term", method: http.MethodPost, path: "/api/v1/subjects", body: `{"language": "EN"}`, fields: []string{"name"}},
+		{name: "left to the stores without vocabularies", method: http.MethodPost, path: "/api/v1/subjects", body: `{"language": "xx"}`, noTerms: true, fields: []string{"name"}},
+		{name: "replace", method: http.MethodPut, path: "/api/v1/subjects/{id}", body: `{"language": "la"}`, fields: []string{"name", "language"}},
+		{name: "replace keeping a deactivated term", method: http.MethodPut, path: "/api/v1/subjects/{id}", body: `{"language": "la"}`, stored: "la", fields: []string{"name"}},
+		{name: "patch", method: http.MethodPatch, path: "/api/v1/subjects/{id}", body: `{"name": "", "language": "xx"}`, fields: []string{"name", "language"}},
+		{name: "patch keeping a deactivated term", method: http.MethodPatch, path: "/api/v1/subjects/{id}", body: `{"name": ""}`, stored: "la", fields: []string{"name"}},
+		{name: "create book", method: http.MethodPost, path: "/api/v1/books", body: `{"title": "Dune", "author_name": "Frank Herbert", "book_type": "poetry"}`, fields: []string{"location_name", "book_type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if tt.stored != "" {
+				subject := f.subjects.subjects[f.subjectID]
+				subject.Language = tt.stored
+				f.subjects.subjects[f.subjectID] = subject
+			}
+			h := f.handler()
+			if tt.noTerms {
+				h.VocabularyStore = nil
+			}
+			var headers []string
+			if tt.method == http.MethodPatch {
+				headers = []string{"Content-Type", mergePatchContentType}
+			}
+
+			path := strings.Replace(tt.path, "{id}", f.subjectID.String(), 1)
+			w := serve(newTestRouter(h), tt.method, path, tt.body, headers...)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Fields []FieldErrorDTO `json:"fields"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, fe := range resp.Fields {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", fields, tt.fields)
+			}
+		})
+	}
+}
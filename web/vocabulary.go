@@ -0,0 +1,315 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/arjunsaxaena/Library-Management/iso639"
+	"github.com/arjunsaxaena/Library-Management/model"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Terms are labelled in the locale the client asks for with ?locale= or
+// Accept-Language, falling back to its base language, then English and
+// then the code itself.
+const defaultLocale = "en"
+
+// HELPER FUNCTIONS
+
+// requestLocale is the locale to label terms in.
+func requestLocale(c *gin.Context) string {
+	if locale := c.Query("locale"); locale != "" {
+		return strings.ToLower(locale)
+	}
+	first, _, _ := strings.Cut(c.GetHeader("Accept-Language"), ",")
+	tag, _, _ := strings.Cut(first, ";")
+	if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && tag != "*" {
+		return tag
+	}
+	return defaultLocale
+}
+
+func termLabel(t model.Term, locale string) string {
+	base, _, _ := strings.Cut(locale, "-")
+	for _, l := range []string{locale, base, defaultLocale} {
+		if label, ok := t.Labels[l]; ok {
+			return label
+		}
+	}
+	if t.Vocabulary == model.VocabularyLanguage {
+		if name := iso639.Name(t.Code); name != "" {
+			return name
+		}
+	}
+	return t.Code
+}
+
+// vocabularyParam checks the :vocabulary path parameter.
+func vocabularyParam(c *gin.Context) (string, *apiError) {
+	vocabulary := c.Param("vocabulary")
+	if !slices.Contains(model.Vocabularies, vocabulary) {
+		return "", newAPIError(http.StatusNotFound, "vocabulary must be one of "+strings.Join(model.Vocabularies, ", "))
+	}
+	return vocabulary, nil
+}
+
+// vocabularyStore returns the store, or 503 if there is none.
+func (h *Handler) vocabularyStore() (model.VocabularyStore, *apiError) {
+	if h.VocabularyStore == nil {
+		return nil, newAPIError(http.StatusServiceUnavailable, "Vocabularies are not configured")
+	}
+	return h.VocabularyStore, nil
+}
+
+// termOptions lists the active terms of a vocabulary for a form to offer,
+// or nil if they cannot be read, in which case the form takes free text.
+func (h *Handler) termOptions(vocabulary, locale string) []TermDTO {
+	if h.VocabularyStore == nil {
+		return nil
+	}
+	terms, err := h.VocabularyStore.Terms(vocabulary)
+	if err != nil {
+		return nil
+	}
+	var options []TermDTO
+	for _, t := range terms {
+		if t.Active {
+			options = append(options, newTermDTO(t, locale))
+		}
+	}
+	return options
+}
+
+// checkTermRequest checks what the binding rules cannot: language codes
+// must be ISO 639-1 codes.
+func checkTermRequest(vocabulary, code string) *apiError {
+	if vocabulary == model.VocabularyLanguage && !iso639.Valid(strings.ToLower(strings.TrimSpace(code))) {
+		apiErr := newAPIError(http.StatusBadRequest, "Invalid request body")
+		apiErr.Body["fields"] = []FieldErrorDTO{{Field: "code", Rule: "iso639", Message: "code must be an ISO 639-1 language code, like en"}}
+		return apiErr
+	}
+	return nil
+}
+
+// termParam is a code given outside a record body, like an import
+// default, or in one that failed its binding rules, and the vocabulary it
+// must be an active term of.
+type termParam struct {
+	field      string
+	vocabulary string
+	code       string
+}
+
+// checkTerms checks the codes of params that are given and answers the
+// ones that are not active terms the way a write the stores reject is
+// answered. Without a vocabulary store the codes are left to the stores.
+func (h *Handler) checkTerms(params ...termParam) *apiError {
+	termErrs, apiErr := h.termErrors(params...)
+	if apiErr != nil {
+		return apiErr
+	}
+	if len(termErrs) > 0 {
+		return termError(termErrs)
+	}
+	return nil
+}
+
+// termErrors lists the codes of params that are given but are not active
+// terms, or none without a vocabulary store.
+func (h *Handler) termErrors(params ...termParam) (model.TermErrors, *apiError) {
+	if h.VocabularyStore == nil {
+		return nil, nil
+	}
+	var termErrs model.TermErrors
+	for _, p := range params {
+		if p.code == "" {
+			continue
+		}
+		term, err := h.VocabularyStore.Term(p.vocabulary, p.code)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			termErrs = append(termErrs, &model.TermError{Field: p.field, Vocabulary: p.vocabulary, Code: p.code})
+		case err != nil:
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to retrieve term")
+		case !term.Active:
+			termErrs = append(termErrs, &model.TermError{Field: p.field, Vocabulary: p.vocabulary, Code: term.Code, Inactive: true})
+		}
+	}
+	return termErrs, nil
+}
+
+func termLookupError(err error) *apiError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return newAPIError(http.StatusNotFound, "Term not found")
+	}
+	return newAPIError(http.StatusInternalServerError, "Failed to retrieve term")
+}
+
+// GET HANDLERS
+
+func (h *Handler) GetVocabularies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"vocabularies": model.Vocabularies})
+}
+
+// GetVocabularyTerms lists a vocabulary's terms; ?active=true leaves out
+// the inactive ones.
+func (h *Handler) GetVocabularyTerms(c *gin.Context) {
+	vocabulary, apiErr := vocabularyParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	store, apiErr := h.vocabularyStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	terms, err := store.Terms(vocabulary)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve terms"})
+		return
+	}
+	activeOnly := c.Query("active") == "true"
+	locale := requestLocale(c)
+	dtos := []TermDTO{}
+	for _, t := range terms {
+		if t.Active || !activeOnly {
+			dtos = append(dtos, newTermDTO(t, locale))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"terms": dtos})
+}
+
+func (h *Handler) GetVocabularyTerm(c *gin.Context) {
+	vocabulary, apiErr := vocabularyParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	store, apiErr := h.vocabularyStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	term, err := store.Term(vocabulary, c.Param("code"))
+	if err != nil {
+		apiErr := termLookupError(err)
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"term": newTermDTO(term, requestLocale(c))})
+}
+
+// ADMIN HANDLERS
+
+func (h *Handler) CreateVocabularyTerm(c *gin.Context) {
+	vocabulary, apiErr := vocabularyParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	store, apiErr := h.vocabularyStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req CreateTermRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	if apiErr := checkTermRequest(vocabulary, req.Code); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	term := model.Term{
+		ID:         uuid.New(),
+		Vocabulary: vocabulary,
+		Code:       req.Code,
+		Active:     req.Active == nil || *req.Active,
+		Labels:     req.Labels,
+	}
+	if err := store.CreateTerm(&term); err != nil {
+		if errors.Is(err, model.ErrDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The vocabulary already has this code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create term"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Term created successfully", "term": newTermDTO(term, requestLocale(c))})
+}
+
+// UpdateVocabularyTerm activates or deactivates a term and replaces its
+// labels. Codes cannot be changed; add a new term and deactivate the old
+// one instead.
+func (h *Handler) UpdateVocabularyTerm(c *gin.Context) {
+	vocabulary, apiErr := vocabularyParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	store, apiErr := h.vocabularyStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	var req UpdateTermRequest
+	if apiErr := h.bindJSON(c, &req); apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	term := model.Term{Vocabulary: vocabulary, Code: c.Param("code"), Active: req.Active, Labels: req.Labels}
+	if err := store.UpdateTerm(&term); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Term not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update term"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Term updated successfully", "term": newTermDTO(term, requestLocale(c))})
+}
+
+// DeleteVocabularyTerm removes a term no record uses. Terms in use can
+// only be deactivated.
+func (h *Handler) DeleteVocabularyTerm(c *gin.Context) {
+	vocabulary, apiErr := vocabularyParam(c)
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+	store, apiErr := h.vocabularyStore()
+	if apiErr != nil {
+		c.JSON(apiErr.Status, apiErr.Body)
+		return
+	}
+
+	if err := store.DeleteTerm(vocabulary, c.Param("code")); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Term not found"})
+		case errors.Is(err, model.ErrInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Records use this term; deactivate it instead"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete term"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Term deleted successfully"})
+}